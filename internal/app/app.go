@@ -16,6 +16,7 @@ import (
 
 	ftpserver "github.com/fclairamb/ftpserverlib"
 
+	"go-file-server/pkgs/auditlog"
 	"go-file-server/pkgs/config"
 	"go-file-server/pkgs/zlog"
 
@@ -25,6 +26,8 @@ import (
 func Start() error {
 	//初始化组件
 	svcCtx := Init.Initializer()
+	// 退出前输出剩余的审计事件
+	defer auditlog.Close()
 	var group = &run.Group{}
 
 	if config.FptCfg.Enable {
@@ -84,7 +87,7 @@ func addGinServer(group *run.Group, svcCtx *types.SvcCtx) {
 
 	//set gin
 	setPublicMiddlewares(svcCtx)
-	registerRouter(svcCtx)
+	stopRouter := registerRouter(svcCtx)
 
 	host := fmt.Sprintf("%s:%s", config.ApplicationCfg.Host, config.ApplicationCfg.Port)
 	srv := &http.Server{
@@ -105,6 +108,9 @@ func addGinServer(group *run.Group, svcCtx *types.SvcCtx) {
 			if err := srv.Shutdown(ctx); err != nil {
 				zlog.SugLog.Errorf("stop gin server err:%v", err)
 			}
+			if err := stopRouter(ctx); err != nil {
+				zlog.SugLog.Errorf("stop router err:%v", err)
+			}
 		},
 	)
 }
@@ -113,7 +119,10 @@ func addGinServer(group *run.Group, svcCtx *types.SvcCtx) {
 func setPublicMiddlewares(svcCtx *types.SvcCtx) {
 	r := svcCtx.Router
 	r.Use(
-		middlewares.NewGlog(repository.NewOperaLogRepository(svcCtx.Db)).GinLogger(),
+		middlewares.NewGlog(
+			repository.NewOperaLogRepository(svcCtx.Db),
+			repository.NewApiRepository(svcCtx.Db),
+		).GinLogger(),
 	)
 	r.Use(middlewares.ErrorHandlingMiddleware())
 
@@ -122,7 +131,8 @@ func setPublicMiddlewares(svcCtx *types.SvcCtx) {
 	r.Use(middlewares.CORSMiddleware())
 }
 
-func registerRouter(svcCtx *types.SvcCtx) {
-	admin.RegisterRouter(svcCtx)
+func registerRouter(svcCtx *types.SvcCtx) func(ctx context.Context) error {
+	stop := admin.RegisterRouter(svcCtx)
 	normal.RegisterRouter(svcCtx)
+	return stop
 }
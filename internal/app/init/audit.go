@@ -0,0 +1,87 @@
+package init
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"go-file-server/pkgs/auditlog"
+	"go-file-server/pkgs/config"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// defaultAuditFileSize 审计文件默认的轮转大小，单位MB
+const defaultAuditFileSize = 100
+
+// initAuditSinks 按配置创建审计事件的输出
+func initAuditSinks() error {
+	var sinks []auditlog.Sink
+	for _, cfg := range config.ApplicationCfg.Audit.Sinks {
+		sink, err := newAuditSink(cfg)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, sink)
+	}
+	auditlog.Init(sinks...)
+	return nil
+}
+
+func newAuditSink(cfg config.AuditSink) (auditlog.Sink, error) {
+	switch cfg.Type {
+	case "stdout":
+		return auditlog.NewStdoutSink(), nil
+	case "file":
+		if cfg.MaxSize == 0 {
+			cfg.MaxSize = defaultAuditFileSize
+		}
+		return auditlog.NewFileSink(auditlog.FileOptions{
+			Path:       cfg.Path,
+			MaxSize:    cfg.MaxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAge,
+			Compress:   cfg.Compress,
+		})
+	case "syslog":
+		facility, err := auditlog.ParseFacility(cfg.Facility)
+		if err != nil {
+			return nil, err
+		}
+		opts := auditlog.SyslogOptions{
+			Network:  cfg.Network,
+			Addr:     cfg.Addr,
+			Facility: facility,
+			AppName:  cfg.AppName,
+		}
+		if cfg.Network == "tls" {
+			if opts.TLSConfig, err = newAuditTLSConfig(cfg); err != nil {
+				return nil, err
+			}
+		}
+		return auditlog.NewSyslogSink(opts)
+	default:
+		return nil, errors.Errorf("不支持的审计输出类型: %s", cfg.Type)
+	}
+}
+
+func newAuditTLSConfig(cfg config.AuditSink) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CaFile != "" {
+		pem, err := os.ReadFile(cfg.CaFile)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("无法解析 ca 证书: %s", cfg.CaFile)
+		}
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -33,7 +33,25 @@ func initDB() (*gorm.DB, error) {
 		return nil, err
 	}
 
-	return db, initializeDBData(db)
+	if err := initializeDBData(db); err != nil {
+		return nil, err
+	}
+	return db, migrateExtTable(db)
+}
+
+// migrateExtTable 后续版本新增的表，每次启动都执行迁移，兼容已经初始化过的数据库
+func migrateExtTable(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&models.SysRole{},
+		&models.SysRoleFsAlias{},
+		&models.SysUserQuota{},
+		&models.SysStorageQuota{},
+		&models.SysJob{},
+		&models.SysWebhook{},
+		&models.SysWebhookDelivery{},
+		&models.SysFileAudit{},
+		&models.SysUserTotp{},
+	)
 }
 
 func initializeDBData(db *gorm.DB) error {
@@ -1,6 +1,7 @@
 package init
 
 import (
+	"go-file-server/pkgs/config"
 	"go-file-server/pkgs/zlog"
 	"reflect"
 	"strings"
@@ -11,6 +12,9 @@ import (
 	"go.uber.org/zap"
 )
 
+// defaultTrustedProxies 未配置 trustedProxies 时只信任本机的反向代理
+var defaultTrustedProxies = []string{"127.0.0.1", "::1"}
+
 func InitGin() *gin.Engine {
 	//设置运行模式
 	if !zlog.Log.Core().Enabled(zap.DebugLevel) {
@@ -20,6 +24,14 @@ func InitGin() *gin.Engine {
 	// 初始化引擎
 	r := gin.New()
 	//r.RedirectTrailingSlash = false
+	// gin 默认信任所有代理，客户端可以伪造 X-Forwarded-For 绕过按ip的登录限制
+	proxies := config.ApplicationCfg.TrustedProxies
+	if len(proxies) == 0 {
+		proxies = defaultTrustedProxies
+	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		zlog.SugLog.Fatal(err)
+	}
 	initGinValidator()
 	return r
 }
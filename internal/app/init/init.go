@@ -6,10 +6,12 @@ import (
 	"go-file-server/internal/cronjob"
 	"go-file-server/internal/ftpserver"
 	"go-file-server/internal/services/admin/apis/fs"
+	"go-file-server/internal/services/admin/apis/fs/utils"
 	"go-file-server/pkgs/cache"
 	"go-file-server/pkgs/casbin"
 	"go-file-server/pkgs/config"
 	"go-file-server/pkgs/pathtool"
+	"go-file-server/pkgs/session"
 	"go-file-server/pkgs/utils/captcha"
 	"go-file-server/pkgs/utils/retry"
 	"go-file-server/pkgs/utils/str"
@@ -32,6 +34,14 @@ func Initializer() *types.SvcCtx {
 	// 工作目录
 	initBaseDir(config.ApplicationCfg.Basedir)
 
+	// 挂载点
+	initMounts()
+
+	// 审计事件输出
+	if err := initAuditSinks(); err != nil {
+		zlog.SugLog.Fatal(err)
+	}
+
 	// 数据库
 	db, err := initDB()
 	if err != nil {
@@ -66,6 +76,8 @@ func Initializer() *types.SvcCtx {
 		Cache:          cache,
 		FsIndexer:      fsIndexer,
 		CasbinEnforcer: casbinEnforcer,
+		Sessions:       session.NewManager(),
+		Actors:         utils.NewActorTracker(),
 	}
 }
 
@@ -148,12 +160,17 @@ func initFileIndexer(cache cache.AdapterCache) (*pathtool.FileIndexer, error) {
 		data.FileCount = data.Count - data.DirCount
 	}
 
-	return pathtool.NewFileIndexer(
-		config.ApplicationCfg.Basedir,
+	opts := []pathtool.Opt{
 		pathtool.WithLog(zlog.SugLog),
 		pathtool.WithStorageType(pathtool.UseDisk),
 		pathtool.WithUpdateCallback(updateCallback),
-	)
+	}
+	for _, m := range config.ApplicationCfg.Mounts {
+		if m.Index {
+			opts = append(opts, pathtool.WithExtraRoot(m.Path, m.Watch))
+		}
+	}
+	return pathtool.NewFileIndexer(config.ApplicationCfg.Basedir, opts...)
 }
 
 func initBaseDir(realPath string) {
@@ -170,6 +187,22 @@ func initBaseDir(realPath string) {
 	}
 }
 
+func initMounts() {
+	if err := utils.ValidateMounts(config.ApplicationCfg.Basedir, config.ApplicationCfg.Mounts); err != nil {
+		zlog.SugLog.Fatal(err)
+	}
+	for _, m := range config.ApplicationCfg.Mounts {
+		initBaseDir(m.Path)
+		if m.Trash != "" && !m.ReadOnly {
+			if err := os.MkdirAll(m.Trash, os.ModePerm); err != nil {
+				zlog.SugLog.Fatal(err)
+			}
+		}
+		zlog.SugLog.Infof("挂载点 %s -> %s, readOnly: %v, index: %v, watch: %v",
+			m.Name, m.Path, m.ReadOnly, m.Index, m.Watch)
+	}
+}
+
 func InitFtpServer(svcCtx *types.SvcCtx) (*ftpserver.Server, error) {
 	ftpCfg := config.FptCfg
 
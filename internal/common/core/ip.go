@@ -1,28 +1,11 @@
 package core
 
 import (
-	"strings"
-
 	"github.com/gin-gonic/gin"
 )
 
+// GetClientIP 请求来自 application.trustedProxies 中的代理时使用 X-Forwarded-For 或 X-Real-Ip 中的客户端ip，
+// 否则使用连接的ip，客户端自己发送的请求头不会被使用
 func GetClientIP(c *gin.Context) string {
-	ClientIP := c.ClientIP()
-	//fmt.Println("ClientIP:", ClientIP)
-	RemoteIP := c.RemoteIP()
-	//fmt.Println("RemoteIP:", RemoteIP)
-	ip := c.Request.Header.Get("X-Forwarded-For")
-	if strings.Contains(ip, "127.0.0.1") || ip == "" {
-		ip = c.Request.Header.Get("X-real-ip")
-	}
-	if ip == "" {
-		ip = "127.0.0.1"
-	}
-	if RemoteIP != "127.0.0.1" {
-		ip = RemoteIP
-	}
-	if ClientIP != "127.0.0.1" {
-		ip = ClientIP
-	}
-	return ip
+	return c.ClientIP()
 }
@@ -15,6 +15,7 @@ const (
 	StatusNotFound      HttpCode = http.StatusNotFound            //请求的资源未找到
 	ForbiddenError      HttpCode = http.StatusForbidden           //请求被禁止
 	ConflictError       HttpCode = http.StatusConflict            // 数据或状态冲突
+	TooManyRequests     HttpCode = http.StatusTooManyRequests     // 超出并发或频率限制
 )
 
 type BizCode int // 业务code
@@ -31,6 +32,8 @@ const (
 	BizOperationFailed                         // 1007, 操作未能成功执行
 	BizRateLimitExceeded                       // 1008, 超出了频率限制
 	BizNotFound                                // 1009, 资源未找到
+	BizStorageExceeded                         // 1010, 存储空间超出配额
+	BizConflict                                // 1011, 数据已被修改
 )
 
 var MessageMap map[BizCode]string = map[BizCode]string{
@@ -44,4 +47,6 @@ var MessageMap map[BizCode]string = map[BizCode]string{
 	BizOperationFailed:   "操作未能成功执行",
 	BizRateLimitExceeded: "超出了频率限制",
 	BizNotFound:          "资源未找到",
+	BizStorageExceeded:   "存储空间超出配额",
+	BizConflict:          "数据已被修改",
 }
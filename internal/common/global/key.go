@@ -4,4 +4,8 @@ const (
 	JwtPayloadKey           = "JWT_PAYLOAD"
 	PermissionKey           = "PERMISSION"
 	PersonalTokenRevokedKey = "PERSONAL_TOKEN_REVOKED_KEY"
+	// ResponseKey 通过 HttpRep 返回的响应，记录操作日志时使用
+	ResponseKey = "RESPONSE"
+	// OperTitleKey 注册路由时设置的操作模块标题，见 middlewares.OperTitle
+	OperTitleKey = "OPER_TITLE"
 )
@@ -0,0 +1,334 @@
+// Package loginguard 登录失败次数限制，按用户名和ip统计失败次数，
+// 失败后逐次增加延迟，达到次数后临时锁定。web登录、两步验证和ftp共用
+package loginguard
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/auditlog"
+	"go-file-server/pkgs/cache"
+	"go-file-server/pkgs/config"
+	"go-file-server/pkgs/zlog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultMaxFailures   = 5
+	DefaultMaxIpFailures = 20
+	DefaultWindow        = 15 * time.Minute
+	DefaultLockDuration  = 15 * time.Minute
+	DefaultMaxDelay      = 8 * time.Second
+
+	failPrefix = "login_fail:"
+	lockPrefix = "login_lock:"
+)
+
+// 锁定的对象
+const (
+	KindUser = "user"
+	KindIp   = "ip"
+)
+
+var (
+	ErrLocked      = errors.New("登录失败次数过多，请稍后再试")
+	ErrInvalidKind = errors.New("无效的锁定类型")
+)
+
+// Options 小于等于0的次数和延迟表示不限制
+type Options struct {
+	MaxFailures   int
+	MaxIpFailures int
+	Window        time.Duration
+	LockDuration  time.Duration
+	MaxDelay      time.Duration
+}
+
+// OptionsFromConfig 0 使用默认值，次数和延迟小于0时关闭对应的限制
+func OptionsFromConfig(cfg config.Lockout) Options {
+	o := Options{
+		MaxFailures:   orDefault(cfg.MaxFailures, DefaultMaxFailures),
+		MaxIpFailures: orDefault(cfg.MaxIpFailures, DefaultMaxIpFailures),
+		Window:        DefaultWindow,
+		LockDuration:  DefaultLockDuration,
+		MaxDelay:      DefaultMaxDelay,
+	}
+	if cfg.WindowMinutes > 0 {
+		o.Window = time.Duration(cfg.WindowMinutes) * time.Minute
+	}
+	if cfg.LockMinutes > 0 {
+		o.LockDuration = time.Duration(cfg.LockMinutes) * time.Minute
+	}
+	if cfg.MaxDelaySeconds != 0 {
+		o.MaxDelay = time.Duration(cfg.MaxDelaySeconds) * time.Second
+	}
+	return o
+}
+
+func orDefault(v, def int) int {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// Lock 锁定的用户名或ip
+type Lock struct {
+	Kind    string `json:"kind"`
+	Subject string `json:"subject"`
+	// Failures 锁定时的失败次数
+	Failures int       `json:"failures"`
+	Until    time.Time `json:"until"`
+}
+
+type Guard struct {
+	cache        cache.AdapterCache
+	loginLogRepo *repository.LoginLogRepository
+	opts         Options
+	sleep        func(time.Duration)
+}
+
+func NewGuard(cache cache.AdapterCache, loginLogRepo *repository.LoginLogRepository) *Guard {
+	return &Guard{
+		cache:        cache,
+		loginLogRepo: loginLogRepo,
+		opts:         OptionsFromConfig(config.ApplicationCfg.Lockout),
+		sleep:        time.Sleep,
+	}
+}
+
+// Verify 检查锁定和延迟后执行 verify，失败时计数，成功时清除用户名的失败次数。
+// 服务端错误不计入失败次数
+func (g *Guard) Verify(protocol, username, ip string, verify func() (*models.SysUser, error)) (*models.SysUser, error) {
+	if err := g.Check(username, ip); err != nil {
+		return nil, err
+	}
+	user, err := verify()
+	if err != nil {
+		if err.Error() != global.ErrServerNotOK {
+			g.Fail(protocol, username, ip)
+		}
+		return nil, err
+	}
+	g.Succeed(username)
+	return user, nil
+}
+
+// Check 用户名或ip被锁定时返回错误，之前有失败时按失败次数延迟
+func (g *Guard) Check(username, ip string) error {
+	if err := g.Locked(username, ip); err != nil {
+		return err
+	}
+	failures := 0
+	for _, s := range g.subjects(username, ip) {
+		failures = max(failures, g.failures(s.kind, s.subject))
+	}
+	if d := progressiveDelay(failures, g.opts.MaxDelay); d > 0 {
+		g.sleep(d)
+	}
+	return nil
+}
+
+// Locked 用户名或ip被锁定时返回错误，不延迟。用于跳过密码校验的缓存登录
+func (g *Guard) Locked(username, ip string) error {
+	for _, s := range g.subjects(username, ip) {
+		if lock, ok := g.lock(s.kind, s.subject); ok {
+			return lockedErr(lock)
+		}
+	}
+	return nil
+}
+
+// Fail 记录一次失败，达到次数后锁定并记录到登录日志
+func (g *Guard) Fail(protocol, username, ip string) {
+	for _, s := range g.subjects(username, ip) {
+		key := failKey(s.kind, s.subject)
+		n, err := g.increase(key)
+		if err != nil {
+			zlog.SugLog.Errorf("记录登录失败次数失败: %v", err)
+			continue
+		}
+		if n >= s.max {
+			g.lockout(protocol, s.kind, s.subject, n, username, ip)
+		}
+	}
+}
+
+// Succeed 登录成功后清除用户名的失败次数，ip的失败次数不清除，
+// 避免使用一个可以登录的账号重置ip的计数
+func (g *Guard) Succeed(username string) {
+	if g.opts.MaxFailures > 0 && username != "" {
+		g.cache.Del(failKey(KindUser, username))
+	}
+}
+
+// Unlock 解除锁定并清除失败次数
+func (g *Guard) Unlock(kind, subject string) error {
+	if kind != KindUser && kind != KindIp {
+		return core.NewApiBizErr(ErrInvalidKind).
+			SetBizCode(global.BizBadRequest).
+			SetMsg(ErrInvalidKind.Error())
+	}
+	if err := g.cache.Del(lockKey(kind, subject)); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(g.cache.Del(failKey(kind, subject)))
+}
+
+// Locks 返回当前的锁定，从锁定时长内的登录日志中查找锁定的用户名和ip
+func (g *Guard) Locks() ([]Lock, error) {
+	logs, _, err := g.loginLogRepo.Find(
+		repository.WithLoginStatus(models.LoginStatusLocked),
+		repository.WithLoginCreatedAfter(time.Now().Add(-g.opts.LockDuration)),
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	locks := []Lock{}
+	seen := make(map[string]bool)
+	for _, log := range logs {
+		for _, s := range [][2]string{{KindUser, log.Username}, {KindIp, log.Ipaddr}} {
+			key := lockKey(s[0], s[1])
+			if s[1] == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			if lock, ok := g.lock(s[0], s[1]); ok {
+				locks = append(locks, lock)
+			}
+		}
+	}
+	return locks, nil
+}
+
+type subject struct {
+	kind    string
+	subject string
+	max     int
+}
+
+// subjects 返回开启了限制的统计对象
+func (g *Guard) subjects(username, ip string) []subject {
+	var s []subject
+	if g.opts.MaxFailures > 0 && username != "" {
+		s = append(s, subject{KindUser, username, g.opts.MaxFailures})
+	}
+	if g.opts.MaxIpFailures > 0 && ip != "" {
+		s = append(s, subject{KindIp, ip, g.opts.MaxIpFailures})
+	}
+	return s
+}
+
+func (g *Guard) lock(kind, subject string) (Lock, bool) {
+	var lock Lock
+	val, err := g.cache.Get(lockKey(kind, subject))
+	if err != nil || json.Unmarshal([]byte(val), &lock) != nil || time.Now().After(lock.Until) {
+		return lock, false
+	}
+	return lock, true
+}
+
+func (g *Guard) failures(kind, subject string) int {
+	val, err := g.cache.Get(failKey(kind, subject))
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(val)
+	return n
+}
+
+// increase 第一次失败时设置过期时间，失败次数在 Window 内有效
+func (g *Guard) increase(key string) (int, error) {
+	if err := g.cache.Increase(key); err != nil {
+		return 0, err
+	}
+	val, err := g.cache.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if n == 1 {
+		err = g.cache.Expire(key, g.opts.Window)
+	}
+	return n, err
+}
+
+func (g *Guard) lockout(protocol, kind, subject string, failures int, username, ip string) {
+	lock := Lock{
+		Kind:     kind,
+		Subject:  subject,
+		Failures: failures,
+		Until:    time.Now().Add(g.opts.LockDuration),
+	}
+	data, err := json.Marshal(lock)
+	if err == nil {
+		err = g.cache.Set(lockKey(kind, subject), string(data), g.opts.LockDuration)
+	}
+	if err != nil {
+		zlog.SugLog.Errorf("锁定登录失败 %s %s: %v", kind, subject, err)
+		return
+	}
+	g.cache.Del(failKey(kind, subject))
+
+	target := "用户名"
+	if kind == KindIp {
+		target = "ip"
+	}
+	msg := fmt.Sprintf("%s 连续失败 %d 次，锁定%s %d 分钟",
+		protocol, failures, target, int(g.opts.LockDuration.Minutes()))
+	if err := g.loginLogRepo.Create(&models.SysLoginLog{
+		Username: username,
+		Status:   models.LoginStatusLocked,
+		Ipaddr:   ip,
+		Remark:   protocol,
+		Msg:      msg,
+	}); err != nil {
+		zlog.SugLog.Errorf("记录登录锁定失败: %v", err)
+	}
+	auditlog.Emit(auditlog.Event{
+		Category: auditlog.CategoryAuth,
+		Action:   auditlog.ActionLockout,
+		Actor:    username,
+		Protocol: protocol,
+		ClientIp: ip,
+		Target:   kind + ":" + subject,
+		Details:  map[string]any{"failures": failures, "until": lock.Until},
+	}.WithErr(ErrLocked))
+}
+
+// progressiveDelay 第一次失败后延迟1秒，之后每次翻倍，不超过 maxDelay
+func progressiveDelay(failures int, maxDelay time.Duration) time.Duration {
+	if failures <= 0 || maxDelay <= 0 {
+		return 0
+	}
+	if failures > 31 {
+		return maxDelay
+	}
+	return min(time.Second<<(failures-1), maxDelay)
+}
+
+func lockedErr(lock Lock) error {
+	minutes := int(math.Ceil(time.Until(lock.Until).Minutes()))
+	return core.NewApiBizErr(ErrLocked).
+		SetHttpCode(global.TooManyRequests).
+		SetBizCode(global.BizRateLimitExceeded).
+		SetMsg(fmt.Sprintf("登录失败次数过多，请在 %d 分钟后重试", max(minutes, 1)))
+}
+
+func failKey(kind, subject string) string {
+	return failPrefix + kind + ":" + subject
+}
+
+func lockKey(kind, subject string) string {
+	return lockPrefix + kind + ":" + subject
+}
@@ -0,0 +1,115 @@
+package loginguard
+
+import (
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/cache"
+	"go-file-server/pkgs/config"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestProgressiveDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		max      time.Duration
+		want     time.Duration
+	}{
+		{name: "no failures", failures: 0, max: 8 * time.Second, want: 0},
+		{name: "first failure", failures: 1, max: 8 * time.Second, want: time.Second},
+		{name: "doubles", failures: 3, max: 8 * time.Second, want: 4 * time.Second},
+		{name: "capped", failures: 6, max: 8 * time.Second, want: 8 * time.Second},
+		{name: "large count", failures: 100, max: 8 * time.Second, want: 8 * time.Second},
+		{name: "disabled", failures: 3, max: -time.Second, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := progressiveDelay(tt.failures, tt.max); got != tt.want {
+				t.Errorf("progressiveDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptionsFromConfig(t *testing.T) {
+	o := OptionsFromConfig(config.Lockout{MaxIpFailures: -1, LockMinutes: 30, MaxDelaySeconds: -1})
+	want := Options{
+		MaxFailures:   DefaultMaxFailures,
+		MaxIpFailures: -1,
+		Window:        DefaultWindow,
+		LockDuration:  30 * time.Minute,
+		MaxDelay:      -time.Second,
+	}
+	if o != want {
+		t.Errorf("OptionsFromConfig() = %+v, want %+v", o, want)
+	}
+}
+
+func TestVerifyCountsFailures(t *testing.T) {
+	var slept []time.Duration
+	g := &Guard{
+		cache: cache.NewMemory(),
+		opts: Options{
+			MaxFailures:   10,
+			MaxIpFailures: 10,
+			Window:        time.Minute,
+			LockDuration:  time.Minute,
+			MaxDelay:      4 * time.Second,
+		},
+		sleep: func(d time.Duration) { slept = append(slept, d) },
+	}
+	fail := func() (*models.SysUser, error) { return nil, errors.New("密码错误") }
+	ok := func() (*models.SysUser, error) { return &models.SysUser{}, nil }
+
+	for i := 0; i < 3; i++ {
+		g.Verify("web", "alice", "10.0.0.1", fail)
+	}
+	if got := g.failures(KindUser, "alice"); got != 3 {
+		t.Fatalf("user failures = %d, want 3", got)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second}
+	if len(slept) != len(want) || slept[0] != want[0] || slept[1] != want[1] {
+		t.Fatalf("delays = %v, want %v", slept, want)
+	}
+
+	if _, err := g.Verify("web", "alice", "10.0.0.1", ok); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if got := g.failures(KindUser, "alice"); got != 0 {
+		t.Errorf("user failures after success = %d, want 0", got)
+	}
+	if got := g.failures(KindIp, "10.0.0.1"); got != 3 {
+		t.Errorf("ip failures after success = %d, want 3", got)
+	}
+}
+
+func TestCheckLocked(t *testing.T) {
+	g := &Guard{
+		cache: cache.NewMemory(),
+		opts:  Options{MaxFailures: 5, MaxIpFailures: 20, LockDuration: time.Minute},
+		sleep: func(time.Duration) {},
+	}
+	g.cache.Set(lockKey(KindIp, "10.0.0.2"), `{"kind":"ip","subject":"10.0.0.2","until":"`+
+		time.Now().Add(time.Minute).Format(time.RFC3339Nano)+`"}`, time.Minute)
+
+	if err := g.Check("bob", "10.0.0.2"); err == nil {
+		t.Fatal("Check() on locked ip returned nil")
+	}
+	if err := g.Locked("bob", "10.0.0.2"); err == nil {
+		t.Fatal("Locked() on locked ip returned nil")
+	}
+	if err := g.Check("bob", "10.0.0.3"); err != nil {
+		t.Fatalf("Check() on other ip error = %v", err)
+	}
+	if err := g.Unlock(KindIp, "10.0.0.2"); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if err := g.Check("bob", "10.0.0.2"); err != nil {
+		t.Errorf("Check() after unlock error = %v", err)
+	}
+	if err := g.Locked("bob", "10.0.0.2"); err != nil {
+		t.Errorf("Locked() after unlock error = %v", err)
+	}
+}
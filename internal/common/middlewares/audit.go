@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"bytes"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/pkgs/auditlog"
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProtocolHttp 通过web接口的操作，与文件审计的访问方式一致
+const ProtocolHttp = "http"
+
+// AuditChange 角色、用户等权限相关的接口执行后发送审计事件，
+// 隐藏敏感字段后的请求参数记录在 details.request 中
+func AuditChange(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		contentType := c.ContentType()
+		limit := captureLimit(contentType)
+		var body []byte
+		if limit > 0 && c.Request.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(c.Request.Body, int64(limit)+1))
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+		}
+		total := max(c.Request.ContentLength, int64(len(body)))
+		body = body[:min(len(body), limit)]
+
+		c.Next()
+
+		claims := core.ExtractClaims(c)
+		e := auditlog.Event{
+			Category: auditlog.CategoryPermission,
+			Action:   action,
+			Outcome:  auditlog.OutcomeSuccess,
+			Actor:    claims.Username,
+			ActorId:  claims.UserId,
+			Protocol: ProtocolHttp,
+			ClientIp: core.GetClientIP(c),
+		}
+		if param := operParam(contentType, body, total); param != "" {
+			e.Details = map[string]any{"request": param}
+		}
+		if len(c.Errors) > 0 {
+			e.Outcome = auditlog.OutcomeFailure
+			e.Reason = c.Errors.Last().Error()
+		} else if rep := getResponse(c); rep != nil && rep.GetBizCode() != global.BizSuccess {
+			e.Outcome = auditlog.OutcomeFailure
+			e.Reason = rep.GetMsg()
+		}
+		auditlog.Emit(e)
+	}
+}
@@ -4,14 +4,19 @@ import (
 	"go-file-server/internal/common/core"
 	"go-file-server/internal/common/global"
 	"go-file-server/internal/common/repository"
+	"go-file-server/internal/common/types"
 	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/config"
 	"go-file-server/pkgs/zlog"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"os"
+	"path"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -22,19 +27,33 @@ import (
 
 type Glog struct {
 	repo *repository.OperaLogRepository
+	// excludes 不记录操作日志的请求，见 config.Logger.Exclude
+	excludes []config.LogExclude
+	titles   *operTitles
 }
 
-func NewGlog(repo *repository.OperaLogRepository) Glog {
-	return Glog{repo: repo}
+func NewGlog(repo *repository.OperaLogRepository, apiRepo *repository.ApiRepository) Glog {
+	for _, rule := range config.LoggerCfg.Exclude {
+		if _, err := path.Match(rule.Path, ""); err != nil {
+			zlog.SugLog.Warnf("操作日志排除规则 %s 无效: %v", rule.Path, err)
+		}
+	}
+	return Glog{repo: repo, excludes: config.LoggerCfg.Exclude, titles: newOperTitles(apiRepo)}
 }
 
 func (g Glog) GinLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if isExcluded(g.excludes, c.Request.Method, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
 		buf := global.BufferPool.AcquireBuffer()
 		defer global.BufferPool.ReleaseBuffer(buf)
+		contentType := c.ContentType()
+		body := &bodyRecorder{buf: buf, limit: captureLimit(contentType)}
 		switch c.Request.Method {
 		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
-			tee := io.TeeReader(c.Request.Body, buf)
+			tee := io.TeeReader(c.Request.Body, body)
 			c.Request.Body = io.NopCloser(tee)
 		case http.MethodOptions:
 			return
@@ -57,13 +76,59 @@ func (g Glog) GinLogger() gin.HandlerFunc {
 		if len(c.Errors) > 0 {
 			data.Status = "2"
 		}
+		// 请求体在返回后会被回收，需要在这里处理
+		data.OperParam = operParam(contentType, buf.Bytes(), body.n)
+		if rep := getResponse(c); rep != nil {
+			data.JsonResult = jsonResult(rep)
+			if rep.GetBizCode() != global.BizSuccess {
+				data.Status = "2"
+			}
+		}
+		route := c.FullPath()
+		title := c.GetString(global.OperTitleKey)
 		go func() {
+			data.Title = g.titles.Lookup(data.RequestMethod, route, title)
+			data.BusinessType = businessType(data.RequestMethod)
 			ptintLog(c, &data)
 			g.intoDb(&data)
 		}()
 	}
 }
 
+// getResponse 返回通过 HttpRep 发送的响应，没有时返回nil
+func getResponse(c *gin.Context) *types.MinRep {
+	if rep, ok := c.Get(global.ResponseKey); ok {
+		if rep, ok := rep.(*types.MinRep); ok {
+			return rep
+		}
+	}
+	return nil
+}
+
+// isExcluded 请求是否匹配任意一条排除规则
+func isExcluded(rules []config.LogExclude, method, urlPath string) bool {
+	for _, rule := range rules {
+		if len(rule.Methods) > 0 && !slices.ContainsFunc(rule.Methods, func(m string) bool {
+			return strings.EqualFold(m, method)
+		}) {
+			continue
+		}
+		if matchPath(rule.Path, urlPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath 以 /** 结尾时匹配该路径以及下面的全部路径，否则使用 path.Match
+func matchPath(pattern, urlPath string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/")
+	}
+	ok, _ := path.Match(pattern, urlPath)
+	return ok
+}
+
 func ptintLog(c *gin.Context, data *models.SysOperaLog) {
 
 	logFields := []zap.Field{
@@ -99,7 +164,7 @@ func GinRecovery() gin.HandlerFunc {
 					}
 				}
 
-				httpRequest, _ := httputil.DumpRequest(c.Request, false)
+				httpRequest := dumpRequest(c.Request)
 				if brokenPipe {
 					zlog.SugLog.Error(c.Request.URL.Path,
 						zap.Any("error", err),
@@ -133,3 +198,33 @@ func GinRecovery() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// sensitiveQuery 记录日志时需要隐藏的查询参数，sse和下载接口通过查询参数传递密码和token
+var sensitiveQuery = []string{"password", "token"}
+
+// dumpRequest 与 httputil.DumpRequest 相同，隐藏敏感的查询参数
+func dumpRequest(r *http.Request) []byte {
+	r2 := r.Clone(r.Context())
+	r2.URL = redactURL(r.URL)
+	r2.RequestURI = ""
+	dump, _ := httputil.DumpRequest(r2, false)
+	return dump
+}
+
+// redactURL 返回隐藏敏感查询参数后的url
+func redactURL(u *url.URL) *url.URL {
+	query := u.Query()
+	redacted := false
+	for _, k := range sensitiveQuery {
+		if query.Has(k) {
+			query.Set(k, "***")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u
+	}
+	u2 := *u
+	u2.RawQuery = query.Encode()
+	return &u2
+}
@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"go-file-server/pkgs/config"
+	"testing"
+)
+
+func TestIsExcluded(t *testing.T) {
+	rules := []config.LogExclude{
+		{Path: "/api/v1/sse/fs/info"},
+		{Path: "/api/v1/fsthumb/**", Methods: []string{"GET"}},
+		{Path: "/api/v1/jobs/*", Methods: []string{"get", "HEAD"}},
+	}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   bool
+	}{
+		{name: "exact any method", method: "POST", path: "/api/v1/sse/fs/info", want: true},
+		{name: "exact other path", method: "GET", path: "/api/v1/sse/fs/info/x", want: false},
+		{name: "prefix root", method: "GET", path: "/api/v1/fsthumb", want: true},
+		{name: "prefix nested", method: "GET", path: "/api/v1/fsthumb/a/b.png", want: true},
+		{name: "prefix not boundary", method: "GET", path: "/api/v1/fsthumbs/a", want: false},
+		{name: "method not matched", method: "DELETE", path: "/api/v1/fsthumb/a.png", want: false},
+		{name: "wildcard single segment", method: "GET", path: "/api/v1/jobs/12", want: true},
+		{name: "wildcard does not cross slash", method: "GET", path: "/api/v1/jobs/12/retry", want: false},
+		{name: "method case insensitive", method: "HEAD", path: "/api/v1/jobs/3", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isExcluded(rules, tt.method, tt.path); got != tt.want {
+				t.Errorf("isExcluded(%s %s) = %v, want %v", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+}
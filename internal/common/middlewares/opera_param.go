@@ -0,0 +1,211 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/common/types"
+	"go-file-server/pkgs/zlog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxCaptureBody 记录请求体时最多缓存的字节数，超出后只记录长度
+	maxCaptureBody = 64 << 10
+	// maxOperParam 操作日志中请求参数的最大字符数
+	maxOperParam = 2000
+	// maxJsonResult 操作日志中返回数据的最大字符数，与数据库字段长度一致
+	maxJsonResult = 255
+	redacted      = "***"
+)
+
+// sensitiveFields 字段名包含这些词时隐藏字段的值，不区分大小写。
+// code 为两步验证的验证码、恢复码和登录的验证码
+var sensitiveFields = []string{"password", "passwd", "token", "secret", "code"}
+
+// bodyRecorder 缓存请求体的前 limit 个字节并统计总长度
+type bodyRecorder struct {
+	buf   *bytes.Buffer
+	limit int
+	n     int64
+}
+
+func (r *bodyRecorder) Write(p []byte) (int, error) {
+	if room := r.limit - r.buf.Len(); room > 0 {
+		r.buf.Write(p[:min(room, len(p))])
+	}
+	r.n += int64(len(p))
+	return len(p), nil
+}
+
+// captureLimit 只缓存能够解析和隐藏敏感字段的请求体，上传文件等只记录长度
+func captureLimit(contentType string) int {
+	if isJson(contentType) || contentType == "application/x-www-form-urlencoded" {
+		return maxCaptureBody
+	}
+	return 0
+}
+
+func isJson(contentType string) bool {
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
+
+// operParam 返回隐藏敏感字段后的请求体，total 为请求体的总长度
+func operParam(contentType string, body []byte, total int64) string {
+	if total == 0 {
+		return ""
+	}
+	if int64(len(body)) < total {
+		return fmt.Sprintf("(%s，共 %d 字节)", contentType, total)
+	}
+	switch {
+	case isJson(contentType):
+		var v any
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return fmt.Sprintf("(无效的json，共 %d 字节)", total)
+		}
+		var out bytes.Buffer
+		enc := json.NewEncoder(&out)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(redactValue(v)); err != nil {
+			return fmt.Sprintf("(无效的json，共 %d 字节)", total)
+		}
+		return truncate(strings.TrimSuffix(out.String(), "\n"), maxOperParam)
+	default:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return fmt.Sprintf("(无效的表单，共 %d 字节)", total)
+		}
+		for k := range values {
+			if isSensitive(k) {
+				values.Set(k, redacted)
+			}
+		}
+		return truncate(values.Encode(), maxOperParam)
+	}
+}
+
+// redactValue 递归隐藏对象中的敏感字段
+func redactValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			if isSensitive(k) {
+				val[k] = redacted
+				continue
+			}
+			val[k] = redactValue(item)
+		}
+	case []any:
+		for i, item := range val {
+			val[i] = redactValue(item)
+		}
+	}
+	return v
+}
+
+func isSensitive(field string) bool {
+	field = strings.ToLower(field)
+	for _, s := range sensitiveFields {
+		if strings.Contains(field, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonResult 记录响应的业务码和信息，不记录返回的数据
+func jsonResult(rep *types.MinRep) string {
+	data, err := json.Marshal(rep)
+	if err != nil {
+		return ""
+	}
+	return truncate(string(data), maxJsonResult)
+}
+
+// truncate 超出 n 个字符时截断并以 ... 结尾
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n-3]) + "..."
+}
+
+// businessType 按请求方式区分操作类型
+func businessType(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return "查询"
+	case http.MethodPost:
+		return "新增"
+	case http.MethodPut, http.MethodPatch:
+		return "修改"
+	case http.MethodDelete:
+		return "删除"
+	default:
+		return "其他"
+	}
+}
+
+// OperTitle 注册路由组时设置操作日志的模块标题，接口没有登记在 sys_api 中时使用
+func OperTitle(title string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(global.OperTitleKey, title)
+	}
+}
+
+// operTitles 根据路由查找操作模块，sys_api 中的标题在第一次使用时加载
+type operTitles struct {
+	apiRepo *repository.ApiRepository
+	mutex   sync.Mutex
+	titles  map[string]string
+}
+
+func newOperTitles(apiRepo *repository.ApiRepository) *operTitles {
+	return &operTitles{apiRepo: apiRepo}
+}
+
+// Lookup route 为 gin 的路由模板，如 /api/v1/role/:id，未匹配路由时为空。
+// 优先使用 sys_api 中的标题，其次是注册路由时通过 OperTitle 设置的 title
+func (t *operTitles) Lookup(method, route, title string) string {
+	if route == "" {
+		return ""
+	}
+	if title, ok := t.load()[method+" "+route]; ok {
+		return title
+	}
+	if title != "" {
+		return title
+	}
+	return route
+}
+
+// load 加载失败时下次再试，期间只使用路由的标题
+func (t *operTitles) load() map[string]string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.titles != nil {
+		return t.titles
+	}
+	apis, err := t.apiRepo.Find()
+	if err != nil {
+		zlog.SugLog.Errorf("加载接口标题失败: %v", err)
+		return nil
+	}
+	t.titles = make(map[string]string, len(apis))
+	for _, api := range apis {
+		t.titles[api.Action+" "+api.Path] = strings.TrimPrefix(api.Title, "*")
+	}
+	return t.titles
+}
@@ -0,0 +1,108 @@
+package middlewares
+
+import (
+	"go-file-server/internal/common/global"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOperParam(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		// total 为0时使用 body 的长度
+		total int64
+		want  string
+	}{
+		{name: "empty", contentType: "application/json", body: "", want: ""},
+		{
+			name:        "json redacted",
+			contentType: "application/json",
+			body:        `{"username":"admin","password":"123456","uuid":"x"}`,
+			want:        `{"password":"***","username":"admin","uuid":"x"}`,
+		},
+		{
+			name:        "json nested and case insensitive",
+			contentType: "application/json",
+			body:        `{"user":{"newPassword":"a","name":"<b>"},"items":[{"apiToken":1}],"clientSecret":null}`,
+			want:        `{"clientSecret":"***","items":[{"apiToken":"***"}],"user":{"name":"<b>","newPassword":"***"}}`,
+		},
+		{
+			name:        "two factor code redacted",
+			contentType: "application/json",
+			body:        `{"challenge":"c","code":"123456"}`,
+			want:        `{"challenge":"c","code":"***"}`,
+		},
+		{name: "json number kept", contentType: "application/json", body: `{"id":12345678901234567890}`, want: `{"id":12345678901234567890}`},
+		{name: "invalid json", contentType: "application/json", body: `{"password":`, want: "(无效的json，共 12 字节)"},
+		{
+			name:        "form redacted",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=a&token=abc",
+			want:        "name=a&token=%2A%2A%2A",
+		},
+		{name: "too large", contentType: "application/json", body: `{"a":1}`, total: 1 << 20, want: "(application/json，共 1048576 字节)"},
+		{name: "upload", contentType: "multipart/form-data", body: "", total: 100, want: "(multipart/form-data，共 100 字节)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total := tt.total
+			if total == 0 {
+				total = int64(len(tt.body))
+			}
+			if got := operParam(tt.contentType, []byte(tt.body), total); got != tt.want {
+				t.Errorf("operParam() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOperParamTruncate(t *testing.T) {
+	body := `{"text":"` + strings.Repeat("中", maxOperParam) + `"}`
+	got := operParam("application/json", []byte(body), int64(len(body)))
+	if n := len([]rune(got)); n != maxOperParam || !strings.HasSuffix(got, "...") {
+		t.Errorf("len = %d, got suffix %q", n, got[len(got)-6:])
+	}
+}
+
+func TestOperTitle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	titles := &operTitles{titles: map[string]string{"PUT /api/v1/role": "修改角色"}}
+	var got string
+	r := gin.New()
+	// 与 Glog 一样在全局中间件中，请求处理完成后读取路由组设置的标题
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		got = titles.Lookup(c.Request.Method, c.FullPath(), c.GetString(global.OperTitleKey))
+	})
+	api := r.Group("/api/v1")
+	role := api.Group("/role", OperTitle("角色管理"))
+	role.GET("", func(*gin.Context) {})
+	role.PUT("", func(*gin.Context) {})
+	api.GET("/untitled/:id", func(*gin.Context) {})
+
+	tests := []struct {
+		method string
+		url    string
+		want   string
+	}{
+		{http.MethodPut, "/api/v1/role", "修改角色"},
+		{http.MethodGet, "/api/v1/role", "角色管理"},
+		{http.MethodGet, "/api/v1/untitled/1", "/api/v1/untitled/:id"},
+		{http.MethodGet, "/api/v1/missing", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			got = "unset"
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.url, nil))
+			if got != tt.want {
+				t.Errorf("title = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/base"
+
+	"gorm.io/gorm"
+)
+
+type ApiRepository struct {
+	Repo *core.Repo
+}
+
+func NewApiRepository(db *gorm.DB) *ApiRepository {
+	return &ApiRepository{Repo: core.NewRepo(db)}
+}
+
+func (r *ApiRepository) Find(opts ...base.DbScope) (apis []models.SysApi, err error) {
+	err = r.Repo.Find(&apis, opts...)
+	return
+}
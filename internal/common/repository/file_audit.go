@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/base"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type FileAuditRepository struct {
+	Repo *core.Repo
+}
+
+func NewFileAuditRepository(db *gorm.DB) *FileAuditRepository {
+	return &FileAuditRepository{Repo: core.NewRepo(db)}
+}
+
+// CreateInBatches 批量写入审计记录
+func (r *FileAuditRepository) CreateInBatches(audits []models.SysFileAudit) error {
+	return r.Repo.GetDB().CreateInBatches(audits, 100).Error
+}
+
+func (r *FileAuditRepository) Find(opts ...base.DbScope) (audits []models.SysFileAudit, err error) {
+	err = r.Repo.Find(&audits, opts...)
+	return
+}
+
+func (r *FileAuditRepository) FindWithCount(opts ...base.DbScope) (audits []models.SysFileAudit, c int64, err error) {
+	err = r.Repo.FindWithCount(&audits, &c, opts...)
+	return
+}
+
+// DeleteBefore 删除指定时间之前的审计记录，返回删除的条数
+func (r *FileAuditRepository) DeleteBefore(t time.Time) (int64, error) {
+	result := r.Repo.GetDB().Where("created_at < ?", t).Delete(&models.SysFileAudit{})
+	return result.RowsAffected, result.Error
+}
+
+// WithAuditPathPrefix 路径以 prefix 开头
+func WithAuditPathPrefix(prefix string) base.DbScope {
+	return base.WithQuery("path like ?", escapeLike(prefix)+"%")
+}
+
+func WithAuditActor(actor string) base.DbScope {
+	return base.WithQuery("actor = ?", actor)
+}
+
+func WithAuditProtocol(protocol string) base.DbScope {
+	return base.WithQuery("protocol = ?", protocol)
+}
+
+func WithAuditAction(action string) base.DbScope {
+	return base.WithQuery("action = ?", action)
+}
+
+func WithAuditResult(result string) base.DbScope {
+	return base.WithQuery("result = ?", result)
+}
+
+func WithAuditBegin(s string) base.DbScope {
+	return base.WithQuery("created_at >= ?", s)
+}
+
+func WithAuditEnd(s string) base.DbScope {
+	return base.WithQuery("created_at <= ?", s)
+}
+
+// WithAuditIdBefore 导出时按id倒序分批读取
+func WithAuditIdBefore(id int) base.DbScope {
+	return base.WithQuery("id < ?", id)
+}
+
+// escapeLike 转义like中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -5,6 +5,8 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/blevesearch/bleve/v2"
@@ -16,6 +18,7 @@ type FileDocument struct {
 	Name  string
 	Path  string
 	IsDir bool
+	Size  int64
 }
 
 var ErrDocumentNotFound = errors.New("document not found")
@@ -24,11 +27,16 @@ type FsScope func(*bleve.SearchRequest)
 
 type FsRepository struct {
 	Indexer *pathtool.FileIndexer
+	// usageCache 目录统计结果的缓存，索引变化时按增量更新，见 fs_usage.go
+	usageCache map[string]*DirUsage
+	usageMutex sync.Mutex
 	sync.RWMutex
 }
 
 func NewFsRepository(indexer *pathtool.FileIndexer) *FsRepository {
-	return &FsRepository{Indexer: indexer}
+	r := &FsRepository{Indexer: indexer, usageCache: make(map[string]*DirUsage)}
+	indexer.OnIndexChange(r.applyUsageChange)
+	return r
 }
 
 // WithPagination 配置查询的分页
@@ -46,6 +54,19 @@ func WithPagination(index, size int) FsScope {
 	}
 }
 
+// WithOffset 按偏移量分页，size 为0时只返回总数
+func WithOffset(from, size int) FsScope {
+	return func(sr *bleve.SearchRequest) {
+		sr.From = max(from, 0)
+		sr.Size = max(size, 0)
+	}
+}
+
+// WithUnderPath 查询目录下的全部文档，不包括目录本身
+func WithUnderPath(path string) FsScope {
+	return WithPrefixPath(strings.TrimSuffix(path, string(filepath.Separator)) + string(filepath.Separator))
+}
+
 // WithPathPrefix 查询特定路径前缀
 func WithPrefixPath(path string) FsScope {
 	return func(sr *bleve.SearchRequest) {
@@ -55,12 +76,20 @@ func WithPrefixPath(path string) FsScope {
 	}
 }
 
-// WithParentPathPrefix 查询上级路径路径前缀
-func WithParentPathPrefix(path string) FsScope {
+// WithParentPathPrefix 查询上级路径路径前缀，多个路径之间为或的关系
+func WithParentPathPrefix(paths ...string) FsScope {
 	return func(sr *bleve.SearchRequest) {
-		q := bleve.NewPrefixQuery(path)
-		q.SetField("ParentPath")
-		sr.Query = combineQueries(sr.Query, q)
+		queries := make([]query.Query, 0, len(paths))
+		for _, path := range paths {
+			q := bleve.NewPrefixQuery(path)
+			q.SetField("ParentPath")
+			queries = append(queries, q)
+		}
+		if len(queries) == 1 {
+			sr.Query = combineQueries(sr.Query, queries[0])
+			return
+		}
+		sr.Query = combineQueries(sr.Query, bleve.NewDisjunctionQuery(queries...))
 	}
 }
 
@@ -175,12 +204,45 @@ func (r *FsRepository) find(scopes ...FsScope) ([]FileDocument, uint64, error) {
 			Path:  hit.Fields["Path"].(string),
 			IsDir: hit.Fields["IsDir"].(bool),
 		}
+		if size, ok := hit.Fields["Size"].(float64); ok {
+			doc.Size = int64(size)
+		}
 		docs = append(docs, doc)
 
 	}
 	return docs, results.Total, nil
 }
 
+// sumSizePageSize 统计大小时每次从索引读取的文档数
+const sumSizePageSize = 10000
+
+// SumSize 统计查询结果中文件大小的总和
+func (r *FsRepository) SumSize(scopes ...FsScope) (int64, error) {
+	r.RLock()
+	defer r.RUnlock()
+	var total int64
+	// 复制一份再追加，避免写入调用方的数组
+	scopes = append(slices.Clip(scopes), WithIsDir(false))
+	for from := 0; ; from += sumSizePageSize {
+		searchRequest := makeSearchRequest(scopes...)
+		searchRequest.Fields = []string{"Size"}
+		searchRequest.From = from
+		searchRequest.Size = sumSizePageSize
+		results, err := r.Indexer.Search(searchRequest)
+		if err != nil {
+			return 0, err
+		}
+		for _, hit := range results.Hits {
+			if size, ok := hit.Fields["Size"].(float64); ok {
+				total += int64(size)
+			}
+		}
+		if uint64(from+sumSizePageSize) >= results.Total {
+			return total, nil
+		}
+	}
+}
+
 func (r *FsRepository) Find(scopes ...FsScope) ([]FileDocument, uint64, error) {
 	r.RLock()
 	defer r.RUnlock()
@@ -246,7 +308,8 @@ func (r *FsRepository) Rename(src, des string) error {
 	if exist {
 		return os.ErrExist
 	}
-	err = os.Rename(src, des)
+	// 跨挂载点(设备)时 os.Rename 会失败，Move 会退化为复制后删除
+	err = pathtool.Move(src, des)
 	if err != nil {
 		return err
 	}
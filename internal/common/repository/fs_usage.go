@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"go-file-server/pkgs/pathtool"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// maxUsageCache 缓存统计结果的目录数量上限，超出时随机淘汰
+const maxUsageCache = 1000
+
+// DirUsage 目录的递归大小以及占用空间最大的子项
+type DirUsage struct {
+	Size      int64           `json:"size"`
+	FileCount uint64          `json:"fileCount"`
+	DirCount  uint64          `json:"dirCount"`
+	Children  []DirUsageChild `json:"children"`
+}
+
+type DirUsageChild struct {
+	Name      string `json:"name"`
+	IsDir     bool   `json:"isDir"`
+	Size      int64  `json:"size"`
+	FileCount uint64 `json:"fileCount"`
+}
+
+// GetDirUsage 根据索引中的文件大小统计目录的占用，top 为返回的子项数量
+func (r *FsRepository) GetDirUsage(path string, top int) (DirUsage, error) {
+	path = filepath.Clean(path)
+	r.usageMutex.Lock()
+	cached, ok := r.usageCache[path]
+	if ok {
+		usage := cached.clone()
+		r.usageMutex.Unlock()
+		return topChildren(usage, top), nil
+	}
+	r.usageMutex.Unlock()
+
+	version := r.Indexer.Version()
+	usage, err := r.sumDirUsage(path)
+	if err != nil {
+		return DirUsage{}, err
+	}
+	r.usageMutex.Lock()
+	// 统计期间索引有变化时不缓存，避免漏掉统计之后才应用的增量
+	if r.Indexer.Version() == version {
+		if len(r.usageCache) >= maxUsageCache {
+			for dir := range r.usageCache {
+				delete(r.usageCache, dir)
+				break
+			}
+		}
+		cached := usage.clone()
+		r.usageCache[path] = &cached
+	}
+	r.usageMutex.Unlock()
+	return topChildren(usage, top), nil
+}
+
+// applyUsageChange 索引变化后更新缓存中上级目录的统计，无法计算增量时删除相关的缓存
+func (r *FsRepository) applyUsageChange(c pathtool.IndexChange) {
+	r.usageMutex.Lock()
+	defer r.usageMutex.Unlock()
+	if c.Path == "" {
+		clear(r.usageCache)
+		return
+	}
+	for dir, usage := range r.usageCache {
+		switch {
+		case c.Reset && (pathtool.IsSubPath(dir, c.Path) || pathtool.IsSubPath(c.Path, dir)):
+			delete(r.usageCache, dir)
+		case !c.Reset && dir != c.Path && pathtool.IsSubPath(dir, c.Path):
+			if !usage.apply(dir, c) {
+				delete(r.usageCache, dir)
+			}
+		}
+	}
+}
+
+// apply 将 dir 下的一个文件的变化累加到统计中，找不到对应的子项时返回false
+func (u *DirUsage) apply(dir string, c pathtool.IndexChange) bool {
+	prefix := strings.TrimSuffix(dir, string(filepath.Separator)) + string(filepath.Separator)
+	name, _, nested := strings.Cut(strings.TrimPrefix(c.Path, prefix), string(filepath.Separator))
+	i := slices.IndexFunc(u.Children, func(child DirUsageChild) bool { return child.Name == name })
+	if i < 0 {
+		if c.Files <= 0 {
+			return false
+		}
+		u.Children = append(u.Children, DirUsageChild{Name: name, IsDir: nested})
+		i = len(u.Children) - 1
+	}
+	child := &u.Children[i]
+	if int64(child.FileCount)+c.Files < 0 || int64(u.FileCount)+c.Files < 0 {
+		return false
+	}
+	u.Size += c.Size
+	u.FileCount = uint64(int64(u.FileCount) + c.Files)
+	child.Size += c.Size
+	child.FileCount = uint64(int64(child.FileCount) + c.Files)
+	if !nested && !child.IsDir && c.Files < 0 {
+		u.Children = slices.Delete(u.Children, i, i+1)
+	}
+	sortChildren(u.Children)
+	return true
+}
+
+func (u DirUsage) clone() DirUsage {
+	u.Children = slices.Clone(u.Children)
+	return u
+}
+
+func (r *FsRepository) sumDirUsage(path string) (DirUsage, error) {
+	r.RLock()
+	defer r.RUnlock()
+	children := make(map[string]*DirUsageChild)
+	var usage DirUsage
+	prefix := strings.TrimSuffix(path, string(filepath.Separator)) + string(filepath.Separator)
+	for from := 0; ; from += sumSizePageSize {
+		searchRequest := makeSearchRequest(WithPrefixPath(prefix))
+		searchRequest.Fields = []string{"Path", "IsDir", "Size"}
+		searchRequest.From = from
+		searchRequest.Size = sumSizePageSize
+		results, err := r.Indexer.Search(searchRequest)
+		if err != nil {
+			return usage, err
+		}
+		for _, hit := range results.Hits {
+			p, _ := hit.Fields["Path"].(string)
+			isDir, _ := hit.Fields["IsDir"].(bool)
+			size, _ := hit.Fields["Size"].(float64)
+			name, _, nested := strings.Cut(strings.TrimPrefix(p, prefix), string(filepath.Separator))
+			child, ok := children[name]
+			if !ok {
+				child = &DirUsageChild{Name: name}
+				children[name] = child
+			}
+			child.IsDir = child.IsDir || isDir || nested
+			if isDir {
+				usage.DirCount++
+				continue
+			}
+			usage.FileCount++
+			usage.Size += int64(size)
+			child.FileCount++
+			child.Size += int64(size)
+		}
+		if uint64(from+sumSizePageSize) >= results.Total {
+			break
+		}
+	}
+
+	usage.Children = make([]DirUsageChild, 0, len(children))
+	for _, child := range children {
+		usage.Children = append(usage.Children, *child)
+	}
+	sortChildren(usage.Children)
+	return usage, nil
+}
+
+// GetDiskDirUsage 遍历磁盘统计目录的占用，用于不建立索引的挂载点，结果不缓存
+func GetDiskDirUsage(path string, top int) (DirUsage, error) {
+	path = filepath.Clean(path)
+	children := make(map[string]*DirUsageChild)
+	var usage DirUsage
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == path {
+			return nil
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		name, _, nested := strings.Cut(rel, string(filepath.Separator))
+		child, ok := children[name]
+		if !ok {
+			child = &DirUsageChild{Name: name}
+			children[name] = child
+		}
+		child.IsDir = child.IsDir || d.IsDir() || nested
+		if d.IsDir() {
+			usage.DirCount++
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		usage.FileCount++
+		usage.Size += info.Size()
+		child.FileCount++
+		child.Size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return usage, err
+	}
+	usage.Children = make([]DirUsageChild, 0, len(children))
+	for _, child := range children {
+		usage.Children = append(usage.Children, *child)
+	}
+	sortChildren(usage.Children)
+	return topChildren(usage, top), nil
+}
+
+// sortChildren 按占用从大到小排序，大小相同时按名称排序
+func sortChildren(children []DirUsageChild) {
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].Size == children[j].Size {
+			return children[i].Name < children[j].Name
+		}
+		return children[i].Size > children[j].Size
+	})
+}
+
+func topChildren(usage DirUsage, top int) DirUsage {
+	if top > 0 && len(usage.Children) > top {
+		usage.Children = usage.Children[:top]
+	}
+	return usage
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"go-file-server/pkgs/pathtool"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetDirUsage(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{
+		"a/x.bin":     100,
+		"a/b/y.bin":   50,
+		"ab/z.bin":    7,
+		"c.bin":       30,
+		"empty/.keep": 0,
+	}
+	for name, size := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	indexer, err := pathtool.NewFileIndexer(dir, pathtool.WithIndexPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewFsRepository(indexer)
+
+	tests := []struct {
+		name      string
+		path      string
+		top       int
+		size      int64
+		fileCount uint64
+		children  []string
+	}{
+		{"root", dir, 2, 187, 5, []string{"a", "c.bin"}},
+		{"sub dir", filepath.Join(dir, "a"), 0, 150, 2, []string{"x.bin", "b"}},
+		{"prefix sibling excluded", filepath.Join(dir, "ab"), 0, 7, 1, []string{"z.bin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, err := repo.GetDirUsage(tt.path, tt.top)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if usage.Size != tt.size || usage.FileCount != tt.fileCount {
+				t.Errorf("GetDirUsage() size = %d, fileCount = %d, want %d, %d",
+					usage.Size, usage.FileCount, tt.size, tt.fileCount)
+			}
+			var names []string
+			for _, c := range usage.Children {
+				names = append(names, c.Name)
+			}
+			if len(names) != len(tt.children) {
+				t.Fatalf("children = %v, want %v", names, tt.children)
+			}
+			for i := range names {
+				if names[i] != tt.children[i] {
+					t.Errorf("children = %v, want %v", names, tt.children)
+				}
+			}
+
+			// 遍历磁盘的统计与索引一致
+			disk, err := GetDiskDirUsage(tt.path, tt.top)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if disk.Size != usage.Size || disk.FileCount != usage.FileCount ||
+				disk.DirCount != usage.DirCount || len(disk.Children) != len(usage.Children) {
+				t.Errorf("GetDiskDirUsage() = %+v, want %+v", disk, usage)
+			}
+		})
+	}
+}
+
+func TestDirUsageIncremental(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a", "x.bin"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	indexer, err := pathtool.NewFileIndexer(dir, pathtool.WithIndexPath(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewFsRepository(indexer)
+	if _, err := repo.GetDirUsage(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name      string
+		change    func() error
+		size      int64
+		fileCount uint64
+		children  int
+	}{
+		{"add file", func() error {
+			p := filepath.Join(dir, "y.bin")
+			if err := os.WriteFile(p, make([]byte, 30), 0644); err != nil {
+				return err
+			}
+			return repo.AddResource(p)
+		}, 130, 2, 2},
+		{"grow nested file", func() error {
+			p := filepath.Join(dir, "a", "x.bin")
+			if err := os.WriteFile(p, make([]byte, 120), 0644); err != nil {
+				return err
+			}
+			return repo.AddResource(p)
+		}, 150, 2, 2},
+		{"remove file", func() error {
+			return repo.Remove(filepath.Join(dir, "y.bin"))
+		}, 120, 1, 1},
+		{"remove dir", func() error {
+			return repo.RemoveAll(filepath.Join(dir, "a"))
+		}, 0, 0, 0},
+	}
+	for _, tt := range steps {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(); err != nil {
+				t.Fatal(err)
+			}
+			usage, err := repo.GetDirUsage(dir, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if usage.Size != tt.size || usage.FileCount != tt.fileCount || len(usage.Children) != tt.children {
+				t.Errorf("GetDirUsage() = %d, %d, %d children, want %d, %d, %d",
+					usage.Size, usage.FileCount, len(usage.Children), tt.size, tt.fileCount, tt.children)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/base"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type JobRepository struct {
+	Repo *core.Repo
+}
+
+func NewJobRepository(db *gorm.DB) *JobRepository {
+	return &JobRepository{Repo: core.NewRepo(db)}
+}
+
+func (r *JobRepository) Create(job *models.SysJob) error {
+	return r.Repo.Create(job)
+}
+
+func (r *JobRepository) FindOne(opts ...base.DbScope) (job models.SysJob, err error) {
+	err = r.Repo.FindOne(&job, opts...)
+	return
+}
+
+func (r *JobRepository) Find(opts ...base.DbScope) (jobs []models.SysJob, err error) {
+	err = r.Repo.Find(&jobs, opts...)
+	return
+}
+
+func (r *JobRepository) FindWithCount(opts ...base.DbScope) (jobs []models.SysJob, c int64, err error) {
+	err = r.Repo.FindWithCount(&jobs, &c, opts...)
+	return
+}
+
+// Updates 按条件更新任务，返回更新的行数，用于根据状态抢占任务
+func (r *JobRepository) Updates(values map[string]any, opts ...base.DbScope) (int64, error) {
+	result := r.Repo.GetDB().Model(&models.SysJob{}).Scopes(opts...).Updates(values)
+	return result.RowsAffected, result.Error
+}
+
+func (r *JobRepository) Delete(opts ...base.DbScope) error {
+	return r.Repo.Delete(&models.SysJob{}, opts...)
+}
+
+func WithJobId(id int) base.DbScope {
+	return base.WithQuery("id = ?", id)
+}
+
+func WithJobIds(ids ...int) base.DbScope {
+	return base.WithQuery("id in ?", ids)
+}
+
+func WithJobUserId(userId int) base.DbScope {
+	return base.WithQuery("user_id = ?", userId)
+}
+
+func WithJobType(t string) base.DbScope {
+	return base.WithQuery("type = ?", t)
+}
+
+func WithJobTypes(types ...string) base.DbScope {
+	return base.WithQuery("type in ?", types)
+}
+
+func WithJobKey(key string) base.DbScope {
+	return base.WithQuery("`key` = ?", key)
+}
+
+func WithJobStatus(status ...string) base.DbScope {
+	return base.WithQuery("status in ?", status)
+}
+
+// WithJobRunnable 已经到达执行时间的任务
+func WithJobRunnable(now time.Time) base.DbScope {
+	return base.WithQuery("run_at <= ?", now)
+}
+
+// WithJobFinishedBefore 在指定时间之前结束的任务
+func WithJobFinishedBefore(t time.Time) base.DbScope {
+	return base.WithQuery("finished_at < ?", t)
+}
+
+// WithJobQueueOrder 按优先级从高到低，同优先级先提交的先执行
+func WithJobQueueOrder() base.DbScope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Order("priority desc").Order("id asc")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"go-file-server/internal/common/core"
 	"go-file-server/internal/services/admin/models"
 	"go-file-server/pkgs/base"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -55,3 +56,7 @@ func WithLoginIds(ids ...int) base.DbScope {
 func (r *LoginLogRepository) Delete(opts ...base.DbScope) error {
 	return r.Repo.Delete(&models.SysLoginLog{}, opts...)
 }
+
+func WithLoginCreatedAfter(t time.Time) base.DbScope {
+	return base.WithQuery("created_at > ?", t)
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/base"
+
+	"gorm.io/gorm"
+)
+
+type RoleFsAliasRepository struct {
+	Repo *core.Repo
+}
+
+func NewRoleFsAliasRepository(db *gorm.DB) *RoleFsAliasRepository {
+	return &RoleFsAliasRepository{Repo: core.NewRepo(db)}
+}
+
+func (r *RoleFsAliasRepository) Create(values []models.SysRoleFsAlias, opts ...base.DbScope) error {
+	return r.Repo.Create(&values, opts...)
+}
+
+func (r *RoleFsAliasRepository) Delete(opts ...base.DbScope) error {
+	return r.Repo.Delete(&models.SysRoleFsAlias{}, opts...)
+}
+
+func (r *RoleFsAliasRepository) Find(opts ...base.DbScope) (data []models.SysRoleFsAlias, err error) {
+	err = r.Repo.Find(&data, opts...)
+	return
+}
+
+func WithFsAliasRoleKey(roleKey string) base.DbScope {
+	return base.WithQuery("role_key = ?", roleKey)
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/base"
+
+	"gorm.io/gorm"
+)
+
+type StorageQuotaRepository struct {
+	Repo *core.Repo
+}
+
+func NewStorageQuotaRepository(db *gorm.DB) *StorageQuotaRepository {
+	return &StorageQuotaRepository{Repo: core.NewRepo(db)}
+}
+
+func (r *StorageQuotaRepository) Create(values *models.SysStorageQuota, opts ...base.DbScope) error {
+	return r.Repo.Create(values, opts...)
+}
+
+func (r *StorageQuotaRepository) Update(values *models.SysStorageQuota, opts ...base.DbScope) error {
+	return r.Repo.Update(values, opts...)
+}
+
+func (r *StorageQuotaRepository) Delete(opts ...base.DbScope) error {
+	return r.Repo.Delete(&models.SysStorageQuota{}, opts...)
+}
+
+func (r *StorageQuotaRepository) Find(opts ...base.DbScope) (data []models.SysStorageQuota, err error) {
+	err = r.Repo.Find(&data, opts...)
+	return
+}
+
+func (r *StorageQuotaRepository) FindOne(opts ...base.DbScope) (data *models.SysStorageQuota, err error) {
+	err = r.Repo.FindOne(&data, opts...)
+	return
+}
+
+func WithStorageQuotaId(id int) base.DbScope {
+	return base.WithQuery("id = ?", id)
+}
+
+func WithStorageQuotaIds(ids ...int) base.DbScope {
+	return base.WithQuery("id in ?", ids)
+}
+
+func WithStorageQuotaPath(path string) base.DbScope {
+	return base.WithQuery("path = ?", path)
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/base"
+
+	"gorm.io/gorm"
+)
+
+type UserQuotaRepository struct {
+	Repo *core.Repo
+}
+
+func NewUserQuotaRepository(db *gorm.DB) *UserQuotaRepository {
+	return &UserQuotaRepository{Repo: core.NewRepo(db)}
+}
+
+func (r *UserQuotaRepository) Save(values *models.SysUserQuota) error {
+	return r.Repo.Save(values)
+}
+
+func (r *UserQuotaRepository) Delete(opts ...base.DbScope) error {
+	return r.Repo.Delete(&models.SysUserQuota{}, opts...)
+}
+
+func (r *UserQuotaRepository) FindOne(opts ...base.DbScope) (data *models.SysUserQuota, err error) {
+	err = r.Repo.FindOne(&data, opts...)
+	return
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/base"
+
+	"gorm.io/gorm"
+)
+
+type UserTotpRepository struct {
+	Repo *core.Repo
+}
+
+func NewUserTotpRepository(db *gorm.DB) *UserTotpRepository {
+	return &UserTotpRepository{Repo: core.NewRepo(db)}
+}
+
+func WithTotpUserId(userId int) base.DbScope {
+	return base.WithQuery("user_id = ?", userId)
+}
+
+// WithTotpLastStepBefore 时间步大于 LastStep 时才能使用，并发校验同一个验证码时只有一个成功
+func WithTotpLastStepBefore(step int64) base.DbScope {
+	return base.WithQuery("last_step < ?", step)
+}
+
+func WithTotpEnabled() base.DbScope {
+	return base.WithQuery("enabled = ?", true)
+}
+
+func WithTotpRecoveryCodes(codes string) base.DbScope {
+	return base.WithQuery("recovery_codes = ?", codes)
+}
+
+func (r *UserTotpRepository) Create(values *models.SysUserTotp) error {
+	return r.Repo.Create(values)
+}
+
+// Updates 使用map更新，可以更新零值，返回更新的行数
+func (r *UserTotpRepository) Updates(values map[string]any, opts ...base.DbScope) (int64, error) {
+	result := r.Repo.GetDB().Model(&models.SysUserTotp{}).Scopes(opts...).Updates(values)
+	return result.RowsAffected, result.Error
+}
+
+func (r *UserTotpRepository) Delete(opts ...base.DbScope) error {
+	return r.Repo.Delete(&models.SysUserTotp{}, opts...)
+}
+
+func (r *UserTotpRepository) FindOne(opts ...base.DbScope) (data *models.SysUserTotp, err error) {
+	err = r.Repo.FindOne(&data, opts...)
+	return
+}
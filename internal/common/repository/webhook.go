@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/base"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type WebhookRepository struct {
+	Repo *core.Repo
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{Repo: core.NewRepo(db)}
+}
+
+func (r *WebhookRepository) Create(hook *models.SysWebhook) error {
+	return r.Repo.Create(hook)
+}
+
+// Updates 使用map更新，可以把 enabled 更新为false
+func (r *WebhookRepository) Updates(values map[string]any, opts ...base.DbScope) error {
+	return r.Repo.GetDB().Model(&models.SysWebhook{}).Scopes(opts...).Updates(values).Error
+}
+
+func (r *WebhookRepository) Delete(opts ...base.DbScope) error {
+	return r.Repo.Delete(&models.SysWebhook{}, opts...)
+}
+
+func (r *WebhookRepository) FindOne(opts ...base.DbScope) (hook models.SysWebhook, err error) {
+	err = r.Repo.FindOne(&hook, opts...)
+	return
+}
+
+func (r *WebhookRepository) Find(opts ...base.DbScope) (hooks []models.SysWebhook, err error) {
+	err = r.Repo.Find(&hooks, opts...)
+	return
+}
+
+func (r *WebhookRepository) CreateDelivery(delivery *models.SysWebhookDelivery) error {
+	return r.Repo.Create(delivery)
+}
+
+func (r *WebhookRepository) UpdateDelivery(values map[string]any, opts ...base.DbScope) error {
+	return r.Repo.GetDB().Model(&models.SysWebhookDelivery{}).Scopes(opts...).Updates(values).Error
+}
+
+func (r *WebhookRepository) DeleteDeliveries(opts ...base.DbScope) error {
+	return r.Repo.Delete(&models.SysWebhookDelivery{}, opts...)
+}
+
+func (r *WebhookRepository) FindDelivery(opts ...base.DbScope) (delivery models.SysWebhookDelivery, err error) {
+	err = r.Repo.FindOne(&delivery, opts...)
+	return
+}
+
+func (r *WebhookRepository) FindDeliveriesWithCount(opts ...base.DbScope) (data []models.SysWebhookDelivery, c int64, err error) {
+	err = r.Repo.FindWithCount(&data, &c, opts...)
+	return
+}
+
+func WithWebhookId(id int) base.DbScope {
+	return base.WithQuery("id = ?", id)
+}
+
+func WithWebhookIds(ids ...int) base.DbScope {
+	return base.WithQuery("id in ?", ids)
+}
+
+func WithWebhookEnabled() base.DbScope {
+	return base.WithQuery("enabled = ?", true)
+}
+
+func WithDeliveryId(id int) base.DbScope {
+	return base.WithQuery("id = ?", id)
+}
+
+func WithDeliveryWebhookIds(ids ...int) base.DbScope {
+	return base.WithQuery("webhook_id in ?", ids)
+}
+
+func WithDeliveryStatus(status ...string) base.DbScope {
+	return base.WithQuery("status in ?", status)
+}
+
+func WithDeliveryEvent(event string) base.DbScope {
+	return base.WithQuery("event = ?", event)
+}
+
+// WithDeliveryCreatedBefore 在指定时间之前创建的投递记录
+func WithDeliveryCreatedBefore(t time.Time) base.DbScope {
+	return base.WithQuery("created_at < ?", t)
+}
@@ -0,0 +1,341 @@
+// Package twofactor 本地用户的 totp 两步验证，web登录、用户接口和ftp共用
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/cache"
+	"go-file-server/pkgs/utils/totp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	// Issuer 身份验证器中显示的服务名称
+	Issuer = "go-file-server"
+	// skew 允许前后一个时间步的时钟误差
+	skew = 1
+	// recoveryCodeCount 每次生成的恢复码数量
+	recoveryCodeCount = 10
+	// challengeTTL 密码校验通过后完成两步验证的时限
+	challengeTTL = 5 * time.Minute
+	// challengeMaxAttempts 一次登录最多输错验证码的次数，超出后需要重新登录
+	challengeMaxAttempts = 5
+	challengePrefix      = "2fa_challenge:"
+	// recoveryAlphabet 恢复码使用的字符，32个字符取余时没有偏差
+	recoveryAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+)
+
+var (
+	ErrInvalidCode      = errors.New("验证码错误")
+	ErrNotEnabled       = errors.New("未启用两步验证")
+	ErrAlreadyEnabled   = errors.New("已启用两步验证")
+	ErrNotEnrolled      = errors.New("请先获取两步验证的密钥")
+	ErrChallengeExpired = errors.New("登录已过期，请重新登录")
+)
+
+// Service 两步验证的登记、校验和登录挑战
+type Service struct {
+	totpRepo *repository.UserTotpRepository
+	roleRepo *repository.RoleRepository
+	cache    cache.AdapterCache
+}
+
+func NewService(
+	totpRepo *repository.UserTotpRepository,
+	roleRepo *repository.RoleRepository,
+	cache cache.AdapterCache,
+) *Service {
+	return &Service{totpRepo: totpRepo, roleRepo: roleRepo, cache: cache}
+}
+
+type Status struct {
+	Enabled bool `json:"enabled"`
+	// Required 角色要求启用两步验证
+	Required bool `json:"required"`
+	// RecoveryCodes 剩余的恢复码数量
+	RecoveryCodes int        `json:"recoveryCodes"`
+	EnabledAt     *time.Time `json:"enabledAt"`
+}
+
+// Status 查询用户的两步验证状态，required 为角色的要求
+func (s *Service) Status(userId int, required bool) (Status, error) {
+	status := Status{Required: required}
+	data, err := s.find(userId)
+	if err != nil || data == nil || !data.Enabled {
+		return status, err
+	}
+	status.Enabled = true
+	status.RecoveryCodes = len(splitCodes(data.RecoveryCodes))
+	status.EnabledAt = data.EnabledAt
+	return status, nil
+}
+
+// Required 角色是否要求启用两步验证，角色不存在时不要求
+func (s *Service) Required(roleId int) (bool, error) {
+	role, err := s.roleRepo.FindOne(repository.WithRoleId(roleId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, errors.WithStack(err)
+	}
+	return role.Require2fa, nil
+}
+
+// Needed 用户登录时是否需要两步验证，enroll 为角色要求但用户还未启用
+func (s *Service) Needed(userId, roleId int) (needed, enroll bool, err error) {
+	enabled, err := s.Enabled(userId)
+	if err != nil || enabled {
+		return enabled, false, err
+	}
+	required, err := s.Required(roleId)
+	return required, required, err
+}
+
+// Enabled 用户是否已经启用两步验证
+func (s *Service) Enabled(userId int) (bool, error) {
+	data, err := s.find(userId)
+	return data != nil && data.Enabled, err
+}
+
+// Enroll 生成新的密钥，验证通过 Enable 后才生效，返回密钥和扫码使用的地址
+func (s *Service) Enroll(userId int, account string) (string, string, error) {
+	data, err := s.find(userId)
+	if err != nil {
+		return "", "", err
+	}
+	if data != nil && data.Enabled {
+		return "", "", core.NewApiBizErr(ErrAlreadyEnabled).SetMsg(ErrAlreadyEnabled.Error())
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	// 重新登记时替换之前未验证的密钥
+	if err := s.totpRepo.Delete(repository.WithTotpUserId(userId)); err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	err = s.totpRepo.Create(&models.SysUserTotp{UserId: userId, Secret: secret})
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	return secret, totp.URI(Issuer, account, secret), nil
+}
+
+// Enable 使用登记的密钥校验验证码后启用，返回新的恢复码
+func (s *Service) Enable(userId int, code string) ([]string, error) {
+	data, err := s.find(userId)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil || data.Secret == "" {
+		return nil, core.NewApiBizErr(ErrNotEnrolled).SetMsg(ErrNotEnrolled.Error())
+	}
+	if data.Enabled {
+		return nil, core.NewApiBizErr(ErrAlreadyEnabled).SetMsg(ErrAlreadyEnabled.Error())
+	}
+	step, ok := totp.Validate(data.Secret, code, time.Now(), skew)
+	if !ok {
+		return nil, invalidCode()
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	n, err := s.totpRepo.Updates(map[string]any{
+		"enabled":        true,
+		"enabled_at":     &now,
+		"last_step":      step,
+		"recovery_codes": hashes,
+	}, repository.WithTotpUserId(userId), repository.WithTotpLastStepBefore(step))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if n == 0 {
+		return nil, invalidCode()
+	}
+	return codes, nil
+}
+
+// Verify 校验验证码或恢复码，恢复码使用后失效
+func (s *Service) Verify(userId int, code string) error {
+	data, err := s.find(userId)
+	if err != nil {
+		return err
+	}
+	if data == nil || !data.Enabled {
+		return core.NewApiBizErr(ErrNotEnabled).SetMsg(ErrNotEnabled.Error())
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(data.Secret, code, time.Now(), skew)
+		if !ok {
+			return invalidCode()
+		}
+		// 时间步没有增加时为重放的验证码
+		n, err := s.totpRepo.Updates(map[string]any{"last_step": step},
+			repository.WithTotpUserId(userId), repository.WithTotpLastStepBefore(step))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if n == 0 {
+			return invalidCode()
+		}
+		return nil
+	}
+
+	hashes := splitCodes(data.RecoveryCodes)
+	i := slices.Index(hashes, hashRecoveryCode(code))
+	if i < 0 {
+		return invalidCode()
+	}
+	remaining := strings.Join(slices.Delete(hashes, i, i+1), ",")
+	// 以原来的恢复码作为条件，并发使用同一个恢复码时只有一个成功
+	n, err := s.totpRepo.Updates(map[string]any{"recovery_codes": remaining},
+		repository.WithTotpUserId(userId), repository.WithTotpRecoveryCodes(data.RecoveryCodes))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if n == 0 {
+		return invalidCode()
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes 生成新的恢复码，之前的恢复码失效
+func (s *Service) RegenerateRecoveryCodes(userId int) ([]string, error) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.totpRepo.Updates(map[string]any{"recovery_codes": hashes},
+		repository.WithTotpUserId(userId), repository.WithTotpEnabled())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if n == 0 {
+		return nil, core.NewApiBizErr(ErrNotEnabled).SetMsg(ErrNotEnabled.Error())
+	}
+	return codes, nil
+}
+
+// Disable 关闭两步验证并删除密钥，管理员重置时也使用
+func (s *Service) Disable(userId int) error {
+	return errors.WithStack(s.totpRepo.Delete(repository.WithTotpUserId(userId)))
+}
+
+func (s *Service) find(userId int) (*models.SysUserTotp, error) {
+	data, err := s.totpRepo.FindOne(repository.WithTotpUserId(userId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
+// Challenge 密码校验通过后等待两步验证的登录
+type Challenge struct {
+	UserId int `json:"userId"`
+	// Enroll 角色要求两步验证但用户还未启用，需要先登记再验证
+	Enroll   bool `json:"enroll"`
+	Attempts int  `json:"attempts"`
+}
+
+// NewChallenge 返回挑战的id，客户端在第二步中提交
+func (s *Service) NewChallenge(userId int, enroll bool) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.WithStack(err)
+	}
+	id := hex.EncodeToString(b)
+	return id, s.saveChallenge(id, Challenge{UserId: userId, Enroll: enroll})
+}
+
+func (s *Service) GetChallenge(id string) (Challenge, error) {
+	var ch Challenge
+	val, err := s.cache.Get(challengePrefix + id)
+	if err != nil || val == "" || json.Unmarshal([]byte(val), &ch) != nil {
+		return ch, core.NewApiBizErr(ErrChallengeExpired).
+			SetBizCode(global.BizUnauthorizedErr).
+			SetMsg(ErrChallengeExpired.Error())
+	}
+	return ch, nil
+}
+
+// FailChallenge 记录一次验证失败，超出次数后删除挑战
+func (s *Service) FailChallenge(id string, ch Challenge) error {
+	ch.Attempts++
+	if ch.Attempts >= challengeMaxAttempts {
+		return s.DeleteChallenge(id)
+	}
+	return s.saveChallenge(id, ch)
+}
+
+func (s *Service) DeleteChallenge(id string) error {
+	return errors.WithStack(s.cache.Del(challengePrefix + id))
+}
+
+func (s *Service) saveChallenge(id string, ch Challenge) error {
+	data, err := json.Marshal(ch)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(s.cache.Set(challengePrefix+id, string(data), challengeTTL))
+}
+
+func invalidCode() error {
+	return core.NewApiBizErr(ErrInvalidCode).
+		SetBizCode(global.BizDataInvalid).
+		SetMsg(ErrInvalidCode.Error())
+}
+
+// newRecoveryCodes 返回明文的恢复码和保存的哈希，恢复码格式为 xxxxx-xxxxx
+func newRecoveryCodes() ([]string, string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	b := make([]byte, 10)
+	for i := range codes {
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", errors.WithStack(err)
+		}
+		var sb strings.Builder
+		for j, v := range b {
+			if j == 5 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(recoveryAlphabet[int(v)%len(recoveryAlphabet)])
+		}
+		codes[i] = sb.String()
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, strings.Join(hashes, ","), nil
+}
+
+// hashRecoveryCode 忽略大小写、空格和分隔符
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func splitCodes(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
@@ -0,0 +1,133 @@
+package twofactor
+
+import (
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/cache"
+	"go-file-server/pkgs/utils/totp"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestService(t *testing.T) *Service {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内存数据库每个连接是独立的库
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.SysUserTotp{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(repository.NewUserTotpRepository(db), nil, cache.NewMemory())
+}
+
+// enable 登记并启用两步验证，返回密钥、启用时使用的时间步和恢复码
+func enable(t *testing.T, s *Service, userId int) (string, int64, []string) {
+	secret, _, err := s.Enroll(userId, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := totp.Step(time.Now())
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := s.Enable(userId, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret, step, codes
+}
+
+func TestVerifyReplay(t *testing.T) {
+	s := newTestService(t)
+	secret, step, _ := enable(t, s, 1)
+
+	used, _ := totp.Code(secret, step)
+	if err := s.Verify(1, used); err == nil {
+		t.Error("启用时使用的验证码可以再次使用")
+	}
+	prev, _ := totp.Code(secret, step-1)
+	if err := s.Verify(1, prev); err == nil {
+		t.Error("早于上次使用的验证码可以使用")
+	}
+	next, _ := totp.Code(secret, step+1)
+	if err := s.Verify(1, next); err != nil {
+		t.Fatalf("下一个时间步的验证码校验失败: %v", err)
+	}
+	if err := s.Verify(1, next); err == nil {
+		t.Error("同一个验证码可以使用两次")
+	}
+}
+
+func TestVerifyRecoveryCode(t *testing.T) {
+	s := newTestService(t)
+	_, _, codes := enable(t, s, 1)
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("恢复码数量 %d, want %d", len(codes), recoveryCodeCount)
+	}
+
+	if err := s.Verify(1, "AAAAA-BBBBB"); err == nil {
+		t.Error("错误的恢复码校验通过")
+	}
+	// 忽略大小写和分隔符
+	if err := s.Verify(1, " "+codes[0][:5]+codes[0][6:]+" "); err != nil {
+		t.Fatalf("恢复码校验失败: %v", err)
+	}
+	if err := s.Verify(1, codes[0]); err == nil {
+		t.Error("恢复码可以使用两次")
+	}
+	status, err := s.Status(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.RecoveryCodes != recoveryCodeCount-1 {
+		t.Errorf("剩余恢复码 %d, want %d", status.RecoveryCodes, recoveryCodeCount-1)
+	}
+
+	// 重新生成后之前的恢复码失效
+	if _, err := s.RegenerateRecoveryCodes(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify(1, codes[1]); err == nil {
+		t.Error("重新生成后旧的恢复码仍然可以使用")
+	}
+}
+
+func TestFailChallenge(t *testing.T) {
+	s := newTestService(t)
+	id, err := s.NewChallenge(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < challengeMaxAttempts; i++ {
+		ch, err := s.GetChallenge(id)
+		if err != nil {
+			t.Fatalf("第 %d 次失败后挑战失效: %v", i-1, err)
+		}
+		if ch.Attempts != i-1 || ch.UserId != 1 {
+			t.Fatalf("挑战 %+v, want attempts %d", ch, i-1)
+		}
+		if err := s.FailChallenge(id, ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ch, err := s.GetChallenge(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.FailChallenge(id, ch); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetChallenge(id); err == nil {
+		t.Errorf("失败 %d 次后挑战仍然有效", challengeMaxAttempts)
+	}
+}
@@ -2,8 +2,10 @@ package types
 
 import (
 	"go-file-server/internal/common/global"
+	"go-file-server/internal/services/admin/apis/fs/utils"
 	"go-file-server/pkgs/cache"
 	"go-file-server/pkgs/pathtool"
+	"go-file-server/pkgs/session"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/dgrijalva/jwt-go"
@@ -17,6 +19,9 @@ type SvcCtx struct {
 	FsIndexer      *pathtool.FileIndexer
 	CasbinEnforcer *casbin.CachedEnforcer
 	Cache          cache.AdapterCache
+	Sessions       *session.Manager
+	// Actors 记录通过接口和ftp修改路径的用户，用于目录变化事件
+	Actors *utils.ActorTracker
 }
 
 func (ctx *SvcCtx) Clone() *SvcCtx {
@@ -118,6 +123,7 @@ func (r *ErrRep) SetBizCode(c global.BizCode) HttpRep {
 
 func (r *ErrRep) SendGin(c *gin.Context) {
 	r.SetMsg(r.GetMsg())
+	c.Set(global.ResponseKey, r.MinRep)
 	c.AbortWithStatusJSON(int(r.GetHttpCode()), r)
 }
 
@@ -143,6 +149,7 @@ func (r *Rep) SetBizCode(c global.BizCode) HttpRep {
 
 func (r *Rep) SendGin(c *gin.Context) {
 	r.SetMsg(r.GetMsg())
+	c.Set(global.ResponseKey, r.MinRep)
 	c.AbortWithStatusJSON(int(r.GetHttpCode()), r)
 
 }
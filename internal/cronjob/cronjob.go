@@ -14,6 +14,8 @@ func InitJobs(db *gorm.DB) {
 		cron.WithLogger(cron.VerbosePrintfLogger(&CornLogger{zlog.SugLog})),
 	)
 	RegisterLdapJob(c, db)
+	RegisterFileAuditJob(c, db)
+	RegisterLogArchiveJob(c, db)
 
 	if len(c.Entries()) == 0 {
 		return
@@ -30,3 +32,36 @@ func RegisterLdapJob(c *cron.Cron, db *gorm.DB) {
 		zlog.SugLog.Fatalf("无法注册ldap用户同步任务: %v", err)
 	}
 }
+
+// RegisterFileAuditJob 每天清理超出保留天数的文件审计记录
+func RegisterFileAuditJob(c *cron.Cron, db *gorm.DB) {
+	days := config.ApplicationCfg.Audit.RetentionDays
+	if days < 0 {
+		return
+	}
+	if days == 0 {
+		days = jobs.DefaultAuditRetentionDays
+	}
+	_, err := c.AddJob("0 30 3 * * *", jobs.NewFileAuditCleaner(db, days))
+	if err != nil {
+		zlog.SugLog.Fatalf("无法注册文件审计清理任务: %v", err)
+	}
+}
+
+// RegisterLogArchiveJob 每天归档并删除超出保留天数的操作日志和登录日志。
+// 这两张表在之前的版本中一直保留，未配置保留天数时不清理，避免升级后直接删除历史日志
+func RegisterLogArchiveJob(c *cron.Cron, db *gorm.DB) {
+	retention := config.LoggerCfg.Retention
+	operaDays := max(retention.OperaDays, 0)
+	loginDays := max(retention.LoginDays, 0)
+	if operaDays == 0 && loginDays == 0 {
+		return
+	}
+	if retention.ArchiveDir == "" {
+		zlog.SugLog.Warnf("未配置 logger.retention.archiveDir，超出保留天数的操作日志和登录日志将直接删除")
+	}
+	_, err := c.AddJob("0 0 4 * * *", jobs.NewLogArchiver(db, operaDays, loginDays, retention.ArchiveDir))
+	if err != nil {
+		zlog.SugLog.Fatalf("无法注册日志归档任务: %v", err)
+	}
+}
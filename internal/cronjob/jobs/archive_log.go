@@ -0,0 +1,184 @@
+package jobs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/zlog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// archiveBatchSize 每次读取和删除的行数
+const archiveBatchSize = 1000
+
+// LogArchiver 将超出保留天数的操作日志和登录日志写入 gzip 压缩的 jsonl 文件后删除，
+// 包括已经在页面上删除(软删除)的记录
+type LogArchiver struct {
+	db         *gorm.DB
+	operaDays  int
+	loginDays  int
+	archiveDir string
+}
+
+// NewLogArchiver 保留天数小于等于0时不处理对应的表，archiveDir 为空时不归档直接删除
+func NewLogArchiver(db *gorm.DB, operaDays, loginDays int, archiveDir string) *LogArchiver {
+	return &LogArchiver{
+		db:         db,
+		operaDays:  operaDays,
+		loginDays:  loginDays,
+		archiveDir: archiveDir,
+	}
+}
+
+func (a *LogArchiver) Run() {
+	if a.operaDays > 0 {
+		archiveTable(a, &models.SysOperaLog{}, a.operaDays, func(b []models.SysOperaLog) (uint, uint) {
+			return b[0].ID, b[len(b)-1].ID
+		})
+	}
+	if a.loginDays > 0 {
+		archiveTable(a, &models.SysLoginLog{}, a.loginDays, func(b []models.SysLoginLog) (uint, uint) {
+			return b[0].ID, b[len(b)-1].ID
+		})
+	}
+}
+
+type tabler interface {
+	TableName() string
+}
+
+// archiveTable bounds 返回一批记录的第一个和最后一个id
+func archiveTable[T any](a *LogArchiver, model tabler, days int, bounds func([]T) (uint, uint)) {
+	before := time.Now().AddDate(0, 0, -days)
+	n, err := archiveLogs(a.db, model, before, a.archiveDir, bounds)
+	if err != nil {
+		zlog.SugLog.Errorf("归档 %s 失败: %v", model.TableName(), err)
+		return
+	}
+	zlog.SugLog.Infof("归档并删除了 %d 条 %s 之前的 %s 记录", n, before.Format(time.DateTime), model.TableName())
+}
+
+// archiveLogs 按id顺序分批读取 before 之前的记录写入归档文件，文件写入完成后再按批次的id范围删除，
+// 写入失败时不删除任何记录。返回删除的条数
+func archiveLogs[T any](db *gorm.DB, model tabler, before time.Time, dir string,
+	bounds func([]T) (uint, uint)) (int64, error) {
+	var w *archiveWriter
+	if dir != "" {
+		var err error
+		name := fmt.Sprintf("%s-%s.jsonl.gz", model.TableName(), time.Now().Format("20060102-150405"))
+		if w, err = newArchiveWriter(filepath.Join(dir, name)); err != nil {
+			return 0, err
+		}
+		defer w.Abort()
+	}
+
+	var ranges [][2]uint
+	var lastId uint
+	for {
+		var batch []T
+		err := db.Unscoped().Model(model).
+			Where("created_at < ? AND id > ?", before, lastId).
+			Order("id").Limit(archiveBatchSize).
+			Find(&batch).Error
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		if w != nil {
+			for _, row := range batch {
+				if err := w.Write(row); err != nil {
+					return 0, err
+				}
+			}
+		}
+		first, last := bounds(batch)
+		ranges = append(ranges, [2]uint{first, last})
+		lastId = last
+	}
+	if len(ranges) == 0 {
+		return 0, nil
+	}
+	if w != nil {
+		if err := w.Close(); err != nil {
+			return 0, err
+		}
+	}
+
+	var n int64
+	for _, r := range ranges {
+		result := db.Unscoped().
+			Where("id BETWEEN ? AND ? AND created_at < ?", r[0], r[1], before).
+			Delete(model)
+		if result.Error != nil {
+			return n, errors.WithStack(result.Error)
+		}
+		n += result.RowsAffected
+	}
+	return n, nil
+}
+
+// archiveWriter 先写入临时文件，Close 成功后才重命名为正式的归档文件
+type archiveWriter struct {
+	path string
+	file *os.File
+	gz   *gzip.Writer
+	buf  *bufio.Writer
+	enc  *json.Encoder
+	done bool
+}
+
+func newArchiveWriter(path string) (*archiveWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file, err := os.OpenFile(path+".part", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	gz := gzip.NewWriter(file)
+	buf := bufio.NewWriter(gz)
+	return &archiveWriter{path: path, file: file, gz: gz, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+// Write 每条记录写入一行json
+func (w *archiveWriter) Write(v any) error {
+	return errors.WithStack(w.enc.Encode(v))
+}
+
+func (w *archiveWriter) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := w.gz.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := w.file.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(w.path+".part", w.path); err != nil {
+		return errors.WithStack(err)
+	}
+	w.done = true
+	return nil
+}
+
+// Abort 没有成功 Close 时删除临时文件
+func (w *archiveWriter) Abort() {
+	if w.done {
+		return
+	}
+	w.file.Close()
+	os.Remove(w.path + ".part")
+}
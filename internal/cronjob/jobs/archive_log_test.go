@@ -0,0 +1,154 @@
+package jobs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"go-file-server/internal/services/admin/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newArchiveTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内存数据库每个连接是独立的库
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.SysLoginLog{}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		row := models.SysLoginLog{Username: fmt.Sprintf("old%d", i)}
+		row.CreatedAt = now.AddDate(0, 0, -10)
+		if err := db.Create(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.Create(&models.SysLoginLog{Username: fmt.Sprintf("new%d", i)}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func loginLogBounds(b []models.SysLoginLog) (uint, uint) {
+	return b[0].ID, b[len(b)-1].ID
+}
+
+func countLoginLogs(t *testing.T, db *gorm.DB) int64 {
+	var n int64
+	if err := db.Unscoped().Model(&models.SysLoginLog{}).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// countLines 读取归档文件的行数
+func countLines(t *testing.T, path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for s := bufio.NewScanner(gz); s.Scan(); n++ {
+	}
+	return n
+}
+
+func TestArchiveLogs(t *testing.T) {
+	db := newArchiveTestDB(t)
+	dir := t.TempDir()
+
+	// 删除前归档文件已经重命名为正式文件并写入了全部记录
+	var archived []string
+	err := db.Callback().Delete().Before("gorm:delete").Register("test:archived", func(tx *gorm.DB) {
+		archived, _ = filepath.Glob(filepath.Join(dir, "*.jsonl.gz"))
+		if parts, _ := filepath.Glob(filepath.Join(dir, "*.part")); len(parts) > 0 {
+			t.Errorf("删除记录时临时文件还存在: %v", parts)
+		}
+		if len(archived) == 1 && countLines(t, archived[0]) != 5 {
+			t.Error("删除记录时归档文件没有写入全部记录")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := archiveLogs(db, &models.SysLoginLog{}, time.Now().AddDate(0, 0, -1), dir, loginLogBounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Errorf("删除了 %d 条, want 5", n)
+	}
+	if len(archived) != 1 {
+		t.Fatalf("删除记录时的归档文件 %v, want 1个", archived)
+	}
+	if got := countLoginLogs(t, db); got != 2 {
+		t.Errorf("剩余 %d 条, want 2", got)
+	}
+}
+
+func TestArchiveLogsWriteFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, dir string) string
+	}{
+		{"无法创建归档目录", func(t *testing.T, dir string) string {
+			file := filepath.Join(dir, "file")
+			if err := os.WriteFile(file, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			return filepath.Join(file, "archive")
+		}},
+		{"无法重命名归档文件", func(t *testing.T, dir string) string {
+			// 正式文件的路径被非空目录占用
+			now := time.Now()
+			for i := 0; i < 3; i++ {
+				name := fmt.Sprintf("%s-%s.jsonl.gz", (&models.SysLoginLog{}).TableName(),
+					now.Add(time.Duration(i)*time.Second).Format("20060102-150405"))
+				if err := os.MkdirAll(filepath.Join(dir, name, "x"), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return dir
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newArchiveTestDB(t)
+			dir := tt.setup(t, t.TempDir())
+			n, err := archiveLogs(db, &models.SysLoginLog{}, time.Now().AddDate(0, 0, -1), dir, loginLogBounds)
+			if err == nil {
+				t.Fatal("archiveLogs() 没有返回错误")
+			}
+			if n != 0 {
+				t.Errorf("删除了 %d 条, want 0", n)
+			}
+			if got := countLoginLogs(t, db); got != 7 {
+				t.Errorf("剩余 %d 条, want 7", got)
+			}
+			if parts, _ := filepath.Glob(filepath.Join(dir, "*.part")); len(parts) > 0 {
+				t.Errorf("临时文件没有删除: %v", parts)
+			}
+		})
+	}
+}
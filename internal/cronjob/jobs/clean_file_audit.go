@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"go-file-server/internal/common/repository"
+	"go-file-server/pkgs/zlog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultAuditRetentionDays 未配置时文件审计记录的保留天数
+const DefaultAuditRetentionDays = 180
+
+// FileAuditCleaner 删除超出保留天数的文件审计记录
+type FileAuditCleaner struct {
+	auditRepo *repository.FileAuditRepository
+	days      int
+}
+
+func NewFileAuditCleaner(db *gorm.DB, days int) *FileAuditCleaner {
+	return &FileAuditCleaner{
+		auditRepo: repository.NewFileAuditRepository(db),
+		days:      days,
+	}
+}
+
+func (c *FileAuditCleaner) Run() {
+	before := time.Now().AddDate(0, 0, -c.days)
+	n, err := c.auditRepo.DeleteBefore(before)
+	if err != nil {
+		zlog.SugLog.Errorf("清理文件审计记录失败: %v", err)
+		return
+	}
+	zlog.SugLog.Infof("清理了 %d 条 %s 之前的文件审计记录", n, before.Format(time.DateTime))
+}
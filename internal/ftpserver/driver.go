@@ -0,0 +1,147 @@
+package ftpserver
+
+import (
+	fsApi "go-file-server/internal/services/admin/apis/fs"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/apis/quota"
+	"go-file-server/internal/services/admin/apis/webhook"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/session"
+	"io/fs"
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+// clientDriver 每个ftp连接独立的驱动，FileServerFs 在同一用户的多个连接间共享，
+// 连接级别的状态(传输进度)记录在 session 中
+type clientDriver struct {
+	*FileServerFs
+	session      *session.Session
+	sessions     *session.Manager
+	quotaManager *quota.Manager
+	audit        *audit.Recorder
+	webhooks     *webhook.Dispatcher
+	// reject 超出并发限制时回复421并关闭控制连接
+	reject func(err error)
+}
+
+// auditEntry 当前连接的审计记录
+func (d *clientDriver) auditEntry(action, name string) models.SysFileAudit {
+	return models.SysFileAudit{
+		UserId:   d.userId,
+		Actor:    d.user,
+		Protocol: audit.ProtocolFtp,
+		Action:   action,
+		Path:     d.auditPath(name),
+		ClientIp: d.session.Ip,
+	}
+}
+
+// emit 操作成功后触发webhook，路径转换为虚拟路径，与web接口触发的事件一致
+func (d *clientDriver) emit(event, name string, data webhook.EventData) {
+	data.Path = utils.GetVirtualPath(d.auditPath(name))
+	data.Actor = d.user
+	data.ClientIp = d.session.Ip
+	d.webhooks.Emit(event, data)
+}
+
+func (d *clientDriver) OpenFile(name string, flag int, perm fs.FileMode) (afero.File, error) {
+	direction := quota.Download
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE) != 0 {
+		direction = quota.Upload
+	}
+	// 只在传输开始时检查配额，传输过程中超出的部分仍然计入用量
+	if err := d.quotaManager.Check(d.userId, d.roleKey, direction); err != nil {
+		return nil, err
+	}
+	limits, err := fsApi.GetRoleLimits(d.roleKey, d.cache, d.roleRepo)
+	if err != nil {
+		return nil, err
+	}
+	// 会话已经占用了ip连接数，这里不再重复计算
+	release, err := d.sessions.AcquireTransfer(d.userId, d.user, "", limits)
+	if err != nil {
+		d.reject(err)
+		return nil, err
+	}
+	action := audit.ActionDownload
+	if direction == quota.Upload {
+		action = audit.ActionUpload
+	}
+	counter := d.quotaManager.NewCounter(d.userId, direction)
+	// n 当前文件传输的字节数
+	var n int64
+	file, err := d.FileServerFs.openFile(name, flag, perm, func(size int) {
+		n += int64(size)
+		d.session.AddBytes(size)
+		counter.Add(size)
+	})
+	if err != nil {
+		release()
+		d.audit.Record(d.auditEntry(action, name), err)
+		return nil, err
+	}
+	nf, ok := file.(*File)
+	if !ok {
+		release()
+		return file, nil
+	}
+	d.session.StartTransfer(name)
+	onClose := nf.onClose
+	nf.onClose = func() {
+		if onClose != nil {
+			onClose()
+		}
+		counter.Flush()
+		d.session.EndTransfer()
+		release()
+		entry := d.auditEntry(action, name)
+		entry.Bytes = n
+		d.audit.Record(entry, nf.transferErr)
+		if direction == quota.Upload && nf.transferErr == nil {
+			d.emit(webhook.EventFileUploaded, name, webhook.EventData{Size: n})
+		}
+	}
+	return nf, nil
+}
+
+func (d *clientDriver) Mkdir(name string, perm fs.FileMode) error {
+	err := d.FileServerFs.Mkdir(name, perm)
+	d.audit.Record(d.auditEntry(audit.ActionMkdir, name), err)
+	return err
+}
+
+func (d *clientDriver) Remove(name string) error {
+	entry := d.auditEntry(audit.ActionDelete, name)
+	err := d.FileServerFs.Remove(name)
+	d.audit.Record(entry, err)
+	if err == nil {
+		d.emit(webhook.EventFileDeleted, name, webhook.EventData{})
+	}
+	return err
+}
+
+func (d *clientDriver) RemoveAll(name string) error {
+	entry := d.auditEntry(audit.ActionDelete, name)
+	err := d.FileServerFs.RemoveAll(name)
+	d.audit.Record(entry, err)
+	if err == nil {
+		d.emit(webhook.EventFileDeleted, name, webhook.EventData{})
+	}
+	return err
+}
+
+func (d *clientDriver) Rename(oldname, newname string) error {
+	entry := d.auditEntry(audit.ActionRename, oldname)
+	entry.Dest = d.auditPath(newname)
+	err := d.FileServerFs.Rename(oldname, newname)
+	d.audit.Record(entry, err)
+	if err == nil {
+		d.emit(webhook.EventFileMoved, newname, webhook.EventData{
+			OldPath: utils.GetVirtualPath(d.auditPath(oldname)),
+		})
+	}
+	return err
+}
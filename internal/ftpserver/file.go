@@ -1,8 +1,14 @@
 package ftpserver
 
 import (
+	"fmt"
+	"go-file-server/internal/services/admin/apis/quota"
 	"io"
 	"os"
+
+	"github.com/pkg/errors"
+
+	serverlib "github.com/fclairamb/ftpserverlib"
 )
 
 type FileInfo struct {
@@ -17,6 +23,21 @@ func (f *FileInfo) Name() string {
 type File struct {
 	*os.File
 	io.ReadWriter
+	onClose func()
+	// transferErr 传输出错的原因，关闭时用于审计记录
+	transferErr error
+}
+
+// TransferError ftpserverlib 在传输出错时调用，之后仍会调用 Close
+func (f *File) TransferError(err error) {
+	f.transferErr = err
+}
+
+func (f *File) Close() error {
+	if f.onClose != nil {
+		f.onClose()
+	}
+	return f.File.Close()
 }
 
 func (f *File) Write(b []byte) (n int, err error) {
@@ -30,3 +51,30 @@ func (f *File) Read(b []byte) (n int, err error) {
 func (f *File) ReadFrom(r io.Reader) (n int64, err error) {
 	return io.Copy(f.ReadWriter, r)
 }
+
+// storageReadWriter 写入超出剩余存储配额时中止传输，ftp客户端收到552
+type storageReadWriter struct {
+	io.ReadWriter
+	remaining int64
+}
+
+func (s *storageReadWriter) Write(b []byte) (n int, err error) {
+	if int64(len(b)) > s.remaining {
+		return 0, storageExceededErr()
+	}
+	n, err = s.ReadWriter.Write(b)
+	s.remaining -= int64(n)
+	return
+}
+
+func storageExceededErr() error {
+	return wrapStorageErr(quota.ErrStorageExceeded)
+}
+
+// wrapStorageErr 超出存储配额的错误需要包装 serverlib.ErrStorageExceeded，ftpserverlib 才会返回552
+func wrapStorageErr(err error) error {
+	if errors.Is(err, quota.ErrStorageExceeded) {
+		return fmt.Errorf("%w: %w", serverlib.ErrStorageExceeded, err)
+	}
+	return err
+}
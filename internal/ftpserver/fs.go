@@ -2,12 +2,12 @@ package ftpserver
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
 	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/common/repository"
 	fsApi "go-file-server/internal/services/admin/apis/fs"
 	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/quota"
 	"go-file-server/internal/services/admin/apis/role"
 	"go-file-server/internal/services/admin/models"
 	"go-file-server/pkgs/cache"
@@ -34,12 +34,17 @@ const (
 type FileServerFs struct {
 	token          string
 	user           string
+	userId         int
+	personalToken  bool
 	roleKey        string
 	cache          cache.AdapterCache
 	roleRepo       *repository.RoleRepository
 	fsRepo         *repository.FsRepository
+	fsAliasRepo    *repository.RoleFsAliasRepository
 	casbinEnforcer *casbin.CachedEnforcer
 	limiterManager *utils.LimiterManager
+	storageManager *quota.StorageManager
+	actors         *utils.ActorTracker
 }
 
 func (f *FileServerFs) VerifPath(name string, action string) (string, error) {
@@ -47,8 +52,7 @@ func (f *FileServerFs) VerifPath(name string, action string) (string, error) {
 		return utils.GetRealPath(name)
 	}
 
-	homePath, err := decryptPath(name)
-
+	homePath, err := f.resolvePath(name)
 	if err != nil {
 		return "", err
 	}
@@ -72,14 +76,59 @@ func (f *FileServerFs) VerifPath(name string, action string) (string, error) {
 	return utils.GetRealPath(homePath)
 }
 
-func finalVisualPath(path, decryptPath string) string {
-	if path == decryptPath {
-		return path
+// verifWritePath 校验权限，并拒绝只读挂载点中的写操作
+func (f *FileServerFs) verifWritePath(name string, action string) (string, error) {
+	path, err := f.VerifPath(name, action)
+	if err != nil {
+		return "", err
+	}
+	if err := utils.AssertWritable(path); err != nil {
+		return "", err
+	}
+	f.actors.Mark(path, f.user)
+	return path, nil
+}
+
+// resolvePath 将虚拟目录名称开头的路径转换为真实的虚拟路径，
+// 角色拥有根目录权限时，根目录直接展示，不使用虚拟目录名称
+func (f *FileServerFs) resolvePath(name string) (string, error) {
+	roots, err := f.getFsRoots()
+	if err != nil {
+		return "", err
+	}
+	for _, root := range roots {
+		if root.Path == "/" {
+			return name, nil
+		}
+	}
+	homePath, _ := role.ResolveFsAliasPath(roots, name)
+	return homePath, nil
+}
+
+// auditPath 审计记录使用的真实路径，无法转换时保留ftp中的路径
+func (f *FileServerFs) auditPath(name string) string {
+	homePath := name
+	if f.roleKey != models.AdminRoleKey {
+		if p, err := f.resolvePath(name); err == nil {
+			homePath = p
+		}
+	}
+	realPath, err := utils.GetRealPath(homePath)
+	if err != nil {
+		return name
 	}
-	if !strings.HasPrefix(decryptPath, "/") {
+	return realPath
+}
+
+func (f *FileServerFs) getFsRoots() ([]role.FsRoot, error) {
+	return role.GetFsRoots(f.roleKey, f.casbinEnforcer, f.cache, f.fsAliasRepo)
+}
+
+func finalVisualPath(path, realPath string) string {
+	if path == realPath {
 		return path
 	}
-	return fmt.Sprintf("%s(%s)", path, decryptPath)
+	return fmt.Sprintf("%s(%s)", path, realPath)
 }
 
 // func parsedActionDescription(action string) string {
@@ -98,7 +147,7 @@ func finalVisualPath(path, decryptPath string) string {
 // }
 
 func (f *FileServerFs) Chmod(name string, mode fs.FileMode) error {
-	path, err := f.VerifPath(name, Update)
+	path, err := f.verifWritePath(name, Update)
 	if err != nil {
 		return err
 	}
@@ -106,7 +155,7 @@ func (f *FileServerFs) Chmod(name string, mode fs.FileMode) error {
 }
 
 func (f *FileServerFs) Chown(name string, uid int, gid int) error {
-	path, err := f.VerifPath(name, Update)
+	path, err := f.verifWritePath(name, Update)
 	if err != nil {
 		return err
 	}
@@ -115,7 +164,7 @@ func (f *FileServerFs) Chown(name string, uid int, gid int) error {
 }
 
 func (f *FileServerFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
-	path, err := f.VerifPath(name, Update)
+	path, err := f.verifWritePath(name, Update)
 	if err != nil {
 		return err
 	}
@@ -124,7 +173,7 @@ func (f *FileServerFs) Chtimes(name string, atime time.Time, mtime time.Time) er
 }
 
 func (f *FileServerFs) Create(name string) (afero.File, error) {
-	path, err := f.VerifPath(name, Write)
+	path, err := f.verifWritePath(name, Write)
 	if err != nil {
 		return nil, err
 	}
@@ -134,7 +183,7 @@ func (f *FileServerFs) Create(name string) (afero.File, error) {
 // f.Mkdir 内部 CreateDir 会调用 os.MkdirAll
 // 对于多层级路径创建，外部会逐层调用 f.Mkdir
 func (f *FileServerFs) Mkdir(name string, perm fs.FileMode) error {
-	path, err := f.VerifPath(name, Write)
+	path, err := f.verifWritePath(name, Write)
 	if err != nil {
 		return err
 	}
@@ -142,7 +191,7 @@ func (f *FileServerFs) Mkdir(name string, perm fs.FileMode) error {
 }
 
 func (f *FileServerFs) MkdirAll(path string, perm fs.FileMode) error {
-	path, err := f.VerifPath(path, Write)
+	path, err := f.verifWritePath(path, Write)
 	if err != nil {
 		return err
 	}
@@ -191,25 +240,62 @@ func (f *FileServerFs) getLimiter() (*limiter.Limiter, error) {
 }
 
 func (f *FileServerFs) OpenFile(name string, flag int, perm fs.FileMode) (afero.File, error) {
+	return f.openFile(name, flag, perm, nil)
+}
+
+// openFile counter 不为空时通过限速器的 WithCounter 统计实际传输的字节数
+func (f *FileServerFs) openFile(name string, flag int, perm fs.FileMode, counter func(n int)) (afero.File, error) {
 
 	path, err := f.VerifPath(name, Read)
 	if err != nil {
 		return nil, err
 	}
+	write := flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0
+	remaining := int64(-1)
+	if write {
+		if err := utils.AssertWritable(path); err != nil {
+			return nil, err
+		}
+		if remaining, err = f.storageManager.Remaining(f.roleKey, path); err != nil {
+			return nil, err
+		}
+		if remaining == 0 {
+			return nil, storageExceededErr()
+		}
+	}
 
 	raleLimiter, err := f.getLimiter()
 	if err != nil {
 		return nil, err
 	}
+	release := func() {}
+	if write {
+		// 上传期间监听到的文件变化都记录为当前用户的操作
+		release = f.actors.Hold(path, f.user)
+	}
 
 	file, err := f.fsRepo.OpenFile(path, flag, perm)
 	if err != nil {
+		release()
 		return nil, err
 	}
 
 	nf := &File{
 		File:       file,
-		ReadWriter: raleLimiter.LimitReadertWriter(context.Background(), file),
+		ReadWriter: raleLimiter.LimitReadertWriter(context.Background(), file, limiter.WithCounter(counter)),
+	}
+	if write {
+		if remaining > 0 {
+			nf.ReadWriter = &storageReadWriter{ReadWriter: nf.ReadWriter, remaining: remaining}
+		}
+		// 写入完成后更新索引中的文件大小
+		nf.onClose = func() {
+			if err := f.fsRepo.AddResource(path); err != nil {
+				zlog.SugLog.Error(err)
+			}
+			f.storageManager.ResetUsage(path)
+			release()
+		}
 	}
 
 	return nf, nil
@@ -230,13 +316,17 @@ func (f *FileServerFs) execRemove(path string, removeFunc func(string) error) er
 	if err != nil {
 		return err
 	}
+	if err := utils.AssertRemovable(realPath); err != nil {
+		return err
+	}
+	f.actors.Mark(realPath, f.user)
 
 	// 直接删除
-	if strings.HasPrefix(path, "/.tmp") {
+	if utils.IsTrashPath(realPath) {
 		return removeFunc(realPath)
 	}
 
-	tmpDir, err := f.ensureTempDir()
+	tmpDir, err := f.ensureTrashDir(realPath)
 	if err != nil {
 		return err
 	}
@@ -257,6 +347,18 @@ func (f *FileServerFs) Rename(oldname string, newname string) error {
 	if err != nil {
 		return err
 	}
+	if err := utils.AssertRemovable(oldname); err != nil {
+		return err
+	}
+	if err := utils.AssertWritable(newname); err != nil {
+		return err
+	}
+	if err := f.storageManager.CheckMove(f.roleKey, oldname, newname); err != nil {
+		return wrapStorageErr(err)
+	}
+	defer f.storageManager.ResetUsage(oldname, newname)
+	f.actors.Mark(oldname, f.user)
+	f.actors.Mark(newname, f.user)
 	return f.fsRepo.Rename(oldname, newname)
 
 }
@@ -269,6 +371,14 @@ func (f *FileServerFs) Stat(name string) (fs.FileInfo, error) {
 	return os.Stat(path)
 }
 
+// ensureTrashDir 挂载点配置了回收站时使用挂载点的回收站
+func (f *FileServerFs) ensureTrashDir(realPath string) (string, error) {
+	if trashDir, ok := utils.GetTrashDir(realPath, f.roleKey); ok {
+		return trashDir, f.fsRepo.MkdirAll(trashDir, os.ModePerm)
+	}
+	return f.ensureTempDir()
+}
+
 func (f *FileServerFs) ensureTempDir() (string, error) {
 	tempPath, err := fsApi.EnsureTempDir(f.roleKey)
 	if err != nil {
@@ -295,84 +405,65 @@ func (f *FileServerFs) listPath(path string) ([]os.FileInfo, error) {
 		return nil, err
 	}
 
+	if path == "/" {
+		files = append(files, mountFileInfos()...)
+	}
 	return files, nil
 }
 
+// mountFileInfos 根目录下展示的挂载点
+func mountFileInfos() []os.FileInfo {
+	var files []os.FileInfo
+	for _, m := range utils.GetMounts() {
+		fileInfo, err := os.Stat(m.Path)
+		if err != nil {
+			zlog.SugLog.Error(err)
+			continue
+		}
+		files = append(files, &FileInfo{
+			FileInfo: fileInfo,
+			fullName: m.Name,
+		})
+	}
+	return files
+}
+
 func (f *FileServerFs) listNormalPath() ([]os.FileInfo, error) {
 	var files []os.FileInfo
-	policies := f.casbinEnforcer.GetFilteredPolicy(0, f.roleKey, "", "GET", "fs")
-	if len(policies) == 0 {
+	roots, err := f.getFsRoots()
+	if err != nil {
+		return nil, err
+	}
+	if len(roots) == 0 {
 		return files, nil
 		// 这里如果返回错误，ftpserverlib库捕获到错误会返回450状态码,
 		// 对于450状态码，lftp客户端一致重试
 		// return files, errors.Errorf("无任何目录权限，请联系管理员赋权")
 	}
-	for _, p := range policies {
-
-		fsPath := role.ParseFsRolepath(p[1])
-		if fsPath == "/" {
+	for _, root := range roots {
+		if root.Path == "/" {
 			return f.listPath("/")
 		}
-		if strings.HasPrefix(fsPath, ".tmp") {
+		if strings.HasPrefix(root.Path, ".tmp") {
 			_, err := f.ensureTempDir()
 			if err != nil {
 				return nil, err
 			}
 		}
 
-		realPath, err := utils.GetRealPath(fsPath)
+		realPath, err := utils.GetRealPath(root.Path)
 		if err != nil {
 			return nil, err
 		}
 		fileInfo, err := os.Stat(realPath)
 		if err != nil {
-			return nil, err
+			zlog.SugLog.Error(err)
+			continue
 		}
 		files = append(files, &FileInfo{
 			FileInfo: fileInfo,
-			fullName: encryptPath(fsPath),
+			fullName: root.Name,
 		})
 	}
 	return files, nil
 }
-
-// 加密逻辑：Base64URL编码路径的前面部分，最后一段保留
-func encryptPath(path string) string {
-	path = filepath.Clean(path)
-	parts := strings.Split(path, "/")
-	if len(parts) < 2 {
-		return path
-	}
-	lastPart := parts[len(parts)-1]
-	remainingPath := strings.Join(parts[:len(parts)-1], "/")
-	encoded := base64.URLEncoding.EncodeToString([]byte(remainingPath))
-	encoded = strings.TrimRight(encoded, "=")
-	return encoded + "-" + lastPart
-}
-
-// 解密逻辑：还原加密路径
-func decryptPath(encryptedPath string) (string, error) {
-
-	encryptedParts := strings.SplitN(encryptedPath, "/", 3)
-	if len(encryptedParts) < 2 {
-		return encryptedPath, nil
-	}
-
-	encryptedStr := encryptedParts[1]
-
-	// 根据 "-" 拆分出加密部分和最后一段
-	parts := strings.Split(encryptedStr, "-")
-	if len(parts) < 2 {
-		return encryptedPath, nil
-	}
-
-	decodedBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
-	if err != nil {
-		return "", fmt.Errorf("error decoding Base64URL: %v", err)
-	}
-	parts[0] = string(decodedBytes)
-	if len(encryptedParts) > 2 {
-		parts = append(parts, encryptedParts[2:]...)
-	}
-	return filepath.Join(parts...), nil
-}
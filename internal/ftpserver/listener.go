@@ -0,0 +1,58 @@
+package ftpserver
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	serverlib "github.com/fclairamb/ftpserverlib"
+)
+
+// controlListener 记录控制连接。ftpserverlib 认证失败时固定回复530，打开文件失败时回复550，
+// 超出并发限制时需要直接在控制连接上回复421
+type controlListener struct {
+	net.Listener
+	conns sync.Map
+}
+
+type controlConn struct {
+	net.Conn
+	listener *controlListener
+	once     sync.Once
+}
+
+func newControlListener(l net.Listener) *controlListener {
+	return &controlListener{Listener: l}
+}
+
+func (l *controlListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	c := &controlConn{Conn: conn, listener: l}
+	l.conns.Store(conn.RemoteAddr().String(), c)
+	return c, nil
+}
+
+// reject 回复421并关闭控制连接，ftpserverlib 之后的回复写入已关闭的连接时失败，
+// 客户端只会收到421
+func (l *controlListener) reject(cc serverlib.ClientContext, msg string) {
+	data, ok := l.conns.Load(cc.RemoteAddr().String())
+	if !ok {
+		cc.Close()
+		return
+	}
+	c := data.(*controlConn)
+	fmt.Fprintf(c.Conn, "%d %s\r\n", serverlib.StatusServiceNotAvailable, msg)
+	c.Close()
+}
+
+func (c *controlConn) Close() error {
+	var err error
+	c.once.Do(func() {
+		c.listener.conns.Delete(c.RemoteAddr().String())
+		err = c.Conn.Close()
+	})
+	return err
+}
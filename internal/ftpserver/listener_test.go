@@ -0,0 +1,48 @@
+package ftpserver
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	serverlib "github.com/fclairamb/ftpserverlib"
+)
+
+type testClientContext struct {
+	serverlib.ClientContext
+	addr net.Addr
+}
+
+func (c testClientContext) RemoteAddr() net.Addr { return c.addr }
+
+func TestControlListenerReject(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := newControlListener(l)
+	defer listener.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener.reject(testClientContext{addr: conn.RemoteAddr()}, "会话数超出限制")
+	// ftpserverlib 之后的回复写入失败，不会发送到客户端
+	if _, err := conn.Write([]byte("530 Authentication error\r\n")); err == nil {
+		t.Error("关闭后仍然可以写入控制连接")
+	}
+	data, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "421 会话数超出限制\r\n"; string(data) != want {
+		t.Errorf("收到 %q, want %q", data, want)
+	}
+}
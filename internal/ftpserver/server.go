@@ -3,13 +3,24 @@ package ftpserver
 import (
 	"crypto/tls"
 	"fmt"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/loginguard"
 	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/common/repository"
+	"go-file-server/internal/common/twofactor"
 	"go-file-server/internal/common/types"
+	fsApi "go-file-server/internal/services/admin/apis/fs"
 	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/apis/quota"
+	"go-file-server/internal/services/admin/apis/webhook"
 	"go-file-server/internal/services/admin/models"
 	"go-file-server/internal/services/normal/apis/auth"
+	"go-file-server/pkgs/auditlog"
 	"go-file-server/pkgs/cache"
+	"go-file-server/pkgs/session"
+	"net"
+	"strings"
 	"sync"
 	"time"
 
@@ -39,10 +50,21 @@ type Server struct {
 	roleRepo         *repository.RoleRepository
 	loginLogRepo     *repository.LoginLogRepository
 	fsRepo           *repository.FsRepository
+	fsAliasRepo      *repository.RoleFsAliasRepository
 	casbinEnforcer   *Casbin.CachedEnforcer
 	requestGroup     singleflight.Group
 	cache            cache.AdapterCache
 	limiterManager   *utils.LimiterManager
+	sessions         *session.Manager
+	actors           *utils.ActorTracker
+	quotaManager     *quota.Manager
+	storageManager   *quota.StorageManager
+	authenticator    *middlewares.Authenticator
+	listener         *controlListener
+	audit            *audit.Recorder
+	webhooks         *webhook.Dispatcher
+	twoFactor        *twofactor.Service
+	guard            *loginguard.Guard
 }
 
 // ErrTimeout is returned when an operation timeouts
@@ -91,9 +113,44 @@ func NewServer(svcCtx *types.SvcCtx, opts ...opt) (*Server, error) {
 		roleRepo:       repository.NewRoleRepository(svcCtx.Db),
 		loginLogRepo:   repository.NewLoginLogRepository(svcCtx.Db),
 		fsRepo:         repository.NewFsRepository(svcCtx.FsIndexer),
+		fsAliasRepo:    repository.NewRoleFsAliasRepository(svcCtx.Db),
 		casbinEnforcer: svcCtx.CasbinEnforcer,
 		cache:          svcCtx.Cache,
-		limiterManager: utils.NewLimiterManager(30*time.Minute, 30*time.Minute),
+		limiterManager: utils.NewLimiterManager(30*time.Minute, 30*time.Minute, utils.WithAdapterCache(svcCtx.Cache)),
+		sessions:       svcCtx.Sessions,
+		actors:         svcCtx.Actors,
+		audit:          audit.NewRecorder(repository.NewFileAuditRepository(svcCtx.Db)),
+		webhooks:       webhook.NewDispatcher(repository.NewWebhookRepository(svcCtx.Db)),
+		twoFactor: twofactor.NewService(
+			repository.NewUserTotpRepository(svcCtx.Db),
+			repository.NewRoleRepository(svcCtx.Db),
+			svcCtx.Cache,
+		),
+		authenticator: middlewares.NewAuthenticator(
+			repository.NewUserTokenRepository(svcCtx.Db),
+			svcCtx.Cache,
+		),
+		guard: loginguard.NewGuard(svcCtx.Cache, repository.NewLoginLogRepository(svcCtx.Db)),
+	}
+	server.quotaManager = quota.NewManager(
+		svcCtx.Cache,
+		server.roleRepo,
+		repository.NewUserQuotaRepository(svcCtx.Db),
+	)
+	server.storageManager = quota.NewStorageManager(
+		svcCtx.Cache,
+		server.roleRepo,
+		repository.NewStorageQuotaRepository(svcCtx.Db),
+		server.fsRepo,
+		svcCtx.CasbinEnforcer,
+	)
+	if server.sessions == nil {
+		server.sessions = session.NewManager()
+	}
+	server.sessions.OnInvalidate(server.invalidateUser)
+	server.webhooks.Run()
+	if server.actors == nil {
+		server.actors = utils.NewActorTracker()
 	}
 
 	for _, x := range opts {
@@ -107,7 +164,15 @@ func NewServer(svcCtx *types.SvcCtx, opts ...opt) (*Server, error) {
 
 // GetSettings returns some general settings around the server setup
 func (s *Server) GetSettings() (*serverlib.Settings, error) {
+	if s.listener == nil {
+		l, err := net.Listen("tcp", s.addr)
+		if err != nil {
+			return nil, err
+		}
+		s.listener = newControlListener(l)
+	}
 	return &serverlib.Settings{
+		Listener:                 s.listener,
 		ListenAddr:               s.addr,
 		PassiveTransferPortRange: s.passivePortRange,
 		PublicHost:               s.publicHost,
@@ -132,6 +197,7 @@ func (s *Server) ClientDisconnected(cc serverlib.ClientContext) {
 	defer s.nbClientsSync.Unlock()
 
 	s.nbClients--
+	s.sessions.Remove(genSessionId(cc))
 	s.logger.Infof(
 		"Client disconnected, clientId: %d, remoteAddr: %s, nbClients: %d.",
 		cc.ID(), cc.RemoteAddr().String(), s.nbClients,
@@ -172,6 +238,89 @@ func genFtpserverKey(user, pass string) string {
 	return fmt.Sprintf("%s_%s_%s", ftpserverKey, user, pass)
 }
 
+func genSessionId(cc serverlib.ClientContext) string {
+	return fmt.Sprintf("%s-%d", session.ProtocolFtp, cc.ID())
+}
+
+// invalidateUser 用户被禁用或重置token时，清理缓存的登录状态
+func (s *Server) invalidateUser(userId int) {
+	for key, item := range s.session.Items() {
+		if fs, ok := item.Object.(*FileServerFs); ok && fs.userId == userId {
+			s.session.Delete(key)
+		}
+	}
+}
+
+// verifyPersonalToken 缓存的个人token会话需要重新校验token是否被撤销
+func (s *Server) verifyPersonalToken(fs *FileServerFs, pass string) bool {
+	if !fs.personalToken {
+		return true
+	}
+	_, err := s.authenticator.ValidateToken(pass)
+	return err == nil
+}
+
+// addClient 检查并发限制并记录在线会话，返回当前连接使用的驱动
+func (s *Server) addClient(cc serverlib.ClientContext, fs *FileServerFs) (serverlib.ClientDriver, error) {
+	limits, err := fsApi.GetRoleLimits(fs.roleKey, s.cache, s.roleRepo)
+	if err != nil {
+		return nil, err
+	}
+	sess := session.New(session.ProtocolFtp, fmt.Sprint(cc.ID()), cc.Close)
+	sess.UserId = fs.userId
+	sess.Username = fs.user
+	sess.Ip = remoteIp(cc.RemoteAddr())
+	err = s.sessions.AddWithLimits(sess, limits)
+	if err != nil {
+		s.rejectLimit(cc, err)
+		return nil, err
+	}
+	return &clientDriver{
+		FileServerFs: fs,
+		session:      sess,
+		sessions:     s.sessions,
+		quotaManager: s.quotaManager,
+		audit:        s.audit,
+		webhooks:     s.webhooks,
+		reject:       func(err error) { s.rejectLimit(cc, err) },
+	}, nil
+}
+
+// PreAuthUser 收到用户名后检查ip的连接数，超出限制时在校验密码前回复421
+func (s *Server) PreAuthUser(cc serverlib.ClientContext, user string) error {
+	userInfo, err := s.userRepo.FindOne(repository.WithUsername(user))
+	if err != nil {
+		// 用户不存在等情况在认证时处理
+		return nil
+	}
+	role, err := s.roleRepo.FindOne(repository.WithRoleId(userInfo.RoleId))
+	if err != nil {
+		return nil
+	}
+	limits, err := fsApi.GetRoleLimits(role.RoleKey, s.cache, s.roleRepo)
+	if err != nil {
+		return nil
+	}
+	err = s.sessions.CheckIpConns(remoteIp(cc.RemoteAddr()), limits)
+	s.rejectLimit(cc, err)
+	return err
+}
+
+// rejectLimit 超出并发限制时回复421并关闭控制连接，其他错误由 ftpserverlib 回复
+func (s *Server) rejectLimit(cc serverlib.ClientContext, err error) {
+	if session.IsLimitErr(err) && s.listener != nil {
+		s.listener.reject(cc, err.Error())
+	}
+}
+
+func remoteIp(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
 func (s *Server) getSession(key string) (*FileServerFs, bool) {
 	data, ok := s.session.Get(key)
 	if ok {
@@ -180,11 +329,65 @@ func (s *Server) getSession(key string) (*FileServerFs, bool) {
 	return nil, false
 }
 
-func (s *Server) createSession(key, user, pass string) (*FileServerFs, error) {
-	userInfo, err := auth.VerifyUser(s.userRepo, auth.LoginReq{
-		Username: user,
-		Password: pass,
+// isPersonalToken 密码是本服务签发的个人token时才按token认证，
+// 签名校验失败的jwt格式密码仍然按普通密码校验
+func isPersonalToken(pass string) bool {
+	if !strings.HasPrefix(pass, "eyJ") || strings.Count(pass, ".") != 2 {
+		return false
+	}
+	claims, err := middlewares.ParseToken(pass)
+	return err == nil && claims.IsPersonalToken
+}
+
+// verifyUser 校验ftp登录，密码为个人token时通过token认证，
+// 用于ldap/dex等没有本地密码的用户以及自动化脚本。
+// ftp 没有验证码，失败次数和锁定由 guard 限制
+func (s *Server) verifyUser(user, pass, ip string) (*models.SysUser, error) {
+	userInfo, err := s.guard.Verify(audit.ProtocolFtp, user, ip, func() (*models.SysUser, error) {
+		return s.verifyCredential(user, pass)
 	})
+	if err != nil || isPersonalToken(pass) {
+		return userInfo, err
+	}
+	// ftp 无法进行两步验证，启用或角色要求两步验证的用户只能使用个人token登录
+	needed, _, err := s.twoFactor.Needed(userInfo.UserId, userInfo.RoleId)
+	if err != nil {
+		return nil, errors.Errorf(global.ErrServerNotOK)
+	}
+	if needed {
+		return nil, errors.Errorf("已启用两步验证，请使用个人token作为密码登录")
+	}
+	return userInfo, nil
+}
+
+func (s *Server) verifyCredential(user, pass string) (*models.SysUser, error) {
+	if !isPersonalToken(pass) {
+		return auth.VerifyUser(s.userRepo, auth.LoginReq{
+			Username: user,
+			Password: pass,
+		})
+	}
+	jwtClaims, err := s.authenticator.ValidateToken(pass)
+	if err != nil {
+		return nil, err
+	}
+	if !jwtClaims.IsPersonalToken || jwtClaims.Username != user {
+		return nil, errors.Errorf(global.ErrFailedAuthentication)
+	}
+	userInfo, err := s.userRepo.FindOne(
+		repository.WithUserId(jwtClaims.UserId),
+		repository.WithUserStatus(models.UserStatusNormal))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.Errorf(global.ErrFailedAuthentication)
+		}
+		return nil, errors.Errorf(global.ErrServerNotOK)
+	}
+	return userInfo, nil
+}
+
+func (s *Server) createSession(key, user, pass, ip string) (*FileServerFs, error) {
+	userInfo, err := s.verifyUser(user, pass, ip)
 	if err != nil {
 		return nil, err
 	}
@@ -213,12 +416,17 @@ func (s *Server) createSession(key, user, pass string) (*FileServerFs, error) {
 	fileServerFs := &FileServerFs{
 		token:          token,
 		user:           user,
+		userId:         userInfo.UserId,
+		personalToken:  isPersonalToken(pass),
 		roleKey:        role.RoleKey,
 		fsRepo:         s.fsRepo,
+		fsAliasRepo:    s.fsAliasRepo,
 		roleRepo:       s.roleRepo,
 		casbinEnforcer: s.casbinEnforcer,
 		cache:          s.cache,
 		limiterManager: s.limiterManager,
+		storageManager: s.storageManager,
+		actors:         s.actors,
 	}
 	s.session.Set(key, fileServerFs, 0)
 	return fileServerFs, nil
@@ -229,6 +437,12 @@ func (s *Server) AuthUser(cc serverlib.ClientContext, user, pass string) (server
 	key := genFtpserverKey(user, pass)
 	session, ok := s.getSession(key)
 
+	// 缓存的登录不经过 guard 校验，用户名或ip被锁定后清理该用户缓存的登录，
+	// 按正常登录返回锁定的错误并记录日志
+	if ok && s.guard.Locked(user, remoteIp(cc.RemoteAddr())) != nil {
+		s.invalidateUser(session.userId)
+		ok = false
+	}
 	if ok {
 		jwtClaims, err := middlewares.ParseToken(session.token)
 		if err != nil {
@@ -239,11 +453,16 @@ func (s *Server) AuthUser(cc serverlib.ClientContext, user, pass string) (server
 		if err != nil {
 			return nil, err
 		}
-		if jwtClaims.IssuedAt > lastTokenReset {
-			return session, nil
+		if jwtClaims.IssuedAt > lastTokenReset && s.verifyPersonalToken(session, pass) {
+			return s.addClient(cc, session)
 		}
+		s.session.Delete(key)
 	}
 
+	msg := "ftp"
+	if isPersonalToken(pass) {
+		msg = "ftp personal token"
+	}
 	result, err := s.requestGroup.Do(key, func() (any interface{}, err error) {
 		var status string = "1"
 		defer func() {
@@ -254,15 +473,30 @@ func (s *Server) AuthUser(cc serverlib.ClientContext, user, pass string) (server
 			go s.loginLogRepo.Create(&models.SysLoginLog{
 				Username: user,
 				Remark:   "ftp",
-				Msg:      "ftp",
+				Msg:      msg,
 				Ipaddr:   cc.RemoteAddr().String(),
 				Status:   status,
 			})
+			e := auditlog.Event{
+				Category: auditlog.CategoryAuth,
+				Action:   auditlog.ActionLogin,
+				Actor:    user,
+				Protocol: audit.ProtocolFtp,
+				ClientIp: remoteIp(cc.RemoteAddr()),
+				Details:  map[string]interface{}{"personalToken": isPersonalToken(pass)},
+			}.WithErr(err)
+			if fs, ok := any.(*FileServerFs); ok {
+				e.ActorId = fs.userId
+			}
+			auditlog.Emit(e)
 		}()
-		return s.createSession(key, user, pass)
+		return s.createSession(key, user, pass, remoteIp(cc.RemoteAddr()))
 	})
 
-	return result.(*FileServerFs), err
+	if err != nil {
+		return nil, err
+	}
+	return s.addClient(cc, result.(*FileServerFs))
 }
 
 func (s *Server) GetTLSConfig() (*tls.Config, error) {
@@ -0,0 +1,50 @@
+package duplicate
+
+import (
+	"context"
+	"fmt"
+	"go-file-server/pkgs/cache"
+	"go-file-server/pkgs/pathtool"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// ChecksumKey 文件校验和的缓存key前缀
+	ChecksumKey = "FileChecksum"
+	// checksumExpiration 校验和缓存时间，文件大小或修改时间变化后缓存失效
+	checksumExpiration = 30 * 24 * time.Hour
+)
+
+func genChecksumKey(path string) string {
+	return fmt.Sprintf("%s:%s", ChecksumKey, path)
+}
+
+// checksumStamp 文件大小和修改时间，与缓存中记录的不一致时需要重新计算
+func checksumStamp(info os.FileInfo) string {
+	return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+}
+
+// GetChecksum 获取文件的sha256，优先使用缓存的校验和
+func GetChecksum(ctx context.Context, ch cache.AdapterCache, path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	stamp := checksumStamp(info)
+	key := genChecksumKey(path)
+	if data, err := ch.Get(key); err == nil {
+		if s, sum, ok := strings.Cut(data, "|"); ok && s == stamp {
+			return sum, nil
+		}
+	} else if !cache.IsKeyNotFoundError(err) {
+		return "", err
+	}
+
+	sum, err := pathtool.Sha256Sum(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	return sum, ch.Set(key, stamp+"|"+sum, checksumExpiration)
+}
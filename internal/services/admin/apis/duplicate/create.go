@@ -0,0 +1,91 @@
+package duplicate
+
+import (
+	"context"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/pathtool"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type CreateReq struct {
+	Path string `json:"path" binding:"required"`
+}
+
+type CreateRep struct {
+	Id string `json:"id"`
+}
+
+// Create 创建查找重复文件的后台任务，只查找调用者有查看权限的目录
+func (api *DuplicateApi) Create(c *gin.Context) {
+	var req CreateReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	claims := core.ExtractClaims(c)
+	path := filepath.Clean("/" + req.Path)
+	if err := api.checkViewPermission(claims.RoleKey, path); err != nil {
+		c.Error(err)
+		return
+	}
+	realPath, err := utils.GetRealPath(path)
+	if err != nil {
+		c.Error(core.NewApiBizErr(err).SetMsg(err.Error()))
+		return
+	}
+	isDir, err := pathtool.NewFiletool(realPath).AssertDir()
+	if err != nil || !isDir {
+		err = errors.Errorf("目录 %s 不存在", path)
+		c.Error(core.NewApiBizErr(err).SetBizCode(global.BizNotFound).SetMsg(err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &ScanJob{
+		Id:        strconv.FormatInt(time.Now().UnixNano(), 36),
+		Path:      path,
+		UserId:    claims.UserId,
+		Username:  claims.Username,
+		StartTime: time.Now(),
+		status:    ScanRunning,
+		cancel:    cancel,
+	}
+	api.addJob(job)
+	go api.runScan(ctx, job, realPath)
+	core.OKRep(CreateRep{Id: job.Id}).SendGin(c)
+}
+
+func (api *DuplicateApi) checkViewPermission(roleKey, path string) error {
+	return api.checkPermission(roleKey, path, "GET", "查看")
+}
+
+func (api *DuplicateApi) checkDeletePermission(roleKey, path string) error {
+	return api.checkPermission(roleKey, path, "DELETE", "删除")
+}
+
+// checkPermission 检查角色对路径是否有 /fs 接口对应method的权限，name 为错误信息中的权限名称
+func (api *DuplicateApi) checkPermission(roleKey, path, method, name string) error {
+	if roleKey == models.AdminRoleKey {
+		return nil
+	}
+	ok, err := api.casbinEnforcer.Enforce(roleKey, filepath.Join("/api/v1/fs", path), method)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !ok {
+		err = errors.Errorf("role: %s , path:%s, 无%s权限", roleKey, path, name)
+		return core.NewApiBizErr(err).
+			SetBizCode(global.BizAccessDenied).
+			SetMsg("您没有 " + path + " 的" + name + "权限")
+	}
+	return nil
+}
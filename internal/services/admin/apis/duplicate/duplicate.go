@@ -0,0 +1,72 @@
+package duplicate
+
+import (
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/apis/quota"
+	"go-file-server/internal/services/admin/apis/webhook"
+	"go-file-server/pkgs/cache"
+	"sync"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// jobRetention 已结束的任务保留时间
+const jobRetention = 24 * time.Hour
+
+type DuplicateApi struct {
+	fsRepo         *repository.FsRepository
+	casbinEnforcer *casbin.CachedEnforcer
+	cache          cache.AdapterCache
+	audit          *audit.Recorder
+	webhooks       *webhook.Dispatcher
+	storageManager *quota.StorageManager
+	// jobs 查找重复文件的后台任务
+	jobs  map[string]*ScanJob
+	mutex sync.RWMutex
+}
+
+func NewDuplicateApi(
+	fsRepo *repository.FsRepository,
+	casbinEnforcer *casbin.CachedEnforcer,
+	cache cache.AdapterCache,
+	audit *audit.Recorder,
+	webhooks *webhook.Dispatcher,
+	storageManager *quota.StorageManager,
+) *DuplicateApi {
+	return &DuplicateApi{
+		fsRepo:         fsRepo,
+		casbinEnforcer: casbinEnforcer,
+		cache:          cache,
+		audit:          audit,
+		webhooks:       webhooks,
+		storageManager: storageManager,
+		jobs:           make(map[string]*ScanJob),
+	}
+}
+
+func (api *DuplicateApi) addJob(job *ScanJob) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	for id, j := range api.jobs {
+		info := j.Info()
+		if info.Status != ScanRunning && time.Since(info.EndTime) > jobRetention {
+			delete(api.jobs, id)
+		}
+	}
+	api.jobs[job.Id] = job
+}
+
+func (api *DuplicateApi) getJob(id string) (*ScanJob, bool) {
+	api.mutex.RLock()
+	defer api.mutex.RUnlock()
+	job, ok := api.jobs[id]
+	return job, ok
+}
+
+func (api *DuplicateApi) removeJob(id string) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	delete(api.jobs, id)
+}
@@ -0,0 +1,113 @@
+package duplicate
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/services/admin/models"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type GetRep struct {
+	Count int           `json:"count"`
+	Items []ScanJobInfo `json:"items"`
+}
+
+// Get 查询任务列表，管理员可以查看全部任务
+func (api *DuplicateApi) Get(c *gin.Context) {
+	claims := core.ExtractClaims(c)
+	api.mutex.RLock()
+	items := make([]ScanJobInfo, 0, len(api.jobs))
+	for _, job := range api.jobs {
+		if claims.RoleKey != models.AdminRoleKey && job.UserId != claims.UserId {
+			continue
+		}
+		items = append(items, job.Info())
+	}
+	api.mutex.RUnlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].StartTime.After(items[j].StartTime)
+	})
+	core.OKRep(GetRep{Count: len(items), Items: items}).SendGin(c)
+}
+
+type GetSetsReq struct {
+	Id        string `uri:"id" binding:"required"`
+	PageIndex int    `form:"pageIndex"`
+	PageSize  int    `form:"pageSize"`
+}
+
+type GetSetsRep struct {
+	ScanJobInfo
+	PageIndex int            `json:"pageIndex"`
+	PageSize  int            `json:"pageSize"`
+	Sets      []DuplicateSet `json:"sets"`
+}
+
+// GetSets 分页查询任务找到的重复文件组
+func (api *DuplicateApi) GetSets(c *gin.Context) {
+	var req GetSetsReq
+	err := core.ShouldBinds(c, &req, core.BindUri, core.BindQuery)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	job, err := api.getOwnJob(c, req.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if req.PageIndex <= 0 {
+		req.PageIndex = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+	sets := job.Sets()
+	start := min((req.PageIndex-1)*req.PageSize, len(sets))
+	end := min(start+req.PageSize, len(sets))
+	core.OKRep(GetSetsRep{
+		ScanJobInfo: job.Info(),
+		PageIndex:   req.PageIndex,
+		PageSize:    req.PageSize,
+		Sets:        sets[start:end],
+	}).SendGin(c)
+}
+
+type DeleteReq struct {
+	Id string `uri:"id" binding:"required"`
+}
+
+// Delete 取消正在运行的任务并删除任务结果
+func (api *DuplicateApi) Delete(c *gin.Context) {
+	var req DeleteReq
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	job, err := api.getOwnJob(c, req.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	job.Cancel()
+	api.removeJob(job.Id)
+	core.OKRep(nil).SendGin(c)
+}
+
+// getOwnJob 获取任务，非管理员只能获取自己创建的任务
+func (api *DuplicateApi) getOwnJob(c *gin.Context, id string) (*ScanJob, error) {
+	claims := core.ExtractClaims(c)
+	job, ok := api.getJob(id)
+	if !ok || (claims.RoleKey != models.AdminRoleKey && job.UserId != claims.UserId) {
+		err := errors.Errorf("任务 %s 不存在", id)
+		return nil, core.NewApiBizErr(err).
+			SetBizCode(global.BizNotFound).
+			SetMsg(err.Error())
+	}
+	return job, nil
+}
@@ -0,0 +1,207 @@
+package duplicate
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/services/admin/apis/fs"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/apis/webhook"
+	"go-file-server/internal/services/admin/models"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const (
+	ActionHardlink = "hardlink"
+	ActionDelete   = "delete"
+)
+
+type ResolveReq struct {
+	// Keep 保留的文件，其他文件与它内容一致时才会处理
+	Keep   string   `json:"keep" binding:"required"`
+	Paths  []string `json:"paths" binding:"required,min=1"`
+	Action string   `json:"action" binding:"required,oneof=hardlink delete"`
+}
+
+type ResolveResult struct {
+	Path  string `json:"path"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Resolve 将重复文件替换为保留文件的硬链接，或者移动到回收站
+func (api *DuplicateApi) Resolve(c *gin.Context) {
+	var req ResolveReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	// 任意一个路径没有权限时拒绝整个请求，被处理的文件需要删除权限
+	roleKey := core.ExtractClaims(c).RoleKey
+	if err := api.checkViewPermission(roleKey, req.Keep); err != nil {
+		c.Error(err)
+		return
+	}
+	for _, path := range req.Paths {
+		if err := api.checkViewPermission(roleKey, path); err != nil {
+			c.Error(err)
+			return
+		}
+		if err := api.checkDeletePermission(roleKey, path); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	keepPath, err := utils.GetRealPath(req.Keep)
+	if err != nil {
+		c.Error(core.NewApiBizErr(err).SetMsg(err.Error()))
+		return
+	}
+	keepInfo, err := os.Stat(keepPath)
+	if err != nil || !keepInfo.Mode().IsRegular() {
+		err = errors.Errorf("文件 %s 不存在", req.Keep)
+		c.Error(core.NewApiBizErr(err).SetMsg(err.Error()))
+		return
+	}
+	keepSum, err := GetChecksum(c, api.cache, keepPath)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	results := make([]ResolveResult, 0, len(req.Paths))
+	for _, path := range req.Paths {
+		result := ResolveResult{Path: path, Ok: true}
+		if err := api.resolve(c, req.Action, keepPath, keepInfo, keepSum, path); err != nil {
+			result.Ok = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	core.OKRep(results).SendGin(c)
+}
+
+// resolve 处理一个重复文件，替换或删除时记录审计，成功后触发webhook并清除配额的统计缓存
+func (api *DuplicateApi) resolve(c *gin.Context, action, keepPath string,
+	keepInfo os.FileInfo, keepSum, path string) error {
+
+	realPath, err := utils.GetRealPath(path)
+	if err != nil {
+		return err
+	}
+	changed, trashPath, err := api.replace(c, action, keepPath, keepInfo, keepSum, path, realPath)
+	if !changed && err == nil {
+		return nil
+	}
+	claims := core.ExtractClaims(c)
+	entry := models.SysFileAudit{
+		UserId:   claims.UserId,
+		Actor:    claims.Username,
+		Protocol: audit.ProtocolHttp,
+		Action:   audit.ActionDelete,
+		Path:     realPath,
+		ClientIp: core.GetClientIP(c),
+	}
+	event := webhook.EventFileDeleted
+	if action == ActionHardlink {
+		entry.Action = audit.ActionHardlink
+		entry.Dest = keepPath
+		event = webhook.EventFileDeduplicated
+	}
+	api.audit.Record(entry, err)
+	if err != nil {
+		return err
+	}
+
+	api.storageManager.ResetUsage(realPath)
+	if trashPath != "" {
+		api.storageManager.ResetUsage(trashPath)
+	}
+	data := webhook.EventData{
+		Path:     utils.GetVirtualPath(realPath),
+		Actor:    claims.Username,
+		ClientIp: entry.ClientIp,
+	}
+	if action == ActionHardlink {
+		data.Source = utils.GetVirtualPath(keepPath)
+	}
+	api.webhooks.Emit(event, data)
+	return nil
+}
+
+// replace 校验内容一致后替换为硬链接或移动到回收站，返回回收站中的路径。已经是硬链接时不处理，changed 为false
+func (api *DuplicateApi) replace(c *gin.Context, action, keepPath string,
+	keepInfo os.FileInfo, keepSum, path, realPath string) (changed bool, trashPath string, err error) {
+
+	if realPath == keepPath {
+		return false, "", errors.New("不能处理保留的文件")
+	}
+	if err := utils.AssertRemovable(realPath); err != nil {
+		return false, "", err
+	}
+	info, err := os.Stat(realPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return false, "", errors.Errorf("文件 %s 不存在", path)
+	}
+	// 已经是硬链接时不需要再处理
+	if action == ActionHardlink && os.SameFile(info, keepInfo) {
+		return false, "", nil
+	}
+	// 处理前重新校验内容，避免扫描结束后文件被修改
+	if info.Size() != keepInfo.Size() {
+		return false, "", errors.New("文件内容与保留的文件不一致")
+	}
+	sum, err := GetChecksum(c, api.cache, realPath)
+	if err != nil {
+		return false, "", errors.New("计算校验和失败")
+	}
+	if sum != keepSum {
+		return false, "", errors.New("文件内容与保留的文件不一致")
+	}
+
+	if action == ActionHardlink {
+		return true, "", hardlink(keepPath, realPath)
+	}
+	trashPath, err = api.moveToTrash(realPath, core.ExtractClaims(c).RoleKey)
+	return true, trashPath, err
+}
+
+// hardlink 先在同一目录创建临时硬链接再替换，替换失败时原文件保持不变
+func hardlink(keepPath, realPath string) error {
+	tmpPath := realPath + ".link_" + utils.GetTimeStr()
+	if err := os.Link(keepPath, tmpPath); err != nil {
+		var linkErr *os.LinkError
+		if errors.As(err, &linkErr) {
+			return errors.New("创建硬链接失败，文件可能位于不同的设备")
+		}
+		return err
+	}
+	if err := os.Rename(tmpPath, realPath); err != nil {
+		os.Remove(tmpPath)
+		return errors.New("替换文件失败")
+	}
+	return nil
+}
+
+func (api *DuplicateApi) moveToTrash(realPath, roleKey string) (string, error) {
+	trashDir, ok := utils.GetTrashDir(realPath, roleKey)
+	if !ok {
+		var err error
+		if trashDir, err = fs.EnsureTempDir(roleKey); err != nil {
+			return "", err
+		}
+	}
+	if err := api.fsRepo.MkdirAll(trashDir, os.ModePerm); err != nil {
+		return "", errors.New("创建回收站目录失败")
+	}
+	desPath := filepath.Join(trashDir, filepath.Base(realPath)+"_"+utils.GetTimeStr())
+	if err := api.fsRepo.Rename(realPath, desPath); err != nil {
+		return "", errors.New("移动到回收站失败")
+	}
+	return desPath, nil
+}
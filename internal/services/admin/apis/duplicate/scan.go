@@ -0,0 +1,274 @@
+package duplicate
+
+import (
+	"context"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/pkgs/utils/concurrentpool"
+	"go-file-server/pkgs/zlog"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ScanRunning  = "running"
+	ScanDone     = "done"
+	ScanFailed   = "failed"
+	ScanCanceled = "canceled"
+
+	// hashPoolSize 同时计算校验和的文件数
+	hashPoolSize = 4
+	// findPageSize 每次从索引读取的文件数
+	findPageSize = 10000
+)
+
+// DuplicateSet 内容相同的一组文件
+type DuplicateSet struct {
+	Hash  string   `json:"hash"`
+	Size  int64    `json:"size"`
+	Files []string `json:"files"`
+	// Wasted 删除多余的副本后可以释放的空间
+	Wasted int64 `json:"wasted"`
+}
+
+// ScanJob 后台查找重复文件的任务
+type ScanJob struct {
+	Id        string    `json:"id"`
+	Path      string    `json:"path"`
+	UserId    int       `json:"userId"`
+	Username  string    `json:"username"`
+	StartTime time.Time `json:"startTime"`
+
+	mutex   sync.RWMutex
+	status  string
+	endTime time.Time
+	errMsg  string
+	sets    []DuplicateSet
+	// total 需要计算校验和的文件数，hashed 已经计算的文件数
+	total  atomic.Int64
+	hashed atomic.Int64
+	cancel context.CancelFunc
+}
+
+// ScanJobInfo 任务快照，用于接口展示
+type ScanJobInfo struct {
+	Id        string    `json:"id"`
+	Path      string    `json:"path"`
+	UserId    int       `json:"userId"`
+	Username  string    `json:"username"`
+	Status    string    `json:"status"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Error     string    `json:"error,omitempty"`
+	Total     int64     `json:"total"`
+	Hashed    int64     `json:"hashed"`
+	SetCount  int       `json:"setCount"`
+	Wasted    int64     `json:"wasted"`
+}
+
+func (j *ScanJob) Info() ScanJobInfo {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	info := ScanJobInfo{
+		Id:        j.Id,
+		Path:      j.Path,
+		UserId:    j.UserId,
+		Username:  j.Username,
+		Status:    j.status,
+		StartTime: j.StartTime,
+		EndTime:   j.endTime,
+		Error:     j.errMsg,
+		Total:     j.total.Load(),
+		Hashed:    j.hashed.Load(),
+		SetCount:  len(j.sets),
+	}
+	for _, s := range j.sets {
+		info.Wasted += s.Wasted
+	}
+	return info
+}
+
+// Sets 返回重复文件组，按可释放空间从大到小排序
+func (j *ScanJob) Sets() []DuplicateSet {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	return j.sets
+}
+
+func (j *ScanJob) Cancel() {
+	if j.cancel != nil {
+		j.cancel()
+	}
+}
+
+func (j *ScanJob) finish(sets []DuplicateSet, err error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.endTime = time.Now()
+	j.sets = sets
+	switch {
+	case err == nil:
+		j.status = ScanDone
+	case errors.Is(err, context.Canceled):
+		j.status = ScanCanceled
+	default:
+		j.status = ScanFailed
+		j.errMsg = "查找重复文件失败"
+	}
+}
+
+type fileEntry struct {
+	path string
+	size int64
+}
+
+func (api *DuplicateApi) runScan(ctx context.Context, job *ScanJob, realPath string) {
+	sets, err := api.scan(ctx, job, realPath)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		zlog.SugLog.Error(err)
+	}
+	job.finish(sets, err)
+}
+
+func (api *DuplicateApi) scan(ctx context.Context, job *ScanJob, realPath string) ([]DuplicateSet, error) {
+	files, err := api.findFiles(realPath)
+	if err != nil {
+		return nil, err
+	}
+	candidates := groupBySize(files)
+	for _, group := range candidates {
+		job.total.Add(int64(len(group)))
+	}
+
+	pool, err := concurrentpool.NewAntsPool(concurrentpool.WithPoolSize(hashPoolSize))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer pool.Release()
+
+	var mutex sync.Mutex
+	hashes := make(map[string][]fileEntry)
+	for _, group := range candidates {
+		for _, f := range group {
+			f := f
+			err := pool.Submit(func() {
+				defer job.hashed.Add(1)
+				sum, err := GetChecksum(ctx, api.cache, f.path)
+				if err != nil {
+					// 扫描期间文件被删除或无法读取时忽略该文件
+					if !errors.Is(err, context.Canceled) {
+						zlog.SugLog.Warnf("计算校验和失败: %v", err)
+					}
+					return
+				}
+				mutex.Lock()
+				defer mutex.Unlock()
+				hashes[sum] = append(hashes[sum], f)
+			})
+			if err != nil {
+				// 提交失败时任务不会执行，需要手动释放计数
+				pool.Done()
+				pool.Wait()
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+	pool.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return buildSets(hashes), nil
+}
+
+// findFiles 从索引中读取目录下的全部文件，回收站中的文件不参与查找
+func (api *DuplicateApi) findFiles(realPath string) ([]fileEntry, error) {
+	var files []fileEntry
+	for page := 1; ; page++ {
+		docs, _, err := api.fsRepo.Find(
+			repository.WithUnderPath(realPath),
+			repository.WithIsDir(false),
+			repository.WithPagination(page, findPageSize),
+		)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, doc := range docs {
+			if doc.Size == 0 || utils.IsTrashPath(doc.Path) {
+				continue
+			}
+			files = append(files, fileEntry{path: doc.Path, size: doc.Size})
+		}
+		if len(docs) < findPageSize {
+			return files, nil
+		}
+	}
+}
+
+// groupBySize 按文件大小分组，只保留有多个文件的分组，大小不同的文件不可能重复
+func groupBySize(files []fileEntry) [][]fileEntry {
+	sizes := make(map[int64][]fileEntry)
+	for _, f := range files {
+		sizes[f.size] = append(sizes[f.size], f)
+	}
+	var groups [][]fileEntry
+	for _, group := range sizes {
+		if len(group) > 1 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+func buildSets(hashes map[string][]fileEntry) []DuplicateSet {
+	sets := []DuplicateSet{}
+	for sum, files := range hashes {
+		if len(files) < 2 {
+			continue
+		}
+		copies := countCopies(files)
+		if copies < 2 {
+			continue
+		}
+		set := DuplicateSet{Hash: sum, Size: files[0].size}
+		for _, f := range files {
+			set.Files = append(set.Files, utils.GetVirtualPath(f.path))
+		}
+		sort.Strings(set.Files)
+		set.Wasted = set.Size * int64(copies-1)
+		sets = append(sets, set)
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		if sets[i].Wasted == sets[j].Wasted {
+			return sets[i].Hash < sets[j].Hash
+		}
+		return sets[i].Wasted > sets[j].Wasted
+	})
+	return sets
+}
+
+// countCopies 统计实际占用空间的副本数，互为硬链接的文件只算一份
+func countCopies(files []fileEntry) int {
+	var infos []os.FileInfo
+	for _, f := range files {
+		info, err := os.Stat(f.path)
+		if err != nil {
+			continue
+		}
+		linked := false
+		for _, other := range infos {
+			if os.SameFile(info, other) {
+				linked = true
+				break
+			}
+		}
+		if !linked {
+			infos = append(infos, info)
+		}
+	}
+	return len(infos)
+}
@@ -0,0 +1,57 @@
+package duplicate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCountCopies(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("duplicate"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a, b := write("a"), write("b")
+	link := filepath.Join(dir, "link")
+	if err := os.Link(a, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  int
+	}{
+		{"two copies", []string{a, b}, 2},
+		{"hardlink counts once", []string{a, link}, 1},
+		{"mixed", []string{a, b, link}, 2},
+		{"missing file ignored", []string{a, filepath.Join(dir, "missing")}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var files []fileEntry
+			for _, p := range tt.paths {
+				files = append(files, fileEntry{path: p, size: 9})
+			}
+			if got := countCopies(files); got != tt.want {
+				t.Errorf("countCopies() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupBySize(t *testing.T) {
+	files := []fileEntry{
+		{path: "/a", size: 10},
+		{path: "/b", size: 10},
+		{path: "/c", size: 20},
+	}
+	groups := groupBySize(files)
+	if len(groups) != 1 || len(groups[0]) != 2 {
+		t.Errorf("groupBySize() = %v, want one group of two files", groups)
+	}
+}
@@ -0,0 +1,223 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/job"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mholt/archiver/v4"
+	"github.com/pkg/errors"
+)
+
+// defaultMaxEntries 列出压缩包内容时默认返回的最大条目数
+const defaultMaxEntries = 10000
+
+var errStopList = errors.New("stop list")
+
+type ListArchiveReq struct {
+	utils.UriPath
+	MaxEntries int `form:"maxEntries" binding:"min=0,max=100000"`
+	// Password 文件名也加密的7z压缩包需要密码才能列出条目
+	Password string `form:"password"`
+}
+
+type ArchiveEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	IsDir   bool      `json:"isDir"`
+	// Encrypted 条目已加密，解压时需要密码，只能识别zip中的条目
+	Encrypted bool `json:"encrypted"`
+}
+
+type ListArchiveRep struct {
+	Path    string         `json:"path"`
+	Entries []ArchiveEntry `json:"entries"`
+	// Truncated 条目数超过 maxEntries 时为true
+	Truncated bool `json:"truncated"`
+}
+
+// ListArchive 不解压列出压缩包中的条目
+func (api *FsApi) ListArchive(c *gin.Context) {
+	var req ListArchiveReq
+	err := core.ShouldBinds(c, &req, core.BindUri, core.BindQuery)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if req.MaxEntries == 0 {
+		req.MaxEntries = defaultMaxEntries
+	}
+	err = api.checkDownloadPermission(core.ExtractClaims(c).RoleKey, req.Path)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	realPath, _, err := statTextFile(req.Path)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	f, extractor, err := openArchive(realPath, req.Password)
+	if err != nil {
+		c.Error(archiveErr(err))
+		return
+	}
+	defer f.Close()
+
+	rep := ListArchiveRep{Path: utils.GetVirtualPath(realPath), Entries: []ArchiveEntry{}}
+	err = extractor.Extract(c.Request.Context(), f, nil, func(ctx context.Context, f archiver.File) error {
+		if len(rep.Entries) >= req.MaxEntries {
+			rep.Truncated = true
+			return errStopList
+		}
+		rep.Entries = append(rep.Entries, ArchiveEntry{
+			Name:      f.NameInArchive,
+			Size:      f.Size(),
+			ModTime:   f.ModTime(),
+			IsDir:     f.IsDir(),
+			Encrypted: utils.IsEncryptedEntry(f),
+		})
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopList) {
+		err = errors.Wrap(err, "读取压缩包失败")
+		c.Error(core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg("读取压缩包失败"))
+		return
+	}
+	core.OKRep(rep).SendGin(c)
+}
+
+type ExtractReq struct {
+	utils.UriPath
+	// Dest 解压的目标目录，为空时解压到压缩包所在目录
+	Dest string `form:"dest"`
+	// Entries 只解压这些条目，目录会包含其下的全部条目，为空时解压全部
+	Entries  []string `form:"entries"`
+	Conflict string   `form:"conflict" binding:"omitempty,oneof=error skip overwrite rename"`
+	Password string   `form:"password"`
+}
+
+// Extract 将压缩包中选择的条目解压到指定目录，解压在后台任务中执行，通过sse推送解压日志
+func (api *FsApi) Extract(c *gin.Context) {
+	var req ExtractReq
+	err := core.ShouldBinds(c, &req, core.BindUri, core.BindQuery)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	api.streamJob(c, JobExtract, extractParams{
+		Path:     req.Path,
+		Dest:     req.Dest,
+		Entries:  req.Entries,
+		Conflict: req.Conflict,
+		Password: req.Password,
+	})
+}
+
+type extractParams struct {
+	Path     string   `json:"path" binding:"required"`
+	Dest     string   `json:"dest"`
+	Entries  []string `json:"entries"`
+	Conflict string   `json:"conflict" binding:"omitempty,oneof=error skip overwrite rename"`
+	// Password 只在提交时传入，不会保存，见 job.Secret
+	Password  string `json:"password,omitempty"`
+	Encrypted bool   `json:"encrypted,omitempty"`
+}
+
+func (p extractParams) TakeSecret() (any, string) {
+	password := p.Password
+	p.Password, p.Encrypted = "", password != ""
+	return p, password
+}
+
+func (api *FsApi) prepareExtract(claims *types.JwtClaims, raw json.RawMessage) (string, any, error) {
+	var params extractParams
+	if err := bindParams(raw, &params); err != nil {
+		return "", nil, err
+	}
+	if params.Conflict == "" {
+		params.Conflict = utils.ConflictError
+	}
+	if params.Dest == "" {
+		params.Dest = filepath.Dir(params.Path)
+	}
+	if err := api.checkDownloadPermission(claims.RoleKey, params.Path); err != nil {
+		return "", nil, accessDenied(err, "无权限读取压缩包")
+	}
+	if err := api.checkFsPermission(claims.RoleKey, params.Dest, "POST"); err != nil {
+		return "", nil, accessDenied(err, "无权限写入目录 "+params.Dest)
+	}
+	realPath, destPath, err := extractPaths(params)
+	if err != nil {
+		return "", nil, core.NewApiBizErr(err).SetMsg(err.Error())
+	}
+	if err := utils.AssertWritable(destPath); err != nil {
+		return "", nil, core.NewApiBizErr(err).SetMsg(err.Error())
+	}
+	if info, err := os.Stat(destPath); err == nil && !info.IsDir() {
+		return "", nil, core.NewApiBizErr(nil).SetMsg(params.Dest + " 不是目录")
+	}
+	// 同一个压缩包解压到同一个目录时只执行一次
+	return realPath + "->" + destPath, params, nil
+}
+
+func extractPaths(params extractParams) (string, string, error) {
+	realPath, err := utils.GetRealPath(params.Path)
+	if err != nil {
+		return "", "", err
+	}
+	destPath, err := utils.GetRealPath(params.Dest)
+	return realPath, destPath, err
+}
+
+func (api *FsApi) runExtract(ctx context.Context, task *job.Task) error {
+	var params extractParams
+	if err := task.Bind(&params); err != nil {
+		return err
+	}
+	password, err := taskPassword(task, params.Encrypted)
+	if err != nil {
+		return err
+	}
+	realPath, destPath, err := extractPaths(params)
+	if err != nil {
+		return job.Fail(err.Error(), nil)
+	}
+	f, extractor, err := openArchive(realPath, password)
+	if err != nil {
+		return jobErr(err)
+	}
+	defer f.Close()
+	defer api.actors.Hold(destPath, task.Job.Username)()
+	if err := api.fsRepo.MkdirAll(destPath, os.ModePerm); err != nil {
+		return job.Fail("创建目标目录失败", err)
+	}
+	// 重新执行时跳过上次已经解压的文件
+	if task.Retried() && params.Conflict == utils.ConflictError {
+		params.Conflict = utils.ConflictSkip
+	}
+	return api.execExtractor(ctx, task, extractor, f, extractTarget{
+		name:     utils.GetVirtualPath(destPath),
+		path:     destPath,
+		entries:  params.Entries,
+		conflict: params.Conflict,
+		source:   realPath,
+	})
+}
+
+func archiveErr(err error) error {
+	var sseErr *core.SseErr
+	if errors.As(err, &sseErr) {
+		return core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(sseErr.Error())
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package fs
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/job"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditEntry 当前请求的审计记录，填写操作和路径后调用 FsApi.audit.Record
+func auditEntry(c *gin.Context, action string) models.SysFileAudit {
+	claims := core.ExtractClaims(c)
+	return models.SysFileAudit{
+		UserId:   claims.UserId,
+		Actor:    claims.Username,
+		Protocol: audit.ProtocolHttp,
+		Action:   action,
+		ClientIp: core.GetClientIP(c),
+	}
+}
+
+// jobAuditEntry 后台任务的审计记录，任务执行时没有客户端ip
+func jobAuditEntry(task *job.Task, action string) models.SysFileAudit {
+	return models.SysFileAudit{
+		UserId:   task.Job.UserId,
+		Actor:    task.Job.Username,
+		Protocol: audit.ProtocolHttp,
+		Action:   action,
+	}
+}
+
+// auditPath 将请求中的虚拟路径转换为审计记录的真实路径，无法转换时保留原路径
+func auditPath(uriPath string) string {
+	realPath, err := utils.GetRealPath(uriPath)
+	if err != nil {
+		return uriPath
+	}
+	return realPath
+}
@@ -0,0 +1,304 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/job"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/apis/quota"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mholt/archiver/v4"
+	"github.com/pkg/errors"
+)
+
+// compressFormats 支持创建的压缩格式
+var compressFormats = map[string]archiver.Archiver{
+	"zip":     archiver.Zip{},
+	"tar":     archiver.Tar{},
+	"tar.gz":  archiver.CompressedArchive{Compression: archiver.Gz{}, Archival: archiver.Tar{}},
+	"tar.bz2": archiver.CompressedArchive{Compression: archiver.Bz2{}, Archival: archiver.Tar{}},
+	"tar.xz":  archiver.CompressedArchive{Compression: archiver.Xz{}, Archival: archiver.Tar{}},
+	"tar.zst": archiver.CompressedArchive{Compression: archiver.Zstd{}, Archival: archiver.Tar{}},
+}
+
+type CompressReq struct {
+	// Paths 需要压缩的文件或目录
+	Paths []string `form:"paths" json:"paths" binding:"required,min=1"`
+	// Dest 压缩包所在的目录
+	Dest string `form:"dest" json:"dest" binding:"required"`
+	// Name 压缩包名称，没有对应的扩展名时自动添加
+	Name   string `form:"name" json:"name" binding:"required"`
+	Format string `form:"format" json:"format" binding:"required,oneof=zip tar tar.gz tar.bz2 tar.xz tar.zst"`
+	// Password 不为空时创建 AES-256 加密的zip，只支持zip格式。只在提交时传入，不会保存，见 job.Secret
+	Password  string `form:"password" json:"password,omitempty"`
+	Encrypted bool   `form:"-" json:"encrypted,omitempty"`
+}
+
+func (req CompressReq) TakeSecret() (any, string) {
+	password := req.Password
+	req.Password, req.Encrypted = "", password != ""
+	return req, password
+}
+
+// Compress 将多个文件或目录压缩到指定目录，通过sse推送压缩日志。
+// 压缩在后台任务中执行，客户端断开后不会停止，需要通过 CancelCompress 取消
+func (api *FsApi) Compress(c *gin.Context) {
+	var req CompressReq
+	err := c.ShouldBindQuery(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	api.streamJob(c, JobCompress, req)
+}
+
+func (api *FsApi) prepareCompress(claims *types.JwtClaims, raw json.RawMessage) (string, any, error) {
+	var req CompressReq
+	if err := bindParams(raw, &req); err != nil {
+		return "", nil, err
+	}
+	if req.Password != "" && req.Format != "zip" {
+		return "", nil, core.NewApiBizErr(nil).SetBizCode(global.BizBadRequest).SetMsg("只有zip格式支持加密")
+	}
+	if !strings.HasSuffix(req.Name, "."+req.Format) {
+		req.Name += "." + req.Format
+	}
+	if err := utils.CheckFsName(req.Name); err != nil {
+		return "", nil, core.NewApiBizErr(err).SetMsg(err.Error())
+	}
+	if err := api.checkFsPermission(claims.RoleKey, req.Dest, "POST"); err != nil {
+		return "", nil, accessDenied(err, "无权限写入目录 "+req.Dest)
+	}
+	archivePath, err := utils.GetRealPath(req.Dest, req.Name)
+	if err != nil {
+		return "", nil, core.NewApiBizErr(err).SetMsg(err.Error())
+	}
+	if err := utils.AssertWritable(archivePath); err != nil {
+		return "", nil, core.NewApiBizErr(err).SetMsg(err.Error())
+	}
+	if _, err := api.compressSources(claims.RoleKey, req.Paths); err != nil {
+		return "", nil, core.NewApiBizErr(err).SetMsg(err.Error())
+	}
+	// 压缩包写入服务器，计入上传流量
+	if err := api.checkQuota(claims, quota.Upload); err != nil {
+		return "", nil, err
+	}
+	if _, err := os.Lstat(archivePath); err == nil {
+		return "", nil, core.NewApiBizErr(nil).SetMsg("当前路径中已经存在: " + req.Name)
+	}
+	return archivePath, req, nil
+}
+
+func (api *FsApi) runCompress(ctx context.Context, task *job.Task) error {
+	var req CompressReq
+	if err := task.Bind(&req); err != nil {
+		return err
+	}
+	archivePath, err := utils.GetRealPath(req.Dest, req.Name)
+	if err != nil {
+		return job.Fail(err.Error(), nil)
+	}
+	if _, err := os.Lstat(archivePath); err == nil {
+		return job.Fail("当前路径中已经存在: "+req.Name, nil)
+	}
+	// 执行时重新检查权限，等待期间权限可能被修改
+	sources, err := api.compressSources(task.Job.RoleKey, req.Paths)
+	if err != nil {
+		return job.Fail(err.Error(), nil)
+	}
+	format := compressFormats[req.Format]
+	if req.Encrypted {
+		password, err := taskPassword(task, req.Encrypted)
+		if err != nil {
+			return err
+		}
+		format = utils.ZipArchiver{Password: password}
+	}
+	defer api.actors.Hold(filepath.Dir(archivePath), task.Job.Username)()
+	err = api.execCompress(ctx, task, sources, archivePath, format)
+	for realPath := range sources {
+		entry := jobAuditEntry(task, audit.ActionCompress)
+		entry.Path, entry.Dest = realPath, archivePath
+		api.audit.Record(entry, err)
+	}
+	return err
+}
+
+// compressSources 检查读取权限，返回 真实路径->压缩包中名称
+func (api *FsApi) compressSources(roleKey string, paths []string) (map[string]string, error) {
+	sources := make(map[string]string, len(paths))
+	names := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if err := api.checkDownloadPermission(roleKey, path); err != nil {
+			return nil, errors.New("无权限读取 " + path)
+		}
+		realPath, err := utils.GetRealPath(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Lstat(realPath); err != nil {
+			return nil, errors.Errorf("%s 不存在", path)
+		}
+		name := filepath.Base(realPath)
+		if names[name] {
+			return nil, errors.Errorf("存在同名的文件或目录: %s", name)
+		}
+		names[name] = true
+		sources[realPath] = name
+	}
+	return sources, nil
+}
+
+func (api *FsApi) execCompress(ctx context.Context, task *job.Task, sources map[string]string,
+	archivePath string, format archiver.Archiver) error {
+
+	files, err := archiver.FilesFromDisk(nil, sources)
+	if err != nil {
+		return job.Fail("读取待压缩的文件失败", err)
+	}
+	task.SetTotal(int64(len(files)))
+	for i := range files {
+		open, name := files[i].Open, files[i].NameInArchive
+		if open == nil {
+			continue
+		}
+		msg := fmt.Sprintf("%s (%d/%d)", name, i+1, len(files))
+		files[i].Open = func() (io.ReadCloser, error) {
+			task.Log(msg)
+			task.Add(1)
+			rc, err := open()
+			if err != nil {
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{utils.NewContextReader(ctx, rc), rc}, nil
+		}
+	}
+
+	remaining, err := api.storageManager.Remaining(task.Job.RoleKey, archivePath)
+	if err != nil {
+		return err
+	}
+	defer api.storageManager.ResetUsage(archivePath)
+	if err := api.quotaManager.Check(task.Job.UserId, task.Job.RoleKey, quota.Upload); err != nil {
+		if errors.Is(err, quota.ErrQuotaExceeded) {
+			return job.Fail("压缩失败，"+err.Error(), nil)
+		}
+		return err
+	}
+	counter := api.quotaManager.NewCounter(task.Job.UserId, quota.Upload)
+	defer counter.Flush()
+
+	// 先写入临时文件，完成后再重命名，失败或取消时删除
+	tmp, err := os.CreateTemp(filepath.Dir(archivePath), "."+filepath.Base(archivePath)+".*")
+	if err != nil {
+		return job.Fail("创建压缩包失败", err)
+	}
+	defer os.Remove(tmp.Name())
+	writer := &quotaWriter{w: tmp, remaining: remaining, counter: counter}
+	err = format.Archive(ctx, writer, files)
+	if err == nil {
+		// archiver 会忽略关闭时写入结尾数据的错误
+		err = writer.err
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// 压缩期间其他人可能创建了同名文件，不能覆盖
+		err = utils.RenameNoReplace(tmp.Name(), archivePath)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, context.Canceled):
+			return err
+		case errors.Is(err, quota.ErrStorageExceeded), errors.Is(err, utils.ErrFileExist):
+			return job.Fail("压缩失败，"+err.Error(), nil)
+		default:
+			return errors.Wrap(err, "压缩失败")
+		}
+	}
+	task.Log("更新索引...")
+	if err := api.fsRepo.AddResource(archivePath); err != nil {
+		return job.Fail("更新索引失败", err)
+	}
+	task.Complete("压缩完成")
+	return nil
+}
+
+// CancelCompress 取消正在进行的压缩，path 为压缩包的路径
+func (api *FsApi) CancelCompress(c *gin.Context) {
+	var req utils.UriPath
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := api.checkFsPermission(core.ExtractClaims(c).RoleKey, filepath.Dir(req.Path), "POST"); err != nil {
+		c.Error(err)
+		return
+	}
+	archivePath, err := utils.GetRealPath(req.Path)
+	if err != nil {
+		c.Error(core.NewApiBizErr(err).SetMsg(err.Error()))
+		return
+	}
+	data, ok, err := api.jobs.FindActive(JobCompress, archivePath)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !ok {
+		err = errors.Errorf("没有正在压缩的 %s", req.Path)
+		c.Error(core.NewApiBizErr(err).SetBizCode(global.BizNotFound).SetMsg(err.Error()))
+		return
+	}
+	if err := core.VerifyResourceOwner(c, data.UserId); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := api.jobs.Cancel(data.Id); err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(nil).SendGin(c)
+}
+
+// quotaWriter 写入超过剩余存储配额时返回错误，remaining 小于0时不限制，
+// 写入的字节数同时计入流量配额。出现错误后后续写入都返回该错误
+type quotaWriter struct {
+	w         io.Writer
+	remaining int64
+	written   int64
+	counter   *quota.Counter
+	err       error
+}
+
+func (w *quotaWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.remaining >= 0 && w.written+int64(len(p)) > w.remaining {
+		w.err = errors.Wrap(quota.ErrStorageExceeded, "压缩包超出存储配额")
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.written += int64(n)
+	if w.counter != nil {
+		w.counter.Add(n)
+	}
+	w.err = err
+	return n, err
+}
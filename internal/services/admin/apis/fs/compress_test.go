@@ -0,0 +1,46 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"go-file-server/internal/services/admin/apis/quota"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mholt/archiver/v4"
+	"github.com/pkg/errors"
+)
+
+func TestCompressQuotaWriter(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), bytes.Repeat([]byte("a"), 1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		format    string
+		remaining int64
+		wantErr   bool
+	}{
+		{"zip", -1, false},
+		{"zip", 10, true},
+		{"tar.gz", -1, false},
+		{"tar.gz", 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			files, err := archiver.FilesFromDisk(nil, map[string]string{dir: "d"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			writer := &quotaWriter{w: &bytes.Buffer{}, remaining: tt.remaining}
+			err = compressFormats[tt.format].Archive(context.Background(), writer, files)
+			if err == nil {
+				err = writer.err
+			}
+			if got := errors.Is(err, quota.ErrStorageExceeded); got != tt.wantErr {
+				t.Errorf("Archive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/job"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/pathtool"
+	"go-file-server/pkgs/zlog"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// copyPaths 检查权限，返回源和目标的真实路径，目标为 destination 下的同名文件或目录
+func (api *FsApi) copyPaths(roleKey string, params moveParams) (string, string, error) {
+	if err := api.checkDownloadPermission(roleKey, params.Path); err != nil {
+		return "", "", accessDenied(err, "无权限读取 "+params.Path)
+	}
+	if err := api.checkFsPermission(roleKey, params.Destination, "POST"); err != nil {
+		return "", "", accessDenied(err, "无权限写入目录 "+params.Destination)
+	}
+	realPath, err := utils.GetRealPath(params.Path)
+	if err != nil {
+		return "", "", core.NewApiBizErr(err).SetMsg(err.Error())
+	}
+	destination, err := utils.GetRealPath(params.Destination, filepath.Base(realPath))
+	if err != nil {
+		return "", "", core.NewApiBizErr(err).SetMsg(err.Error())
+	}
+	if err := utils.AssertWritable(destination); err != nil {
+		return "", "", core.NewApiBizErr(err).SetMsg(err.Error())
+	}
+	if _, err := os.Lstat(realPath); err != nil {
+		return "", "", core.NewApiBizErr(err).SetMsg(params.Path + " 不存在")
+	}
+	if _, err := os.Lstat(destination); err == nil {
+		return "", "", core.NewApiBizErr(nil).SetMsg("目标目录中已经存在: " + filepath.Base(realPath))
+	}
+	if pathtool.IsSubPath(realPath, destination) {
+		return "", "", core.NewApiBizErr(nil).SetMsg("不能复制到自身的子目录中")
+	}
+	return realPath, destination, nil
+}
+
+func (api *FsApi) prepareCopy(claims *types.JwtClaims, raw json.RawMessage) (string, any, error) {
+	var params moveParams
+	if err := bindParams(raw, &params); err != nil {
+		return "", nil, err
+	}
+	realPath, destination, err := api.copyPaths(claims.RoleKey, params)
+	if err != nil {
+		return "", nil, err
+	}
+	size, err := api.storageManager.PathSize(realPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := api.storageManager.Check(claims.RoleKey, destination, size); err != nil {
+		return "", nil, storageErr(err)
+	}
+	return destination, params, nil
+}
+
+// copyTmpPath 复制任务在目标目录下的临时路径
+func copyTmpPath(id int, params moveParams) (string, error) {
+	realPath, err := utils.GetRealPath(params.Path)
+	if err != nil {
+		return "", err
+	}
+	destination, err := utils.GetRealPath(params.Destination, filepath.Base(realPath))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(destination),
+		fmt.Sprintf(".%s.job%d", filepath.Base(destination), id)), nil
+}
+
+// runCopy 先复制到目标目录下的临时路径，完成后重命名，重新执行时在上次未完成的临时路径上继续复制，
+// 任务被取消或不再重试时删除临时路径
+func (api *FsApi) runCopy(ctx context.Context, task *job.Task) error {
+	err := api.copyToTmp(ctx, task)
+	if err != nil && task.Final(err) {
+		api.discardCopy(task.Job)
+	}
+	return err
+}
+
+// discardCopy 删除复制任务留下的临时路径
+func (api *FsApi) discardCopy(j models.SysJob) {
+	var params moveParams
+	if err := json.Unmarshal([]byte(j.Params), &params); err != nil {
+		return
+	}
+	tmp, err := copyTmpPath(j.Id, params)
+	if err != nil {
+		return
+	}
+	if err := os.RemoveAll(tmp); err != nil {
+		zlog.SugLog.Error(err)
+	}
+	api.storageManager.ResetUsage(tmp)
+}
+
+func (api *FsApi) copyToTmp(ctx context.Context, task *job.Task) error {
+	var params moveParams
+	if err := task.Bind(&params); err != nil {
+		return err
+	}
+	realPath, destination, err := api.copyPaths(task.Job.RoleKey, params)
+	if err != nil {
+		return jobErr(err)
+	}
+	size, err := api.storageManager.PathSize(realPath)
+	if err != nil {
+		return err
+	}
+	if err := api.storageManager.Check(task.Job.RoleKey, destination, size); err != nil {
+		return jobErr(storageErr(err))
+	}
+	defer api.storageManager.ResetUsage(destination)
+	defer api.actors.Hold(filepath.Dir(destination), task.Job.Username)()
+
+	tmp, err := copyTmpPath(task.Job.Id, params)
+	if err != nil {
+		return err
+	}
+	// 重新执行时继续使用上次的临时目录，跳过已经完整复制的文件
+	copyAll := pathtool.ResumeCopyWithContext
+	if !task.Retried() {
+		if err := os.RemoveAll(tmp); err != nil {
+			return errors.WithStack(err)
+		}
+		copyAll = pathtool.CopyAllWithContext
+	}
+	task.SetTotal(size)
+	var copied int64
+	err = copyAll(ctx, realPath, tmp, func(path string, n int64) {
+		task.Log(utils.GetVirtualPath(path))
+		task.Add(n)
+		copied += n
+	})
+	if err == nil {
+		err = os.Rename(tmp, destination)
+	}
+	entry := jobAuditEntry(task, audit.ActionCopy)
+	entry.Path, entry.Dest, entry.Bytes = realPath, destination, copied
+	api.audit.Record(entry, err)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+		return errors.Wrap(err, "复制失败")
+	}
+	task.Log("更新索引...")
+	if err := api.fsRepo.AddResource(destination); err != nil {
+		return job.Fail("更新索引失败", err)
+	}
+	task.Complete("复制完成")
+	return nil
+}
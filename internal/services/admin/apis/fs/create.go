@@ -4,6 +4,10 @@ import (
 	"go-file-server/internal/common/core"
 	"go-file-server/internal/common/global"
 	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/apis/quota"
+	"go-file-server/internal/services/admin/apis/webhook"
+	"go-file-server/pkgs/utils/limiter"
 	"io"
 	"os"
 	"path/filepath"
@@ -50,8 +54,15 @@ func (api *FsApi) createDir(c *gin.Context) {
 
 		return
 	}
+	if err := utils.AssertWritable(realPath); err != nil {
+		core.ErrBizRep().SetMsg(err.Error()).SendGin(c)
+		return
+	}
 
 	err = api.fsRepo.MkdirAll(realPath, os.ModePerm)
+	entry := auditEntry(c, audit.ActionMkdir)
+	entry.Path = realPath
+	api.audit.Record(entry, err)
 	if err != nil {
 		if ok, err := utils.ParsePathErr(err); ok {
 			core.ErrBizRep().SetMsg(err.Error()).SendGin(c)
@@ -80,7 +91,7 @@ func (api *FsApi) upLoad(c *gin.Context) {
 
 }
 
-func (api *FsApi) saveUploadedFile(c *gin.Context, req CreateReq) error {
+func (api *FsApi) saveUploadedFile(c *gin.Context, req CreateReq) (err error) {
 
 	filepart, err := c.FormFile("file")
 	if err != nil {
@@ -91,6 +102,36 @@ func (api *FsApi) saveUploadedFile(c *gin.Context, req CreateReq) error {
 	if err != nil {
 		return core.NewApiErr(err).SetHttpCode(global.BadRequestError)
 	}
+	if err := utils.AssertWritable(dst); err != nil {
+		return core.NewApiBizErr(err).SetMsg(err.Error())
+	}
+	var written int64
+	entry := auditEntry(c, audit.ActionUpload)
+	entry.Path = dst
+	defer func() {
+		entry.Bytes = written
+		api.audit.Record(entry, err)
+	}()
+
+	claims := core.ExtractClaims(c)
+	if err := api.checkQuota(claims, quota.Upload); err != nil {
+		return err
+	}
+	// 覆盖已存在的文件时只计算增加的大小
+	delta := filepart.Size
+	if info, err := os.Stat(dst); err == nil && !info.IsDir() {
+		delta -= info.Size()
+	}
+	if delta > 0 {
+		if err := api.storageManager.Check(claims.RoleKey, dst, delta); err != nil {
+			return storageErr(err)
+		}
+	}
+	release, err := api.acquireTransfer(c, claims)
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	src, err := filepart.Open()
 	if err != nil {
@@ -107,17 +148,29 @@ func (api *FsApi) saveUploadedFile(c *gin.Context, req CreateReq) error {
 		return err
 	}
 	defer out.Close()
-	claims := core.ExtractClaims(c)
 	raleLimiter, err := api.getLimiter(claims.UserId, claims.RoleKey)
 	if err != nil {
 		return err
 	}
-	reader := raleLimiter.LimitReader(c.Request.Context(), src)
-	_, err = io.Copy(out, reader)
+	counter := api.quotaManager.NewCounter(claims.UserId, quota.Upload)
+	defer counter.Flush()
+	reader := raleLimiter.LimitReader(c.Request.Context(), src,
+		limiter.WithCounter(counter.Add))
+	written, err = io.Copy(out, reader)
 	if err != nil {
 		return err
 	}
 
-	return api.fsRepo.AddResource(dst)
+	defer api.storageManager.ResetUsage(dst)
+	if err := api.fsRepo.AddResource(dst); err != nil {
+		return err
+	}
+	api.webhooks.Emit(webhook.EventFileUploaded, webhook.EventData{
+		Path:     utils.GetVirtualPath(dst),
+		Actor:    claims.Username,
+		ClientIp: core.GetClientIP(c),
+		Size:     filepart.Size,
+	})
+	return nil
 
 }
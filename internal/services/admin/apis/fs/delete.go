@@ -3,9 +3,11 @@ package fs
 import (
 	"go-file-server/internal/common/core"
 	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/apis/webhook"
 	"go-file-server/pkgs/zlog"
+	"path"
 	"path/filepath"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -19,12 +21,20 @@ func (api *FsApi) Delete(c *gin.Context) {
 		return
 	}
 
-	roleKey := core.ExtractClaims(c).RoleKey
-	err = api.execDelete(req.Path, roleKey)
+	claims := core.ExtractClaims(c)
+	err = api.execDelete(req.Path, claims.RoleKey)
+	entry := auditEntry(c, audit.ActionDelete)
+	entry.Path = auditPath(req.Path)
+	api.audit.Record(entry, err)
 	if err != nil {
 		c.Error(err)
 		return
 	}
+	api.webhooks.Emit(webhook.EventFileDeleted, webhook.EventData{
+		Path:     path.Clean("/" + req.Path),
+		Actor:    claims.Username,
+		ClientIp: core.GetClientIP(c),
+	})
 	core.OKRep(nil).SendGin(c)
 }
 
@@ -39,12 +49,16 @@ func (api *FsApi) execDelete(path string, roleKey string) (err error) {
 		return handleErr(err)
 	}
 
+	if err := utils.AssertRemovable(srcPath); err != nil {
+		return handleErr(err)
+	}
+
 	// 直接删除
-	if strings.HasPrefix(srcPath, utils.GetTmpDir()) {
+	if utils.IsTrashPath(srcPath) {
 		return api.fsRepo.RemoveAll(srcPath)
 	}
 
-	tmpDir, err := api.ensureTempDir(roleKey)
+	tmpDir, err := api.ensureTempDir(srcPath, roleKey)
 	if err != nil {
 		return err
 	}
@@ -7,6 +7,9 @@ import (
 	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/common/types"
 	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/apis/quota"
+	"go-file-server/internal/services/admin/apis/webhook"
 	"go-file-server/internal/services/admin/models"
 	"go-file-server/pkgs/cache"
 	"go-file-server/pkgs/pathtool"
@@ -14,6 +17,7 @@ import (
 	"go-file-server/pkgs/utils/str"
 	"go-file-server/pkgs/utils/zip"
 	"io"
+	"mime"
 	"net/url"
 	"os"
 	"path"
@@ -28,11 +32,19 @@ import (
 type DownloadInfo struct {
 	Path  string
 	Token string
+	// Share 链接用于分享给其他人，访问记录为 share 协议并触发 share.accessed
+	Share bool `json:",omitempty"`
+}
+
+type DownloadUrlReq struct {
+	utils.UriPath
+	// Share 为true时生成用于分享的链接，否则为当前用户自己下载使用
+	Share bool `form:"share"`
 }
 
 func (api *FsApi) GetDownloadUrl(c *gin.Context) {
-	var req utils.UriPath
-	err := core.ShouldBinds(c, &req, core.BindUri)
+	var req DownloadUrlReq
+	err := core.ShouldBinds(c, &req, core.BindUri, core.BindQuery)
 	if err != nil {
 		c.Error(err)
 		return
@@ -52,12 +64,12 @@ func (api *FsApi) GetDownloadUrl(c *gin.Context) {
 	core.OKRep(url).SendGin(c)
 }
 
-func (api *FsApi) genNewUrl(c *gin.Context, req utils.UriPath) (string, error) {
+func (api *FsApi) genNewUrl(c *gin.Context, req DownloadUrlReq) (string, error) {
 	token, err := middlewares.GetToken(c)
 	if err != nil {
 		return "", err
 	}
-	id, err := api.makeID(req.Path, token)
+	id, err := api.makeID(DownloadInfo{Path: req.Path, Token: token, Share: req.Share})
 	if err != nil {
 		return "", err
 	}
@@ -82,7 +94,7 @@ func (api *FsApi) genNewUrl(c *gin.Context, req utils.UriPath) (string, error) {
 	return parsedURL.String(), nil
 }
 
-func (api *FsApi) makeID(uriPath, token string) (id string, err error) {
+func (api *FsApi) makeID(data DownloadInfo) (id string, err error) {
 	var sdata string
 	defer func() {
 		if err != nil {
@@ -91,8 +103,6 @@ func (api *FsApi) makeID(uriPath, token string) (id string, err error) {
 		err = api.cache.Set(id, sdata, 3*time.Hour)
 	}()
 
-	data := DownloadInfo{Path: uriPath, Token: token}
-
 	sdata, err = str.ConvertToString(data)
 	if err != nil {
 		return "", err
@@ -135,10 +145,35 @@ func (api *FsApi) Download(c *gin.Context) {
 	if err != nil {
 		return
 	}
-	err = api.send(c, jwtClaims, downloadInfo.Path)
+	written, err := api.send(c, jwtClaims, downloadInfo.Path)
+	protocol := audit.ProtocolHttp
+	if downloadInfo.Share {
+		protocol = audit.ProtocolShare
+	}
+	api.audit.Record(models.SysFileAudit{
+		UserId:   jwtClaims.UserId,
+		Actor:    jwtClaims.Username,
+		Protocol: protocol,
+		Action:   audit.ActionDownload,
+		Path:     auditPath(downloadInfo.Path),
+		Bytes:    written,
+		ClientIp: core.GetClientIP(c),
+	}, err)
+	if err == nil && downloadInfo.Share {
+		api.webhooks.Emit(webhook.EventShareAccessed, webhook.EventData{
+			Path:     path.Clean("/" + downloadInfo.Path),
+			Actor:    jwtClaims.Username,
+			ClientIp: core.GetClientIP(c),
+		})
+	}
 }
 
 func (api *FsApi) checkDownloadPermission(roleKey, uriPath string) error {
+	return api.checkFsPermission(roleKey, uriPath, "GET")
+}
+
+// checkFsPermission 检查角色对路径是否有 /fs 接口对应method的权限
+func (api *FsApi) checkFsPermission(roleKey, uriPath, method string) error {
 	if roleKey == models.AdminRoleKey {
 		return nil
 	}
@@ -147,7 +182,7 @@ func (api *FsApi) checkDownloadPermission(roleKey, uriPath string) error {
 	ok, err := api.casbinEnforcer.Enforce(
 		roleKey,
 		apiPath,
-		"GET",
+		method,
 	)
 	if err != nil {
 		return core.NewApiErr(err)
@@ -224,45 +259,64 @@ func checkPath(path string) (isDir bool, realPath string, err error) {
 	return
 }
 
-func (api *FsApi) send(c *gin.Context, jwtClaims *types.JwtClaims, path string) error {
+// send 发送文件或目录，返回实际发送的字节数
+func (api *FsApi) send(c *gin.Context, jwtClaims *types.JwtClaims, path string) (int64, error) {
 	raleLimiter, err := api.getLimiter(jwtClaims.UserId, jwtClaims.RoleKey)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	isDIr, realPath, err := checkPath(path)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if err := api.checkQuota(jwtClaims, quota.Download); err != nil {
+		return 0, err
+	}
+	release, err := api.acquireTransfer(c, jwtClaims)
+	if err != nil {
+		return 0, err
 	}
+	defer release()
+	counter := api.quotaManager.NewCounter(jwtClaims.UserId, quota.Download)
+	defer counter.Flush()
+	var written int64
+	writer := raleLimiter.LimitWriter(c.Request.Context(), c.Writer,
+		limiter.WithCounter(func(n int) {
+			counter.Add(n)
+			written += int64(n)
+		}))
 	if isDIr {
-		return sendDir(c, realPath, raleLimiter)
+		err = sendDir(c, realPath, writer)
+	} else {
+		err = sendFile(c, realPath, writer)
 	}
-	return sendFile(c, realPath, raleLimiter)
+	return written, err
 }
 
-func sendFile(c *gin.Context, src string, limiter *limiter.Limiter) error {
+func sendFile(c *gin.Context, src string, writer io.Writer) error {
 	fileName := filepath.Base(src)
 	c.Header("Content-Type", "application/octet-stream")
-	//强制浏览器下载
-	c.Header("Content-Disposition", "attachment; filename="+fileName)
-	//浏览器下载或预览
-	c.Header("Content-Disposition", "inline;filename="+fileName)
+	//强制浏览器下载，预览使用 preview.go 的接口
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": fileName})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", disposition)
 	c.Header("Content-Transfer-Encoding", "binary")
 	fs, err := os.Open(src)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	defer fs.Close()
-	writer := limiter.LimitWriter(c.Request.Context(), c.Writer)
 	c.Writer.Flush()
 	_, err = io.Copy(writer, fs)
 	return errors.WithStack(err)
 }
 
-func sendDir(c *gin.Context, src string, limiter *limiter.Limiter) error {
+func sendDir(c *gin.Context, src string, writer io.Writer) error {
 	fileName := filepath.Base(src) + ".zip"
 	c.Header("Content-Disposition", "attachment; filename="+fileName)
 	c.Header("Content-Type", "application/zip")
-	writer := limiter.LimitWriter(c.Request.Context(), c.Writer)
 	err := zip.NewStreamZip(writer).ZipWithCtx(c.Request.Context(), src)
 	return errors.WithStack(err)
 }
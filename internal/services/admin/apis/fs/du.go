@@ -0,0 +1,65 @@
+package fs
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type GetDirUsageReq struct {
+	utils.UriPath
+	// Top 返回占用空间最大的子项数量，默认10
+	Top int `form:"top" binding:"min=0,max=1000"`
+}
+
+type GetDirUsageRep struct {
+	Path string `json:"path"`
+	repository.DirUsage
+}
+
+// GetDirUsage 统计目录的递归大小、文件数以及占用空间最大的子项，不建立索引的挂载点遍历磁盘统计
+func (api *FsApi) GetDirUsage(c *gin.Context) {
+	var req GetDirUsageReq
+	err := core.ShouldBinds(c, &req, core.BindUri, core.BindQuery)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if req.Top == 0 {
+		req.Top = 10
+	}
+	err = api.checkDownloadPermission(core.ExtractClaims(c).RoleKey, req.Path)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	isDir, realPath, err := checkPath(req.Path)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !isDir {
+		err = errors.Errorf("%s 不是目录", req.Path)
+		c.Error(core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(err.Error()))
+		return
+	}
+	var usage repository.DirUsage
+	if utils.IsIndexed(realPath) {
+		usage, err = api.fsRepo.GetDirUsage(realPath, req.Top)
+	} else {
+		// 不建立索引的挂载点直接遍历磁盘
+		usage, err = repository.GetDiskDirUsage(realPath, req.Top)
+	}
+	if err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	core.OKRep(GetDirUsageRep{
+		Path:     utils.GetVirtualPath(realPath),
+		DirUsage: usage,
+	}).SendGin(c)
+}
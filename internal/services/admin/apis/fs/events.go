@@ -0,0 +1,300 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/pkgs/cache"
+	"go-file-server/pkgs/pathtool"
+	"go-file-server/pkgs/utils/timex"
+	"go-file-server/pkgs/zlog"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// fsEventChannel 多实例部署时广播目录变化事件的redis频道
+	fsEventChannel = "go-file-server:fs-events"
+	// fsEventQueueSize 等待发布的事件数，超出后丢弃新的事件
+	fsEventQueueSize = 1024
+	// MessageFsEvent 目录变化事件的sse消息类型
+	MessageFsEvent = "event"
+	// fsEventMergeDelay 没有操作者的事件等待的时间，期间从redis收到相同的事件时丢弃
+	fsEventMergeDelay = time.Second
+	// fsEventSeenTTL 从redis收到的事件用于去重的时间
+	fsEventSeenTTL = 5 * time.Second
+)
+
+// FileEvent 目录变化事件，路径为虚拟路径
+type FileEvent struct {
+	// Op created、deleted、renamed、modified
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	// OldPath 重命名前的路径
+	OldPath string `json:"oldPath,omitempty"`
+	// Actor 通过接口或ftp修改时为用户名，直接在服务器上修改时为空
+	Actor string    `json:"actor"`
+	Time  time.Time `json:"time"`
+}
+
+// key 不同实例监听到的同一个变化使用相同的key
+func (fe FileEvent) key() string {
+	return fe.Op + "\x00" + fe.Path + "\x00" + fe.OldPath
+}
+
+// FileEvents 将索引监听到的文件变化转换为 FileEvent 推送给订阅者，需要开启目录监听。
+// 缓存为redis时，多个实例监听同一个存储，每个实例都会监听到相同的变化，
+// 只有本实例用户操作的事件发布到redis，由各实例推送给自己的客户端，这样其他实例的客户端也能看到操作者。
+// 没有操作者的事件只推送给本实例的客户端，等待 fsEventMergeDelay 后，
+// 已经从redis收到相同的事件时说明是其他实例用户的操作，直接丢弃
+type FileEvents struct {
+	publisher *utils.Publisher[FileEvent]
+	redis     *redis.Client
+	actors    *utils.ActorTracker
+	queue     chan FileEvent
+	// delayed 等待去重的没有操作者的事件
+	delayed chan FileEvent
+	// seen 最近从redis收到的事件
+	seenMutex sync.Mutex
+	seen      map[string]time.Time
+}
+
+func NewFileEvents(fsRepo *repository.FsRepository, ch cache.AdapterCache, actors *utils.ActorTracker) *FileEvents {
+	e := &FileEvents{
+		publisher: utils.NewPublisher[FileEvent](),
+		actors:    actors,
+		queue:     make(chan FileEvent, fsEventQueueSize),
+		delayed:   make(chan FileEvent, fsEventQueueSize),
+		seen:      make(map[string]time.Time),
+	}
+	if ch != nil && ch.String() == "redis" {
+		if client, ok := ch.GetClient().(*redis.Client); ok {
+			e.redis = client
+		}
+	}
+	fsRepo.Indexer.OnEvent(e.handle)
+	go e.run()
+	if e.redis != nil {
+		go e.runDelayed()
+		go e.subscribe()
+	}
+	return e
+}
+
+// Subscribe 订阅本实例收到的全部事件，使用完需要Close
+func (e *FileEvents) Subscribe() *utils.Subscriber[FileEvent] {
+	return e.publisher.CreateSubscriber()
+}
+
+// handle 在索引的锁内调用，只做转换后放入队列
+func (e *FileEvents) handle(event pathtool.Event) {
+	fe := FileEvent{
+		Op:    string(event.Op),
+		Path:  utils.GetVirtualPath(event.Path),
+		Actor: e.actors.Lookup(event.Path),
+		Time:  time.Now(),
+	}
+	if event.OldPath != "" {
+		fe.OldPath = utils.GetVirtualPath(event.OldPath)
+		if fe.Actor == "" {
+			fe.Actor = e.actors.Lookup(event.OldPath)
+		}
+	}
+	select {
+	case e.queue <- fe:
+	default:
+		zlog.SugLog.Warnf("目录变化事件过多，丢弃事件: %s %s", fe.Op, fe.Path)
+	}
+}
+
+func (e *FileEvents) run() {
+	for fe := range e.queue {
+		if e.redis == nil {
+			e.publisher.Publish(fe)
+			continue
+		}
+		if fe.Actor == "" {
+			select {
+			case e.delayed <- fe:
+			default:
+				zlog.SugLog.Warnf("目录变化事件过多，丢弃事件: %s %s", fe.Op, fe.Path)
+			}
+			continue
+		}
+		data, err := json.Marshal(fe)
+		if err != nil {
+			zlog.SugLog.Error(err)
+			continue
+		}
+		if err := e.redis.Publish(context.Background(), fsEventChannel, data).Err(); err != nil {
+			// redis不可用时至少推送给本实例的客户端
+			zlog.SugLog.Errorf("发布目录变化事件失败: %v", err)
+			e.publisher.Publish(fe)
+		}
+	}
+}
+
+// runDelayed 按顺序处理没有操作者的事件，等待到期后没有从redis收到相同的事件时推送
+func (e *FileEvents) runDelayed() {
+	for fe := range e.delayed {
+		time.Sleep(time.Until(fe.Time.Add(fsEventMergeDelay)))
+		if !e.isSeen(fe) {
+			e.publisher.Publish(fe)
+		}
+	}
+}
+
+// subscribe 接收所有实例发布的事件，断开后 go-redis 会自动重连
+func (e *FileEvents) subscribe() {
+	pubsub := e.redis.Subscribe(context.Background(), fsEventChannel)
+	defer pubsub.Close()
+	for msg := range pubsub.Channel() {
+		var fe FileEvent
+		if err := json.Unmarshal([]byte(msg.Payload), &fe); err != nil {
+			zlog.SugLog.Error(err)
+			continue
+		}
+		e.markSeen(fe)
+		e.publisher.Publish(fe)
+	}
+}
+
+func (e *FileEvents) markSeen(fe FileEvent) {
+	e.seenMutex.Lock()
+	defer e.seenMutex.Unlock()
+	now := time.Now()
+	if len(e.seen) >= fsEventQueueSize {
+		for key, t := range e.seen {
+			if now.Sub(t) > fsEventSeenTTL {
+				delete(e.seen, key)
+			}
+		}
+	}
+	e.seen[fe.key()] = now
+}
+
+// isSeen 最近是否从redis收到过相同的事件
+func (e *FileEvents) isSeen(fe FileEvent) bool {
+	e.seenMutex.Lock()
+	defer e.seenMutex.Unlock()
+	t, ok := e.seen[fe.key()]
+	return ok && time.Since(t) <= fsEventSeenTTL
+}
+
+type WatchReq struct {
+	utils.UriPath
+	// Recursive 为true时包括子目录中的变化，否则只推送目录下直接的条目
+	Recursive bool `form:"recursive"`
+}
+
+// Watch 通过sse推送目录中的变化，需要有目录的读取权限
+func (api *FsApi) Watch(c *gin.Context) {
+	var req WatchReq
+	err := core.ShouldBinds(c, &req, core.BindUri, core.BindQuery)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	roleKey := core.ExtractClaims(c).RoleKey
+	if err := api.checkDownloadPermission(roleKey, req.Path); err != nil {
+		c.Error(err)
+		return
+	}
+	isDir, _, err := checkPath(req.Path)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !isDir {
+		c.Error(core.NewApiBizErr(nil).SetMsg(req.Path + " 不是目录"))
+		return
+	}
+	dir := path.Clean("/" + req.Path)
+
+	subscriber := api.events.Subscribe()
+	defer subscriber.Close()
+	ticker := timex.NewImmediateTicker(time.Millisecond * 500)
+	defer ticker.Stop()
+	core.SetSseHeader(c)
+	defer c.Writer.Flush()
+	for {
+		select {
+		case fe, ok := <-subscriber.Messages():
+			if !ok {
+				return
+			}
+			fe, ok = api.visibleEvent(roleKey, dir, req.Recursive, fe)
+			if !ok {
+				continue
+			}
+			data, err := json.Marshal(fe)
+			if err != nil {
+				continue
+			}
+			c.SSEvent(MessageFsEvent, string(data))
+		case <-ticker.C:
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// visibleEvent 返回推送给客户端的事件。事件的路径需要位于监听的目录中，
+// 新旧路径所在的目录都需要有读取权限，从没有权限的目录移入或移出时，分别作为创建和删除推送
+func (api *FsApi) visibleEvent(roleKey, dir string, recursive bool, fe FileEvent) (FileEvent, bool) {
+	inDir := func(p string) bool {
+		if p == "" {
+			return false
+		}
+		parent := path.Dir(p)
+		return parent == dir || recursive && strings.HasPrefix(parent, strings.TrimSuffix(dir, "/")+"/")
+	}
+	readable := func(p string) bool {
+		return p != "" && api.checkDownloadPermission(roleKey, path.Dir(p)) == nil
+	}
+	if !inDir(fe.Path) && !inDir(fe.OldPath) {
+		return fe, false
+	}
+	if fe.OldPath == "" {
+		return fe, readable(fe.Path)
+	}
+	newReadable, oldReadable := readable(fe.Path), readable(fe.OldPath)
+	switch {
+	case newReadable && oldReadable:
+		return fe, true
+	case newReadable:
+		fe.Op, fe.OldPath = string(pathtool.EventCreated), ""
+		return fe, inDir(fe.Path)
+	case oldReadable:
+		fe.Op, fe.Path, fe.OldPath = string(pathtool.EventDeleted), fe.OldPath, ""
+		return fe, inDir(fe.Path)
+	}
+	return fe, false
+}
+
+// MarkActor 修改文件的请求执行期间，将请求路径及其下的变化记录为当前用户的操作
+func (api *FsApi) MarkActor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Param("path") == "" {
+			c.Next()
+			return
+		}
+		realPath, err := utils.GetRealPath(c.Param("path"))
+		if err != nil {
+			c.Next()
+			return
+		}
+		release := api.actors.Hold(realPath, core.ExtractClaims(c).Username)
+		defer release()
+		c.Next()
+	}
+}
@@ -0,0 +1,100 @@
+package fs
+
+import (
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+)
+
+func TestVisibleEvent(t *testing.T) {
+	m, err := model.NewModelFromString(`
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = r.sub == p.sub && keyMatch(r.obj, p.obj) && r.act == p.act
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer, err := casbin.NewCachedEnforcer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer.AddPolicy("user", "/api/v1/fs/pub/*", "GET")
+	enforcer.AddPolicy("user", "/api/v1/fs/pub", "GET")
+	api := &FsApi{casbinEnforcer: enforcer}
+
+	tests := []struct {
+		name      string
+		recursive bool
+		event     FileEvent
+		want      FileEvent
+		wantOk    bool
+	}{
+		{"目录中的文件", false,
+			FileEvent{Op: "created", Path: "/pub/a.txt"},
+			FileEvent{Op: "created", Path: "/pub/a.txt"}, true},
+		{"不监听子目录", false,
+			FileEvent{Op: "created", Path: "/pub/sub/a.txt"}, FileEvent{}, false},
+		{"子目录", true,
+			FileEvent{Op: "created", Path: "/pub/sub/a.txt"},
+			FileEvent{Op: "created", Path: "/pub/sub/a.txt"}, true},
+		{"移动到有权限的目录", false,
+			FileEvent{Op: "renamed", Path: "/pub/sub/a.txt", OldPath: "/pub/a.txt"},
+			FileEvent{Op: "renamed", Path: "/pub/sub/a.txt", OldPath: "/pub/a.txt"}, true},
+		{"移动到没有权限的目录", false,
+			FileEvent{Op: "renamed", Path: "/private/a.txt", OldPath: "/pub/a.txt"},
+			FileEvent{Op: "deleted", Path: "/pub/a.txt"}, true},
+		{"从没有权限的目录移入", false,
+			FileEvent{Op: "renamed", Path: "/pub/a.txt", OldPath: "/private/a.txt"},
+			FileEvent{Op: "created", Path: "/pub/a.txt"}, true},
+		{"目录外的移动", true,
+			FileEvent{Op: "renamed", Path: "/private/b.txt", OldPath: "/private/a.txt"}, FileEvent{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := api.visibleEvent("user", "/pub", tt.recursive, tt.event)
+			if ok != tt.wantOk || ok && got != tt.want {
+				t.Errorf("visibleEvent() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+// TestFileEventsMerge 其他实例用户的操作在本实例监听到时没有操作者，从redis收到后丢弃本地的事件
+func TestFileEventsMerge(t *testing.T) {
+	e := &FileEvents{
+		publisher: utils.NewPublisher[FileEvent](),
+		delayed:   make(chan FileEvent, fsEventQueueSize),
+		seen:      make(map[string]time.Time),
+	}
+	subscriber := e.publisher.CreateSubscriber()
+	defer subscriber.Close()
+	go e.runDelayed()
+
+	now := time.Now().Add(-fsEventMergeDelay)
+	remote := FileEvent{Op: "created", Path: "/a.txt", Actor: "alice"}
+	e.markSeen(remote)
+	e.delayed <- FileEvent{Op: "created", Path: "/a.txt", Time: now}
+	e.delayed <- FileEvent{Op: "created", Path: "/b.txt", Time: now}
+	close(e.delayed)
+
+	select {
+	case fe := <-subscriber.Messages():
+		if fe.Path != "/b.txt" {
+			t.Errorf("收到事件 %+v, want /b.txt", fe)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("没有收到 /b.txt 的事件")
+	}
+}
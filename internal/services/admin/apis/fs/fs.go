@@ -1,16 +1,24 @@
 package fs
 
 import (
+	"encoding/json"
 	"fmt"
 	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
 	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/common/repository"
+	"go-file-server/internal/common/types"
 	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/job"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/apis/quota"
 	"go-file-server/internal/services/admin/apis/role"
+	"go-file-server/internal/services/admin/apis/webhook"
 	"go-file-server/pkgs/cache"
 	"go-file-server/pkgs/pathtool"
+	"go-file-server/pkgs/session"
+	"go-file-server/pkgs/thumbnail"
 	"go-file-server/pkgs/utils/limiter"
-	resourcemanager "go-file-server/pkgs/utils/resource-manager"
 	"go-file-server/pkgs/zlog"
 	"os"
 	"strconv"
@@ -18,6 +26,7 @@ import (
 	"time"
 
 	"github.com/casbin/casbin/v2"
+	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 )
 
@@ -25,38 +34,81 @@ type FsApi struct {
 	Authenticator  *middlewares.Authenticator
 	roleRepo       *repository.RoleRepository
 	fsRepo         *repository.FsRepository
+	fsAliasRepo    *repository.RoleFsAliasRepository
 	casbinEnforcer *casbin.CachedEnforcer
 	cache          cache.AdapterCache
+	//在线会话以及并发计数，用于限制并发传输数
+	sessions *session.Manager
+	//流量配额，用于上传下载时检查和记录用量
+	quotaManager *quota.Manager
+	//存储配额，用于上传、移动、解压时检查目录占用
+	storageManager *quota.StorageManager
+	//缩略图生成器，用于thumbnail.go生成和缓存图片缩略图
+	thumbnails *thumbnail.Generator
 	//流量限速器，用于download.go下载文件限速
 	limiterManager utils.LimiterManager
 	//双向map, 用于获取下载链接时，缓存下载元数据和路径id的对应关系
 	idManager utils.IdManager
-	//消息发布器，用于unarchiver.go解压文件时向多个客户端推送解压日志
-	publisherManager *resourcemanager.ResourceManager[*utils.Publisher[utils.Message]]
+	//后台任务队列，解压、压缩、复制等耗时操作在后台执行，见jobs.go
+	jobs *job.Manager
+	//目录变化事件，用于events.go推送目录中的变化
+	events *FileEvents
+	//记录修改路径的用户，目录变化事件中的操作者
+	actors *utils.ActorTracker
+	//webhook，上传、删除、移动、解压以及通过链接下载时触发
+	webhooks *webhook.Dispatcher
+	//文件访问审计，记录上传、下载以及修改文件的操作
+	audit *audit.Recorder
 	sync.RWMutex
 }
 
 func NewFsApi(
 	roleRepo *repository.RoleRepository,
 	fsRepo *repository.FsRepository,
+	fsAliasRepo *repository.RoleFsAliasRepository,
 	casbinEnforcer *casbin.CachedEnforcer,
 	cache cache.AdapterCache,
-
+	sessions *session.Manager,
+	quotaManager *quota.Manager,
+	storageManager *quota.StorageManager,
+	thumbnails *thumbnail.Generator,
+	jobs *job.Manager,
+	events *FileEvents,
+	actors *utils.ActorTracker,
+	webhooks *webhook.Dispatcher,
+	audit *audit.Recorder,
 ) *FsApi {
-	return &FsApi{
-		roleRepo:         roleRepo,
-		fsRepo:           fsRepo,
-		casbinEnforcer:   casbinEnforcer,
-		cache:            cache,
-		limiterManager:   *utils.NewLimiterManager(30*time.Minute, 30*time.Minute),
-		publisherManager: resourcemanager.NewResourceManager[*utils.Publisher[utils.Message]](),
-		idManager:        *utils.NewIdManager(3*time.Hour, 3*time.Hour),
+	api := &FsApi{
+		roleRepo:       roleRepo,
+		fsRepo:         fsRepo,
+		fsAliasRepo:    fsAliasRepo,
+		casbinEnforcer: casbinEnforcer,
+		cache:          cache,
+		sessions:       sessions,
+		quotaManager:   quotaManager,
+		storageManager: storageManager,
+		thumbnails:     thumbnails,
+		limiterManager: *utils.NewLimiterManager(30*time.Minute, 30*time.Minute, utils.WithAdapterCache(cache)),
+		jobs:           jobs,
+		events:         events,
+		actors:         actors,
+		webhooks:       webhooks,
+		audit:          audit,
+		idManager:      *utils.NewIdManager(3*time.Hour, 3*time.Hour),
 	}
+	api.registerJobs()
+	return api
 }
 func (api *FsApi) execRename(realPath, destination string) error {
 	if realPath == destination {
 		return nil
 	}
+	if err := utils.AssertRemovable(realPath); err != nil {
+		return core.NewApiBizErr(err).SetMsg(err.Error())
+	}
+	if err := utils.AssertWritable(destination); err != nil {
+		return core.NewApiBizErr(err).SetMsg(err.Error())
+	}
 	err := api.fsRepo.Rename(realPath, destination)
 	if err != nil {
 		ok, err := utils.ParsePathErr(err)
@@ -83,7 +135,11 @@ func (api *FsApi) getLimiter(uid int, roleKey string) (*limiter.Limiter, error)
 
 }
 
-func (api *FsApi) ensureTempDir(roleKey string) (string, error) {
+func (api *FsApi) ensureTempDir(realPath, roleKey string) (string, error) {
+	// 挂载点配置了回收站时使用挂载点的回收站，避免跨设备移动
+	if trashDir, ok := utils.GetTrashDir(realPath, roleKey); ok {
+		return trashDir, api.fsRepo.MkdirAll(trashDir, os.ModePerm)
+	}
 
 	tempPath, err := EnsureTempDir(roleKey)
 	if err != nil {
@@ -115,6 +171,77 @@ func GetRaleLimiteBytes(roleKey string, ch cache.AdapterCache,
 	return data.RateLimit, err
 }
 
+// GetRoleLimits 获取角色的并发限制
+func GetRoleLimits(roleKey string, ch cache.AdapterCache,
+	roleRepo *repository.RoleRepository) (session.Limits, error) {
+
+	var limits session.Limits
+	key := fmt.Sprintf("%s-%s", role.ConnLimitsKey, roleKey)
+	data, err := ch.Get(key)
+	if err == nil {
+		return limits, json.Unmarshal([]byte(data), &limits)
+	}
+	if !cache.IsKeyNotFoundError(err) {
+		return limits, err
+	}
+	sysRole, err := roleRepo.FindOne(repository.WithRoleKey(roleKey))
+	if err != nil {
+		return limits, err
+	}
+	limits = session.Limits{
+		MaxSessions:  sysRole.MaxSessions,
+		MaxTransfers: sysRole.MaxTransfers,
+		MaxIpConns:   sysRole.MaxIpConns,
+	}
+	b, err := json.Marshal(limits)
+	if err != nil {
+		return limits, err
+	}
+	return limits, ch.Set(key, string(b), 0)
+}
+
+// acquireTransfer 占用一个并发传输名额，超出限制时返回429
+func (api *FsApi) acquireTransfer(c *gin.Context, claims *types.JwtClaims) (func(), error) {
+	limits, err := GetRoleLimits(claims.RoleKey, api.cache, api.roleRepo)
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.sessions.AcquireTransfer(
+		claims.UserId, claims.Username, core.GetClientIP(c), limits)
+	if err != nil {
+		return nil, core.NewApiErr(err).
+			SetHttpCode(global.TooManyRequests).
+			SetBizCode(global.BizRateLimitExceeded).
+			SetMsg(err.Error())
+	}
+	return release, nil
+}
+
+// checkQuota 检查流量配额，超出配额时返回429
+func (api *FsApi) checkQuota(claims *types.JwtClaims, direction string) error {
+	err := api.quotaManager.Check(claims.UserId, claims.RoleKey, direction)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, quota.ErrQuotaExceeded) {
+		return core.NewApiErr(err).
+			SetHttpCode(global.TooManyRequests).
+			SetBizCode(global.BizRateLimitExceeded).
+			SetMsg(err.Error())
+	}
+	return err
+}
+
+// storageErr 超出存储配额时返回业务错误
+func storageErr(err error) error {
+	if errors.Is(err, quota.ErrStorageExceeded) {
+		return core.NewApiBizErr(err).
+			SetBizCode(global.BizStorageExceeded).
+			SetMsg(err.Error())
+	}
+	return err
+}
+
 func EnsureTempDir(roleKey string) (string, error) {
 	tempPath, err := utils.GetRealPath(".tmp", roleKey)
 	if err != nil {
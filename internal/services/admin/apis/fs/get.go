@@ -7,10 +7,13 @@ import (
 	"go-file-server/internal/services/admin/apis/fs/utils"
 	"go-file-server/internal/services/admin/apis/role"
 	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/config"
 	"go-file-server/pkgs/pathtool"
 	"go-file-server/pkgs/utils/concurrentpool"
 	"go-file-server/pkgs/utils/str"
 	"go-file-server/pkgs/zlog"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -21,8 +24,9 @@ import (
 )
 
 type Item struct {
-	Name    string `json:"name"`
-	Mtime   string `json:"mtime"`
+	Name  string `json:"name"`
+	Mtime string `json:"mtime"`
+	// RoleDir 授权目录的路径，根目录下的授权目录 Name 为虚拟目录名称，通过 RoleDir 访问
 	RoleDir string `json:"roleDir"`
 	Type    string `json:"type"`
 	Size    string `json:"size"`
@@ -115,7 +119,7 @@ func (api *FsApi) getPage(c *gin.Context, getReq GetReq) (GetPageRep, error) {
 	roleKey := core.ExtractClaims(c).RoleKey
 
 	if strings.HasPrefix(getReq.Path, "/.tmp") {
-		_, err := api.ensureTempDir(roleKey)
+		_, err := api.ensureTempDir("", roleKey)
 		if err != nil {
 			return GetPageRep{}, err
 		}
@@ -143,13 +147,16 @@ func (api *FsApi) listNormalPath(roleKey, realPath string, getReq GetReq) (GetPa
 		getReq.Rid = roleDir
 		return api.listPath(realPath, getReq)
 	}
-	policies := api.casbinEnforcer.GetFilteredPolicy(0, roleKey, "", "GET", "fs")
-	if len(policies) == 0 {
+	roots, err := role.GetFsRoots(roleKey, api.casbinEnforcer, api.cache, api.fsAliasRepo)
+	if err != nil {
+		return data, err
+	}
+	if len(roots) == 0 {
 		return data, core.NewApiBizErr(nil).SetMsg("无任何目录权限，请联系管理员赋权")
 	}
-	for _, p := range policies {
+	for _, root := range roots {
 
-		fsPath := role.ParseFsRolepath(p[1])
+		fsPath := root.Path
 		if fsPath == "/" {
 			return api.listPath(realPath, getReq)
 		}
@@ -169,7 +176,7 @@ func (api *FsApi) listNormalPath(roleKey, realPath string, getReq GetReq) (GetPa
 		}
 		data.Count += 1
 		items := makeItem(fsPath, filesDetails)
-		items.Name = fsPath
+		items.Name = root.Name
 		data.Items = append(
 			data.Items,
 			items,
@@ -182,7 +189,7 @@ func (api *FsApi) listNormalPath(roleKey, realPath string, getReq GetReq) (GetPa
 
 func (api *FsApi) listAdminPath(realPath string, getReq GetReq) (GetPageRep, error) {
 	if getReq.OnlyDir {
-		return api.listPathOnlyDir(realPath)
+		return api.listPathOnlyDir(realPath, getReq)
 	}
 	return api.listPath(realPath, getReq)
 }
@@ -197,14 +204,12 @@ func makeItem(roledir string, f pathtool.FilesDetails) Item {
 	}
 }
 
-func (api *FsApi) find(path string, req GetReq) ([]repository.FileDocument, uint64, error) {
-	querys := []repository.FsScope{
-		repository.WithPagination(req.PageIndex, req.PageSize),
-	}
+func (api *FsApi) find(path string, req GetReq, page repository.FsScope) ([]repository.FileDocument, uint64, error) {
+	querys := []repository.FsScope{page}
 
 	if req.Name != "" {
 		querys = append(querys,
-			repository.WithParentPathPrefix(path),
+			repository.WithParentPathPrefix(searchRoots(path)...),
 			repository.WithRegexpName(".*"+req.Name+".*"))
 
 	} else {
@@ -217,9 +222,21 @@ func (api *FsApi) find(path string, req GetReq) ([]repository.FileDocument, uint
 
 }
 
-func (api *FsApi) listPathOnlyDir(path string) (GetPageRep, error) {
+func (api *FsApi) listPathOnlyDir(path string, getReq GetReq) (GetPageRep, error) {
 	var data GetPageRep
 	data.Items = []Item{}
+	if !utils.IsIndexed(path) {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return data, errors.WithStack(err)
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				data.Items = append(data.Items, Item{Name: e.Name()})
+			}
+		}
+		return data, nil
+	}
 	filesList, _, err := api.fsRepo.Find(
 		repository.WithTermParentPath(path),
 		repository.WithIsDir(true),
@@ -230,6 +247,11 @@ func (api *FsApi) listPathOnlyDir(path string) (GetPageRep, error) {
 	for _, f := range filesList {
 		data.Items = append(data.Items, Item{Name: f.Name})
 	}
+	if getReq.Path == "/" {
+		for _, m := range utils.GetMounts() {
+			data.Items = append(data.Items, Item{Name: m.Name})
+		}
+	}
 	return data, nil
 }
 
@@ -242,8 +264,20 @@ func parseRoleDir(rid string) (string, error) {
 
 func (api *FsApi) listPath(realPath string, getReq GetReq) (GetPageRep, error) {
 	var data GetPageRep
+	if !utils.IsIndexed(realPath) {
+		return listDiskPath(realPath, getReq)
+	}
 
-	filesList, total, err := api.find(realPath, getReq)
+	// 根目录下挂载点排在索引中的文件之前，一起参与分页
+	var mounts []Item
+	mountCount := 0
+	page := repository.WithPagination(getReq.PageIndex, getReq.PageSize)
+	if getReq.Path == "/" && getReq.Name == "" {
+		all := mountItems(getReq)
+		mountCount = len(all)
+		mounts, page = mountPage(all, getReq.PageIndex, getReq.PageSize)
+	}
+	filesList, total, err := api.find(realPath, getReq, page)
 	if err != nil {
 		return data, errors.WithStack(err)
 	}
@@ -268,14 +302,98 @@ func (api *FsApi) listPath(realPath string, getReq GetReq) (GetPageRep, error) {
 	}
 	pool.Wait()
 	close(itemsChan)
+	data.Items = mounts
 	for item := range itemsChan {
 		data.Items = append(data.Items, item)
 	}
-	data.Count = int64(total)
+	data.Count = int64(total) + int64(mountCount)
 	data.PageSize = len(data.Items)
 	return data, nil
 }
 
+// mountItems 根目录下展示的挂载点
+func mountItems(getReq GetReq) []Item {
+	var items []Item
+	for _, m := range utils.GetMounts() {
+		details := pathtool.NewFiletool(m.Path).GetFsDetails()
+		if details.Err != nil {
+			zlog.SugLog.Error(details.Err)
+			continue
+		}
+		item := makeItem(getReq.Rid, details)
+		item.Name = m.Name
+		items = append(items, item)
+	}
+	return items
+}
+
+// mountPage 返回当前页中的挂载点，以及扣除挂载点后索引查询的偏移量和数量
+func mountPage(mounts []Item, index, size int) ([]Item, repository.FsScope) {
+	if size <= 0 {
+		size = 10
+	}
+	start := max((index-1)*size, 0)
+	end := start + size
+	n := len(mounts)
+	from := max(start, n)
+	return mounts[min(start, n):min(end, n)], repository.WithOffset(from-n, end-from)
+}
+
+// listDiskPath 未建立索引的挂载点直接读取磁盘，name只匹配当前目录
+func listDiskPath(realPath string, getReq GetReq) (GetPageRep, error) {
+	var data GetPageRep
+	entries, err := os.ReadDir(realPath)
+	if err != nil {
+		return data, errors.WithStack(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if getReq.Name != "" && !strings.Contains(e.Name(), getReq.Name) {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	start, end := pageRange(len(names), getReq.PageIndex, getReq.PageSize)
+	for _, name := range names[start:end] {
+		item, err := processFile(filepath.Join(realPath, name), realPath, GetReq{Rid: getReq.Rid})
+		if err != nil {
+			continue
+		}
+		data.Items = append(data.Items, item)
+	}
+	data.Count = int64(len(names))
+	data.PageSize = len(data.Items)
+	return data, nil
+}
+
+func pageRange(total, index, size int) (int, int) {
+	if size <= 0 {
+		size = 10
+	}
+	start := (index - 1) * size
+	if start < 0 {
+		start = 0
+	}
+	if start > total {
+		start = total
+	}
+	return start, min(start+size, total)
+}
+
+// searchRoots 在根目录搜索时同时搜索已建立索引的挂载点
+func searchRoots(realPath string) []string {
+	roots := []string{realPath}
+	if realPath != config.ApplicationCfg.Basedir {
+		return roots
+	}
+	for _, m := range utils.GetMounts() {
+		if m.Index {
+			roots = append(roots, m.Path)
+		}
+	}
+	return roots
+}
+
 func processFile(filePath, findPath string, getReq GetReq) (Item, error) {
 	var item Item
 	details := pathtool.NewFiletool(filePath).GetFsDetails()
@@ -290,11 +408,11 @@ func processFile(filePath, findPath string, getReq GetReq) (Item, error) {
 		return item, nil
 	}
 
-	normalizedPrefix := findPath
+	normalizedPrefix := utils.GetVirtualPath(findPath)
 	if normalizedPrefix != "/" {
 		normalizedPrefix += "/"
 	}
 
-	item.Name = strings.TrimPrefix(details.Path, normalizedPrefix)
+	item.Name = strings.TrimPrefix(utils.GetVirtualPath(details.Path), normalizedPrefix)
 	return item, nil
 }
@@ -0,0 +1,99 @@
+package fs
+
+import (
+	"encoding/json"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/services/admin/apis/job"
+	"go-file-server/pkgs/zlog"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/pkg/errors"
+)
+
+// 文件操作的后台任务类型
+const (
+	JobUnarchive = "unarchive"
+	JobExtract   = "extract"
+	JobCompress  = "compress"
+	JobCopy      = "copy"
+	JobMove      = "move"
+	JobReindex   = "reindex"
+	JobPurge     = "purge"
+)
+
+func (api *FsApi) registerJobs() {
+	for _, t := range []job.Type{
+		// 解压和复制重新执行时跳过已经写入的文件
+		{Name: JobUnarchive, Concurrency: 2, MaxAttempts: 2, Resumable: true,
+			Prepare: api.prepareUnarchive, Run: api.runUnarchive},
+		{Name: JobExtract, Concurrency: 2, MaxAttempts: 2, Resumable: true,
+			Prepare: api.prepareExtract, Run: api.runExtract},
+		{Name: JobCompress, Concurrency: 2, MaxAttempts: 2, Resumable: true,
+			Prepare: api.prepareCompress, Run: api.runCompress},
+		{Name: JobCopy, Concurrency: 2, MaxAttempts: 3, Resumable: true,
+			Prepare: api.prepareCopy, Run: api.runCopy, Discard: api.discardCopy},
+		// 跨设备移动中断后源和目标可能都不完整，不自动重新执行
+		{Name: JobMove, Concurrency: 2, MaxAttempts: 1,
+			Prepare: api.prepareMove, Run: api.runMove},
+		{Name: JobReindex, Concurrency: 1, MaxAttempts: 2, Resumable: true, AdminOnly: true,
+			Prepare: api.prepareReindex, Run: api.runReindex},
+		{Name: JobPurge, Concurrency: 1, MaxAttempts: 3, Resumable: true,
+			Prepare: api.preparePurge, Run: api.runPurge},
+	} {
+		api.jobs.Register(t)
+	}
+}
+
+// streamJob 提交任务并通过sse推送任务日志，用于原有的sse接口，
+// 响应头 X-Job-Id 为任务id，客户端断开后可以通过 /sse/jobs/:id 重新订阅
+func (api *FsApi) streamJob(c *gin.Context, name string, params any) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	data, err := api.jobs.Submit(core.ExtractClaims(c), name, raw, 0)
+	if err != nil {
+		var apiErr *core.ApiErr
+		if errors.As(err, &apiErr) {
+			core.OnceStream(c, job.MessageError, apiErr.Error())
+			return
+		}
+		zlog.SugLog.Error(err)
+		core.OnceStream(c, job.MessageError, "内部错误")
+		return
+	}
+	c.Header("X-Job-Id", strconv.Itoa(data.Id))
+	api.jobs.Stream(c, data.Id)
+}
+
+// bindParams 解析并校验任务参数
+func bindParams(raw json.RawMessage, v any) error {
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, v); err != nil {
+			return core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg("任务参数格式错误")
+		}
+	}
+	return binding.Validator.ValidateStruct(v)
+}
+
+// jobErr 将接口错误转换为不重试的任务错误，其他错误可以重试
+func jobErr(err error) error {
+	var apiErr *core.ApiErr
+	if errors.As(err, &apiErr) {
+		return job.Fail(apiErr.Error(), apiErr.GetRawErr())
+	}
+	var sseErr *core.SseErr
+	if errors.As(err, &sseErr) {
+		return job.Fail(sseErr.Error(), sseErr.GetRawErr())
+	}
+	return err
+}
+
+// accessDenied 提交任务时的无权限错误，不使用401避免前端退出登录
+func accessDenied(err error, msg string) error {
+	return core.NewApiBizErr(err).SetBizCode(global.BizAccessDenied).SetMsg(msg)
+}
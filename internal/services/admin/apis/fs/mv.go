@@ -1,10 +1,16 @@
 package fs
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"go-file-server/internal/common/core"
 	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/types"
 	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/job"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/apis/webhook"
 	"go-file-server/internal/services/admin/models"
 	"path/filepath"
 
@@ -25,21 +31,28 @@ func (api *FsApi) move(c *gin.Context, req UpdateReq) {
 		return
 	}
 
-	if err := api.execMove(c, req); err != nil {
+	claims := core.ExtractClaims(c)
+	entry := auditEntry(c, audit.ActionMove)
+	if err := api.execMove(claims.RoleKey, entry, req.Path, req.Destination); err != nil {
 		c.Error(err)
 		return
 	}
 
 	core.OKRep(nil).SendGin(c)
 }
-func (api *FsApi) checMovePermission(c *gin.Context, req UpdateReq) error {
-	roleKey := core.ExtractClaims(c).RoleKey
+
+// checkMovePermission 移动需要源路径的删除权限以及目标目录的创建权限
+func (api *FsApi) checkMovePermission(roleKey, path, dest string) error {
 	if roleKey == models.AdminRoleKey {
 		return nil
 	}
+	srcApi, err := utils.SafeJoinPath("/api/v1/fs", path)
+	if err != nil {
+		return err
+	}
 	ok, err := api.casbinEnforcer.Enforce(
 		roleKey,
-		c.Request.URL.Path,
+		srcApi,
 		"DELETE",
 	)
 	if err != nil {
@@ -47,12 +60,12 @@ func (api *FsApi) checMovePermission(c *gin.Context, req UpdateReq) error {
 	}
 
 	if !ok {
-		err = errors.Errorf("role: %s , path:%s, 无删除权限", roleKey, c.Request.URL.Path)
+		err = errors.Errorf("role: %s , path:%s, 无删除权限", roleKey, srcApi)
 		return core.NewApiBizErr(err).SetBizCode(global.BizAccessDenied).
-			SetMsg(fmt.Sprintf("您没有当前目录 %s 的删除权限", req.Path))
+			SetMsg(fmt.Sprintf("您没有当前目录 %s 的删除权限", path))
 	}
 
-	desApi, err := utils.SafeJoinPath("/api/v1/fs", req.Destination)
+	desApi, err := utils.SafeJoinPath("/api/v1/fs", dest)
 
 	if err != nil {
 		return err
@@ -72,31 +85,59 @@ func (api *FsApi) checMovePermission(c *gin.Context, req UpdateReq) error {
 		err = errors.Errorf("role: %s , path:%s, 无创建权限", roleKey, desApi)
 
 		return core.NewApiBizErr(err).SetBizCode(global.BizAccessDenied).
-			SetMsg(fmt.Sprintf("您没有目标目录 %s 创建资源的权限", req.Destination))
+			SetMsg(fmt.Sprintf("您没有目标目录 %s 创建资源的权限", dest))
 	}
 	return nil
 
 }
 
-func (api *FsApi) execMove(c *gin.Context, req UpdateReq) error {
-	err := api.checMovePermission(c, req)
+// movePaths 检查权限和存储配额，返回源和目标的真实路径
+func (api *FsApi) movePaths(roleKey, path, dest string) (string, string, error) {
+	err := api.checkMovePermission(roleKey, path, dest)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
-	realPath, err := utils.GetRealPath(req.Path)
+	realPath, err := utils.GetRealPath(path)
 	if err != nil {
-		return core.NewApiBizErr(err).SetMsg(err.Error())
+		return "", "", core.NewApiBizErr(err).SetMsg(err.Error())
 	}
 
-	destination, err := utils.GetRealPath(req.Destination, filepath.Base(realPath))
+	destination, err := utils.GetRealPath(dest, filepath.Base(realPath))
 	if err != nil {
-		return core.NewApiBizErr(err).SetMsg(err.Error())
+		return "", "", core.NewApiBizErr(err).SetMsg(err.Error())
 
 	}
 
-	return api.execRename(realPath, destination)
+	err = api.storageManager.CheckMove(roleKey, realPath, destination)
+	if err != nil {
+		return "", "", storageErr(err)
+	}
+	return realPath, destination, nil
+}
 
+// execMove entry 为填写了用户的审计记录，其中的用户同时用于目录变化事件和webhook
+func (api *FsApi) execMove(roleKey string, entry models.SysFileAudit, path, dest string) error {
+	realPath, destination, err := api.movePaths(roleKey, path, dest)
+	if err != nil {
+		return err
+	}
+	defer api.storageManager.ResetUsage(realPath, destination)
+	defer api.actors.Hold(realPath, entry.Actor)()
+	defer api.actors.Hold(destination, entry.Actor)()
+	err = api.execRename(realPath, destination)
+	entry.Path, entry.Dest = realPath, destination
+	api.audit.Record(entry, err)
+	if err != nil {
+		return err
+	}
+	api.webhooks.Emit(webhook.EventFileMoved, webhook.EventData{
+		Path:     utils.GetVirtualPath(destination),
+		OldPath:  utils.GetVirtualPath(realPath),
+		Actor:    entry.Actor,
+		ClientIp: entry.ClientIp,
+	})
+	return nil
 }
 
 func validateMoveFiled(req UpdateReq) error {
@@ -105,3 +146,39 @@ func validateMoveFiled(req UpdateReq) error {
 	}
 	return nil
 }
+
+// moveParams 移动和复制任务的参数
+type moveParams struct {
+	Path        string `json:"path" binding:"required"`
+	Destination string `json:"destination" binding:"required"`
+}
+
+func (api *FsApi) prepareMove(claims *types.JwtClaims, raw json.RawMessage) (string, any, error) {
+	var params moveParams
+	if err := bindParams(raw, &params); err != nil {
+		return "", nil, err
+	}
+	if params.Path == params.Destination {
+		return "", nil, core.NewApiBizErr(nil).SetMsg("源路径和目标路径相同")
+	}
+	realPath, _, err := api.movePaths(claims.RoleKey, params.Path, params.Destination)
+	if err != nil {
+		return "", nil, err
+	}
+	return realPath, params, nil
+}
+
+// runMove 跨设备移动大目录时耗时较长，在后台任务中执行
+func (api *FsApi) runMove(ctx context.Context, task *job.Task) error {
+	var params moveParams
+	if err := task.Bind(&params); err != nil {
+		return err
+	}
+	task.Log(fmt.Sprintf("%s -> %s", params.Path, params.Destination))
+	entry := jobAuditEntry(task, audit.ActionMove)
+	if err := api.execMove(task.Job.RoleKey, entry, params.Path, params.Destination); err != nil {
+		return jobErr(err)
+	}
+	task.Complete("移动完成")
+	return nil
+}
@@ -0,0 +1,295 @@
+package fs
+
+import (
+	"fmt"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/apis/quota"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultPreviewBytes 预览默认读取的字节数
+	defaultPreviewBytes = 256 << 10
+	// maxTextBytes 预览和在线编辑的最大字节数，超过时只能下载
+	maxTextBytes = 4 << 20
+)
+
+// genETag 根据修改时间和大小生成ETag，用于保存时检查文件是否被修改
+func genETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+type GetPreviewReq struct {
+	utils.UriPath
+	// MaxBytes 读取的最大字节数，默认256KB
+	MaxBytes int64 `form:"maxBytes" binding:"min=0,max=4194304"`
+}
+
+type GetPreviewRep struct {
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"modTime"`
+	ETag      string    `json:"etag"`
+	Encoding  string    `json:"encoding"`
+	Language  string    `json:"language"`
+	Content   string    `json:"content"`
+	Truncated bool      `json:"truncated"`
+	// Editable 内容完整且路径可写时才允许在线编辑
+	Editable bool `json:"editable"`
+}
+
+// GetPreview 预览文本文件，自动检测编码并返回代码高亮使用的语言
+func (api *FsApi) GetPreview(c *gin.Context) {
+	var req GetPreviewReq
+	err := core.ShouldBinds(c, &req, core.BindUri, core.BindQuery)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if req.MaxBytes == 0 {
+		req.MaxBytes = defaultPreviewBytes
+	}
+	err = api.checkDownloadPermission(core.ExtractClaims(c).RoleKey, req.Path)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	realPath, info, err := statTextFile(req.Path)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	f, err := os.Open(realPath)
+	if err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, req.MaxBytes))
+	entry := auditEntry(c, audit.ActionPreview)
+	entry.Path, entry.Bytes = realPath, int64(len(data))
+	api.audit.Record(entry, err)
+	if err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	encoding, err := utils.DetectEncoding(data)
+	if err != nil {
+		c.Error(core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(err.Error()))
+		return
+	}
+	content, err := utils.DecodeText(data, encoding)
+	if err != nil {
+		c.Error(core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(utils.ErrNotText.Error()))
+		return
+	}
+
+	truncated := int64(len(data)) < info.Size()
+	etag := genETag(info)
+	c.Header("ETag", etag)
+	core.OKRep(GetPreviewRep{
+		Path:      utils.GetVirtualPath(realPath),
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		ETag:      etag,
+		Encoding:  encoding,
+		Language:  utils.GetLanguage(realPath),
+		Content:   content,
+		Truncated: truncated,
+		Editable:  !truncated && utils.AssertWritable(realPath) == nil,
+	}).SendGin(c)
+}
+
+type SaveTextReq struct {
+	utils.UriPath
+	Content string `json:"content"`
+	// Encoding 保存使用的编码，为空时使用文件当前的编码
+	Encoding string `json:"encoding"`
+	// ETag 预览时返回的ETag，也可以通过 If-Match 请求头传递
+	ETag string `json:"etag"`
+}
+
+type SaveTextRep struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	ETag    string    `json:"etag"`
+}
+
+// SaveText 保存在线编辑的文本，文件在预览后被修改时返回冲突
+func (api *FsApi) SaveText(c *gin.Context) {
+	var req SaveTextReq
+	err := core.ShouldBinds(c, &req, core.BindUri, core.BindJson)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if req.ETag == "" {
+		req.ETag = c.GetHeader("If-Match")
+	}
+	if req.ETag == "" {
+		err = errors.New("缺少ETag")
+		c.Error(core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(err.Error()))
+		return
+	}
+	claims := core.ExtractClaims(c)
+	err = api.checkFsPermission(claims.RoleKey, req.Path, "POST")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	realPath, info, err := statTextFile(req.Path)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := utils.AssertWritable(realPath); err != nil {
+		c.Error(core.NewApiBizErr(err).SetMsg(err.Error()))
+		return
+	}
+	if info.Size() > maxTextBytes {
+		err = errors.Errorf("文件超过 %s，不支持在线编辑", core.FormatBytes(maxTextBytes))
+		c.Error(core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(err.Error()))
+		return
+	}
+	if req.ETag != genETag(info) {
+		c.Error(core.NewApiBizErr(errTextConflict).
+			SetHttpCode(global.ConflictError).
+			SetBizCode(global.BizConflict).
+			SetMsg(errTextConflict.Error()))
+		return
+	}
+	if req.Encoding == "" {
+		if req.Encoding, err = detectFileEncoding(realPath); err != nil {
+			c.Error(core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(err.Error()))
+			return
+		}
+	}
+	data, err := utils.EncodeText(req.Content, req.Encoding)
+	if err != nil {
+		c.Error(core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(err.Error()))
+		return
+	}
+	if len(data) > maxTextBytes {
+		err = errors.Errorf("内容超过 %s，不支持在线编辑", core.FormatBytes(maxTextBytes))
+		c.Error(core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(err.Error()))
+		return
+	}
+
+	if err := api.checkQuota(claims, quota.Upload); err != nil {
+		c.Error(err)
+		return
+	}
+	if delta := int64(len(data)) - info.Size(); delta > 0 {
+		if err := api.storageManager.Check(claims.RoleKey, realPath, delta); err != nil {
+			c.Error(storageErr(err))
+			return
+		}
+	}
+
+	api.Lock()
+	newInfo, err := api.writeText(realPath, info, data)
+	api.Unlock()
+	entry := auditEntry(c, audit.ActionWrite)
+	entry.Path, entry.Bytes = realPath, int64(len(data))
+	api.audit.Record(entry, err)
+	if err != nil {
+		if errors.Is(err, errTextConflict) {
+			c.Error(core.NewApiBizErr(err).
+				SetHttpCode(global.ConflictError).
+				SetBizCode(global.BizConflict).
+				SetMsg(err.Error()))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	counter := api.quotaManager.NewCounter(claims.UserId, quota.Upload)
+	counter.Add(len(data))
+	counter.Flush()
+	api.storageManager.ResetUsage(realPath)
+
+	etag := genETag(newInfo)
+	c.Header("ETag", etag)
+	core.OKRep(SaveTextRep{
+		Size:    newInfo.Size(),
+		ModTime: newInfo.ModTime(),
+		ETag:    etag,
+	}).SendGin(c)
+}
+
+var errTextConflict = errors.New("文件已被修改，请重新加载后再保存")
+
+// writeText 先写入同目录的临时文件再替换，避免写入中断时损坏原文件
+func (api *FsApi) writeText(realPath string, info os.FileInfo, data []byte) (os.FileInfo, error) {
+	// 加锁后再次检查，避免两个请求同时通过ETag检查
+	current, err := os.Stat(realPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if genETag(current) != genETag(info) {
+		return nil, errTextConflict
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(realPath), "."+filepath.Base(realPath)+".*")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, errors.WithStack(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.Rename(tmp.Name(), realPath); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := api.fsRepo.AddResource(realPath); err != nil {
+		return nil, err
+	}
+	newInfo, err := os.Stat(realPath)
+	return newInfo, errors.WithStack(err)
+}
+
+// statTextFile 检查路径是否为文件
+func statTextFile(path string) (string, os.FileInfo, error) {
+	isDir, realPath, err := checkPath(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if isDir {
+		err = errors.Errorf("%s 不是文件", path)
+		return "", nil, core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(err.Error())
+	}
+	info, err := os.Stat(realPath)
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+	return realPath, info, nil
+}
+
+// detectFileEncoding 检测文件当前的编码，空文件使用utf-8
+func detectFileEncoding(realPath string) (string, error) {
+	data, err := os.ReadFile(realPath)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if len(data) == 0 {
+		return utils.EncodingUTF8, nil
+	}
+	return utils.DetectEncoding(data)
+}
@@ -0,0 +1,101 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/job"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type purgeParams struct {
+	// OlderThan 只清理删除超过该天数的条目，为0时清空回收站
+	OlderThan int `json:"olderThan" binding:"min=0"`
+}
+
+func (api *FsApi) preparePurge(claims *types.JwtClaims, raw json.RawMessage) (string, any, error) {
+	var params purgeParams
+	if err := bindParams(raw, &params); err != nil {
+		return "", nil, err
+	}
+	return claims.RoleKey, params, nil
+}
+
+// runPurge 清理任务所属角色的回收站，包括挂载点配置的回收站
+func (api *FsApi) runPurge(ctx context.Context, task *job.Task) error {
+	var params purgeParams
+	if err := task.Bind(&params); err != nil {
+		return err
+	}
+	before := time.Now().AddDate(0, 0, -params.OlderThan)
+	dirs, err := trashDirs(task.Job.RoleKey)
+	if err != nil {
+		return job.Fail(err.Error(), nil)
+	}
+	for _, dir := range dirs {
+		defer api.actors.Hold(dir, task.Job.Username)()
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return errors.WithStack(err)
+		}
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			if params.OlderThan > 0 && !trashTime(entry.Name(), info.ModTime()).Before(before) {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if err := api.fsRepo.RemoveAll(path); err != nil {
+				return errors.WithStack(err)
+			}
+			task.Log(utils.GetVirtualPath(path))
+			task.Add(1)
+		}
+	}
+	api.storageManager.ResetUsage(dirs...)
+	task.Complete("回收站清理完成")
+	return nil
+}
+
+// trashDirs 角色的全部回收站目录
+func trashDirs(roleKey string) ([]string, error) {
+	dir, err := utils.GetRealPath(".tmp", roleKey)
+	if err != nil {
+		return nil, err
+	}
+	dirs := []string{dir}
+	for _, m := range utils.GetMounts() {
+		if m.Trash != "" {
+			dirs = append(dirs, filepath.Join(m.Trash, roleKey))
+		}
+	}
+	return dirs, nil
+}
+
+// trashTime 从回收站条目名称中解析删除时间，名称格式为 原名称_时间，见 delete.go，
+// 解析失败时使用修改时间
+func trashTime(name string, modTime time.Time) time.Time {
+	i := strings.LastIndex(name, "_")
+	if i < 0 {
+		return modTime
+	}
+	t, err := time.ParseInLocation(utils.TimeStrLayout, name[i+1:], time.Local)
+	if err != nil {
+		return modTime
+	}
+	return t
+}
@@ -4,6 +4,8 @@ import (
 	"go-file-server/internal/common/core"
 	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/apis/webhook"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
@@ -33,9 +35,18 @@ func (api *FsApi) rename(c *gin.Context, req UpdateReq) {
 
 	newPath := filepath.Join(filepath.Dir(realPath), req.NewName)
 	err = api.execRename(realPath, newPath)
+	entry := auditEntry(c, audit.ActionRename)
+	entry.Path, entry.Dest = realPath, newPath
+	api.audit.Record(entry, err)
 	if err != nil {
 		c.Error(err)
 		return
 	}
+	api.webhooks.Emit(webhook.EventFileMoved, webhook.EventData{
+		Path:     utils.GetVirtualPath(newPath),
+		OldPath:  utils.GetVirtualPath(realPath),
+		Actor:    core.ExtractClaims(c).Username,
+		ClientIp: core.GetClientIP(c),
+	})
 	core.OKRep(nil).SendGin(c)
 }
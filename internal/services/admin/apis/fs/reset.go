@@ -1,11 +1,16 @@
 package fs
 
 import (
+	"context"
+	"encoding/json"
 	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/apis/job"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Reset 重建索引，在后台任务中执行，返回任务信息
 func (api *FsApi) Reset(c *gin.Context) {
 
 	if err := core.AssertAdmin(c); err != nil {
@@ -13,10 +18,24 @@ func (api *FsApi) Reset(c *gin.Context) {
 		return
 	}
 
-	if err := api.fsRepo.ResetIndex(); err != nil {
+	data, err := api.jobs.Submit(core.ExtractClaims(c), JobReindex, nil, 0)
+	if err != nil {
 		c.Error(err)
 		return
 	}
 
-	core.OKRep(nil).SendGin(c)
+	core.OKRep(data).SendGin(c)
+}
+
+func (api *FsApi) prepareReindex(claims *types.JwtClaims, raw json.RawMessage) (string, any, error) {
+	return JobReindex, struct{}{}, nil
+}
+
+func (api *FsApi) runReindex(ctx context.Context, task *job.Task) error {
+	task.Log("重建索引...")
+	if err := api.fsRepo.ResetIndex(); err != nil {
+		return err
+	}
+	task.Complete("索引重建完成")
+	return nil
 }
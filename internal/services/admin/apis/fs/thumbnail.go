@@ -0,0 +1,86 @@
+package fs
+
+import (
+	"context"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/pkgs/thumbnail"
+	"path/filepath"
+	"runtime"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/fx"
+)
+
+// NewThumbnailGenerator 缩略图缓存在索引目录旁，监听到文件变化时删除对应的缩略图，服务退出时释放工作池
+func NewThumbnailGenerator(lc fx.Lifecycle, fsRepo *repository.FsRepository) (*thumbnail.Generator, error) {
+	dir := filepath.Join(filepath.Dir(fsRepo.Indexer.IndexPath), ".thumbnails")
+	generator, err := thumbnail.NewGenerator(dir, runtime.NumCPU())
+	if err != nil {
+		return nil, err
+	}
+	fsRepo.Indexer.OnChange(generator.Invalidate)
+	lc.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			generator.Release()
+			return nil
+		},
+	})
+	return generator, nil
+}
+
+type GetThumbnailReq struct {
+	utils.UriPath
+	// Size 缩略图最长边的像素，默认256
+	Size int `form:"size"`
+}
+
+// GetThumbnail 获取图片的缩略图
+func (api *FsApi) GetThumbnail(c *gin.Context) {
+	var req GetThumbnailReq
+	err := core.ShouldBinds(c, &req, core.BindUri, core.BindQuery)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if req.Size == 0 {
+		req.Size = thumbnail.DefaultSize
+	}
+	if !slices.Contains(thumbnail.Sizes, req.Size) {
+		err = errors.Errorf("缩略图尺寸只能是 %v", thumbnail.Sizes)
+		c.Error(core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(err.Error()))
+		return
+	}
+	err = api.checkDownloadPermission(core.ExtractClaims(c).RoleKey, req.Path)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	isDir, realPath, err := checkPath(req.Path)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if isDir || !thumbnail.IsSupported(realPath) {
+		c.Error(core.NewApiBizErr(thumbnail.ErrUnsupported).
+			SetBizCode(global.BizBadRequest).
+			SetMsg(thumbnail.ErrUnsupported.Error()))
+		return
+	}
+
+	dest, err := api.thumbnails.Get(c, realPath, req.Size)
+	if err != nil {
+		if errors.Is(err, thumbnail.ErrUnsupported) || errors.Is(err, thumbnail.ErrTooLarge) {
+			c.Error(core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(err.Error()))
+			return
+		}
+		c.Error(errors.WithStack(err))
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.File(dest)
+}
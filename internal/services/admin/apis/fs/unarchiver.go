@@ -2,162 +2,188 @@ package fs
 
 import (
 	"context"
+	"encoding/json"
 	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/types"
 	"go-file-server/internal/services/admin/apis/fs/utils"
-	"go-file-server/pkgs/utils/timex"
+	"go-file-server/internal/services/admin/apis/job"
+	"go-file-server/internal/services/admin/apis/log/audit"
+	"go-file-server/internal/services/admin/apis/quota"
+	"go-file-server/internal/services/admin/apis/webhook"
+	zipUtil "go-file-server/pkgs/utils/zip"
 	"go-file-server/pkgs/zlog"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mholt/archiver/v4"
 	"github.com/pkg/errors"
 )
 
-// 解压标识
-const (
-	// message
-	unarchiveMessage = "message"
-	// done
-	unarchiveDone = "done"
-	// error
-	unarchiveError = "error"
-)
+type UnarchiveReq struct {
+	utils.UriPath
+	// Password 加密的zip/7z/rar压缩包的密码，sse只能使用GET请求，通过查询参数传递
+	Password string `form:"password"`
+}
 
-// 解压
+// 解压，解压在后台任务中执行，客户端断开后不会停止
 func (api *FsApi) Unarchive(c *gin.Context) {
-	var req utils.UriPath
-	err := c.ShouldBindUri(&req)
+	var req UnarchiveReq
+	err := core.ShouldBinds(c, &req, core.BindUri, core.BindQuery)
 	if err != nil {
 		c.Error(err)
 		return
 	}
-	realPath, err := utils.GetRealPath(req.Path)
-	if err != nil {
-		core.OnceStream(c, "error", err.Error())
-		return
+	api.streamJob(c, JobUnarchive, unarchiveParams{Path: req.Path, Password: req.Password})
+}
+
+type unarchiveParams struct {
+	// Path 压缩包的虚拟路径，解压到压缩包所在目录下的同名目录
+	Path string `json:"path" binding:"required"`
+	// Password 只在提交时传入，不会保存，见 job.Secret
+	Password string `json:"password,omitempty"`
+	// Encrypted 提交时带有密码
+	Encrypted bool `json:"encrypted,omitempty"`
+}
+
+func (p unarchiveParams) TakeSecret() (any, string) {
+	password := p.Password
+	p.Password, p.Encrypted = "", password != ""
+	return p, password
+}
+
+func (api *FsApi) prepareUnarchive(claims *types.JwtClaims, raw json.RawMessage) (string, any, error) {
+	var params unarchiveParams
+	if err := bindParams(raw, &params); err != nil {
+		return "", nil, err
 	}
-	//如果ok, 说明已经有进程处理解压，直接订阅日志返回给客户端
-	publisher, ok := api.publisherManager.Get(realPath)
-	if ok {
-		handleMsg(c, publisher)
-		return
+	if err := api.checkDownloadPermission(claims.RoleKey, params.Path); err != nil {
+		return "", nil, accessDenied(err, "无权限读取压缩包")
 	}
-	//如果 ok，说明已经被创建了，只需要订阅日志
-	publisher, ok = api.publisherManager.GetOrSet(realPath, utils.NewPublisher[utils.Message]())
-	if ok {
-		handleMsg(c, publisher)
-		return
+	if err := api.checkFsPermission(claims.RoleKey, path.Dir(params.Path), "POST"); err != nil {
+		return "", nil, accessDenied(err, "无权限写入目录 "+path.Dir(params.Path))
 	}
-	defer api.publisherManager.Del(realPath)
-	err = api.unarchive(c, realPath, publisher)
+	realPath, err := utils.GetRealPath(params.Path)
 	if err != nil {
-		c.Error(err)
-		return
+		return "", nil, core.NewApiBizErr(err).SetMsg(err.Error())
+	}
+	if err := utils.AssertWritable(realPath); err != nil {
+		return "", nil, core.NewApiBizErr(err).SetMsg(err.Error())
 	}
+	return realPath, params, nil
 }
 
-func (api *FsApi) unarchive(c *gin.Context, realPath string, publisher *utils.Publisher[utils.Message]) (err error) {
-	f, err := os.Open(realPath)
+func (api *FsApi) runUnarchive(ctx context.Context, task *job.Task) error {
+	var params unarchiveParams
+	if err := task.Bind(&params); err != nil {
+		return err
+	}
+	password, err := taskPassword(task, params.Encrypted)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return core.NewSseErr(errors.WithStack(err)).
-				SetMsg("待解压的文件已经不存在或移动到其他位置，请刷新界面")
-		}
 		return err
 	}
-	defer func() {
-		if cerr := f.Close(); err == nil {
-			err = errors.WithStack(cerr)
-		}
-	}()
-	extractor, err := parseExtractor(realPath, f)
+	realPath, err := utils.GetRealPath(params.Path)
 	if err != nil {
-		return errors.WithStack(err)
+		return job.Fail(err.Error(), nil)
 	}
+	f, extractor, err := openArchive(realPath, password)
+	if err != nil {
+		return jobErr(err)
+	}
+	defer f.Close()
+	defer api.actors.Hold(filepath.Dir(realPath), task.Job.Username)()
+	// 重新执行时继续使用上次创建的目录，跳过已经解压的文件
+	desName, desPath, err := createDesDir(realPath, task.Retried())
+	if err != nil {
+		return job.Fail(err.Error(), nil)
+	}
+	conflict := utils.ConflictError
+	if task.Retried() {
+		conflict = utils.ConflictSkip
+	}
+	return api.execExtractor(ctx, task, extractor, f, extractTarget{
+		name:     desName,
+		path:     desPath,
+		conflict: conflict,
+		source:   realPath,
+	})
+}
 
-	go api.execExtractor(c, extractor, f, realPath, publisher)
-	handleMsg(c, publisher)
-
-	return nil
+// extractTarget 解压的目标
+type extractTarget struct {
+	// name 目标目录在消息中显示的名称
+	name string
+	path string
+	// entries 只解压压缩包中的这些条目，为空时解压全部
+	entries  []string
+	conflict string
+	// source 压缩包的真实路径，用于审计和webhook
+	source string
 }
 
-func (api *FsApi) execExtractor(c *gin.Context, extractor archiver.Extractor,
-	sourceArchive io.Reader, path string, publisher *utils.Publisher[utils.Message]) {
-	defer publisher.Close()
-	desName, desPath, err := createDesDir(path)
+func (api *FsApi) execExtractor(ctx context.Context, task *job.Task, extractor archiver.Extractor,
+	sourceArchive io.Reader, target extractTarget) (err error) {
+	remaining, err := api.storageManager.Remaining(task.Job.RoleKey, target.path)
 	if err != nil {
-		publisher.Publish(utils.NewMessage("error", err.Error()))
-		return
+		return err
 	}
-	err = extractor.Extract(c.Request.Context(), sourceArchive, nil,
+	guard, err := utils.NewExtractGuard(target.path)
+	if err != nil {
+		return err
+	}
+	// 按实际写入的字节数累计，超出剩余配额时停止解压
+	guard.LimitStorage(remaining, errors.Wrap(quota.ErrStorageExceeded, "解压后的文件超出存储配额"))
+	defer api.storageManager.ResetUsage(target.path)
+	entry := jobAuditEntry(task, audit.ActionExtract)
+	entry.Path, entry.Dest = target.source, target.path
+	defer func() {
+		entry.Bytes = guard.Written()
+		api.audit.Record(entry, err)
+	}()
+	err = extractor.Extract(ctx, sourceArchive, target.entries,
 		func(ctx context.Context, f archiver.File) error {
-			msg := filepath.Join(desName, f.NameInArchive)
-			publisher.Publish(utils.NewMessage(unarchiveMessage, msg))
-			return utils.HandleFile(ctx, f, desPath)
+			msg := filepath.Join(target.name, f.NameInArchive)
+			err := guard.HandleFile(ctx, f, target.conflict)
+			if errors.Is(err, utils.ErrEntrySkipped) {
+				task.Skip(msg + ": " + err.Error())
+				return nil
+			}
+			task.Log(msg)
+			task.Add(1)
+			return err
 		},
 	)
 	if err != nil {
-		zlog.SugLog.Error(err)
-		if err == context.Canceled {
-			publisher.Publish(utils.NewMessage(unarchiveError, "解压被取消"))
-			return
+		if errors.Is(err, context.Canceled) {
+			return err
 		}
-		publisher.Publish(utils.NewMessage(unarchiveError, "解压失败"))
-		return
-	}
-	publisher.Publish(utils.NewMessage(unarchiveMessage, "更新索引..."))
-	err = api.fsRepo.AddResource(desPath)
-	if err != nil {
-		zlog.SugLog.Error(err)
-		publisher.Publish(utils.NewMessage(unarchiveError, "更新索引失败"))
-		return
-	}
-	publisher.Publish(utils.NewMessage(unarchiveDone, "解压完成"))
-
-}
-
-func handleMsg(c *gin.Context, publisher *utils.Publisher[utils.Message]) {
-
-	subscriber := publisher.CreateSubscriber()
-	defer subscriber.Close()
-
-	ticker := timex.NewImmediateTicker(time.Millisecond * 500)
-	defer ticker.Stop()
-	core.SetSseHeader(c)
-	var currentMessage string
-	defer c.Writer.Flush()
-	for {
-		select {
-		case message := <-subscriber.Messages():
-			currentMessage = message.K
-			c.SSEvent(message.K, message.V)
-		case <-ticker.C:
-			c.Writer.Flush()
-		case <-c.Request.Context().Done():
-			return
-		case <-publisher.Done():
-			lastMessage := publisher.LastMessage()
-
-			if !(lastMessage.K == unarchiveDone ||
-				lastMessage.K == unarchiveError) {
-				c.SSEvent(unarchiveError, "解压异常")
-				return
-			}
-			if currentMessage != lastMessage.K {
-				c.SSEvent(lastMessage.K, lastMessage.V)
-			}
-			return
+		if errors.Is(err, quota.ErrStorageExceeded) || errors.Is(err, utils.ErrFileExist) ||
+			errors.Is(err, utils.ErrExtractLimit) {
+			return job.Fail("解压失败，"+err.Error(), nil)
 		}
-
+		if perr := passwordErr(err); perr != nil {
+			return job.Fail("解压失败，"+perr.Error(), nil)
+		}
+		return job.Fail("解压失败", err)
 	}
-
+	task.Log("更新索引...")
+	if err := api.fsRepo.AddResource(target.path); err != nil {
+		return job.Fail("更新索引失败", err)
+	}
+	task.Complete("解压完成")
+	api.webhooks.Emit(webhook.EventArchiveExtracted, webhook.EventData{
+		Path:   utils.GetVirtualPath(target.path),
+		Source: utils.GetVirtualPath(target.source),
+		Actor:  task.Job.Username,
+	})
+	return nil
 }
 
-func createDesDir(path string) (string, string, error) {
+// createDesDir 创建解压的目标目录，reuse 为true时目录已存在不返回错误
+func createDesDir(path string, reuse bool) (string, string, error) {
 	baseName := filepath.Base(path)
 	baseNames := strings.Split(baseName, ".")
 	if len(baseNames) < 1 {
@@ -168,6 +194,9 @@ func createDesDir(path string) (string, string, error) {
 	err := os.Mkdir(des, 0755)
 	if err != nil {
 		if os.IsExist(err) {
+			if reuse {
+				return baseNames[0], des, nil
+			}
 			return "", "", errors.New("解压失败,当前路径中已经存在: " + baseNames[0])
 		}
 		zlog.SugLog.Error(err)
@@ -176,11 +205,44 @@ func createDesDir(path string) (string, string, error) {
 	return baseNames[0], des, nil
 }
 
-func parseExtractor(filename string, stream io.Reader) (archiver.Extractor, error) {
-	format, _, err := archiver.Identify(filename, stream)
+// openArchive 打开压缩包并识别格式，识别时读取的内容会回退，返回的文件可以直接用于解压。
+// password 用于加密的zip/7z/rar，为空时只能读取未加密的条目
+func openArchive(realPath, password string) (*os.File, archiver.Extractor, error) {
+	f, err := os.Open(realPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, core.NewSseErr(errors.WithStack(err)).
+				SetMsg("待解压的文件已经不存在或移动到其他位置，请刷新界面")
+		}
+		return nil, nil, err
+	}
+	extractor, err := parseExtractor(realPath, f, password)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, errors.WithStack(err)
+	}
+	return f, extractor, nil
+}
+
+func parseExtractor(filename string, stream io.Reader, password string) (archiver.Extractor, error) {
+	format, _, err := archiver.Identify(filepath.Base(filename), stream)
 	if err != nil {
 		return nil, core.NewSseErr(err).SetMsg("识别格式失败")
 	}
+	switch f := format.(type) {
+	case archiver.Zip:
+		// archiver 不支持加密的zip
+		return utils.ZipExtractor{Zip: f, Password: password}, nil
+	case archiver.SevenZip:
+		f.Password = password
+		return f, nil
+	case archiver.Rar:
+		f.Password = password
+		return f, nil
+	}
 
 	extractor, ok := format.(archiver.Extractor)
 	if !ok {
@@ -188,3 +250,23 @@ func parseExtractor(filename string, stream io.Reader) (archiver.Extractor, erro
 	}
 	return extractor, nil
 }
+
+// taskPassword 返回提交任务时的密码，服务重启后密码已经丢失
+func taskPassword(task *job.Task, encrypted bool) (string, error) {
+	password := task.Secret()
+	if encrypted && password == "" {
+		return "", job.Fail("服务重启后密码已失效，请重新提交任务", nil)
+	}
+	return password, nil
+}
+
+// passwordErr 返回缺少密码、密码错误等可以展示给用户的错误，其他错误返回nil
+func passwordErr(err error) error {
+	for _, target := range []error{zipUtil.ErrPasswordRequired, zipUtil.ErrPasswordIncorrect,
+		zipUtil.ErrAuthFailed, zipUtil.ErrUnsupportedEncrypted} {
+		if errors.Is(err, target) {
+			return target
+		}
+	}
+	return nil
+}
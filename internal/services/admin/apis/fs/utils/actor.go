@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// actorTTL 操作结束后记录保留的时间，文件监听的事件通常在操作后很快到达
+const actorTTL = 10 * time.Second
+
+type actorEntry struct {
+	actor string
+	// expire 为零值时操作还未结束
+	expire time.Time
+}
+
+// ActorTracker 记录正在或刚刚修改路径的用户，监听到文件变化时用于确定操作者。
+// 记录对路径下的全部文件生效，直接在服务器上的修改没有操作者。
+// web接口和ftp服务共用 SvcCtx 中的同一个实例
+type ActorTracker struct {
+	mutex   sync.Mutex
+	entries map[string]*actorEntry
+}
+
+func NewActorTracker() *ActorTracker {
+	return &ActorTracker{entries: make(map[string]*actorEntry)}
+}
+
+// Mark 记录 actor 刚刚修改了 path
+func (t *ActorTracker) Mark(path, actor string) {
+	t.Hold(path, actor)()
+}
+
+// Hold 记录 actor 正在修改 path，用于解压、复制等耗时的操作，结束后调用返回的函数
+func (t *ActorTracker) Hold(path, actor string) func() {
+	entry := &actorEntry{actor: actor}
+	path = filepath.Clean(path)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.sweep()
+	t.entries[path] = entry
+	return func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		entry.expire = time.Now().Add(actorTTL)
+	}
+}
+
+// Lookup 返回修改 path 或其上级目录的用户，没有记录时返回空
+func (t *ActorTracker) Lookup(path string) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	now := time.Now()
+	for path = filepath.Clean(path); ; path = filepath.Dir(path) {
+		if e, ok := t.entries[path]; ok && (e.expire.IsZero() || now.Before(e.expire)) {
+			return e.actor
+		}
+		if parent := filepath.Dir(path); parent == path {
+			return ""
+		}
+	}
+}
+
+// sweep 持有锁时调用，删除过期的记录
+func (t *ActorTracker) sweep() {
+	now := time.Now()
+	for path, e := range t.entries {
+		if !e.expire.IsZero() && now.After(e.expire) {
+			delete(t.entries, path)
+		}
+	}
+}
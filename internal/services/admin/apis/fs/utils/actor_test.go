@@ -0,0 +1,27 @@
+package utils
+
+import "testing"
+
+func TestActorTracker(t *testing.T) {
+	tracker := NewActorTracker()
+	tracker.Mark("/data/a", "alice")
+	release := tracker.Hold("/data/b/c", "bob")
+	defer release()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/data/a", "alice"},
+		{"/data/a/x/y.txt", "alice"},
+		{"/data/b/c/d", "bob"},
+		{"/data/b", ""},
+		{"/data/ab", ""},
+		{"/other", ""},
+	}
+	for _, tt := range tests {
+		if got := tracker.Lookup(tt.path); got != tt.want {
+			t.Errorf("Lookup(%s) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
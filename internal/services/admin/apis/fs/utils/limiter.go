@@ -2,20 +2,45 @@ package utils
 
 import (
 	"fmt"
+	adapterCache "go-file-server/pkgs/cache"
 	"go-file-server/pkgs/utils/limiter"
 	"time"
 
 	"github.com/patrickmn/go-cache"
+	"github.com/redis/go-redis/v9"
 )
 
+// rateLimiterPrefix redis令牌桶key的前缀
+const rateLimiterPrefix = "RateLimiter:"
+
 type LimiterManager struct {
 	limiters *cache.Cache
+	// redisClient 不为空时使用redis令牌桶，多个实例共享限速
+	redisClient *redis.Client
 }
 
-func NewLimiterManager(defaultExpiration, cleanupInterval time.Duration) *LimiterManager {
-	return &LimiterManager{
+type limiterManagerOpt func(*LimiterManager)
+
+// WithAdapterCache 缓存为redis时使用分布式限速，保证多实例部署时角色的限速全局生效
+func WithAdapterCache(ch adapterCache.AdapterCache) limiterManagerOpt {
+	return func(m *LimiterManager) {
+		if ch == nil || ch.String() != "redis" {
+			return
+		}
+		if client, ok := ch.GetClient().(*redis.Client); ok {
+			m.redisClient = client
+		}
+	}
+}
+
+func NewLimiterManager(defaultExpiration, cleanupInterval time.Duration, opts ...limiterManagerOpt) *LimiterManager {
+	m := &LimiterManager{
 		limiters: cache.New(defaultExpiration, cleanupInterval),
 	}
+	for _, o := range opts {
+		o(m)
+	}
+	return m
 }
 
 func (m *LimiterManager) GetLimiter(key string, rateLimitBytes uint64) *limiter.Limiter {
@@ -24,7 +49,7 @@ func (m *LimiterManager) GetLimiter(key string, rateLimitBytes uint64) *limiter.
 		return lim.(*limiter.Limiter)
 	}
 
-	newLimiter := m.createLimiter(rateLimitBytes)
+	newLimiter := m.createLimiter(key, rateLimitBytes)
 	if added := m.limiters.Add(key, newLimiter, cache.DefaultExpiration); added == nil {
 		return newLimiter
 	}
@@ -33,7 +58,10 @@ func (m *LimiterManager) GetLimiter(key string, rateLimitBytes uint64) *limiter.
 	return lim.(*limiter.Limiter)
 }
 
-func (m *LimiterManager) createLimiter(rateLimitBytes uint64) *limiter.Limiter {
+func (m *LimiterManager) createLimiter(key string, rateLimitBytes uint64) *limiter.Limiter {
+	if m.redisClient != nil {
+		return limiter.NewRedisLimiter(m.redisClient, rateLimiterPrefix+key, rateLimitBytes, rateLimitBytes)
+	}
 	return limiter.NewLimiter(rateLimitBytes, rateLimitBytes)
 }
 
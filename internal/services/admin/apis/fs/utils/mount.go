@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"go-file-server/pkgs/config"
+	"go-file-server/pkgs/pathtool"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GetMounts 返回配置的全部挂载点
+func GetMounts() []config.Mount {
+	return config.ApplicationCfg.Mounts
+}
+
+// GetMountByName 通过虚拟目录名称查找挂载点
+func GetMountByName(name string) (config.Mount, bool) {
+	for _, m := range GetMounts() {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return config.Mount{}, false
+}
+
+// GetMountByRealPath 查找真实路径所在的挂载点
+func GetMountByRealPath(realPath string) (config.Mount, bool) {
+	for _, m := range GetMounts() {
+		if pathtool.IsSubPath(m.Path, realPath) {
+			return m, true
+		}
+	}
+	return config.Mount{}, false
+}
+
+// IsMountRoot 判断真实路径是否为挂载点根目录
+func IsMountRoot(realPath string) bool {
+	m, ok := GetMountByRealPath(realPath)
+	return ok && m.Path == filepath.Clean(realPath)
+}
+
+// IsIndexed 判断真实路径是否在文件索引范围内
+func IsIndexed(realPath string) bool {
+	m, ok := GetMountByRealPath(realPath)
+	return !ok || m.Index
+}
+
+// AssertWritable 只读挂载点中的路径返回错误
+func AssertWritable(realPath string) error {
+	m, ok := GetMountByRealPath(realPath)
+	if ok && m.ReadOnly {
+		return errors.Errorf("挂载点 %s 为只读，不允许修改", m.Name)
+	}
+	return nil
+}
+
+// AssertRemovable 挂载点根目录以及只读挂载点中的路径不允许删除或移动
+func AssertRemovable(realPath string) error {
+	if IsMountRoot(realPath) {
+		return errors.Errorf("挂载点不允许删除或移动")
+	}
+	return AssertWritable(realPath)
+}
+
+// GetVirtualPath 将真实路径转换为用户可见的虚拟路径
+func GetVirtualPath(realPath string) string {
+	if m, ok := GetMountByRealPath(realPath); ok {
+		return filepath.Join("/", m.Name, strings.TrimPrefix(realPath, m.Path))
+	}
+	return filepath.Join("/", strings.TrimPrefix(realPath, config.ApplicationCfg.Basedir))
+}
+
+// ValidateMounts 校验挂载点配置并规范化路径。挂载点名称不能与工作目录下已有的目录重名，
+// 挂载路径不能与工作目录或其他挂载点互相包含，否则同一个文件会有多个虚拟路径
+func ValidateMounts(basedir string, mounts []config.Mount) error {
+	basedir = resolvePath(basedir)
+	names := make(map[string]struct{}, len(mounts))
+	for i := range mounts {
+		m := &mounts[i]
+		if err := CheckFsName(m.Name); err != nil {
+			return errors.Errorf("挂载点名称 %s 不合法: %v", m.Name, err)
+		}
+		if m.Name == ".tmp" {
+			return errors.Errorf("挂载点名称不能为 .tmp")
+		}
+		if _, ok := names[m.Name]; ok {
+			return errors.Errorf("挂载点名称重复: %s", m.Name)
+		}
+		names[m.Name] = struct{}{}
+		if _, err := os.Lstat(filepath.Join(basedir, m.Name)); err == nil {
+			return errors.Errorf("挂载点名称 %s 与工作目录下已有的文件重名", m.Name)
+		}
+		if !filepath.IsAbs(m.Path) {
+			return errors.Errorf("挂载点 %s 的路径必须为绝对路径", m.Name)
+		}
+		m.Path = filepath.Clean(m.Path)
+		if overlaps(resolvePath(m.Path), basedir) {
+			return errors.Errorf("挂载点 %s 的路径不能与工作目录互相包含", m.Name)
+		}
+		for _, other := range mounts[:i] {
+			if overlaps(resolvePath(m.Path), resolvePath(other.Path)) {
+				return errors.Errorf("挂载点 %s 与 %s 的路径互相包含", m.Name, other.Name)
+			}
+		}
+		if m.Trash != "" && !filepath.IsAbs(m.Trash) {
+			m.Trash = filepath.Join(m.Path, m.Trash)
+		}
+		if m.Trash != "" {
+			m.Trash = filepath.Clean(m.Trash)
+		}
+	}
+	return nil
+}
+
+// resolvePath 返回解析符号链接后的绝对路径，路径不存在时只做规范化
+func resolvePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
+
+// overlaps 两个路径相同或其中一个在另一个之下
+func overlaps(a, b string) bool {
+	return pathtool.IsSubPath(a, b) || pathtool.IsSubPath(b, a)
+}
+
+// splitMountPath 拆分虚拟路径，返回匹配的挂载点和挂载点内的相对路径
+func splitMountPath(virtualPath string) (config.Mount, string, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(virtualPath, "/"), "/", 2)
+	m, ok := GetMountByName(parts[0])
+	if !ok {
+		return m, "", false
+	}
+	if len(parts) == 1 {
+		return m, "", true
+	}
+	return m, parts[1], true
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"go-file-server/pkgs/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetRealPathWithMounts(t *testing.T) {
+	config.ApplicationCfg = &config.Application{
+		Basedir: "/data",
+		Mounts: []config.Mount{
+			{Name: "nas", Path: "/mnt/nas", ReadOnly: true},
+			{Name: "backup", Path: "/mnt/backup"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		paths    []string
+		want     string
+		virtual  string
+		writable bool
+	}{
+		{"basedir", []string{"/docs/a.txt"}, "/data/docs/a.txt", "/docs/a.txt", true},
+		{"mount root", []string{"/nas"}, "/mnt/nas", "/nas", false},
+		{"mount sub path", []string{"/nas", "movie/b.mp4"}, "/mnt/nas/movie/b.mp4", "/nas/movie/b.mp4", false},
+		{"writable mount", []string{"backup/c"}, "/mnt/backup/c", "/backup/c", true},
+		{"prefix only", []string{"/nasx/d"}, "/data/nasx/d", "/nasx/d", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetRealPath(tt.paths...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("GetRealPath() = %v, want %v", got, tt.want)
+			}
+			if v := GetVirtualPath(got); v != tt.virtual {
+				t.Errorf("GetVirtualPath() = %v, want %v", v, tt.virtual)
+			}
+			if err := AssertWritable(got); (err == nil) != tt.writable {
+				t.Errorf("AssertWritable() error = %v, writable %v", err, tt.writable)
+			}
+		})
+	}
+}
+
+func TestValidateMounts(t *testing.T) {
+	basedir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(basedir, "docs"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	other := t.TempDir()
+	tests := []struct {
+		name    string
+		mounts  []config.Mount
+		wantErr bool
+	}{
+		{"valid", []config.Mount{{Name: "nas", Path: other}}, false},
+		{"name hides basedir dir", []config.Mount{{Name: "docs", Path: other}}, true},
+		{"path inside basedir", []config.Mount{{Name: "nas", Path: filepath.Join(basedir, "docs")}}, true},
+		{"basedir inside path", []config.Mount{{Name: "nas", Path: filepath.Dir(basedir)}}, true},
+		{"path inside other mount", []config.Mount{
+			{Name: "nas", Path: other},
+			{Name: "sub", Path: filepath.Join(other, "sub")},
+		}, true},
+		{"duplicate name", []config.Mount{{Name: "nas", Path: other}, {Name: "nas", Path: "/mnt/x"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateMounts(basedir, tt.mounts); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMounts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
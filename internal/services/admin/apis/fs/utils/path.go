@@ -2,6 +2,7 @@ package utils
 
 import (
 	"go-file-server/pkgs/config"
+	"go-file-server/pkgs/pathtool"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -23,10 +24,39 @@ func GetTmpDir() string {
 	return filepath.Join(config.ApplicationCfg.Basedir, ".tmp")
 }
 
+// GetTrashDir 获取真实路径删除时使用的回收站目录
+// 挂载点配置了trash时使用挂载点自己的回收站，否则使用basedir下的.tmp
+func GetTrashDir(realPath, roleKey string) (string, bool) {
+	if m, ok := GetMountByRealPath(realPath); ok && m.Trash != "" {
+		return filepath.Join(m.Trash, roleKey), true
+	}
+	return "", false
+}
+
+// IsTrashPath 判断真实路径是否已经位于回收站中
+func IsTrashPath(realPath string) bool {
+	if pathtool.IsSubPath(GetTmpDir(), realPath) {
+		return true
+	}
+	for _, m := range GetMounts() {
+		if m.Trash != "" && pathtool.IsSubPath(m.Trash, realPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRealPath 将虚拟路径转换为真实路径
+// 第一级目录与挂载点名称相同时，映射到挂载点的路径，否则映射到basedir
 func GetRealPath(paths ...string) (string, error) {
-	realPath := config.ApplicationCfg.Basedir
-	paths = append([]string{realPath}, paths...)
-	return SafeJoinPath(paths...)
+	virtualPath, err := SafeJoinPath(append([]string{"/"}, paths...)...)
+	if err != nil {
+		return "", err
+	}
+	if m, subPath, ok := splitMountPath(virtualPath); ok {
+		return filepath.Join(m.Path, subPath), nil
+	}
+	return filepath.Join(config.ApplicationCfg.Basedir, virtualPath), nil
 }
 
 func SafeJoinPath(paths ...string) (string, error) {
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF8BOM = "utf-8-bom"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingGBK     = "gbk"
+)
+
+var ErrNotText = errors.New("不是文本文件")
+
+var textEncodings = map[string]encoding.Encoding{
+	EncodingUTF8:    unicode.UTF8,
+	EncodingUTF8BOM: unicode.UTF8BOM,
+	EncodingUTF16LE: unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM),
+	EncodingUTF16BE: unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM),
+	EncodingGBK:     simplifiedchinese.GBK,
+}
+
+// DetectEncoding 检测文本的编码，data 可以是被截断的文件开头
+func DetectEncoding(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8BOM, nil
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE, nil
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE, nil
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", ErrNotText
+	}
+	if utf8.Valid(trimIncompleteRune(data)) {
+		return EncodingUTF8, nil
+	}
+	// 截断时末尾可能是半个双字节字符
+	if isGBK(data) || (len(data) > 1 && isGBK(data[:len(data)-1])) {
+		return EncodingGBK, nil
+	}
+	return "", ErrNotText
+}
+
+func isGBK(data []byte) bool {
+	text, err := simplifiedchinese.GBK.NewDecoder().Bytes(data)
+	return err == nil && !bytes.ContainsRune(text, utf8.RuneError)
+}
+
+// DecodeText 将指定编码的内容转换为utf-8
+func DecodeText(data []byte, name string) (string, error) {
+	enc, ok := textEncodings[name]
+	if !ok {
+		return "", errors.Errorf("不支持的编码 %s", name)
+	}
+	if name == EncodingUTF8 {
+		data = trimIncompleteRune(data)
+	}
+	text, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(bytes.TrimRight(text, string(utf8.RuneError))), nil
+}
+
+// EncodeText 将utf-8内容转换为指定的编码，包含该编码无法表示的字符时返回错误
+func EncodeText(text, name string) ([]byte, error) {
+	enc, ok := textEncodings[name]
+	if !ok {
+		return nil, errors.Errorf("不支持的编码 %s", name)
+	}
+	data, err := enc.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		for _, r := range text {
+			if _, err := enc.NewEncoder().String(string(r)); err != nil {
+				return nil, errors.Errorf("内容包含无法转换为 %s 编码的字符 %q", name, r)
+			}
+		}
+		return nil, errors.Errorf("内容无法转换为 %s 编码", name)
+	}
+	return data, nil
+}
+
+// trimIncompleteRune 去掉截断时末尾不完整的utf-8字符
+func trimIncompleteRune(data []byte) []byte {
+	for i := 0; i < utf8.UTFMax && i < len(data); i++ {
+		r, size := utf8.DecodeLastRune(data[:len(data)-i])
+		if r != utf8.RuneError || size > 1 {
+			return data[:len(data)-i]
+		}
+	}
+	return data
+}
+
+var languages = map[string]string{
+	".go":   "go",
+	".js":   "javascript",
+	".mjs":  "javascript",
+	".jsx":  "javascript",
+	".ts":   "typescript",
+	".tsx":  "typescript",
+	".vue":  "xml",
+	".py":   "python",
+	".java": "java",
+	".kt":   "kotlin",
+	".c":    "c",
+	".h":    "c",
+	".cpp":  "cpp",
+	".cc":   "cpp",
+	".hpp":  "cpp",
+	".cs":   "csharp",
+	".rs":   "rust",
+	".rb":   "ruby",
+	".php":  "php",
+	".lua":  "lua",
+	".sh":   "bash",
+	".bash": "bash",
+	".ps1":  "powershell",
+	".bat":  "dos",
+	".sql":  "sql",
+	".json": "json",
+	".yaml": "yaml",
+	".yml":  "yaml",
+	".toml": "ini",
+	".ini":  "ini",
+	".conf": "ini",
+	".xml":  "xml",
+	".html": "xml",
+	".htm":  "xml",
+	".css":  "css",
+	".scss": "scss",
+	".less": "less",
+	".md":   "markdown",
+	".diff": "diff",
+	".csv":  "plaintext",
+	".log":  "plaintext",
+	".txt":  "plaintext",
+}
+
+var languageNames = map[string]string{
+	"dockerfile":     "dockerfile",
+	"makefile":       "makefile",
+	"go.mod":         "go",
+	"cmakelists.txt": "cmake",
+}
+
+// GetLanguage 根据文件名返回代码高亮使用的语言标识(highlight.js)
+func GetLanguage(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+	if lang, ok := languageNames[name]; ok {
+		return lang
+	}
+	if lang, ok := languages[filepath.Ext(name)]; ok {
+		return lang
+	}
+	return "plaintext"
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte("中文内容，测试编码"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	utf8Text := []byte("中文内容")
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{"ascii", []byte("hello"), EncodingUTF8, false},
+		{"utf-8", utf8Text, EncodingUTF8, false},
+		{"utf-8 truncated", utf8Text[:len(utf8Text)-1], EncodingUTF8, false},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, utf8Text...), EncodingUTF8BOM, false},
+		{"utf-16le bom", []byte{0xFF, 0xFE, 'a', 0}, EncodingUTF16LE, false},
+		{"gbk", gbk, EncodingGBK, false},
+		{"gbk truncated", gbk[:len(gbk)-1], EncodingGBK, false},
+		{"binary", []byte{0x89, 'P', 'N', 'G', 0, 0}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectEncoding(tt.data)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("DetectEncoding() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	text, err := DecodeText(gbk, EncodingGBK)
+	if err != nil || text != "中文内容，测试编码" {
+		t.Errorf("DecodeText() = %q, %v", text, err)
+	}
+	data, err := EncodeText(text, EncodingGBK)
+	if err != nil || string(data) != string(gbk) {
+		t.Errorf("EncodeText() = %v, %v", data, err)
+	}
+	// GBK 无法表示的字符不能静默替换
+	if _, err := EncodeText("表情😀", EncodingGBK); err == nil {
+		t.Errorf("EncodeText() with emoji returned nil error")
+	}
+}
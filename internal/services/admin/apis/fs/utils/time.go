@@ -2,6 +2,9 @@ package utils
 
 import "time"
 
+// TimeStrLayout GetTimeStr 的时间格式，回收站条目名称使用该格式记录删除时间
+const TimeStrLayout = "2006-01-02-15.04.05.000"
+
 func GetTimeStr() string {
-	return time.Now().Format("2006-01-02-15.04.05.000")
+	return time.Now().Format(TimeStrLayout)
 }
@@ -3,24 +3,172 @@ package utils
 import (
 	"context"
 	"fmt"
+	"go-file-server/pkgs/config"
+	"go-file-server/pkgs/pathtool"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/mholt/archiver/v4"
+	"github.com/pkg/errors"
 )
 
-func HandleFile(ctx context.Context, f archiver.File, dest string) (err error) {
-	fpath := filepath.Join(dest, f.NameInArchive)
+// 解压时目标文件已存在的处理方式
+const (
+	// ConflictError 停止解压并返回错误
+	ConflictError = "error"
+	// ConflictSkip 跳过已存在的文件
+	ConflictSkip = "skip"
+	// ConflictOverwrite 覆盖已存在的文件
+	ConflictOverwrite = "overwrite"
+	// ConflictRename 重命名为 name (n).ext
+	ConflictRename = "rename"
+)
+
+const (
+	// DefaultMaxExtractSize 未配置时单次解压的最大字节数
+	DefaultMaxExtractSize = 20 << 30
+	// DefaultMaxExtractEntries 未配置时单次解压的最大条目数
+	DefaultMaxExtractEntries = 100000
+)
+
+var (
+	ErrFileExist = errors.New("目标文件已存在")
+	// ErrEntrySkipped 不安全或不支持的条目，跳过后继续解压
+	ErrEntrySkipped = errors.New("跳过")
+	// ErrExtractLimit 超出解压大小或条目数限制，停止解压
+	ErrExtractLimit = errors.New("超出解压限制")
+)
+
+// ExtractGuard 限制解压的路径、大小和条目数，防止 zip slip 和 zip 炸弹
+type ExtractGuard struct {
+	dest       string
+	realDest   string
+	maxSize    int64
+	maxEntries int
+	size       int64
+	entries    int
+	// storage 剩余的存储配额，小于0时不限制，超出时返回 storageErr
+	storage    int64
+	storageErr error
+}
+
+// NewExtractGuard 使用配置的限制创建，dest 需要已经存在
+func NewExtractGuard(dest string) (*ExtractGuard, error) {
+	realDest, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	g := &ExtractGuard{
+		dest:       filepath.Clean(dest),
+		realDest:   realDest,
+		maxSize:    config.ApplicationCfg.Unarchive.MaxSize,
+		maxEntries: config.ApplicationCfg.Unarchive.MaxEntries,
+		storage:    -1,
+	}
+	if g.maxSize <= 0 {
+		g.maxSize = DefaultMaxExtractSize
+	}
+	if g.maxEntries <= 0 {
+		g.maxEntries = DefaultMaxExtractEntries
+	}
+	return g, nil
+}
+
+// LimitStorage 限制写入的总字节数不超过剩余的存储配额，remaining 小于0时不限制，超出时返回 exceeded
+func (g *ExtractGuard) LimitStorage(remaining int64, exceeded error) {
+	g.storage = remaining
+	g.storageErr = exceeded
+}
+
+// Written 已经写入的字节数
+func (g *ExtractGuard) Written() int64 {
+	return g.size
+}
+
+// SafePath 返回条目在 dest 下的路径，拒绝绝对路径和包含 .. 的路径
+func (g *ExtractGuard) SafePath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || filepath.VolumeName(name) != "" ||
+		(len(name) > 1 && name[1] == ':') {
+		return "", errors.Wrap(ErrEntrySkipped, "绝对路径")
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", errors.Wrap(ErrEntrySkipped, "路径包含..")
+		}
+	}
+	fpath := filepath.Join(g.dest, filepath.FromSlash(name))
+	if !pathtool.IsSubPath(g.dest, fpath) {
+		return "", errors.Wrap(ErrEntrySkipped, "路径超出目标目录")
+	}
+	return fpath, nil
+}
+
+// checkDir 确认目录(或最近的已存在的上级目录)解析符号链接后仍位于 dest 下，
+// 避免通过目标目录中已存在的链接写到外部
+func (g *ExtractGuard) checkDir(dir string) error {
+	for {
+		if _, err := os.Lstat(dir); err == nil || dir == g.dest {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !pathtool.IsSubPath(g.realDest, realDir) {
+		return errors.Wrap(ErrEntrySkipped, "路径超出目标目录")
+	}
+	return nil
+}
 
-	if f.IsDir() {
+// HandleFile 解压一个条目，返回 ErrEntrySkipped 时调用方应记录后继续
+func (g *ExtractGuard) HandleFile(ctx context.Context, f archiver.File, conflict string) (err error) {
+	if g.entries++; g.entries > g.maxEntries {
+		return errors.Wrapf(ErrExtractLimit, "条目数超过 %d", g.maxEntries)
+	}
+	fpath, err := g.SafePath(f.NameInArchive)
+	if err != nil {
+		return err
+	}
+	mode := f.Mode()
+	switch {
+	case mode&os.ModeSymlink != 0 || f.LinkTarget != "":
+		return errors.Wrap(ErrEntrySkipped, "不支持链接")
+	case f.IsDir():
+		if err := g.checkDir(fpath); err != nil {
+			return err
+		}
 		return os.MkdirAll(fpath, os.ModePerm)
+	case !mode.IsRegular():
+		return errors.Wrap(ErrEntrySkipped, "不支持的文件类型")
 	}
 
+	if err := g.checkDir(filepath.Dir(fpath)); err != nil {
+		return err
+	}
 	if err = os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
 		return fmt.Errorf("failed to create directory for file %s: %w", fpath, err)
 	}
 
+	overwrite := false
+	if info, err := os.Lstat(fpath); err == nil {
+		switch {
+		case conflict == ConflictSkip:
+			return nil
+		case conflict == ConflictOverwrite && !info.IsDir():
+			// 写入完成后重命名覆盖，不跟随已存在的符号链接
+			overwrite = true
+		case conflict == ConflictRename:
+			fpath = RenameConflict(fpath)
+		default:
+			return errors.Wrap(ErrFileExist, f.NameInArchive)
+		}
+	}
+
 	inFile, err := f.Open()
 	if err != nil {
 		return fmt.Errorf("failed to open zip file entry %s: %w", f.NameInArchive, err)
@@ -31,8 +179,9 @@ func HandleFile(ctx context.Context, f archiver.File, dest string) (err error) {
 		}
 	}()
 
-	// 使用os.OpenFile和适当的标志来避免覆盖现有文件
-	outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, f.Mode())
+	// 先写入临时文件，完整写入后再重命名，出错、取消或超出限制时不留下不完整的文件，
+	// 重新执行时跳过已存在的文件不会保留截断的内容
+	outFile, err := os.CreateTemp(filepath.Dir(fpath), "."+filepath.Base(fpath)+".*.tmp")
 	if err != nil {
 		return fmt.Errorf("failed to create destination file %s: %w", fpath, err)
 	}
@@ -40,20 +189,79 @@ func HandleFile(ctx context.Context, f archiver.File, dest string) (err error) {
 		if cerr := outFile.Close(); cerr != nil && err == nil {
 			err = fmt.Errorf("failed to close output file %s: %w", fpath, cerr)
 		}
+		if err == nil {
+			if overwrite {
+				err = errors.WithStack(os.Rename(outFile.Name(), fpath))
+			} else {
+				err = RenameNoReplace(outFile.Name(), fpath)
+			}
+		}
+		if err != nil {
+			os.Remove(outFile.Name())
+		}
 	}()
-	ctxReader := &ContextReader{ctx: ctx, reader: inFile}
-	if _, err = io.Copy(outFile, ctxReader); err != nil {
+	if err = outFile.Chmod(mode.Perm()); err != nil {
+		return errors.WithStack(err)
+	}
+	// 按实际写入的字节数限制，压缩包中记录的大小可能是伪造的
+	limit := g.maxSize - g.size
+	byStorage := g.storage >= 0 && g.storage-g.size < limit
+	if byStorage {
+		limit = max(g.storage-g.size, 0)
+	}
+	ctxReader := &ContextReader{ctx: ctx, reader: io.LimitReader(inFile, limit+1)}
+	n, err := io.Copy(outFile, ctxReader)
+	g.size += n
+	if err != nil {
 		return fmt.Errorf("failed to copy contents to %s: %w", fpath, err)
 	}
+	if n > limit && byStorage {
+		return g.storageErr
+	}
+	if n > limit {
+		return errors.Wrapf(ErrExtractLimit, "解压后的大小超过 %d 字节", g.maxSize)
+	}
 	return nil
 }
 
+// RenameNoReplace 通过硬链接重命名，目标已存在时返回 ErrFileExist 而不是覆盖。
+// 文件系统不支持硬链接时再检查一次目标后重命名
+func RenameNoReplace(src, dst string) error {
+	err := os.Link(src, dst)
+	if err == nil {
+		return errors.WithStack(os.Remove(src))
+	}
+	if os.IsExist(err) {
+		return errors.Wrap(ErrFileExist, filepath.Base(dst))
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return errors.Wrap(ErrFileExist, filepath.Base(dst))
+	}
+	return errors.WithStack(os.Rename(src, dst))
+}
+
+// RenameConflict 返回不存在的路径 name (n).ext
+func RenameConflict(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		p := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Lstat(p); os.IsNotExist(err) {
+			return p
+		}
+	}
+}
+
 // 带ctx的Reader
 type ContextReader struct {
 	ctx    context.Context
 	reader io.Reader
 }
 
+func NewContextReader(ctx context.Context, reader io.Reader) *ContextReader {
+	return &ContextReader{ctx: ctx, reader: reader}
+}
+
 func (r *ContextReader) Read(p []byte) (int, error) {
 	select {
 	case <-r.ctx.Done():
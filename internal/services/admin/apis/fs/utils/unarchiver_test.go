@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mholt/archiver/v4"
+	"github.com/pkg/errors"
+)
+
+type fileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() fs.FileMode  { return fi.mode | 0644 }
+func (fi fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fileInfo) IsDir() bool        { return false }
+func (fi fileInfo) Sys() any           { return nil }
+
+func TestHandleFileConflict(t *testing.T) {
+	newFile := func(content string) archiver.File {
+		return archiver.File{
+			FileInfo:      fileInfo{name: "a.txt", size: int64(len(content))},
+			NameInArchive: "a.txt",
+			Open: func() (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader(content)), nil
+			},
+		}
+	}
+	tests := []struct {
+		conflict string
+		wantErr  bool
+		want     string
+		renamed  bool
+	}{
+		{ConflictError, true, "old", false},
+		{ConflictSkip, false, "old", false},
+		{ConflictOverwrite, false, "new", false},
+		{ConflictRename, false, "old", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.conflict, func(t *testing.T) {
+			dir := t.TempDir()
+			dst := filepath.Join(dir, "a.txt")
+			if err := os.WriteFile(dst, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			guard, err := NewExtractGuard(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = guard.HandleFile(context.Background(), newFile("new"), tt.conflict)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HandleFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if b, _ := os.ReadFile(dst); string(b) != tt.want {
+				t.Errorf("a.txt = %q, want %q", b, tt.want)
+			}
+			_, err = os.Stat(filepath.Join(dir, "a (1).txt"))
+			if (err == nil) != tt.renamed {
+				t.Errorf("a (1).txt exists = %v, want %v", err == nil, tt.renamed)
+			}
+		})
+	}
+}
+
+func TestExtractGuard(t *testing.T) {
+	root := t.TempDir()
+	dest := filepath.Join(root, "dest")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{dest, outside} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 目标目录中已存在指向外部的链接
+	if err := os.Symlink(outside, filepath.Join(dest, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		entry   string
+		mode    fs.FileMode
+		link    string
+		content string
+		wantErr error
+	}{
+		{"normal", "a/b.txt", 0, "", "ok", nil},
+		{"parent dir", "../evil.txt", 0, "", "x", ErrEntrySkipped},
+		{"nested parent dir", "a/../../evil.txt", 0, "", "x", ErrEntrySkipped},
+		{"absolute", "/etc/evil.txt", 0, "", "x", ErrEntrySkipped},
+		{"windows absolute", "C:\\evil.txt", 0, "", "x", ErrEntrySkipped},
+		{"backslash parent", "..\\evil.txt", 0, "", "x", ErrEntrySkipped},
+		{"symlink entry", "s", fs.ModeSymlink, "/etc/passwd", "", ErrEntrySkipped},
+		{"hardlink entry", "h", 0, "/etc/passwd", "", ErrEntrySkipped},
+		{"through existing link", "link/evil.txt", 0, "", "x", ErrEntrySkipped},
+		{"too large", "big.txt", 0, "", "0123456789abcdef", ErrExtractLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard, err := NewExtractGuard(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			guard.maxSize = 10
+			f := archiver.File{
+				FileInfo:      fileInfo{name: filepath.Base(tt.entry), size: 1, mode: tt.mode},
+				NameInArchive: tt.entry,
+				LinkTarget:    tt.link,
+				Open: func() (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader(tt.content)), nil
+				},
+			}
+			err = guard.HandleFile(context.Background(), f, ConflictError)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("HandleFile() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	entries, _ := os.ReadDir(outside)
+	if len(entries) != 0 {
+		t.Errorf("files written outside dest: %v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(root, "evil.txt")); err == nil {
+		t.Errorf("evil.txt written outside dest")
+	}
+}
+
+func TestExtractGuardStorage(t *testing.T) {
+	errStorage := errors.New("storage exceeded")
+	dest := t.TempDir()
+	guard, err := NewExtractGuard(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard.LimitStorage(5, errStorage)
+	// 压缩包中记录的大小是伪造的，按实际写入的字节数计算
+	for i, tt := range []struct {
+		content string
+		wantErr error
+	}{
+		{"abc", nil},
+		{"defg", errStorage},
+	} {
+		f := archiver.File{
+			FileInfo:      fileInfo{name: "f", size: 0},
+			NameInArchive: strings.Repeat("f", i+1),
+			Open: func() (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader(tt.content)), nil
+			},
+		}
+		if err := guard.HandleFile(context.Background(), f, ConflictError); err != tt.wantErr {
+			t.Fatalf("HandleFile(%q) error = %v, want %v", tt.content, err, tt.wantErr)
+		}
+	}
+	if got := guard.Written(); got != 6 {
+		t.Errorf("Written() = %d, want 6", got)
+	}
+	// 超出限制的文件不保留不完整的内容
+	if entries, _ := os.ReadDir(dest); len(entries) != 1 || entries[0].Name() != "f" {
+		t.Errorf("dest entries = %v, want only f", entries)
+	}
+}
+
+func TestRenameNoReplace(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, ".a.zip.tmp")
+	dst := filepath.Join(dir, "a.zip")
+	for _, name := range []string{src, dst} {
+		if err := os.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RenameNoReplace(src, dst); !errors.Is(err, ErrFileExist) {
+		t.Fatalf("RenameNoReplace() error = %v, want ErrFileExist", err)
+	}
+	if b, _ := os.ReadFile(dst); string(b) != dst {
+		t.Errorf("existing archive overwritten: %q", b)
+	}
+
+	if err := os.Remove(dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenameNoReplace(src, dst); err != nil {
+		t.Fatalf("RenameNoReplace() error = %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("temp file still exists")
+	}
+}
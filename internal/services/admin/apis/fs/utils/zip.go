@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	zipUtil "go-file-server/pkgs/utils/zip"
+
+	"github.com/mholt/archiver/v4"
+	"github.com/pkg/errors"
+)
+
+// ZipExtractor 解压zip，支持 AES 加密的条目。
+// Password 为空时打开加密的条目返回 zipUtil.ErrPasswordRequired，只影响读取内容，不影响列出条目
+type ZipExtractor struct {
+	archiver.Zip
+	Password string
+}
+
+func (z ZipExtractor) Extract(ctx context.Context, sourceArchive io.Reader, pathsInArchive []string,
+	handleFile archiver.FileHandler) error {
+	sra, ok := sourceArchive.(interface {
+		io.ReaderAt
+		io.Seeker
+	})
+	if !ok {
+		return errors.New("zip 解压需要支持 io.ReaderAt 和 io.Seeker")
+	}
+	size, err := sra.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	zr, err := zip.NewReader(sra, size)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var skipDirs []string
+	for i, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if pathsInArchive != nil && !entryIncluded(pathsInArchive, f.Name) {
+			continue
+		}
+		if entryIncluded(skipDirs, f.Name) {
+			continue
+		}
+		file := archiver.File{
+			FileInfo:      f.FileInfo(),
+			Header:        f.FileHeader,
+			NameInArchive: f.Name,
+			Open: func() (io.ReadCloser, error) {
+				if zipUtil.IsEncrypted(f) {
+					return zipUtil.OpenEncrypted(f, z.Password)
+				}
+				return f.Open()
+			},
+		}
+		err := handleFile(ctx, file)
+		if errors.Is(err, fs.SkipDir) {
+			dir := f.Name
+			if !file.IsDir() {
+				dir = path.Dir(f.Name) + "/"
+			}
+			skipDirs = append(skipDirs, dir)
+		} else if err != nil {
+			return fmt.Errorf("handling file %d: %s: %w", i, f.Name, err)
+		}
+	}
+	return nil
+}
+
+// entryIncluded 与 archiver 的规则一致，名称相同或位于列表中的目录下
+func entryIncluded(names []string, name string) bool {
+	for _, n := range names {
+		if name == n || strings.HasPrefix(name, strings.TrimSuffix(n, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEncryptedEntry 条目是否加密，目前只能识别zip中的条目
+func IsEncryptedEntry(f archiver.File) bool {
+	hdr, ok := f.Header.(zip.FileHeader)
+	return ok && hdr.Flags&0x1 != 0
+}
+
+// ZipArchiver 创建使用 AES-256 加密的zip，目录条目不加密
+type ZipArchiver struct {
+	Password string
+}
+
+func (z ZipArchiver) Archive(ctx context.Context, output io.Writer, files []archiver.File) error {
+	zw := zip.NewWriter(output)
+	for i, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := z.archiveFile(zw, file); err != nil {
+			return fmt.Errorf("writing file %d: %s: %w", i, file.Name(), err)
+		}
+	}
+	return zw.Close()
+}
+
+func (z ZipArchiver) archiveFile(zw *zip.Writer, file archiver.File) error {
+	hdr, err := zip.FileInfoHeader(file)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	hdr.Name = file.NameInArchive
+	if file.IsDir() {
+		if !strings.HasSuffix(hdr.Name, "/") {
+			hdr.Name += "/"
+		}
+		hdr.Method = zip.Store
+		_, err := zw.CreateHeader(hdr)
+		return err
+	}
+	w, err := zipUtil.CreateEncrypted(zw, hdr, z.Password)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(file, w); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func copyFile(file archiver.File, w io.Writer) error {
+	if file.Open == nil {
+		return nil
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(w, rc)
+	return err
+}
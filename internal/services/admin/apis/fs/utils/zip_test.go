@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	zipUtil "go-file-server/pkgs/utils/zip"
+
+	"github.com/mholt/archiver/v4"
+	"github.com/pkg/errors"
+)
+
+func TestEncryptedZip(t *testing.T) {
+	files := []archiver.File{{
+		FileInfo:      fileInfo{name: "a.txt", size: 5},
+		NameInArchive: "dir/a.txt",
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("hello")), nil
+		},
+	}}
+	var buf bytes.Buffer
+	if err := (ZipArchiver{Password: "secret"}).Archive(context.Background(), &buf, files); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		wantErr  error
+	}{
+		{"secret", nil},
+		{"", zipUtil.ErrPasswordRequired},
+		{"wrong", zipUtil.ErrPasswordIncorrect},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			var got string
+			extractor := ZipExtractor{Password: tt.password}
+			err := extractor.Extract(context.Background(), bytes.NewReader(buf.Bytes()), nil,
+				func(ctx context.Context, f archiver.File) error {
+					if !IsEncryptedEntry(f) || f.NameInArchive != "dir/a.txt" {
+						t.Errorf("entry = %s, encrypted %v", f.NameInArchive, IsEncryptedEntry(f))
+					}
+					rc, err := f.Open()
+					if err != nil {
+						return err
+					}
+					defer rc.Close()
+					b, err := io.ReadAll(rc)
+					got = string(b)
+					return err
+				})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Extract() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got != "hello" {
+				t.Errorf("content = %q, want hello", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package job
+
+import (
+	"encoding/json"
+	"go-file-server/internal/common/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateReq struct {
+	Type string `json:"type" binding:"required"`
+	// Params 任务参数，格式由任务类型决定
+	Params json.RawMessage `json:"params"`
+	// Priority 优先级，数值越大越先执行
+	Priority int `json:"priority" binding:"min=0,max=9"`
+}
+
+// Create 提交任务，相同的任务未结束时返回已有的任务
+func (api *JobApi) Create(c *gin.Context) {
+	var req CreateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	job, err := api.manager.Submit(core.ExtractClaims(c), req.Type, req.Params, req.Priority)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(job).SendGin(c)
+}
+
+// Retry 重新执行失败或已取消的任务
+func (api *JobApi) Retry(c *gin.Context) {
+	var req IdReq
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	job, err := api.getOwnJob(c, req.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := api.manager.Retry(job); err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(nil).SendGin(c)
+}
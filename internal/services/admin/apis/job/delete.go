@@ -0,0 +1,25 @@
+package job
+
+import (
+	"go-file-server/internal/common/core"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Delete 取消等待中或执行中的任务
+func (api *JobApi) Delete(c *gin.Context) {
+	var req IdReq
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if _, err := api.getOwnJob(c, req.Id); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := api.manager.Cancel(req.Id); err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(nil).SendGin(c)
+}
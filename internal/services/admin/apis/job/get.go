@@ -0,0 +1,75 @@
+package job
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/base"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GetPageReq struct {
+	types.Pagination
+	Type   string `form:"type"`
+	Status string `form:"status" binding:"omitempty,oneof=pending running done failed canceled"`
+	// UserId 只对管理员生效，其他用户只能查看自己的任务
+	UserId int `form:"userId"`
+}
+
+type GetPageRep struct {
+	types.Page
+	Items []models.SysJob `json:"items"`
+}
+
+// GetPage 分页查询任务，按提交时间倒序
+func (api *JobApi) GetPage(c *gin.Context) {
+	var req GetPageReq
+	err := c.ShouldBindQuery(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	claims := core.ExtractClaims(c)
+	if claims.RoleKey != models.AdminRoleKey {
+		req.UserId = claims.UserId
+	}
+	var querys []base.DbScope
+	if req.UserId != 0 {
+		querys = append(querys, repository.WithJobUserId(req.UserId))
+	}
+	if req.Type != "" {
+		querys = append(querys, repository.WithJobType(req.Type))
+	}
+	if req.Status != "" {
+		querys = append(querys, repository.WithJobStatus(req.Status))
+	}
+	data, count, err := api.jobRepo.FindWithCount(append(querys,
+		base.WithOrderBy("id", true),
+		base.WithPaginate(req.PageIndex, req.PageSize),
+	)...)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(GetPageRep{
+		Page:  types.NewPage(count, req.PageIndex, req.PageSize),
+		Items: data,
+	}).SendGin(c)
+}
+
+// Get 查询任务详情
+func (api *JobApi) Get(c *gin.Context) {
+	var req IdReq
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	job, err := api.getOwnJob(c, req.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(job).SendGin(c)
+}
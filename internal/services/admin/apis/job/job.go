@@ -0,0 +1,46 @@
+package job
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type JobApi struct {
+	manager *Manager
+	jobRepo *repository.JobRepository
+}
+
+func NewJobApi(manager *Manager, jobRepo *repository.JobRepository) *JobApi {
+	return &JobApi{
+		manager: manager,
+		jobRepo: jobRepo,
+	}
+}
+
+type IdReq struct {
+	Id int `uri:"id" binding:"required"`
+}
+
+// getOwnJob 查询任务，只有任务所属用户和管理员可以访问
+func (api *JobApi) getOwnJob(c *gin.Context, id int) (models.SysJob, error) {
+	job, err := api.jobRepo.FindOne(repository.WithJobId(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return job, core.NewApiBizErr(err).
+				SetHttpCode(global.StatusNotFound).
+				SetBizCode(global.BizNotFound).
+				SetMsg("任务不存在")
+		}
+		return job, errors.WithStack(err)
+	}
+	if err := core.VerifyResourceOwner(c, job.UserId); err != nil {
+		return job, err
+	}
+	return job, nil
+}
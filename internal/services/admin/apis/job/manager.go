@@ -0,0 +1,583 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/base"
+	"go-file-server/pkgs/zlog"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxConcurrency 全部类型同时执行的任务数
+	maxConcurrency = 4
+	// scheduleBatch 每次调度时读取的待执行任务数
+	scheduleBatch = 100
+	// pollInterval 定时检查到达执行时间的任务，重试的任务需要延后执行
+	pollInterval = 5 * time.Second
+	// retention 已结束的任务保留时间
+	retention = 30 * 24 * time.Hour
+	// cleanupInterval 清理过期任务的间隔
+	cleanupInterval = time.Hour
+	// maxRetryDelay 自动重试的最大等待时间
+	maxRetryDelay = 10 * time.Minute
+)
+
+// Type 任务类型，需要在 Start 之前通过 Register 注册
+type Type struct {
+	Name string
+	// Concurrency 该类型同时执行的任务数，为0时只受全局限制
+	Concurrency int
+	// MaxAttempts 执行失败后自动重试的最大执行次数，小于1时按1处理
+	MaxAttempts int
+	// Resumable 服务重启时中断的任务是否重新执行，否则标记为失败
+	Resumable bool
+	// AdminOnly 只有管理员可以提交
+	AdminOnly bool
+	// Prepare 提交前检查权限和参数，返回去重key和需要保存的参数，
+	// 返回的错误会直接展示给用户
+	Prepare func(claims *types.JwtClaims, raw json.RawMessage) (key string, params any, err error)
+	Run     func(ctx context.Context, task *Task) error
+	// Discard 等待重试的任务被取消后删除之前执行留下的部分结果，可以为空
+	Discard func(job models.SysJob)
+}
+
+// Secret 包含密码等敏感信息的任务参数，Prepare 返回的参数实现该接口时，
+// 敏感信息只保存在内存中，不会写入数据库，服务重启后失效
+type Secret interface {
+	// TakeSecret 返回去掉敏感信息后需要保存的参数以及敏感信息
+	TakeSecret() (params any, secret string)
+}
+
+// Manager 持久化的后台任务队列，任务状态保存在数据库中，
+// 执行时与请求无关，客户端断开后任务继续执行。
+// 只支持单实例部署，启动时会把执行中的任务视为被中断
+type Manager struct {
+	repo  *repository.JobRepository
+	types map[string]*Type
+	// running 当前实例正在执行的任务
+	running map[int]*Task
+	// publishers 未结束任务的消息发布器，用于sse推送任务日志
+	publishers map[int]*utils.Publisher[utils.Message]
+	// secrets 任务的敏感参数，见 Secret。失败或取消的任务可以手动重试，
+	// 只在任务完成或被清理时删除
+	secrets map[int]string
+	mutex   sync.Mutex
+	wake    chan struct{}
+	once    sync.Once
+}
+
+func NewManager(repo *repository.JobRepository) *Manager {
+	return &Manager{
+		repo:       repo,
+		types:      make(map[string]*Type),
+		running:    make(map[int]*Task),
+		publishers: make(map[int]*utils.Publisher[utils.Message]),
+		secrets:    make(map[int]string),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Register 注册任务类型
+func (m *Manager) Register(t Type) {
+	if t.MaxAttempts < 1 {
+		t.MaxAttempts = 1
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.types[t.Name] = &t
+}
+
+// Start 恢复服务重启前中断的任务并开始调度，多次调用只执行一次
+func (m *Manager) Start() {
+	m.once.Do(func() {
+		if err := m.recoverInterrupted(); err != nil {
+			zlog.SugLog.Errorf("恢复后台任务失败: %v", err)
+		}
+		go m.loop()
+	})
+}
+
+// recoverInterrupted 可恢复的任务重新排队，其他任务标记为失败
+func (m *Manager) recoverInterrupted() error {
+	var resumable []string
+	for name, t := range m.types {
+		if t.Resumable {
+			resumable = append(resumable, name)
+		}
+	}
+	now := time.Now()
+	if len(resumable) > 0 {
+		_, err := m.repo.Updates(map[string]any{"status": models.JobPending, "run_at": now},
+			repository.WithJobStatus(models.JobRunning), repository.WithJobTypes(resumable...))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	_, err := m.repo.Updates(map[string]any{
+		"status":      models.JobFailed,
+		"error":       "服务重启，任务被中断",
+		"finished_at": now,
+	}, repository.WithJobStatus(models.JobRunning))
+	return errors.WithStack(err)
+}
+
+func (m *Manager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) loop() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	var cleanedAt time.Time
+	for {
+		m.schedule()
+		if time.Since(cleanedAt) > cleanupInterval {
+			cleanedAt = time.Now()
+			m.cleanup()
+		}
+		select {
+		case <-m.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) cleanup() {
+	err := m.repo.Delete(
+		repository.WithJobStatus(models.JobDone, models.JobFailed, models.JobCanceled),
+		repository.WithJobFinishedBefore(time.Now().Add(-retention)),
+	)
+	if err != nil {
+		zlog.SugLog.Error(err)
+		return
+	}
+	m.pruneSecrets()
+}
+
+// pruneSecrets 删除已经被清理的任务的敏感参数
+func (m *Manager) pruneSecrets() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if len(m.secrets) == 0 {
+		return
+	}
+	ids := make([]int, 0, len(m.secrets))
+	for id := range m.secrets {
+		ids = append(ids, id)
+	}
+	jobs, err := m.repo.Find(repository.WithJobIds(ids...))
+	if err != nil {
+		zlog.SugLog.Error(err)
+		return
+	}
+	exists := make(map[int]bool, len(jobs))
+	for _, job := range jobs {
+		exists[job.Id] = true
+	}
+	for _, id := range ids {
+		if !exists[id] {
+			delete(m.secrets, id)
+		}
+	}
+}
+
+func (m *Manager) schedule() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	free := maxConcurrency - len(m.running)
+	if free <= 0 {
+		return
+	}
+	jobs, err := m.repo.Find(
+		repository.WithJobStatus(models.JobPending),
+		repository.WithJobRunnable(time.Now()),
+		repository.WithJobQueueOrder(),
+		base.WithPaginate(1, scheduleBatch),
+	)
+	if err != nil {
+		zlog.SugLog.Error(err)
+		return
+	}
+	for _, job := range jobs {
+		if _, ok := m.types[job.Type]; !ok {
+			m.failUnknown(job)
+		}
+	}
+	running := make(map[string]int)
+	for _, task := range m.running {
+		running[task.Job.Type]++
+	}
+	for _, job := range pickJobs(jobs, m.types, running, free) {
+		if err := m.claim(&job); err != nil {
+			if !errors.Is(err, errNotClaimed) {
+				zlog.SugLog.Error(err)
+			}
+			continue
+		}
+		m.start(job, m.types[job.Type])
+	}
+}
+
+// pickJobs 按顺序选出可以执行的任务，同时满足全局和类型的并发限制
+func pickJobs(jobs []models.SysJob, jobTypes map[string]*Type, running map[string]int, free int) []models.SysJob {
+	counts := make(map[string]int, len(running))
+	for k, v := range running {
+		counts[k] = v
+	}
+	var data []models.SysJob
+	for _, job := range jobs {
+		if len(data) >= free {
+			break
+		}
+		t, ok := jobTypes[job.Type]
+		if !ok {
+			continue
+		}
+		if t.Concurrency > 0 && counts[job.Type] >= t.Concurrency {
+			continue
+		}
+		counts[job.Type]++
+		data = append(data, job)
+	}
+	return data
+}
+
+var errNotClaimed = errors.New("任务已被处理")
+
+// claim 将任务从等待状态改为执行状态，任务已被取消时返回 errNotClaimed
+func (m *Manager) claim(job *models.SysJob) error {
+	now := time.Now()
+	n, err := m.repo.Updates(map[string]any{
+		"status":     models.JobRunning,
+		"attempts":   gorm.Expr("attempts + 1"),
+		"started_at": now,
+	}, repository.WithJobId(job.Id), repository.WithJobStatus(models.JobPending))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if n == 0 {
+		return errNotClaimed
+	}
+	job.Status = models.JobRunning
+	job.Attempts++
+	job.StartedAt = &now
+	return nil
+}
+
+func (m *Manager) failUnknown(job models.SysJob) {
+	_, err := m.repo.Updates(map[string]any{
+		"status":      models.JobFailed,
+		"error":       "不支持的任务类型",
+		"finished_at": time.Now(),
+	}, repository.WithJobId(job.Id), repository.WithJobStatus(models.JobPending))
+	if err != nil {
+		zlog.SugLog.Error(err)
+	}
+}
+
+// start 在调度锁内调用
+func (m *Manager) start(job models.SysJob, t *Type) {
+	ctx, cancel := context.WithCancel(context.Background())
+	publisher, ok := m.publishers[job.Id]
+	if !ok {
+		publisher = utils.NewPublisher[utils.Message]()
+		m.publishers[job.Id] = publisher
+	}
+	task := newTask(m, job, publisher, cancel)
+	task.secret = m.secrets[job.Id]
+	m.running[job.Id] = task
+	go m.run(ctx, task, t)
+}
+
+func (m *Manager) run(ctx context.Context, task *Task, t *Type) {
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = errors.Errorf("任务 %d panic: %v", task.Job.Id, r)
+			}
+		}()
+		err = t.Run(ctx, task)
+	}()
+	m.finish(task, err)
+}
+
+// retryDelay 第n次执行失败后等待的时间
+func retryDelay(attempts int) time.Duration {
+	delay := 10 * time.Second
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// willRetry 执行失败后是否会自动重试，Fail 返回的错误和最后一次执行失败时不再重试
+func willRetry(job models.SysJob, err error) bool {
+	var failErr *FailError
+	return !errors.As(err, &failErr) && job.Attempts < job.MaxAttempts
+}
+
+func (m *Manager) finish(task *Task, err error) {
+	job := task.Job
+	total, done, message := task.progress()
+	now := time.Now()
+	values := map[string]any{"total": total, "done": done, "message": message}
+	var final *utils.Message
+	switch {
+	case err == nil:
+		msg := task.result
+		if msg == "" {
+			msg = "任务完成"
+		}
+		values["status"], values["message"], values["finished_at"] = models.JobDone, msg, now
+		final = &utils.Message{K: MessageDone, V: msg}
+	case task.canceled.Load():
+		values["status"], values["error"], values["finished_at"] = models.JobCanceled, "任务已取消", now
+		final = &utils.Message{K: MessageError, V: "任务已取消"}
+	default:
+		msg := "任务执行失败"
+		var failErr *FailError
+		if errors.As(err, &failErr) {
+			msg = failErr.Msg
+		}
+		if failErr == nil || failErr.Err != nil {
+			zlog.SugLog.Errorf("任务 %d(%s) 执行失败: %+v", job.Id, job.Type, err)
+		}
+		values["error"] = msg
+		if willRetry(job, err) {
+			delay := retryDelay(job.Attempts)
+			values["status"], values["run_at"] = models.JobPending, now.Add(delay)
+			task.publish(MessageLog, msg+"，"+delay.String()+"后重试")
+			break
+		}
+		values["status"], values["finished_at"] = models.JobFailed, now
+		final = &utils.Message{K: MessageError, V: msg}
+	}
+
+	m.mutex.Lock()
+	delete(m.running, job.Id)
+	if _, err := m.repo.Updates(values, repository.WithJobId(job.Id)); err != nil {
+		zlog.SugLog.Error(err)
+	}
+	var publisher *utils.Publisher[utils.Message]
+	if final != nil {
+		publisher = m.publishers[job.Id]
+		delete(m.publishers, job.Id)
+	}
+	if err == nil {
+		delete(m.secrets, job.Id)
+	}
+	m.mutex.Unlock()
+
+	if publisher != nil {
+		publisher.Publish(*final)
+		publisher.Close()
+	}
+	m.notify()
+}
+
+// Submit 检查参数后创建任务，相同key的任务未结束时返回已有的任务
+func (m *Manager) Submit(claims *types.JwtClaims, name string, raw json.RawMessage, priority int) (models.SysJob, error) {
+	m.mutex.Lock()
+	t, ok := m.types[name]
+	m.mutex.Unlock()
+	if !ok {
+		err := errors.Errorf("不支持的任务类型: %s", name)
+		return models.SysJob{}, core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(err.Error())
+	}
+	if t.AdminOnly && claims.RoleKey != models.AdminRoleKey {
+		return models.SysJob{}, core.NewApiBizErr(nil).SetBizCode(global.BizAccessDenied).SetMsg("无权限")
+	}
+	key, params, err := t.Prepare(claims, raw)
+	if err != nil {
+		return models.SysJob{}, err
+	}
+	var secret string
+	if s, ok := params.(Secret); ok {
+		params, secret = s.TakeSecret()
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return models.SysJob{}, errors.WithStack(err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if key != "" {
+		job, ok, err := m.findActive(name, key)
+		if err != nil {
+			return job, err
+		}
+		if ok {
+			if job.UserId != claims.UserId {
+				return job, core.NewApiBizErr(nil).SetHttpCode(global.ConflictError).
+					SetBizCode(global.BizConflict).SetMsg("其他用户正在执行相同的任务，请稍后再试")
+			}
+			return job, nil
+		}
+	}
+	job := models.SysJob{
+		Type:        name,
+		Key:         key,
+		Status:      models.JobPending,
+		Priority:    priority,
+		Params:      string(data),
+		MaxAttempts: t.MaxAttempts,
+		UserId:      claims.UserId,
+		Username:    claims.Username,
+		RoleKey:     claims.RoleKey,
+		RunAt:       time.Now(),
+	}
+	job.SetCreateBy(claims.UserId)
+	if err := m.repo.Create(&job); err != nil {
+		return job, errors.WithStack(err)
+	}
+	if secret != "" {
+		m.secrets[job.Id] = secret
+	}
+	m.notify()
+	return job, nil
+}
+
+func (m *Manager) findActive(name, key string) (models.SysJob, bool, error) {
+	job, err := m.repo.FindOne(
+		repository.WithJobType(name),
+		repository.WithJobKey(key),
+		repository.WithJobStatus(models.JobPending, models.JobRunning),
+	)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return job, false, nil
+	}
+	return job, err == nil, errors.WithStack(err)
+}
+
+// FindActive 查找相同key未结束的任务
+func (m *Manager) FindActive(name, key string) (models.SysJob, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.findActive(name, key)
+}
+
+// Cancel 取消任务，执行中的任务会停止执行
+func (m *Manager) Cancel(id int) error {
+	m.mutex.Lock()
+	if task, ok := m.running[id]; ok {
+		task.canceled.Store(true)
+		task.cancel()
+		m.mutex.Unlock()
+		return nil
+	}
+	n, err := m.repo.Updates(map[string]any{
+		"status":      models.JobCanceled,
+		"error":       "任务已取消",
+		"finished_at": time.Now(),
+	}, repository.WithJobId(id), repository.WithJobStatus(models.JobPending))
+	publisher := m.publishers[id]
+	if n > 0 {
+		delete(m.publishers, id)
+	}
+	m.mutex.Unlock()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if n == 0 {
+		return core.NewApiBizErr(nil).SetBizCode(global.BizBadRequest).SetMsg("任务已经结束")
+	}
+	if publisher != nil {
+		publisher.Publish(utils.NewMessage(MessageError, "任务已取消"))
+		publisher.Close()
+	}
+	go m.discard(id)
+	return nil
+}
+
+// discard 等待重试的任务被取消后清理之前执行留下的部分结果
+func (m *Manager) discard(id int) {
+	job, err := m.repo.FindOne(repository.WithJobId(id))
+	if err != nil {
+		zlog.SugLog.Error(err)
+		return
+	}
+	if job.Attempts == 0 && !job.Retried {
+		return
+	}
+	m.mutex.Lock()
+	t, ok := m.types[job.Type]
+	m.mutex.Unlock()
+	if ok && t.Discard != nil {
+		t.Discard(job)
+	}
+}
+
+// Retry 重新执行失败或已取消的任务
+func (m *Manager) Retry(job models.SysJob) error {
+	if job.Status != models.JobFailed && job.Status != models.JobCanceled {
+		return core.NewApiBizErr(nil).SetBizCode(global.BizBadRequest).SetMsg("只能重试失败或已取消的任务")
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if job.Key != "" {
+		_, ok, err := m.findActive(job.Type, job.Key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return core.NewApiBizErr(nil).SetHttpCode(global.ConflictError).
+				SetBizCode(global.BizConflict).SetMsg("已有相同的任务正在执行")
+		}
+	}
+	n, err := m.repo.Updates(map[string]any{
+		"status":      models.JobPending,
+		"attempts":    0,
+		"retried":     true,
+		"done":        0,
+		"error":       "",
+		"run_at":      time.Now(),
+		"started_at":  nil,
+		"finished_at": nil,
+	}, repository.WithJobId(job.Id), repository.WithJobStatus(models.JobFailed, models.JobCanceled))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if n == 0 {
+		return core.NewApiBizErr(nil).SetBizCode(global.BizConflict).SetMsg("任务状态已变化，请刷新后重试")
+	}
+	m.notify()
+	return nil
+}
+
+// subscribe 订阅任务日志，任务已经结束时返回nil，同时返回订阅时任务的状态
+func (m *Manager) subscribe(id int) (*utils.Subscriber[utils.Message], models.SysJob, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job, err := m.repo.FindOne(repository.WithJobId(id))
+	if err != nil {
+		return nil, job, errors.WithStack(err)
+	}
+	if task, ok := m.running[id]; ok {
+		job.Total, job.Done, job.Message = task.progress()
+	}
+	publisher, ok := m.publishers[id]
+	if !ok {
+		if job.Finished() {
+			return nil, job, nil
+		}
+		publisher = utils.NewPublisher[utils.Message]()
+		m.publishers[id] = publisher
+	}
+	return publisher.CreateSubscriber(), job, nil
+}
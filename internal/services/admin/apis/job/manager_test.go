@@ -0,0 +1,85 @@
+package job
+
+import (
+	"go-file-server/internal/services/admin/models"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestPickJobs(t *testing.T) {
+	jobTypes := map[string]*Type{
+		"copy":    {Name: "copy", Concurrency: 1},
+		"reindex": {Name: "reindex"},
+	}
+	jobs := []models.SysJob{
+		{Id: 1, Type: "copy"},
+		{Id: 2, Type: "copy"},
+		{Id: 3, Type: "unknown"},
+		{Id: 4, Type: "reindex"},
+		{Id: 5, Type: "reindex"},
+	}
+	tests := []struct {
+		name    string
+		running map[string]int
+		free    int
+		want    []int
+	}{
+		{"类型并发限制", nil, 4, []int{1, 4, 5}},
+		{"全局并发限制", nil, 2, []int{1, 4}},
+		{"已有执行中的任务", map[string]int{"copy": 1}, 4, []int{4, 5}},
+		{"没有空闲", nil, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pickJobs(jobs, jobTypes, tt.running, tt.free)
+			if len(got) != len(tt.want) {
+				t.Fatalf("pickJobs() = %v, want %v", got, tt.want)
+			}
+			for i, job := range got {
+				if job.Id != tt.want[i] {
+					t.Errorf("pickJobs()[%d] = %d, want %d", i, job.Id, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{20, maxRetryDelay},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestWillRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		err      error
+		want     bool
+	}{
+		{"还有重试次数", 1, errors.New("io"), true},
+		{"最后一次执行", 3, errors.New("io"), false},
+		{"不再重试的错误", 1, errors.Wrap(Fail("参数错误", nil), "复制失败"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := models.SysJob{Attempts: tt.attempts, MaxAttempts: 3}
+			if got := willRetry(job, tt.err); got != tt.want {
+				t.Errorf("willRetry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
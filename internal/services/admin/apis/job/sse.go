@@ -0,0 +1,75 @@
+package job
+
+import (
+	"encoding/json"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/utils/timex"
+	"go-file-server/pkgs/zlog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stream 通过sse推送任务日志和进度
+func (api *JobApi) Stream(c *gin.Context) {
+	var req IdReq
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if _, err := api.getOwnJob(c, req.Id); err != nil {
+		c.Error(err)
+		return
+	}
+	api.manager.Stream(c, req.Id)
+}
+
+// Stream 推送任务日志直到任务结束或客户端断开，客户端断开不会影响任务执行。
+// 连接时先推送一次当前进度，任务已结束时只推送结果
+func (m *Manager) Stream(c *gin.Context, id int) {
+	subscriber, job, err := m.subscribe(id)
+	if err != nil {
+		zlog.SugLog.Error(err)
+		core.OnceStream(c, MessageError, "内部错误")
+		return
+	}
+	if subscriber == nil {
+		k, v := finalMessage(job)
+		core.OnceStream(c, k, v)
+		return
+	}
+	defer subscriber.Close()
+
+	ticker := timex.NewImmediateTicker(time.Millisecond * 500)
+	defer ticker.Stop()
+	core.SetSseHeader(c)
+	defer c.Writer.Flush()
+	if data, err := json.Marshal(Progress{Total: job.Total, Done: job.Done}); err == nil {
+		c.SSEvent(MessageProgress, string(data))
+	}
+	for {
+		select {
+		case message, ok := <-subscriber.Messages():
+			if !ok {
+				return
+			}
+			c.SSEvent(message.K, message.V)
+			if message.K == MessageDone || message.K == MessageError {
+				return
+			}
+		case <-ticker.C:
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// finalMessage 已结束任务的结果
+func finalMessage(job models.SysJob) (string, string) {
+	if job.Status == models.JobDone {
+		return MessageDone, job.Message
+	}
+	return MessageError, job.Error
+}
@@ -0,0 +1,176 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/zlog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// sse 消息类型，与解压接口的消息保持一致
+const (
+	MessageLog      = "message"
+	MessageSkip     = "skip"
+	MessageProgress = "progress"
+	MessageDone     = "done"
+	MessageError    = "error"
+)
+
+// flushInterval 进度写入数据库以及推送进度的最小间隔
+const flushInterval = time.Second
+
+// FailError 不需要重试的错误，Msg 会展示给用户
+type FailError struct {
+	Msg string
+	Err error
+}
+
+func (e *FailError) Error() string {
+	if e.Err != nil {
+		return e.Msg + ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+func (e *FailError) Unwrap() error {
+	return e.Err
+}
+
+// Fail 返回不再重试的错误，msg 作为任务的失败原因展示给用户，err 只记录日志
+func Fail(msg string, err error) error {
+	return &FailError{Msg: msg, Err: err}
+}
+
+// Progress 任务进度
+type Progress struct {
+	Total int64 `json:"total"`
+	Done  int64 `json:"done"`
+}
+
+// Task 执行中的任务，Run 通过 Task 读取参数以及上报日志和进度
+type Task struct {
+	// Job 开始执行时的任务信息，不会随进度更新
+	Job       models.SysJob
+	manager   *Manager
+	publisher *utils.Publisher[utils.Message]
+	cancel    context.CancelFunc
+	canceled  atomic.Bool
+	secret    string
+
+	mutex     sync.Mutex
+	total     int64
+	done      int64
+	message   string
+	result    string
+	flushedAt time.Time
+}
+
+func newTask(m *Manager, job models.SysJob, publisher *utils.Publisher[utils.Message], cancel context.CancelFunc) *Task {
+	return &Task{
+		Job:       job,
+		manager:   m,
+		publisher: publisher,
+		cancel:    cancel,
+		total:     job.Total,
+		done:      job.Done,
+		message:   job.Message,
+		flushedAt: time.Now(),
+	}
+}
+
+// Bind 解析提交时保存的参数
+func (t *Task) Bind(v any) error {
+	if err := json.Unmarshal([]byte(t.Job.Params), v); err != nil {
+		return Fail("任务参数错误", err)
+	}
+	return nil
+}
+
+// Secret 提交时的敏感参数，服务重启后为空
+func (t *Task) Secret() string {
+	return t.secret
+}
+
+// Retried 任务之前执行过，可能留下了部分结果，用于重试或服务重启后继续执行
+func (t *Task) Retried() bool {
+	return t.Job.Retried || t.Job.Attempts > 1
+}
+
+// Final 本次执行返回 err 后任务被取消或不会再自动重试，用于清理重试时才需要的部分结果
+func (t *Task) Final(err error) bool {
+	return t.canceled.Load() || !willRetry(t.Job, err)
+}
+
+// Log 推送日志，最新的一条日志会保存到任务中
+func (t *Task) Log(msg string) {
+	t.publish(MessageLog, msg)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.message = msg
+	t.flush(false)
+}
+
+// Skip 推送被跳过的条目
+func (t *Task) Skip(msg string) {
+	t.publish(MessageSkip, msg)
+}
+
+// SetTotal 设置总进度，单位由任务类型决定
+func (t *Task) SetTotal(total int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.total = total
+	t.flush(false)
+}
+
+// Add 增加已完成的进度
+func (t *Task) Add(n int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.done += n
+	t.flush(false)
+}
+
+// Complete 设置任务完成时推送的消息
+func (t *Task) Complete(msg string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.result = msg
+}
+
+func (t *Task) publish(k, v string) {
+	t.publisher.Publish(utils.NewMessage(k, v))
+}
+
+func (t *Task) progress() (total, done int64, message string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.total, t.done, t.message
+}
+
+// flush 持有锁时调用，距离上次写入不足 flushInterval 时跳过
+func (t *Task) flush(force bool) {
+	if !force && time.Since(t.flushedAt) < flushInterval {
+		return
+	}
+	t.flushedAt = time.Now()
+	_, err := t.manager.repo.Updates(map[string]any{
+		"total":   t.total,
+		"done":    t.done,
+		"message": t.message,
+	}, repository.WithJobId(t.Job.Id))
+	if err != nil {
+		zlog.SugLog.Error(errors.WithStack(err))
+	}
+	data, err := json.Marshal(Progress{Total: t.total, Done: t.done})
+	if err == nil {
+		t.publish(MessageProgress, string(data))
+	}
+}
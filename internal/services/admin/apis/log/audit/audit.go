@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/base"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditApi struct {
+	auditRepo *repository.FileAuditRepository
+}
+
+func NewAuditApi(auditRepo *repository.FileAuditRepository) *AuditApi {
+	return &AuditApi{
+		auditRepo: auditRepo,
+	}
+}
+
+type QueryReq struct {
+	// Path 真实路径前缀
+	Path      string `form:"path"`
+	Actor     string `form:"actor"`
+	Protocol  string `form:"protocol"`
+	Action    string `form:"action"`
+	Result    string `form:"result" binding:"omitempty,oneof=success failed"`
+	BeginTime string `form:"beginTime"`
+	EndTime   string `form:"endTime"`
+}
+
+func (req QueryReq) scopes() []base.DbScope {
+	var querys []base.DbScope
+	if req.Path != "" {
+		querys = append(querys, repository.WithAuditPathPrefix(req.Path))
+	}
+	if req.Actor != "" {
+		querys = append(querys, repository.WithAuditActor(req.Actor))
+	}
+	if req.Protocol != "" {
+		querys = append(querys, repository.WithAuditProtocol(req.Protocol))
+	}
+	if req.Action != "" {
+		querys = append(querys, repository.WithAuditAction(req.Action))
+	}
+	if req.Result != "" {
+		querys = append(querys, repository.WithAuditResult(req.Result))
+	}
+	if req.BeginTime != "" {
+		querys = append(querys, repository.WithAuditBegin(req.BeginTime))
+	}
+	if req.EndTime != "" {
+		querys = append(querys, repository.WithAuditEnd(req.EndTime))
+	}
+	return querys
+}
+
+type GetPageReq struct {
+	types.Pagination
+	QueryReq
+}
+
+type GetPageRep struct {
+	types.Page
+	Items []models.SysFileAudit `json:"items"`
+}
+
+// GetPage 分页查询文件审计记录，按时间倒序
+func (api *AuditApi) GetPage(c *gin.Context) {
+	var req GetPageReq
+	err := c.ShouldBindQuery(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	data, count, err := api.auditRepo.FindWithCount(append(req.scopes(),
+		base.WithOrderBy("id", true),
+		base.WithPaginate(req.PageIndex, req.PageSize),
+	)...)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(GetPageRep{
+		Page:  types.NewPage(count, req.PageIndex, req.PageSize),
+		Items: data,
+	}).SendGin(c)
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"encoding/csv"
+	"fmt"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/base"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// exportBatch 导出时每次从数据库读取的条数
+const exportBatch = 1000
+
+var csvHeader = []string{"时间", "用户", "访问方式", "操作", "路径", "目标路径", "字节数", "结果", "失败原因", "客户端ip"}
+
+// Export 按查询条件导出csv，按时间倒序分批读取后直接写入响应
+func (api *AuditApi) Export(c *gin.Context) {
+	var req QueryReq
+	err := c.ShouldBindQuery(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	// 先读取第一批，查询出错时还能返回错误信息
+	audits, err := api.findBatch(req, 0)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	fileName := fmt.Sprintf("file-audit-%s.csv", time.Now().Format("20060102150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+fileName)
+	if err := api.writeCsv(c.Writer, req, audits); err != nil {
+		// 响应头已经发送，只能中断
+		c.Error(err)
+		c.Abort()
+	}
+}
+
+func (api *AuditApi) writeCsv(w io.Writer, req QueryReq, audits []models.SysFileAudit) error {
+	// excel 需要BOM才能识别utf-8
+	if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for len(audits) > 0 {
+		for _, a := range audits {
+			err := writeRow(cw, []string{
+				a.CreatedAt.Format(time.DateTime),
+				a.Actor,
+				a.Protocol,
+				a.Action,
+				a.Path,
+				a.Dest,
+				strconv.FormatInt(a.Bytes, 10),
+				a.Result,
+				a.Error,
+				a.ClientIp,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		if len(audits) < exportBatch {
+			break
+		}
+		var err error
+		if audits, err = api.findBatch(req, audits[len(audits)-1].Id); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeRow 路径、用户名、错误信息都可能由用户控制，
+// 以 = + - @ 制表符或回车开头的单元格会被表格软件当作公式执行，加上 ' 前缀作为文本
+func writeRow(cw *csv.Writer, row []string) error {
+	for i, cell := range row {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			row[i] = "'" + cell
+		}
+	}
+	return cw.Write(row)
+}
+
+// findBatch 读取id小于 beforeId 的一批记录，beforeId 为0时从最新的开始
+func (api *AuditApi) findBatch(req QueryReq, beforeId int) ([]models.SysFileAudit, error) {
+	querys := req.scopes()
+	if beforeId > 0 {
+		querys = append(querys, repository.WithAuditIdBefore(beforeId))
+	}
+	audits, err := api.auditRepo.Find(append(querys,
+		base.WithOrderBy("id", true),
+		base.WithPaginate(1, exportBatch),
+	)...)
+	return audits, errors.WithStack(err)
+}
@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"go-file-server/internal/services/admin/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteCsv(t *testing.T) {
+	at := time.Date(2024, 5, 1, 8, 30, 0, 0, time.Local)
+	tests := []struct {
+		name   string
+		audits []models.SysFileAudit
+		want   [][]string
+	}{
+		{
+			name: "empty",
+			want: [][]string{csvHeader},
+		},
+		{
+			name: "quoted fields",
+			audits: []models.SysFileAudit{{
+				Id: 2, Actor: "alice", Protocol: ProtocolFtp, Action: ActionUpload,
+				Path: "/data/a,b.txt", Bytes: 1024, Result: models.AuditFailed,
+				Error: `写入失败 "disk full"`, ClientIp: "10.0.0.1", CreatedAt: at,
+			}},
+			want: [][]string{csvHeader, {
+				"2024-05-01 08:30:00", "alice", "ftp", "upload", "/data/a,b.txt", "", "1024",
+				"failed", `写入失败 "disk full"`, "10.0.0.1",
+			}},
+		},
+		{
+			name: "formula cells",
+			audits: []models.SysFileAudit{{
+				Id: 3, Actor: "=cmd", Protocol: ProtocolHttp, Action: ActionRename,
+				Path: "/data/+1.txt", Dest: "@SUM(A1)", Result: models.AuditFailed,
+				Error: "-x", ClientIp: "\t10.0.0.1", CreatedAt: at,
+			}},
+			want: [][]string{csvHeader, {
+				"2024-05-01 08:30:00", "'=cmd", "http", "rename", "/data/+1.txt", "'@SUM(A1)", "0",
+				"failed", "'-x", "'\t10.0.0.1",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := (&AuditApi{}).writeCsv(&buf, QueryReq{}, tt.audits); err != nil {
+				t.Fatal(err)
+			}
+			out, ok := strings.CutPrefix(buf.String(), "\xEF\xBB\xBF")
+			if !ok {
+				t.Fatal("缺少BOM")
+			}
+			got, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("rows = %d, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if strings.Join(got[i], "|") != strings.Join(tt.want[i], "|") {
+					t.Errorf("row %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/auditlog"
+	"go-file-server/pkgs/session"
+	"go-file-server/pkgs/zlog"
+	"strings"
+	"time"
+)
+
+// 访问方式
+const (
+	ProtocolHttp = "http"
+	ProtocolFtp  = session.ProtocolFtp
+	ProtocolSftp = session.ProtocolSftp
+	// ProtocolShare 通过生成的分享链接访问，链接可以转发给其他人，不需要登录。
+	// 用户自己下载使用的链接记录为 http
+	ProtocolShare = "share"
+)
+
+// 操作类型
+const (
+	ActionUpload   = "upload"
+	ActionDownload = "download"
+	ActionPreview  = "preview"
+	ActionWrite    = "write"
+	ActionMkdir    = "mkdir"
+	ActionDelete   = "delete"
+	ActionRename   = "rename"
+	ActionMove     = "move"
+	ActionCopy     = "copy"
+	ActionExtract  = "extract"
+	ActionCompress = "compress"
+	// ActionHardlink 重复文件替换为保留文件的硬链接，Dest 为保留的文件
+	ActionHardlink = "hardlink"
+)
+
+const (
+	// queueSize 等待写入的审计记录数，超出后丢弃新的记录
+	queueSize = 4096
+	// batchSize 每次批量写入的最大条数
+	batchSize = 100
+	// flushInterval 不满一批时的写入间隔
+	flushInterval = time.Second
+	// maxErrorLen 失败原因的最大长度
+	maxErrorLen = 512
+)
+
+// Recorder 异步批量写入文件审计记录，不阻塞文件传输
+type Recorder struct {
+	repo  *repository.FileAuditRepository
+	queue chan models.SysFileAudit
+}
+
+func NewRecorder(repo *repository.FileAuditRepository) *Recorder {
+	r := &Recorder{
+		repo:  repo,
+		queue: make(chan models.SysFileAudit, queueSize),
+	}
+	go r.run()
+	return r
+}
+
+// Record 记录一次文件操作，err 不为空时记录为失败
+func (r *Recorder) Record(a models.SysFileAudit, err error) {
+	a.Result = models.AuditSuccess
+	if err != nil {
+		a.Result = models.AuditFailed
+		a.Error = truncate(err.Error(), maxErrorLen)
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	auditlog.Emit(toEvent(a))
+	select {
+	case r.queue <- a:
+	default:
+		zlog.SugLog.Warnf("文件审计记录过多，丢弃记录: %s %s %s", a.Actor, a.Action, a.Path)
+	}
+}
+
+// toEvent 转换为输出到审计总线的事件
+func toEvent(a models.SysFileAudit) auditlog.Event {
+	e := auditlog.Event{
+		Time:     a.CreatedAt,
+		Category: auditlog.CategoryFile,
+		Action:   a.Action,
+		Outcome:  auditlog.OutcomeSuccess,
+		Actor:    a.Actor,
+		ActorId:  a.UserId,
+		Protocol: a.Protocol,
+		ClientIp: a.ClientIp,
+		Target:   a.Path,
+		Details:  map[string]any{"bytes": a.Bytes},
+	}
+	if a.Dest != "" {
+		e.Details["dest"] = a.Dest
+	}
+	if a.Result == models.AuditFailed {
+		e.Outcome = auditlog.OutcomeFailure
+		e.Reason = a.Error
+	}
+	return e
+}
+
+func (r *Recorder) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	batch := make([]models.SysFileAudit, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := r.repo.CreateInBatches(batch); err != nil {
+			zlog.SugLog.Errorf("写入文件审计记录失败: %v", err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case a := <-r.queue:
+			batch = append(batch, a)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// truncate 截断到 n 字节，去掉被截断的不完整字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
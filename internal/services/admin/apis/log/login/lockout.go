@@ -0,0 +1,56 @@
+package login
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/loginguard"
+	"go-file-server/internal/common/middlewares"
+	"go-file-server/pkgs/auditlog"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GetLockoutRep struct {
+	Items []loginguard.Lock `json:"items"`
+}
+
+type UnlockReq struct {
+	// Kind user 或 ip
+	Kind    string `json:"kind" binding:"required,oneof=user ip"`
+	Subject string `json:"subject" binding:"required"`
+}
+
+// GetLockout 当前锁定的用户名和ip
+func (api *LoginAPI) GetLockout(c *gin.Context) {
+	locks, err := api.guard.Locks()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(GetLockoutRep{Items: locks}).SendGin(c)
+}
+
+// Unlock 解除用户名或ip的锁定
+func (api *LoginAPI) Unlock(c *gin.Context) {
+	var req UnlockReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	err = api.guard.Unlock(req.Kind, req.Subject)
+	claims := core.ExtractClaims(c)
+	auditlog.Emit(auditlog.Event{
+		Category: auditlog.CategoryAuth,
+		Action:   auditlog.ActionUnlock,
+		Actor:    claims.Username,
+		ActorId:  claims.UserId,
+		Protocol: middlewares.ProtocolHttp,
+		ClientIp: core.GetClientIP(c),
+		Target:   req.Kind + ":" + req.Subject,
+	}.WithErr(err))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(nil).SendGin(c)
+}
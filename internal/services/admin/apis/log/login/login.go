@@ -1,16 +1,21 @@
 package login
 
-import "go-file-server/internal/common/repository"
+import (
+	"go-file-server/internal/common/loginguard"
+	"go-file-server/internal/common/repository"
+)
 
 type LoginAPI struct {
 	loginrepo *repository.LoginLogRepository
+	guard     *loginguard.Guard
 }
 
 func NewLogApi(
 	loginrepo *repository.LoginLogRepository,
-
+	guard *loginguard.Guard,
 ) *LoginAPI {
 	return &LoginAPI{
 		loginrepo: loginrepo,
+		guard:     guard,
 	}
 }
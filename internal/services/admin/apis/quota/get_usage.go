@@ -0,0 +1,72 @@
+package quota
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type GetUsageReq struct {
+	// UserId 为空时查询当前用户，查询其他用户需要管理员权限
+	UserId int `form:"userId"`
+}
+
+// GetUsage 查询流量配额以及当前用量
+func (api *QuotaApi) GetUsage(c *gin.Context) {
+	var req GetUsageReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	claims := core.ExtractClaims(c)
+	if req.UserId == 0 || req.UserId == claims.UserId {
+		data, err := api.manager.GetUsage(claims.UserId, claims.RoleKey)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		core.OKRep(data).SendGin(c)
+		return
+	}
+
+	if err := core.AssertAdmin(c); err != nil {
+		c.Error(err)
+		return
+	}
+	roleKey, err := api.getRoleKey(req.UserId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	data, err := api.manager.GetUsage(req.UserId, roleKey)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(data).SendGin(c)
+}
+
+func (api *QuotaApi) getRoleKey(userId int) (string, error) {
+	user, err := api.userRepo.FindOne(repository.WithUserId(userId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", core.NewApiBizErr(err).
+				SetBizCode(global.BizNotFound).
+				SetMsg("用户不存在")
+		}
+		return "", errors.WithStack(err)
+	}
+	role, err := api.roleRepo.FindOne(repository.WithRoleId(user.RoleId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", errors.WithStack(err)
+	}
+	return role.RoleKey, nil
+}
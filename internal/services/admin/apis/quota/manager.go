@@ -0,0 +1,266 @@
+package quota
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/apis/role"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/cache"
+	"go-file-server/pkgs/zlog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	Download = "download"
+	Upload   = "upload"
+
+	UserQuotaKey = "UserQuotaKey"
+	usagePrefix  = "TransferUsage"
+
+	// flushBytes 计数器累计到该值时写入缓存，减少redis的访问次数
+	flushBytes = 1 << 20
+)
+
+var ErrQuotaExceeded = errors.New("传输流量超出配额")
+
+// Manager 流量配额管理，用量按天/按月记录在缓存中
+type Manager struct {
+	cache         cache.AdapterCache
+	roleRepo      *repository.RoleRepository
+	userQuotaRepo *repository.UserQuotaRepository
+}
+
+func NewManager(
+	cache cache.AdapterCache,
+	roleRepo *repository.RoleRepository,
+	userQuotaRepo *repository.UserQuotaRepository,
+) *Manager {
+	return &Manager{
+		cache:         cache,
+		roleRepo:      roleRepo,
+		userQuotaRepo: userQuotaRepo,
+	}
+}
+
+// Usage 当前窗口的配额和用量
+type Usage struct {
+	Quota           models.TransferQuota `json:"quota"`
+	DailyDownload   int64                `json:"dailyDownload"`
+	DailyUpload     int64                `json:"dailyUpload"`
+	MonthlyDownload int64                `json:"monthlyDownload"`
+	MonthlyUpload   int64                `json:"monthlyUpload"`
+	DailyResetAt    time.Time            `json:"dailyResetAt"`
+	MonthlyResetAt  time.Time            `json:"monthlyResetAt"`
+}
+
+func GenRoleQuotaKey(roleKey string) string {
+	return fmt.Sprintf("%s-%s", role.TransferQuotaKey, roleKey)
+}
+
+func GenUserQuotaKey(userId int) string {
+	return fmt.Sprintf("%s-%d", UserQuotaKey, userId)
+}
+
+func genUsageKey(userId int, direction, window string) string {
+	return fmt.Sprintf("%s:%d:%s:%s", usagePrefix, userId, direction, window)
+}
+
+func dayWindow(t time.Time) (string, time.Time) {
+	y, m, d := t.Date()
+	return t.Format("20060102"), time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+}
+
+func monthWindow(t time.Time) (string, time.Time) {
+	y, m, _ := t.Date()
+	return t.Format("200601"), time.Date(y, m+1, 1, 0, 0, 0, 0, t.Location())
+}
+
+// GetQuota 获取用户生效的配额，用户配额存在时覆盖角色配额
+func (m *Manager) GetQuota(userId int, roleKey string) (models.TransferQuota, error) {
+	userQuota, err := m.getUserQuota(userId)
+	if err != nil {
+		return models.TransferQuota{}, err
+	}
+	if userQuota != nil {
+		return *userQuota, nil
+	}
+	return m.getRoleQuota(roleKey)
+}
+
+func (m *Manager) getRoleQuota(roleKey string) (models.TransferQuota, error) {
+	var quota models.TransferQuota
+	key := GenRoleQuotaKey(roleKey)
+	data, err := m.cache.Get(key)
+	if err == nil {
+		return quota, json.Unmarshal([]byte(data), &quota)
+	}
+	if !cache.IsKeyNotFoundError(err) {
+		return quota, err
+	}
+	sysRole, err := m.roleRepo.FindOne(repository.WithRoleKey(roleKey))
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return quota, errors.WithStack(err)
+	}
+	if err == nil {
+		quota = sysRole.TransferQuota
+	}
+	b, err := json.Marshal(quota)
+	if err != nil {
+		return quota, err
+	}
+	return quota, m.cache.Set(key, string(b), 0)
+}
+
+func (m *Manager) getUserQuota(userId int) (*models.TransferQuota, error) {
+	var quota *models.TransferQuota
+	key := GenUserQuotaKey(userId)
+	data, err := m.cache.Get(key)
+	if err == nil {
+		return quota, json.Unmarshal([]byte(data), &quota)
+	}
+	if !cache.IsKeyNotFoundError(err) {
+		return nil, err
+	}
+	userQuota, err := m.userQuotaRepo.FindOne(repository.WithUserId(userId))
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errors.WithStack(err)
+	}
+	if err == nil {
+		quota = &userQuota.TransferQuota
+	}
+	// 不存在时缓存null，避免每次传输都查询数据库
+	b, err := json.Marshal(quota)
+	if err != nil {
+		return nil, err
+	}
+	return quota, m.cache.Set(key, string(b), 0)
+}
+
+// GetUsage 获取用户当前的配额和用量
+func (m *Manager) GetUsage(userId int, roleKey string) (Usage, error) {
+	var usage Usage
+	quota, err := m.GetQuota(userId, roleKey)
+	if err != nil {
+		return usage, err
+	}
+	now := time.Now()
+	day, dayReset := dayWindow(now)
+	month, monthReset := monthWindow(now)
+	usage.Quota = quota
+	usage.DailyResetAt = dayReset
+	usage.MonthlyResetAt = monthReset
+	for _, v := range []struct {
+		dest      *int64
+		direction string
+		window    string
+	}{
+		{&usage.DailyDownload, Download, day},
+		{&usage.DailyUpload, Upload, day},
+		{&usage.MonthlyDownload, Download, month},
+		{&usage.MonthlyUpload, Upload, month},
+	} {
+		*v.dest, err = m.getUsed(genUsageKey(userId, v.direction, v.window))
+		if err != nil {
+			return usage, err
+		}
+	}
+	return usage, nil
+}
+
+func (m *Manager) getUsed(key string) (int64, error) {
+	data, err := m.cache.Get(key)
+	if err != nil {
+		if cache.IsKeyNotFoundError(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return strconv.ParseInt(data, 10, 64)
+}
+
+// Check 检查用户是否还有传输配额，超出配额时返回带重置时间的错误
+func (m *Manager) Check(userId int, roleKey, direction string) error {
+	usage, err := m.GetUsage(userId, roleKey)
+	if err != nil {
+		return err
+	}
+	daily, dailyUsed := usage.Quota.DailyDownload, usage.DailyDownload
+	monthly, monthlyUsed := usage.Quota.MonthlyDownload, usage.MonthlyDownload
+	name := "下载"
+	if direction == Upload {
+		daily, dailyUsed = usage.Quota.DailyUpload, usage.DailyUpload
+		monthly, monthlyUsed = usage.Quota.MonthlyUpload, usage.MonthlyUpload
+		name = "上传"
+	}
+	if monthly > 0 && monthlyUsed >= monthly {
+		return errors.Wrapf(ErrQuotaExceeded, "本月%s流量已用完，将于 %s 重置",
+			name, usage.MonthlyResetAt.Format(time.DateTime))
+	}
+	if daily > 0 && dailyUsed >= daily {
+		return errors.Wrapf(ErrQuotaExceeded, "今日%s流量已用完，将于 %s 重置",
+			name, usage.DailyResetAt.Format(time.DateTime))
+	}
+	return nil
+}
+
+// Counter 传输计数器，配合 limiter.WithCounter 使用，传输结束后需要调用 Flush
+type Counter struct {
+	manager   *Manager
+	userId    int
+	direction string
+	mutex     sync.Mutex
+	pending   int64
+}
+
+func (m *Manager) NewCounter(userId int, direction string) *Counter {
+	return &Counter{
+		manager:   m,
+		userId:    userId,
+		direction: direction,
+	}
+}
+
+func (c *Counter) Add(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.pending += int64(n)
+	if c.pending >= flushBytes {
+		c.flush()
+	}
+}
+
+func (c *Counter) Flush() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.flush()
+}
+
+func (c *Counter) flush() {
+	if c.pending == 0 {
+		return
+	}
+	now := time.Now()
+	day, dayReset := dayWindow(now)
+	month, monthReset := monthWindow(now)
+	for _, w := range []struct {
+		window string
+		reset  time.Time
+	}{{day, dayReset}, {month, monthReset}} {
+		key := genUsageKey(c.userId, c.direction, w.window)
+		if err := c.manager.cache.IncreaseBy(key, c.pending); err != nil {
+			zlog.SugLog.Error(err)
+			continue
+		}
+		// 窗口结束后多保留一天，便于查询
+		if err := c.manager.cache.Expire(key, time.Until(w.reset)+24*time.Hour); err != nil {
+			zlog.SugLog.Error(err)
+		}
+	}
+	c.pending = 0
+}
@@ -0,0 +1,44 @@
+package quota
+
+import (
+	"encoding/json"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/cache"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestManagerCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		quota     models.TransferQuota
+		direction string
+		used      int
+		exceeded  bool
+	}{
+		{"unlimited", models.TransferQuota{}, Download, 10 << 20, false},
+		{"daily under quota", models.TransferQuota{DailyDownload: 2 << 20}, Download, 1 << 20, false},
+		{"daily exceeded", models.TransferQuota{DailyDownload: 2 << 20}, Download, 2 << 20, true},
+		{"monthly exceeded", models.TransferQuota{MonthlyUpload: 1 << 20}, Upload, 1 << 20, true},
+		{"other direction", models.TransferQuota{DailyUpload: 1 << 20}, Download, 1 << 20, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(cache.NewMemory(), nil, nil)
+			userId := i + 1
+			b, _ := json.Marshal(tt.quota)
+			if err := m.cache.Set(GenUserQuotaKey(userId), string(b), 0); err != nil {
+				t.Fatal(err)
+			}
+			counter := m.NewCounter(userId, tt.direction)
+			counter.Add(tt.used)
+			counter.Flush()
+
+			err := m.Check(userId, "", tt.direction)
+			if errors.Is(err, ErrQuotaExceeded) != tt.exceeded {
+				t.Errorf("Check() error = %v, exceeded %v", err, tt.exceeded)
+			}
+		})
+	}
+}
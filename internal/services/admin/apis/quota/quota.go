@@ -0,0 +1,36 @@
+package quota
+
+import (
+	"go-file-server/internal/common/repository"
+	"go-file-server/pkgs/cache"
+)
+
+type QuotaApi struct {
+	manager          *Manager
+	storageManager   *StorageManager
+	storageQuotaRepo *repository.StorageQuotaRepository
+	userRepo         *repository.UserRepository
+	roleRepo         *repository.RoleRepository
+	userQuotaRepo    *repository.UserQuotaRepository
+	cache            cache.AdapterCache
+}
+
+func NewQuotaApi(
+	manager *Manager,
+	storageManager *StorageManager,
+	storageQuotaRepo *repository.StorageQuotaRepository,
+	userRepo *repository.UserRepository,
+	roleRepo *repository.RoleRepository,
+	userQuotaRepo *repository.UserQuotaRepository,
+	cache cache.AdapterCache,
+) *QuotaApi {
+	return &QuotaApi{
+		manager:          manager,
+		storageManager:   storageManager,
+		storageQuotaRepo: storageQuotaRepo,
+		userRepo:         userRepo,
+		roleRepo:         roleRepo,
+		userQuotaRepo:    userQuotaRepo,
+		cache:            cache,
+	}
+}
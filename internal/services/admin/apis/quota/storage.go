@@ -0,0 +1,326 @@
+package quota
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/apis/role"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/cache"
+	"go-file-server/pkgs/pathtool"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	goCache "github.com/patrickmn/go-cache"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	// StorageDirQuotaKey 目录存储配额列表的缓存key
+	StorageDirQuotaKey = "StorageDirQuotaKey"
+
+	StorageKindRole = "role"
+	StorageKindDir  = "dir"
+
+	// usageExpiration 目录占用空间的缓存时间，统计需要遍历索引，
+	// 短时间内的多次写入共用一次统计结果
+	usageExpiration = 5 * time.Second
+)
+
+var ErrStorageExceeded = errors.New("存储空间超出配额")
+
+// StorageLimit 写入某个路径时需要满足的一项存储配额
+type StorageLimit struct {
+	Kind string `json:"kind"`
+	// Name 角色为roleKey，目录为虚拟路径
+	Name  string `json:"name"`
+	Quota int64  `json:"quota"`
+	Usage int64  `json:"usage"`
+	// dirs 配额统计的真实目录
+	dirs []string
+}
+
+// Contains 判断路径是否在配额的统计范围内
+func (l StorageLimit) Contains(realPath string) bool {
+	for _, dir := range l.dirs {
+		if pathtool.IsSubPath(dir, realPath) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l StorageLimit) Remaining() int64 {
+	return max(l.Quota-l.Usage, 0)
+}
+
+func (l StorageLimit) exceededErr() error {
+	name := "角色 " + l.Name
+	if l.Kind == StorageKindDir {
+		name = "目录 " + l.Name
+	}
+	return errors.Wrapf(ErrStorageExceeded, "%s 的存储空间已超出配额，已用 %s / 限额 %s", name,
+		core.FormatBytes(uint64(l.Usage)), core.FormatBytes(uint64(l.Quota)))
+}
+
+// StorageManager 存储配额管理，占用空间通过索引中的文件大小统计，不建立索引的挂载点遍历磁盘统计
+type StorageManager struct {
+	cache            cache.AdapterCache
+	roleRepo         *repository.RoleRepository
+	storageQuotaRepo *repository.StorageQuotaRepository
+	fsRepo           *repository.FsRepository
+	casbinEnforcer   *casbin.CachedEnforcer
+	usage            *goCache.Cache
+}
+
+func NewStorageManager(
+	cache cache.AdapterCache,
+	roleRepo *repository.RoleRepository,
+	storageQuotaRepo *repository.StorageQuotaRepository,
+	fsRepo *repository.FsRepository,
+	casbinEnforcer *casbin.CachedEnforcer,
+) *StorageManager {
+	return &StorageManager{
+		cache:            cache,
+		roleRepo:         roleRepo,
+		storageQuotaRepo: storageQuotaRepo,
+		fsRepo:           fsRepo,
+		casbinEnforcer:   casbinEnforcer,
+		usage:            goCache.New(usageExpiration, time.Minute),
+	}
+}
+
+// Check 检查向 realPath 写入 size 字节后是否超出配额
+func (m *StorageManager) Check(roleKey, realPath string, size int64) error {
+	limits, err := m.Limits(roleKey, realPath)
+	if err != nil {
+		return err
+	}
+	for _, l := range limits {
+		if l.Usage+size > l.Quota {
+			return l.exceededErr()
+		}
+	}
+	return nil
+}
+
+// CheckMove 检查将 src 移动到 dst 后是否超出配额，src 已经在统计范围内的配额不受影响
+func (m *StorageManager) CheckMove(roleKey, src, dst string) error {
+	limits, err := m.Limits(roleKey, dst)
+	if err != nil {
+		return err
+	}
+	var size int64 = -1
+	for _, l := range limits {
+		if l.Contains(src) {
+			continue
+		}
+		if size < 0 {
+			if size, err = m.PathSize(src); err != nil {
+				return err
+			}
+		}
+		if l.Usage+size > l.Quota {
+			return l.exceededErr()
+		}
+	}
+	return nil
+}
+
+// PathSize 文件的大小或目录下全部文件的大小
+func (m *StorageManager) PathSize(realPath string) (int64, error) {
+	info, err := os.Stat(realPath)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if !info.IsDir() {
+		return info.Size(), nil
+	}
+	return m.sumSize(realPath)
+}
+
+// Remaining 返回向 realPath 还能写入的字节数，不限制时返回-1
+func (m *StorageManager) Remaining(roleKey, realPath string) (int64, error) {
+	limits, err := m.Limits(roleKey, realPath)
+	if err != nil {
+		return 0, err
+	}
+	remaining := int64(-1)
+	for _, l := range limits {
+		if remaining < 0 || l.Remaining() < remaining {
+			remaining = l.Remaining()
+		}
+	}
+	return remaining, nil
+}
+
+// Limits 返回写入 realPath 时需要满足的全部存储配额以及当前的占用
+func (m *StorageManager) Limits(roleKey, realPath string) ([]StorageLimit, error) {
+	var limits []StorageLimit
+	if roleKey != models.AdminRoleKey {
+		roleLimit, ok, err := m.roleLimit(roleKey, realPath)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			limits = append(limits, roleLimit)
+		}
+	}
+
+	dirQuotas, err := m.GetDirQuotas()
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range dirQuotas {
+		dir, err := utils.GetRealPath(q.Path)
+		if err != nil || !pathtool.IsSubPath(dir, realPath) {
+			continue
+		}
+		usage, err := m.DirUsage(dir)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, StorageLimit{
+			Kind:  StorageKindDir,
+			Name:  q.Path,
+			Quota: q.Quota,
+			Usage: usage,
+			dirs:  []string{dir},
+		})
+	}
+	return limits, nil
+}
+
+// RoleUsage 返回角色的存储配额和可写目录的总占用
+func (m *StorageManager) RoleUsage(roleKey string) (StorageLimit, error) {
+	limit := StorageLimit{Kind: StorageKindRole, Name: roleKey}
+	quota, err := m.getRoleStorageQuota(roleKey)
+	if err != nil {
+		return limit, err
+	}
+	limit.Quota = quota
+	limit.dirs = m.roleWritableDirs(roleKey)
+	for _, dir := range limit.dirs {
+		usage, err := m.DirUsage(dir)
+		if err != nil {
+			return limit, err
+		}
+		limit.Usage += usage
+	}
+	return limit, nil
+}
+
+func (m *StorageManager) roleLimit(roleKey, realPath string) (StorageLimit, bool, error) {
+	quota, err := m.getRoleStorageQuota(roleKey)
+	if err != nil || quota <= 0 {
+		return StorageLimit{}, false, err
+	}
+	limit, err := m.RoleUsage(roleKey)
+	if err != nil {
+		return StorageLimit{}, false, err
+	}
+	return limit, limit.Contains(realPath), nil
+}
+
+// roleWritableDirs 角色有创建权限的目录，嵌套的目录只保留上级目录，避免重复统计
+func (m *StorageManager) roleWritableDirs(roleKey string) []string {
+	var dirs []string
+	for _, p := range m.casbinEnforcer.GetFilteredPolicy(0, roleKey, "", "POST", "fs") {
+		dir, err := utils.GetRealPath(role.ParseFsRolepath(p[1]))
+		if err != nil {
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	var data []string
+	for _, dir := range dirs {
+		if len(data) > 0 && pathtool.IsSubPath(data[len(data)-1], dir) {
+			continue
+		}
+		data = append(data, dir)
+	}
+	return data
+}
+
+// DirUsage 统计目录下全部文件的大小
+func (m *StorageManager) DirUsage(dir string) (int64, error) {
+	if v, ok := m.usage.Get(dir); ok {
+		return v.(int64), nil
+	}
+	usage, err := m.sumSize(dir)
+	if err != nil {
+		return 0, err
+	}
+	m.usage.SetDefault(dir, usage)
+	return usage, nil
+}
+
+// sumSize 目录下全部文件的大小，不建立索引的挂载点遍历磁盘统计，否则索引中没有文件，配额不会生效
+func (m *StorageManager) sumSize(dir string) (int64, error) {
+	if !utils.IsIndexed(dir) {
+		usage, err := repository.GetDiskDirUsage(dir, 0)
+		return usage.Size, errors.WithStack(err)
+	}
+	size, err := m.fsRepo.SumSize(repository.WithUnderPath(dir))
+	return size, errors.WithStack(err)
+}
+
+// ResetUsage 写入完成后清除包含 paths 或位于 paths 下的目录的统计缓存，下次检查时重新统计
+func (m *StorageManager) ResetUsage(paths ...string) {
+	for dir := range m.usage.Items() {
+		for _, path := range paths {
+			if pathtool.IsSubPath(dir, path) || pathtool.IsSubPath(path, dir) {
+				m.usage.Delete(dir)
+				break
+			}
+		}
+	}
+}
+
+func (m *StorageManager) getRoleStorageQuota(roleKey string) (int64, error) {
+	key := fmt.Sprintf("%s-%s", role.StorageQuotaKey, roleKey)
+	data, err := m.cache.Get(key)
+	if err == nil {
+		return strconv.ParseInt(data, 10, 64)
+	}
+	if !cache.IsKeyNotFoundError(err) {
+		return 0, err
+	}
+	var quota int64
+	sysRole, err := m.roleRepo.FindOne(repository.WithRoleKey(roleKey))
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return 0, errors.WithStack(err)
+	}
+	if err == nil {
+		quota = sysRole.StorageQuota
+	}
+	return quota, m.cache.Set(key, quota, 0)
+}
+
+// GetDirQuotas 获取全部目录存储配额
+func (m *StorageManager) GetDirQuotas() ([]models.SysStorageQuota, error) {
+	var quotas []models.SysStorageQuota
+	data, err := m.cache.Get(StorageDirQuotaKey)
+	if err == nil {
+		return quotas, json.Unmarshal([]byte(data), &quotas)
+	}
+	if !cache.IsKeyNotFoundError(err) {
+		return nil, err
+	}
+	quotas, err = m.storageQuotaRepo.Find()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	b, err := json.Marshal(quotas)
+	if err != nil {
+		return nil, err
+	}
+	return quotas, m.cache.Set(StorageDirQuotaKey, string(b), 0)
+}
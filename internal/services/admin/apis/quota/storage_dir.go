@@ -0,0 +1,132 @@
+package quota
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	coreModels "go-file-server/internal/common/models"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/pathtool"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type CreateDirQuotaReq struct {
+	Path   string `json:"path" binding:"required"`
+	Quota  int64  `json:"quota" binding:"required,min=1"`
+	Remark string `json:"remark"`
+}
+
+// CreateDirQuota 为目录设置存储配额
+func (api *QuotaApi) CreateDirQuota(c *gin.Context) {
+	var req CreateDirQuotaReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	path, err := checkQuotaDir(req.Path)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	_, err = api.storageQuotaRepo.FindOne(repository.WithStorageQuotaPath(path))
+	if err == nil {
+		core.ErrBizRep().SetMsg("该目录已经设置了存储配额").SendGin(c)
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	err = api.storageQuotaRepo.Create(&models.SysStorageQuota{
+		Path:      path,
+		Quota:     req.Quota,
+		Remark:    req.Remark,
+		ControlBy: coreModels.ControlBy{CreateBy: core.GetUserId(c)},
+	})
+	if err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	if err := api.cache.Del(StorageDirQuotaKey); err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(nil).SendGin(c)
+}
+
+type UpdateDirQuotaReq struct {
+	Id     int    `json:"id" binding:"required"`
+	Quota  int64  `json:"quota" binding:"required,min=1"`
+	Remark string `json:"remark"`
+}
+
+// UpdateDirQuota 修改目录的存储配额
+func (api *QuotaApi) UpdateDirQuota(c *gin.Context) {
+	var req UpdateDirQuotaReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	err = api.storageQuotaRepo.Update(&models.SysStorageQuota{
+		Quota:     req.Quota,
+		Remark:    req.Remark,
+		ControlBy: coreModels.ControlBy{UpdateBy: core.GetUserId(c)},
+	}, repository.WithStorageQuotaId(req.Id))
+	if err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	if err := api.cache.Del(StorageDirQuotaKey); err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(nil).SendGin(c)
+}
+
+type DeleteDirQuotaReq struct {
+	Ids []int `json:"ids" binding:"required,min=1"`
+}
+
+// DeleteDirQuota 删除目录的存储配额
+func (api *QuotaApi) DeleteDirQuota(c *gin.Context) {
+	var req DeleteDirQuotaReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	err = api.storageQuotaRepo.Delete(repository.WithStorageQuotaIds(req.Ids...))
+	if err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	if err := api.cache.Del(StorageDirQuotaKey); err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(nil).SendGin(c)
+}
+
+// checkQuotaDir 规范化虚拟路径并检查目录是否存在
+func checkQuotaDir(path string) (string, error) {
+	path = filepath.Clean("/" + path)
+	realPath, err := utils.GetRealPath(path)
+	if err != nil {
+		return "", core.NewApiBizErr(err).SetMsg(err.Error())
+	}
+	isDir, err := pathtool.NewFiletool(realPath).AssertDir()
+	if err != nil || !isDir {
+		err = errors.Errorf("目录 %s 不存在", path)
+		return "", core.NewApiBizErr(err).
+			SetBizCode(global.BizNotFound).
+			SetMsg(err.Error())
+	}
+	return path, nil
+}
@@ -0,0 +1,65 @@
+package quota
+
+import (
+	"go-file-server/pkgs/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorageLimitContains(t *testing.T) {
+	limit := StorageLimit{Quota: 100, Usage: 120, dirs: []string{"/data/a", "/mnt/nas/"}}
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{"dir itself", "/data/a", true},
+		{"sub path", "/data/a/b/c.txt", true},
+		{"sibling prefix", "/data/ab", false},
+		{"trailing slash dir", "/mnt/nas/x", true},
+		{"outside", "/data/b", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := limit.Contains(tt.path); got != tt.want {
+				t.Errorf("Contains(%s) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+	if limit.Remaining() != 0 {
+		t.Errorf("Remaining() = %d, want 0", limit.Remaining())
+	}
+}
+
+// TestDirUsageNotIndexed 不建立索引的挂载点没有使用索引，占用从磁盘统计
+func TestDirUsageNotIndexed(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, size := range map[string]int{"a.bin": 100, "sub/b.bin": 20} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mounts := config.ApplicationCfg.Mounts
+	config.ApplicationCfg.Mounts = []config.Mount{{Name: "nfs", Path: dir, Index: false}}
+	defer func() { config.ApplicationCfg.Mounts = mounts }()
+
+	m := NewStorageManager(nil, nil, nil, nil, nil)
+	usage, err := m.DirUsage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != 120 {
+		t.Errorf("DirUsage() = %d, want 120", usage)
+	}
+	size, err := m.PathSize(filepath.Join(dir, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 20 {
+		t.Errorf("PathSize() = %d, want 20", size)
+	}
+}
@@ -0,0 +1,63 @@
+package quota
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type DirStorageUsage struct {
+	models.SysStorageQuota
+	Usage int64 `json:"usage"`
+}
+
+type GetStorageUsageRep struct {
+	Roles []StorageLimit    `json:"roles"`
+	Dirs  []DirStorageUsage `json:"dirs"`
+}
+
+// GetStorageUsage 查询全部角色和目录的存储配额以及当前占用
+func (api *QuotaApi) GetStorageUsage(c *gin.Context) {
+	roles, err := api.roleRepo.Find()
+	if err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	data := GetStorageUsageRep{
+		Roles: []StorageLimit{},
+		Dirs:  []DirStorageUsage{},
+	}
+	for _, r := range roles {
+		if r.RoleKey == models.AdminRoleKey {
+			continue
+		}
+		usage, err := api.storageManager.RoleUsage(r.RoleKey)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		data.Roles = append(data.Roles, usage)
+	}
+
+	dirQuotas, err := api.storageManager.GetDirQuotas()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for _, q := range dirQuotas {
+		item := DirStorageUsage{SysStorageQuota: q}
+		// 目录不存在(如挂载点被移除)时占用为0
+		if dir, err := utils.GetRealPath(q.Path); err == nil {
+			item.Usage, err = api.storageManager.DirUsage(dir)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+		}
+		data.Dirs = append(data.Dirs, item)
+	}
+	core.OKRep(data).SendGin(c)
+}
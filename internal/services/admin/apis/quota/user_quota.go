@@ -0,0 +1,93 @@
+package quota
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type GetUserQuotaReq struct {
+	UserId int `uri:"userId" binding:"required"`
+}
+
+// GetUserQuota 查询用户级别的配额，不存在时返回null，表示使用角色配额
+func (api *QuotaApi) GetUserQuota(c *gin.Context) {
+	var req GetUserQuotaReq
+	err := c.ShouldBindUri(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	data, err := api.userQuotaRepo.FindOne(repository.WithUserId(req.UserId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			core.OKRep(nil).SendGin(c)
+			return
+		}
+		c.Error(errors.WithStack(err))
+		return
+	}
+	core.OKRep(data).SendGin(c)
+}
+
+type UpdateUserQuotaReq struct {
+	UserId int `json:"userId" binding:"required"`
+	models.TransferQuota
+}
+
+// UpdateUserQuota 设置用户级别的配额，覆盖角色配额
+func (api *QuotaApi) UpdateUserQuota(c *gin.Context) {
+	var req UpdateUserQuotaReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if _, err := api.getRoleKey(req.UserId); err != nil {
+		c.Error(err)
+		return
+	}
+	err = api.userQuotaRepo.Save(&models.SysUserQuota{
+		UserId:        req.UserId,
+		TransferQuota: req.TransferQuota,
+	})
+	if err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	if err := api.cache.Del(GenUserQuotaKey(req.UserId)); err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(nil).SendGin(c)
+}
+
+type DeleteUserQuotaReq struct {
+	Ids []int `json:"ids" binding:"required,min=1"`
+}
+
+// DeleteUserQuota 删除用户级别的配额，恢复使用角色配额
+func (api *QuotaApi) DeleteUserQuota(c *gin.Context) {
+	var req DeleteUserQuotaReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	err = api.userQuotaRepo.Delete(repository.WithUserIds(req.Ids...))
+	if err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	for _, id := range req.Ids {
+		if err := api.cache.Del(GenUserQuotaKey(id)); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	core.OKRep(nil).SendGin(c)
+}
@@ -81,6 +81,10 @@ func (api *RoleApi) deleteRole(id int) (result DeleteResult) {
 		return
 	}
 	err = api.deletePolicies(data.RoleKey)
+	if err != nil {
+		return
+	}
+	err = api.updateFsAliases(data.RoleKey, nil)
 	return
 }
 
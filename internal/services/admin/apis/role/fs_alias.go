@@ -0,0 +1,198 @@
+package role
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/apis/fs/utils"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/cache"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/pkg/errors"
+)
+
+const FsAliasKey = "FsAliasKey"
+
+// FsRoot 角色被授权的根目录，Name 为展示给用户的虚拟目录名称
+type FsRoot struct {
+	Name string
+	Path string
+}
+
+func genFsAliasKey(roleKey string) string {
+	return fmt.Sprintf("%s-%s", FsAliasKey, roleKey)
+}
+
+// GetFsRoots 获取角色有查看权限的根目录及其虚拟目录名称，
+// ftp、sftp、webdav以及web页面的根目录都通过该方法展示
+func GetFsRoots(roleKey string, enforcer *casbin.CachedEnforcer,
+	ch cache.AdapterCache, aliasRepo *repository.RoleFsAliasRepository) ([]FsRoot, error) {
+
+	aliases, err := getFsAliases(roleKey, ch, aliasRepo)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range enforcer.GetFilteredPolicy(0, roleKey, "", "GET", "fs") {
+		paths = append(paths, ParseFsRolepath(p[1]))
+	}
+	return ResolveFsRoots(paths, aliases), nil
+}
+
+// ResolveFsRoots 为授权目录分配虚拟目录名称，没有配置别名时使用最后一级目录名。
+// 名称重复时追加由路径计算的后缀，不受其他授权目录增减的影响
+func ResolveFsRoots(paths []string, aliases map[string]string) []FsRoot {
+	paths = uniqueSorted(paths)
+	roots := make([]FsRoot, 0, len(paths))
+	names := make(map[string]string, len(paths))
+	counts := make(map[string]int, len(paths))
+	configured := make(map[string]int, len(paths))
+	for _, path := range paths {
+		name, ok := aliases[path]
+		if ok && name != "" {
+			configured[name]++
+		} else {
+			name = defaultAlias(path)
+		}
+		names[path] = name
+		counts[name]++
+	}
+	for _, path := range paths {
+		name := names[path]
+		// 配置的别名只有一个时保留，与之重名的默认名称追加后缀
+		isAlias := aliases[path] != ""
+		if counts[name] > 1 && !(isAlias && configured[name] == 1) {
+			name = fmt.Sprintf("%s_%s", name, pathSuffix(path))
+		}
+		roots = append(roots, FsRoot{Name: name, Path: path})
+	}
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].Name < roots[j].Name
+	})
+	return roots
+}
+
+// pathSuffix 由路径计算的名称后缀
+func pathSuffix(path string) string {
+	sum := sha1.Sum([]byte(path))
+	return hex.EncodeToString(sum[:3])
+}
+
+// ResolveFsAliasPath 将以虚拟目录名称开头的路径转换为真实的虚拟路径
+func ResolveFsAliasPath(roots []FsRoot, path string) (string, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(filepath.Clean("/"+path), "/"), "/", 2)
+	for _, root := range roots {
+		if root.Name != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			return filepath.Join("/", root.Path), true
+		}
+		return filepath.Join("/", root.Path, parts[1]), true
+	}
+	return path, false
+}
+
+// normalizeFsPath 与 ParseFsRolepath 的结果保持一致
+func normalizeFsPath(path string) string {
+	return ParseFsRolepath(buildRolePath(path))
+}
+
+func defaultAlias(path string) string {
+	path = strings.Trim(path, "/")
+	// 回收站目录为 .tmp/<roleKey>
+	if strings.HasPrefix(path, ".tmp/") {
+		return ".tmp"
+	}
+	return filepath.Base(path)
+}
+
+func uniqueSorted(paths []string) []string {
+	mp := make(map[string]struct{}, len(paths))
+	var data []string
+	for _, p := range paths {
+		if _, ok := mp[p]; ok {
+			continue
+		}
+		mp[p] = struct{}{}
+		data = append(data, p)
+	}
+	sort.Strings(data)
+	return data
+}
+
+func getFsAliases(roleKey string, ch cache.AdapterCache,
+	aliasRepo *repository.RoleFsAliasRepository) (map[string]string, error) {
+
+	key := genFsAliasKey(roleKey)
+	aliases := make(map[string]string)
+	data, err := ch.Get(key)
+	if err == nil {
+		return aliases, json.Unmarshal([]byte(data), &aliases)
+	}
+	if !cache.IsKeyNotFoundError(err) {
+		return nil, err
+	}
+	records, err := aliasRepo.Find(repository.WithFsAliasRoleKey(roleKey))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, r := range records {
+		aliases[r.Path] = r.Alias
+	}
+	b, err := json.Marshal(aliases)
+	if err != nil {
+		return nil, err
+	}
+	return aliases, ch.Set(key, string(b), 0)
+}
+
+// validateFsAliases 校验虚拟目录名称，同一角色下配置的别名和默认名称都不能重复，
+// 避免保存后自动追加后缀
+func validateFsAliases(fsPermissions []FsPermissions) error {
+	names := make(map[string]string)
+	for _, p := range fsPermissions {
+		path := normalizeFsPath(p.Path)
+		name := p.Alias
+		if name == "" {
+			name = defaultAlias(path)
+		} else if err := utils.CheckFsName(name); err != nil {
+			return errors.Errorf("虚拟目录名称 %s 不合法", name)
+		}
+		if old, ok := names[name]; ok && old != path {
+			return errors.Errorf("目录 %s 和 %s 的虚拟目录名称都为 %s，请设置不同的别名", old, path, name)
+		}
+		names[name] = path
+	}
+	return nil
+}
+
+func (api *RoleApi) updateFsAliases(roleKey string, fsPermissions []FsPermissions) error {
+	var records []models.SysRoleFsAlias
+	for _, p := range fsPermissions {
+		if p.Alias == "" {
+			continue
+		}
+		records = append(records, models.SysRoleFsAlias{
+			RoleKey: roleKey,
+			Path:    normalizeFsPath(p.Path),
+			Alias:   p.Alias,
+		})
+	}
+	err := api.fsAliasRepo.Delete(repository.WithFsAliasRoleKey(roleKey))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(records) > 0 {
+		if err := api.fsAliasRepo.Create(records); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return api.cache.Del(genFsAliasKey(roleKey))
+}
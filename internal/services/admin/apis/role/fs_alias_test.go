@@ -0,0 +1,104 @@
+package role
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveFsRoots(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   []string
+		aliases map[string]string
+		want    []FsRoot
+	}{
+		{
+			name:  "default last part",
+			paths: []string{"teamA/projects", ".tmp/dev"},
+			want: []FsRoot{
+				{Name: ".tmp", Path: ".tmp/dev"},
+				{Name: "projects", Path: "teamA/projects"},
+			},
+		},
+		{
+			name:  "same last part",
+			paths: []string{"teamB/projects", "teamA/projects"},
+			want: []FsRoot{
+				{Name: "projects_" + pathSuffix("teamB/projects"), Path: "teamB/projects"},
+				{Name: "projects_" + pathSuffix("teamA/projects"), Path: "teamA/projects"},
+			},
+		},
+		{
+			name:    "alias first",
+			paths:   []string{"teamA/projects", "teamB/docs"},
+			aliases: map[string]string{"teamB/docs": "projects"},
+			want: []FsRoot{
+				{Name: "projects", Path: "teamB/docs"},
+				{Name: "projects_" + pathSuffix("teamA/projects"), Path: "teamA/projects"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveFsRoots(tt.paths, tt.aliases); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolveFsRoots() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveFsAliasPath(t *testing.T) {
+	roots := []FsRoot{{Name: "projects", Path: "teamA/projects"}}
+	tests := []struct {
+		name   string
+		path   string
+		want   string
+		wantOk bool
+	}{
+		{"root", "/projects", "/teamA/projects", true},
+		{"sub path", "/projects/a/b.txt", "/teamA/projects/a/b.txt", true},
+		{"not alias", "/other/a", "/other/a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ResolveFsAliasPath(roots, tt.path)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("ResolveFsAliasPath() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestResolveFsRootsStable(t *testing.T) {
+	before := ResolveFsRoots([]string{"teamA/projects", "teamB/projects", "teamC/projects"}, nil)
+	after := ResolveFsRoots([]string{"teamB/projects", "teamC/projects"}, nil)
+	names := make(map[string]string)
+	for _, r := range before {
+		names[r.Path] = r.Name
+	}
+	for _, r := range after {
+		if names[r.Path] != r.Name {
+			t.Errorf("name of %s changed from %s to %s", r.Path, names[r.Path], r.Name)
+		}
+	}
+}
+
+func TestValidateFsAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		perms   []FsPermissions
+		wantErr bool
+	}{
+		{"distinct", []FsPermissions{{Path: "/a/docs"}, {Path: "/b/src"}}, false},
+		{"default collision", []FsPermissions{{Path: "/a/docs"}, {Path: "/b/docs"}}, true},
+		{"resolved by alias", []FsPermissions{{Path: "/a/docs"}, {Path: "/b/docs", Alias: "docs-b"}}, false},
+		{"alias collides with default", []FsPermissions{{Path: "/a/docs"}, {Path: "/b/src", Alias: "docs"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateFsAliases(tt.perms); (err != nil) != tt.wantErr {
+				t.Errorf("validateFsAliases() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"go-file-server/internal/common/global"
 	"go-file-server/internal/common/repository"
 	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/zlog"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -73,10 +74,15 @@ func (api *RoleApi) getFsRoles(roleKey string) []models.FsPermissions {
 		}
 	}
 
+	aliases, err := getFsAliases(roleKey, api.cache, api.fsAliasRepo)
+	if err != nil {
+		zlog.SugLog.Error(err)
+	}
+
 	// 构建结果列表
 	results := []models.FsPermissions{}
 	for path, actions := range pathActions {
-		results = append(results, models.FsPermissions{Path: path, Permissions: actions})
+		results = append(results, models.FsPermissions{Path: path, Permissions: actions, Alias: aliases[path]})
 	}
 
 	return results
@@ -3,6 +3,7 @@ package role
 import (
 	"go-file-server/internal/common/repository"
 	"go-file-server/pkgs/cache"
+	"go-file-server/pkgs/session"
 
 	"github.com/casbin/casbin/v2"
 )
@@ -14,6 +15,8 @@ type RoleApi struct {
 	deptRepo       *repository.DeptRepository
 	casbinEnforcer *casbin.CachedEnforcer
 	cache          cache.AdapterCache
+	fsAliasRepo    *repository.RoleFsAliasRepository
+	sessions       *session.Manager
 }
 
 func NewRoleApi(
@@ -23,6 +26,8 @@ func NewRoleApi(
 	deptRepo *repository.DeptRepository,
 	casbinEnforcer *casbin.CachedEnforcer,
 	cache cache.AdapterCache,
+	fsAliasRepo *repository.RoleFsAliasRepository,
+	sessions *session.Manager,
 ) *RoleApi {
 	return &RoleApi{
 		userRepo:       userRepo,
@@ -31,5 +36,7 @@ func NewRoleApi(
 		deptRepo:       deptRepo,
 		casbinEnforcer: casbinEnforcer,
 		cache:          cache,
+		fsAliasRepo:    fsAliasRepo,
+		sessions:       sessions,
 	}
 }
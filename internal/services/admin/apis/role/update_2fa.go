@@ -0,0 +1,58 @@
+package role
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/repository"
+	"go-file-server/pkgs/base"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type UpdateTwoFactorReq struct {
+	RoleId     int   `json:"roleId" binding:"required" comment:"角色编码"` // 角色编码
+	Require2fa *bool `json:"require2fa" binding:"required" comment:"要求两步验证"`
+}
+
+// UpdateTwoFactor 设置角色是否要求两步验证，未启用的用户在下次登录时需要先登记
+func (api *RoleApi) UpdateTwoFactor(c *gin.Context) {
+	var updateReq UpdateTwoFactorReq
+	err := c.ShouldBind(&updateReq)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	err = api.updateTwoFactor(updateReq)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(nil).SendGin(c)
+}
+
+func (api *RoleApi) updateTwoFactor(updateReq UpdateTwoFactorReq) error {
+	role, err := api.roleRepo.FindOne(
+		repository.WithRoleId(updateReq.RoleId),
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	enabling := !role.Require2fa && *updateReq.Require2fa
+	role.Require2fa = *updateReq.Require2fa
+	err = api.roleRepo.Save(role, base.WithFullAssociations())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !enabling {
+		return nil
+	}
+	// 要求两步验证后ftp不能再使用密码登录，清理角色下用户缓存的ftp登录并断开会话
+	users, _, err := api.userRepo.Find(repository.WithRoleId(role.RoleId))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, user := range users {
+		api.sessions.InvalidateUser(user.UserId)
+	}
+	return nil
+}
@@ -21,16 +21,34 @@ type UpdateFsRep struct {
 type FsPermissions struct {
 	Path        string   `json:"path" binding:"required"`
 	Permissions []string `json:"permissions" binding:"required,min=1,dive,oneof=POST DELETE PUT GET"`
+	// Alias ftp等客户端根目录下展示的虚拟目录名称，为空时使用最后一级目录名
+	Alias string `json:"alias"`
 }
 
 type UpdateFsReq struct {
 	RoleId        int             `json:"roleId" binding:"required"`
 	RateLimit     uint64          `json:"rateLimit"`
+	MaxSessions   int             `json:"maxSessions" binding:"min=0"`
+	MaxTransfers  int             `json:"maxTransfers" binding:"min=0"`
+	MaxIpConns    int             `json:"maxIpConns" binding:"min=0"`
 	FsPermissions []FsPermissions `json:"fsRoles"`
+	// 流量配额，单位 byte，0 表示不限制
+	models.TransferQuota
+	// StorageQuota 存储配额，单位 byte，0 表示不限制
+	StorageQuota int64 `json:"storageQuota" binding:"min=0"`
 }
 
 const RateLimitKey = "RateLimitKey"
 
+// ConnLimitsKey 角色并发限制的缓存key
+const ConnLimitsKey = "ConnLimitsKey"
+
+// TransferQuotaKey 角色流量配额的缓存key
+const TransferQuotaKey = "TransferQuotaKey"
+
+// StorageQuotaKey 角色存储配额的缓存key
+const StorageQuotaKey = "StorageQuotaKey"
+
 func (api *RoleApi) UpdateFs(c *gin.Context) {
 	var updateReq UpdateFsReq
 	err := c.ShouldBind(&updateReq)
@@ -47,6 +65,9 @@ func (api *RoleApi) UpdateFs(c *gin.Context) {
 }
 
 func (api *RoleApi) updateFs(updateReq UpdateFsReq) error {
+	if err := validateFsAliases(updateReq.FsPermissions); err != nil {
+		return core.NewApiBizErr(err).SetMsg(err.Error())
+	}
 	var g errgroup.Group
 	g.Go(func() error {
 		return api.updateFsRateLimit(updateReq)
@@ -61,12 +82,32 @@ func (api *RoleApi) updateFsRateLimit(updateReq UpdateFsReq) error {
 
 	err := api.roleRepo.Update(func(sr *models.SysRole) {
 		sr.RateLimit = updateReq.RateLimit
+		sr.MaxSessions = updateReq.MaxSessions
+		sr.MaxTransfers = updateReq.MaxTransfers
+		sr.MaxIpConns = updateReq.MaxIpConns
+		sr.TransferQuota = updateReq.TransferQuota
+		sr.StorageQuota = updateReq.StorageQuota
 	}, repository.WithRoleId(updateReq.RoleId),
-		base.WithSelect("rate_limit"),
+		base.WithSelect("rate_limit", "max_sessions", "max_transfers", "max_ip_conns",
+			"daily_download", "daily_upload", "monthly_download", "monthly_upload", "storage_quota"),
 	)
 	if err != nil {
 		return err
 	}
+	role, err := api.roleRepo.FindOne(repository.WithRoleId(updateReq.RoleId))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = api.cache.Del(fmt.Sprintf("%s-%s", ConnLimitsKey, role.RoleKey))
+	if err != nil {
+		return err
+	}
+	for _, key := range []string{TransferQuotaKey, StorageQuotaKey} {
+		err = api.cache.Del(fmt.Sprintf("%s-%s", key, role.RoleKey))
+		if err != nil {
+			return err
+		}
+	}
 	return api.cache.Set(
 		fmt.Sprintf("%d-%s", updateReq.RoleId, RateLimitKey),
 		updateReq.RateLimit, 0)
@@ -91,6 +132,9 @@ func (api *RoleApi) updateFsPermissions(updateReq UpdateFsReq) error {
 	if len(updateReq.FsPermissions) != 0 {
 		addDefaultRole(role.RoleKey, &updateReq)
 	}
+	if err := api.updateFsAliases(role.RoleKey, updateReq.FsPermissions); err != nil {
+		return err
+	}
 
 	var policiesToRemove [][]string
 	var policiesToAdd [][]string
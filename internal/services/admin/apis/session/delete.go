@@ -0,0 +1,60 @@
+package session
+
+import (
+	"go-file-server/internal/common/core"
+	sess "go-file-server/pkgs/session"
+	"go-file-server/pkgs/zlog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type DeleteReq struct {
+	// Ids 需要断开的会话
+	Ids []string `json:"ids"`
+	// UserIds 需要断开全部会话的用户
+	UserIds []int `json:"userIds"`
+}
+
+type DeleteRep struct {
+	Count int `json:"count"`
+}
+
+// Delete 断开会话
+func (api *SessionApi) Delete(c *gin.Context) {
+	var req DeleteReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if len(req.Ids) == 0 && len(req.UserIds) == 0 {
+		core.ErrBizRep().SetMsg("ids 和 userIds 不能同时为空").SendGin(c)
+		return
+	}
+	count, err := api.kick(req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(DeleteRep{Count: count}).SendGin(c)
+}
+
+func (api *SessionApi) kick(req DeleteReq) (int, error) {
+	var count int
+	for _, id := range req.Ids {
+		err := api.sessions.Kick(id)
+		if errors.Is(err, sess.ErrSessionNotFound) {
+			return count, core.NewApiBizErr(err).SetMsg("会话不存在: " + id)
+		}
+		if err != nil {
+			// 连接可能已经断开，会话已经移除，只记录日志
+			zlog.SugLog.Warnf("close session %s err: %v", id, err)
+		}
+		count++
+	}
+	for _, userId := range req.UserIds {
+		count += api.sessions.KickUser(userId)
+	}
+	return count, nil
+}
@@ -0,0 +1,35 @@
+package session
+
+import (
+	"go-file-server/internal/common/core"
+	sess "go-file-server/pkgs/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GetReq struct {
+	Username string `form:"username"`
+}
+
+type GetRep struct {
+	Count int         `json:"count"`
+	Items []sess.Info `json:"items"`
+	// Stats 每个用户和每个ip当前的并发计数
+	Stats sess.Stats `json:"stats"`
+}
+
+// Get 在线会话列表
+func (api *SessionApi) Get(c *gin.Context) {
+	var req GetReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	items := api.sessions.List(req.Username)
+	core.OKRep(GetRep{
+		Count: len(items),
+		Items: items,
+		Stats: api.sessions.Stats(),
+	}).SendGin(c)
+}
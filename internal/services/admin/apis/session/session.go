@@ -0,0 +1,15 @@
+package session
+
+import (
+	sess "go-file-server/pkgs/session"
+)
+
+type SessionApi struct {
+	sessions *sess.Manager
+}
+
+func NewSessionApi(sessions *sess.Manager) *SessionApi {
+	return &SessionApi{
+		sessions: sessions,
+	}
+}
@@ -0,0 +1,39 @@
+package user
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/middlewares"
+	"go-file-server/pkgs/auditlog"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// emitToken 发送token相关的审计事件，userId 为token所属的用户
+func emitToken(c *gin.Context, action string, userId int, details map[string]any, err error) {
+	claims := core.ExtractClaims(c)
+	auditlog.Emit(auditlog.Event{
+		Category: auditlog.CategoryToken,
+		Action:   action,
+		Actor:    claims.Username,
+		ActorId:  claims.UserId,
+		Protocol: middlewares.ProtocolHttp,
+		ClientIp: core.GetClientIP(c),
+		Target:   "user:" + strconv.Itoa(userId),
+		Details:  details,
+	}.WithErr(err))
+}
+
+// emitTwoFactor 发送两步验证相关的审计事件，userId 为被操作的用户
+func emitTwoFactor(c *gin.Context, action string, userId int, err error) {
+	claims := core.ExtractClaims(c)
+	auditlog.Emit(auditlog.Event{
+		Category: auditlog.CategoryAuth,
+		Action:   action,
+		Actor:    claims.Username,
+		ActorId:  claims.UserId,
+		Protocol: middlewares.ProtocolHttp,
+		ClientIp: core.GetClientIP(c),
+		Target:   "user:" + strconv.Itoa(userId),
+	}.WithErr(err))
+}
@@ -5,6 +5,7 @@ import (
 	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/common/repository"
 	"go-file-server/internal/common/types"
+	"go-file-server/pkgs/auditlog"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -26,6 +27,7 @@ func (api *UserAPI) DeleteToken(c *gin.Context) {
 	claims := core.ExtractClaims(c)
 
 	err = api.deleteToken(claims, deleteReq)
+	emitToken(c, auditlog.ActionTokenRevoke, claims.UserId, map[string]any{"tokenIds": deleteReq.Ids}, err)
 	if err != nil {
 		c.Error(err)
 		return
@@ -49,6 +51,11 @@ func (api *UserAPI) deleteToken(claims *types.JwtClaims, deleteReq DeleteTokenRe
 	}
 	err = api.userTokenRepo.Delete(repository.WithUserTokenUserId(claims.UserId),
 		repository.WithUserTokenIds(deleteReq.Ids...))
-	return errors.WithStack(err)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// ftp 缓存的会话和已建立的连接不会再校验token，需要清理后重新登录
+	api.sessions.InvalidateUser(claims.UserId)
+	return nil
 
 }
@@ -27,6 +27,9 @@ func (api *UserAPI) Delete(c *gin.Context) {
 		c.Error(err)
 		return
 	}
+	for _, id := range deleteReq.Ids {
+		api.sessions.InvalidateUser(id)
+	}
 
 	core.OKRep(DeleteRep{
 		DeleteReq: deleteReq,
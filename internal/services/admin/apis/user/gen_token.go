@@ -6,6 +6,7 @@ import (
 	coreModels "go-file-server/internal/common/models"
 	"go-file-server/internal/common/types"
 	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/auditlog"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -23,26 +24,27 @@ type GenTokenRep struct {
 func (api *UserAPI) GenToken(c *gin.Context) {
 	var rep GenTokenRep
 	var err error
+	var query GenTokenReq
+	var tokenId int
+	claims := core.ExtractClaims(c)
 	defer func() {
 		if err != nil {
 			c.Error(err)
 		}
+		emitToken(c, auditlog.ActionTokenCreate, claims.UserId, map[string]any{"tokenId": tokenId}, err)
 	}()
-	var query GenTokenReq
 
 	err = c.ShouldBind(&query)
 	if err != nil {
 		return
 	}
 
-	claims := core.ExtractClaims(c)
-
 	if query.UserID != claims.UserId {
 		err = core.NewApiBizErr(errors.Errorf("生成失败: 只能生成自己的token"))
 		return
 	}
 
-	err = api.genToken(claims, &rep)
+	tokenId, err = api.genToken(claims, &rep)
 	if err != nil {
 		return
 	}
@@ -50,7 +52,8 @@ func (api *UserAPI) GenToken(c *gin.Context) {
 	core.OKRep(rep).SendGin(c)
 }
 
-func (api *UserAPI) genToken(claims *types.JwtClaims, rep *GenTokenRep) error {
+// genToken 返回生成的token的id
+func (api *UserAPI) genToken(claims *types.JwtClaims, rep *GenTokenRep) (int, error) {
 	ntime := time.Now()
 	token, _, err := middlewares.CreateToken(
 		func(jc *types.JwtClaims) {
@@ -65,7 +68,7 @@ func (api *UserAPI) genToken(claims *types.JwtClaims, rep *GenTokenRep) error {
 		},
 	)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	data := &models.UserToken{
@@ -77,10 +80,10 @@ func (api *UserAPI) genToken(claims *types.JwtClaims, rep *GenTokenRep) error {
 	err = api.userTokenRepo.Create(data)
 
 	if err != nil {
-		return err
+		return 0, err
 
 	}
 
 	rep.Token = token
-	return nil
+	return data.ID, nil
 }
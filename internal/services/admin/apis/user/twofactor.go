@@ -0,0 +1,159 @@
+package user
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/repository"
+	"go-file-server/pkgs/auditlog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type TwoFactorCodeReq struct {
+	// Code 身份验证器中的6位验证码，关闭和重新生成恢复码时也可以使用恢复码
+	Code string `json:"code" binding:"required"`
+}
+
+type EnrollTwoFactorRep struct {
+	Secret string `json:"secret"`
+	// Uri 生成二维码使用的 otpauth 地址
+	Uri string `json:"uri"`
+}
+
+type RecoveryCodesRep struct {
+	// RecoveryCodes 恢复码只在生成时返回一次
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type ResetTwoFactorReq struct {
+	UserId int `json:"userId" binding:"required"`
+}
+
+// GetTwoFactor 当前用户的两步验证状态
+func (api *UserAPI) GetTwoFactor(c *gin.Context) {
+	claims := core.ExtractClaims(c)
+	required, err := api.twoFactor.Required(claims.RoleId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	status, err := api.twoFactor.Status(claims.UserId, required)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(status).SendGin(c)
+}
+
+// EnrollTwoFactor 生成新的密钥，调用 EnableTwoFactor 验证后生效
+func (api *UserAPI) EnrollTwoFactor(c *gin.Context) {
+	claims := core.ExtractClaims(c)
+	secret, uri, err := api.twoFactor.Enroll(claims.UserId, claims.Username)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(EnrollTwoFactorRep{Secret: secret, Uri: uri}).SendGin(c)
+}
+
+// EnableTwoFactor 校验验证码后启用，返回恢复码
+func (api *UserAPI) EnableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	userId := core.GetUserId(c)
+	codes, err := api.twoFactor.Enable(userId, req.Code)
+	emitTwoFactor(c, auditlog.ActionEnable2fa, userId, err)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	// 启用后ftp不能再使用密码登录，清理以密码缓存的ftp登录并断开会话
+	api.sessions.InvalidateUser(userId)
+	core.OKRep(RecoveryCodesRep{RecoveryCodes: codes}).SendGin(c)
+}
+
+// RegenerateRecoveryCodes 校验验证码后生成新的恢复码
+func (api *UserAPI) RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	userId := core.GetUserId(c)
+	var codes []string
+	err = api.twoFactor.Verify(userId, req.Code)
+	if err == nil {
+		codes, err = api.twoFactor.RegenerateRecoveryCodes(userId)
+	}
+	emitTwoFactor(c, auditlog.ActionRecovery2fa, userId, err)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(RecoveryCodesRep{RecoveryCodes: codes}).SendGin(c)
+}
+
+// DisableTwoFactor 校验验证码后关闭，角色要求两步验证时不能关闭
+func (api *UserAPI) DisableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	claims := core.ExtractClaims(c)
+	err = api.disableTwoFactor(claims.UserId, claims.RoleId, req.Code)
+	emitTwoFactor(c, auditlog.ActionDisable2fa, claims.UserId, err)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(nil).SendGin(c)
+}
+
+func (api *UserAPI) disableTwoFactor(userId, roleId int, code string) error {
+	required, err := api.twoFactor.Required(roleId)
+	if err != nil {
+		return err
+	}
+	if required {
+		err = errors.New("角色要求启用两步验证，不能关闭")
+		return core.NewApiBizErr(err).SetBizCode(global.BizBadRequest).SetMsg(err.Error())
+	}
+	if err = api.twoFactor.Verify(userId, code); err != nil {
+		return err
+	}
+	return api.twoFactor.Disable(userId)
+}
+
+// ResetTwoFactor 管理员重置用户的两步验证，用于用户丢失设备和恢复码的情况。
+// 角色要求两步验证时，用户下次登录需要重新登记
+func (api *UserAPI) ResetTwoFactor(c *gin.Context) {
+	var req ResetTwoFactorReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	err = api.resetTwoFactor(req.UserId)
+	emitTwoFactor(c, auditlog.ActionReset2fa, req.UserId, err)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(nil).SendGin(c)
+}
+
+func (api *UserAPI) resetTwoFactor(userId int) error {
+	_, err := api.userRepo.FindOne(repository.WithUserId(userId))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return api.twoFactor.Disable(userId)
+}
@@ -5,6 +5,7 @@ import (
 	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/common/repository"
 	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/auditlog"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -43,6 +44,7 @@ func (api *UserAPI) updateUser(c *gin.Context, updateDeptReq UpdateDeptReq) erro
 
 	if userInfo.RoleId != updateDeptReq.RoleId {
 		err = middlewares.UpdateLastTokenReset(api.cache, userInfo.UserId)
+		emitToken(c, auditlog.ActionTokenReset, userInfo.UserId, map[string]any{"reason": "role"}, err)
 		if err != nil {
 			return err
 		}
@@ -62,7 +64,16 @@ func (api *UserAPI) updateUser(c *gin.Context, updateDeptReq UpdateDeptReq) erro
 		su.Remark = updateDeptReq.Remark
 		su.UpdateBy = claims.UserId
 	}, repository.WithUserId(updateDeptReq.UserId))
+	if err != nil {
+		return errors.WithStack(err)
+	}
 
-	return errors.WithStack(err)
+	// 角色变更、修改密码或者用户被禁用时，立即断开ftp等长连接会话，
+	// 同时清理以旧密码缓存的ftp登录
+	if userInfo.RoleId != updateDeptReq.RoleId || updateDeptReq.Password != "" ||
+		updateDeptReq.Status != models.UserStatusNormal {
+		api.sessions.InvalidateUser(userInfo.UserId)
+	}
+	return nil
 
 }
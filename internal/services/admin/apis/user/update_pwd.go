@@ -6,6 +6,7 @@ import (
 	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/common/repository"
 	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/auditlog"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -33,6 +34,7 @@ func (api *UserAPI) UpdatePwd(c *gin.Context) {
 	}
 
 	err = api.updatePwd(req)
+	emitToken(c, auditlog.ActionTokenReset, req.UserId, map[string]any{"reason": "password"}, err)
 	if err != nil {
 		c.Error(err)
 		return
@@ -68,6 +70,10 @@ func (api *UserAPI) updatePwd(req UpdatePwdReq) error {
 	err = api.userRepo.Update(func(su *models.SysUser) {
 		su.Password = req.NewPassword
 	}, repository.WithUserId(req.UserId))
-	return errors.WithStack(err)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	api.sessions.InvalidateUser(user.UserId)
+	return nil
 
 }
@@ -2,7 +2,9 @@ package user
 
 import (
 	"go-file-server/internal/common/repository"
+	"go-file-server/internal/common/twofactor"
 	"go-file-server/pkgs/cache"
+	"go-file-server/pkgs/session"
 )
 
 type UserAPI struct {
@@ -11,6 +13,8 @@ type UserAPI struct {
 	roleRepo      *repository.RoleRepository
 	menuRepo      *repository.MenuRepository
 	cache         cache.AdapterCache
+	sessions      *session.Manager
+	twoFactor     *twofactor.Service
 }
 
 func NewUserAPI(
@@ -19,6 +23,8 @@ func NewUserAPI(
 	roleRepo *repository.RoleRepository,
 	menuRepo *repository.MenuRepository,
 	cache cache.AdapterCache,
+	sessions *session.Manager,
+	twoFactor *twofactor.Service,
 ) *UserAPI {
 	return &UserAPI{
 		userRepo:      userRepo,
@@ -26,5 +32,7 @@ func NewUserAPI(
 		roleRepo:      roleRepo,
 		menuRepo:      menuRepo,
 		cache:         cache,
+		sessions:      sessions,
+		twoFactor:     twoFactor,
 	}
 }
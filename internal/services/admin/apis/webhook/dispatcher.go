@@ -0,0 +1,422 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/utils/retry"
+	"go-file-server/pkgs/zlog"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 事件类型
+const (
+	EventFileUploaded     = "file.uploaded"
+	EventFileDeleted      = "file.deleted"
+	EventFileMoved        = "file.moved"
+	EventArchiveExtracted = "archive.extracted"
+	// EventShareAccessed 通过生成的分享链接(share=true)访问文件
+	EventShareAccessed = "share.accessed"
+	// EventFileDeduplicated 重复文件替换为保留文件的硬链接，Source 为保留的文件
+	EventFileDeduplicated = "file.deduplicated"
+)
+
+// Events 可以订阅的事件类型
+var Events = []string{
+	EventFileUploaded,
+	EventFileDeleted,
+	EventFileMoved,
+	EventArchiveExtracted,
+	EventShareAccessed,
+	EventFileDeduplicated,
+}
+
+// 请求头
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature 值为 sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	// queueSize 等待投递的事件数，超出后丢弃新的事件
+	queueSize = 1024
+	// maxConcurrency 同时进行的投递数
+	maxConcurrency = 8
+	// hooksTTL webhook配置的缓存时间，修改后本实例立即生效，其他实例最迟在该时间后生效
+	hooksTTL = time.Minute
+	// requestTimeout 单次请求的超时时间
+	requestTimeout = 10 * time.Second
+	// maxResponseBody 投递记录中保存的响应内容摘要和错误信息的长度，
+	// 只用于排查接收方返回的状态，不保存完整的响应
+	maxResponseBody = 256
+	// retention 投递记录保留时间
+	retention = 30 * 24 * time.Hour
+	// cleanupInterval 清理过期投递记录的间隔
+	cleanupInterval = time.Hour
+)
+
+// EventData 事件内容，路径均为虚拟路径
+type EventData struct {
+	Path string `json:"path"`
+	// OldPath 移动、重命名前的路径
+	OldPath string `json:"oldPath,omitempty"`
+	// Source 解压时为压缩包的路径，去重时为保留的文件
+	Source string `json:"source,omitempty"`
+	// Actor 执行操作的用户名
+	Actor    string `json:"actor"`
+	ClientIp string `json:"clientIp,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+}
+
+// paths 用于匹配 webhook 的路径前缀
+func (d EventData) paths() []string {
+	var paths []string
+	for _, p := range []string{d.Path, d.OldPath, d.Source} {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// Payload 请求体
+type Payload struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Data  EventData `json:"data"`
+}
+
+type emitted struct {
+	event string
+	data  EventData
+	time  time.Time
+}
+
+// retryPolicy 投递失败后的重试策略
+type retryPolicy struct {
+	attempts int
+	delay    time.Duration
+	maxDelay time.Duration
+}
+
+var defaultRetry = retryPolicy{attempts: 5, delay: 2 * time.Second, maxDelay: time.Minute}
+
+// Dispatcher 将文件事件投递给订阅的 webhook，每次投递都记录在数据库中。
+// 投递在后台执行，失败时按指数退避重试，不影响触发事件的请求
+type Dispatcher struct {
+	repo   *repository.WebhookRepository
+	client *http.Client
+	retry  retryPolicy
+	queue  chan emitted
+	sem    chan struct{}
+	once   sync.Once
+
+	mutex    sync.Mutex
+	hooks    []models.SysWebhook
+	loadedAt time.Time
+}
+
+func NewDispatcher(repo *repository.WebhookRepository) *Dispatcher {
+	return &Dispatcher{
+		repo:   repo,
+		client: newClient(),
+		retry:  defaultRetry,
+		queue:  make(chan emitted, queueSize),
+		sem:    make(chan struct{}, maxConcurrency),
+	}
+}
+
+// newClient 不跟随重定向，3xx作为接收方拒绝请求处理，避免签名的请求被转发到其他地址
+func newClient() *http.Client {
+	return &http.Client{
+		Timeout: requestTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Start 将服务重启前未完成的投递标记为失败并开始投递，定期清理过期的投递记录，
+// 多次调用只执行一次
+func (d *Dispatcher) Start() {
+	d.once.Do(func() {
+		err := d.repo.UpdateDelivery(map[string]any{
+			"status": models.DeliveryFailed,
+			"error":  "服务重启，投递被中断",
+		}, repository.WithDeliveryStatus(models.DeliveryPending))
+		if err != nil {
+			zlog.SugLog.Errorf("更新中断的webhook投递失败: %v", err)
+		}
+		go d.loop()
+		go d.cleanup()
+	})
+}
+
+// Run 只开始投递，用于ftp等同一进程中的其他服务创建的 Dispatcher，
+// 中断的投递和过期的记录由接口服务的 Start 处理
+func (d *Dispatcher) Run() {
+	d.once.Do(func() {
+		go d.loop()
+	})
+}
+
+// Emit 触发事件，不会阻塞
+func (d *Dispatcher) Emit(event string, data EventData) {
+	select {
+	case d.queue <- emitted{event: event, data: data, time: time.Now()}:
+	default:
+		zlog.SugLog.Warnf("webhook事件过多，丢弃事件: %s %s", event, data.Path)
+	}
+}
+
+// Reload 修改 webhook 后清除缓存
+func (d *Dispatcher) Reload() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.hooks = nil
+	d.loadedAt = time.Time{}
+}
+
+func (d *Dispatcher) loop() {
+	for e := range d.queue {
+		d.dispatch(e)
+	}
+}
+
+func (d *Dispatcher) cleanup() {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := d.repo.DeleteDeliveries(repository.WithDeliveryCreatedBefore(time.Now().Add(-retention)))
+		if err != nil {
+			zlog.SugLog.Errorf("清理webhook投递记录失败: %v", err)
+		}
+	}
+}
+
+// enabledHooks 返回启用的 webhook，缓存 hooksTTL
+func (d *Dispatcher) enabledHooks() ([]models.SysWebhook, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.hooks != nil && time.Since(d.loadedAt) < hooksTTL {
+		return d.hooks, nil
+	}
+	hooks, err := d.repo.Find(repository.WithWebhookEnabled())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if hooks == nil {
+		hooks = []models.SysWebhook{}
+	}
+	d.hooks, d.loadedAt = hooks, time.Now()
+	return hooks, nil
+}
+
+// dispatch 为每个订阅了事件的 webhook 创建投递记录并在后台投递
+func (d *Dispatcher) dispatch(e emitted) {
+	hooks, err := d.enabledHooks()
+	if err != nil {
+		zlog.SugLog.Errorf("查询webhook失败: %v", err)
+		return
+	}
+	var body []byte
+	for _, hook := range hooks {
+		if !Match(hook, e.event, e.data) {
+			continue
+		}
+		if body == nil {
+			body, err = json.Marshal(Payload{Event: e.event, Time: e.time, Data: e.data})
+			if err != nil {
+				zlog.SugLog.Error(err)
+				return
+			}
+		}
+		if _, err := d.Deliver(hook, e.event, string(body), 0); err != nil {
+			zlog.SugLog.Errorf("创建webhook投递记录失败: %v", err)
+		}
+	}
+}
+
+// Deliver 创建投递记录并在后台投递，redeliveryOf 为重新投递的原记录id
+func (d *Dispatcher) Deliver(hook models.SysWebhook, event, payload string,
+	redeliveryOf int) (models.SysWebhookDelivery, error) {
+	delivery := models.SysWebhookDelivery{
+		WebhookId:    hook.Id,
+		Event:        event,
+		Payload:      payload,
+		Status:       models.DeliveryPending,
+		RedeliveryOf: redeliveryOf,
+	}
+	if err := d.repo.CreateDelivery(&delivery); err != nil {
+		return delivery, errors.WithStack(err)
+	}
+	go func() {
+		d.sem <- struct{}{}
+		defer func() { <-d.sem }()
+		d.finish(delivery.Id, d.send(context.Background(), hook, delivery))
+	}()
+	return delivery, nil
+}
+
+func (d *Dispatcher) finish(id int, result Result) {
+	status := models.DeliverySuccess
+	errMsg := ""
+	if result.Err != nil {
+		status = models.DeliveryFailed
+		errMsg = truncate(result.Err.Error(), maxResponseBody)
+	}
+	now := time.Now()
+	err := d.repo.UpdateDelivery(map[string]any{
+		"status":        status,
+		"attempts":      result.Attempts,
+		"response_code": result.StatusCode,
+		"response_body": result.Body,
+		"error":         errMsg,
+		"duration":      result.Duration.Milliseconds(),
+		"delivered_at":  &now,
+	}, repository.WithDeliveryId(id))
+	if err != nil {
+		zlog.SugLog.Errorf("更新webhook投递记录失败: %v", err)
+	}
+}
+
+// Result 一次投递的结果，包括重试
+type Result struct {
+	Attempts   int
+	StatusCode int
+	// Body 最后一次请求的响应内容摘要，最多 maxResponseBody 字节
+	Body string
+	Err  error
+	// Duration 最后一次请求的耗时
+	Duration time.Duration
+}
+
+// statusErr 接收方返回了非2xx的状态码
+type statusErr struct {
+	code int
+}
+
+func (e statusErr) Error() string {
+	return fmt.Sprintf("接收方返回状态码 %d", e.code)
+}
+
+// retryable 网络错误、5xx以及429需要重试，其他状态码说明接收方拒绝了请求
+func retryable(err error) bool {
+	var se statusErr
+	if errors.As(err, &se) {
+		return se.code >= http.StatusInternalServerError || se.code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// send 发送请求，失败时按指数退避重试
+func (d *Dispatcher) send(ctx context.Context, hook models.SysWebhook, delivery models.SysWebhookDelivery) Result {
+	var result Result
+	err := retry.RetryWithCtx(ctx, func() error {
+		result.Attempts++
+		start := time.Now()
+		code, body, err := d.post(ctx, hook, delivery)
+		result.StatusCode, result.Body, result.Duration = code, body, time.Since(start)
+		return err
+	},
+		retry.WithAttempts(d.retry.attempts),
+		retry.WithDelay(d.retry.delay),
+		retry.WithBackoff(d.retry.maxDelay),
+		retry.WithRetryableCondition(retryable),
+		retry.WithLogger(zlog.SugLog),
+	)
+	result.Err = err
+	return result
+}
+
+func (d *Dispatcher) post(ctx context.Context, hook models.SysWebhook,
+	delivery models.SysWebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", errors.WithStack(err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-file-server-webhook")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.Id))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+	rep, err := d.client.Do(req)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return 0, "", errors.New("请求超时")
+		}
+		return 0, "", err
+	}
+	defer rep.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(rep.Body, maxResponseBody))
+	// 读完剩余内容以便复用连接
+	_, _ = io.Copy(io.Discard, io.LimitReader(rep.Body, 64*1024))
+	text := excerpt(string(data))
+	if rep.StatusCode < 200 || rep.StatusCode >= 300 {
+		return rep.StatusCode, text, statusErr{code: rep.StatusCode}
+	}
+	return rep.StatusCode, text, nil
+}
+
+// Sign 计算签名，接收方使用相同的方式校验请求
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Match 判断 webhook 是否订阅了该事件，路径中任意一个位于 PathPrefix 下即可
+func Match(hook models.SysWebhook, event string, data EventData) bool {
+	if hook.Events != "" && !slices.Contains(strings.Split(hook.Events, ","), event) {
+		return false
+	}
+	if hook.PathPrefix == "" || hook.PathPrefix == "/" {
+		return true
+	}
+	prefix := strings.TrimSuffix(path.Clean("/"+hook.PathPrefix), "/")
+	for _, p := range data.paths() {
+		p = path.Clean("/" + p)
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// excerpt 响应内容的摘要，合并空白字符为一行并截断到 maxResponseBody
+func excerpt(s string) string {
+	return truncate(strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " "), maxResponseBody)
+}
+
+// truncate 截断到 n 字节，去掉被截断的不完整字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
@@ -0,0 +1,120 @@
+package webhook
+
+import (
+	"context"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/zlog"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestSend(t *testing.T) {
+	zlog.SugLog = zap.NewNop().Sugar()
+	const secret = "test-secret"
+	payload := `{"event":"file.uploaded","data":{"path":"/a/b.txt"}}`
+	tests := []struct {
+		name string
+		// codes 接收方依次返回的状态码，超出后返回最后一个
+		codes        []int
+		wantAttempts int
+		wantCode     int
+		wantErr      bool
+	}{
+		{name: "success", codes: []int{200}, wantAttempts: 1, wantCode: 200},
+		{name: "retry 5xx", codes: []int{500, 503, 201}, wantAttempts: 3, wantCode: 201},
+		{name: "retry 429", codes: []int{429, 200}, wantAttempts: 2, wantCode: 200},
+		{name: "4xx not retried", codes: []int{400}, wantAttempts: 1, wantCode: 400, wantErr: true},
+		{name: "attempts exhausted", codes: []int{502}, wantAttempts: 3, wantCode: 502, wantErr: true},
+		{name: "redirect not followed", codes: []int{302}, wantAttempts: 1, wantCode: 302, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				ts, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+				if err != nil || r.Header.Get(HeaderSignature) != Sign(secret, ts, body) {
+					t.Errorf("签名校验失败")
+				}
+				if string(body) != payload || r.Header.Get(HeaderEvent) != EventFileUploaded ||
+					r.Header.Get(HeaderDelivery) != "7" {
+					t.Errorf("请求内容错误: %s %v", body, r.Header)
+				}
+				n := int(calls.Add(1))
+				w.Header().Set("Location", "/redirected")
+				w.WriteHeader(tt.codes[min(n, len(tt.codes))-1])
+				w.Write([]byte("ok"))
+			}))
+			defer srv.Close()
+
+			d := &Dispatcher{
+				client: newClient(),
+				retry:  retryPolicy{attempts: 3, delay: time.Millisecond, maxDelay: 4 * time.Millisecond},
+			}
+			hook := models.SysWebhook{Url: srv.URL, Secret: secret}
+			delivery := models.SysWebhookDelivery{Id: 7, Event: EventFileUploaded, Payload: payload}
+			got := d.send(context.Background(), hook, delivery)
+			if got.Attempts != tt.wantAttempts || int(calls.Load()) != tt.wantAttempts {
+				t.Errorf("attempts = %d, calls = %d, want %d", got.Attempts, calls.Load(), tt.wantAttempts)
+			}
+			if got.StatusCode != tt.wantCode || got.Body != "ok" {
+				t.Errorf("response = %d %q, want %d", got.StatusCode, got.Body, tt.wantCode)
+			}
+			if (got.Err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", got.Err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("错误", maxResponseBody)
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"short", "ok", "ok"},
+		{"whitespace", "<html>\n  <body>\tBad Gateway</body>\r\n</html>\n", "<html> <body> Bad Gateway</body> </html>"},
+		{"long", long, long[:maxResponseBody-maxResponseBody%len("错")]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := excerpt(tt.body); got != tt.want {
+				t.Errorf("excerpt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	data := EventData{Path: "/docs/report.pdf", OldPath: "/inbox/report.pdf"}
+	tests := []struct {
+		name  string
+		hook  models.SysWebhook
+		event string
+		want  bool
+	}{
+		{name: "all", hook: models.SysWebhook{}, event: EventFileMoved, want: true},
+		{name: "event subscribed", hook: models.SysWebhook{Events: "file.deleted,file.moved"}, event: EventFileMoved, want: true},
+		{name: "event not subscribed", hook: models.SysWebhook{Events: "file.deleted"}, event: EventFileMoved, want: false},
+		{name: "prefix new path", hook: models.SysWebhook{PathPrefix: "/docs"}, event: EventFileMoved, want: true},
+		{name: "prefix old path", hook: models.SysWebhook{PathPrefix: "/inbox/"}, event: EventFileMoved, want: true},
+		{name: "prefix not dir boundary", hook: models.SysWebhook{PathPrefix: "/doc"}, event: EventFileMoved, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Match(tt.hook, tt.event, data); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	coreModels "go-file-server/internal/common/models"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/base"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type WebhookApi struct {
+	dispatcher  *Dispatcher
+	webhookRepo *repository.WebhookRepository
+}
+
+func NewWebhookApi(dispatcher *Dispatcher, webhookRepo *repository.WebhookRepository) *WebhookApi {
+	return &WebhookApi{
+		dispatcher:  dispatcher,
+		webhookRepo: webhookRepo,
+	}
+}
+
+// GetList 查询全部webhook
+func (api *WebhookApi) GetList(c *gin.Context) {
+	data, err := api.webhookRepo.Find(base.WithOrderBy("id", false))
+	if err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	core.OKRep(data).SendGin(c)
+}
+
+type CreateReq struct {
+	Name string `json:"name" binding:"required,max=64"`
+	Url  string `json:"url" binding:"required,max=1024"`
+	// Secret 为空时随机生成
+	Secret     string   `json:"secret" binding:"max=255"`
+	PathPrefix string   `json:"pathPrefix" binding:"max=255"`
+	Events     []string `json:"events"`
+	Enabled    bool     `json:"enabled"`
+	Remark     string   `json:"remark" binding:"max=255"`
+}
+
+type CreateRep struct {
+	Id int `json:"id"`
+	// Secret 只在创建时返回
+	Secret string `json:"secret"`
+}
+
+// Create 添加webhook
+func (api *WebhookApi) Create(c *gin.Context) {
+	var req CreateReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	events, err := checkHook(req.Url, req.Events)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if req.Secret == "" {
+		if req.Secret, err = newSecret(); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	hook := models.SysWebhook{
+		Name:       req.Name,
+		Url:        req.Url,
+		Secret:     req.Secret,
+		PathPrefix: cleanPrefix(req.PathPrefix),
+		Events:     events,
+		Enabled:    req.Enabled,
+		Remark:     req.Remark,
+		ControlBy:  coreModels.ControlBy{CreateBy: core.GetUserId(c)},
+	}
+	if err := api.webhookRepo.Create(&hook); err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	api.dispatcher.Reload()
+	core.OKRep(CreateRep{Id: hook.Id, Secret: hook.Secret}).SendGin(c)
+}
+
+type UpdateReq struct {
+	Id   int    `json:"id" binding:"required"`
+	Name string `json:"name" binding:"required,max=64"`
+	Url  string `json:"url" binding:"required,max=1024"`
+	// Secret 为空时不修改
+	Secret     string   `json:"secret" binding:"max=255"`
+	PathPrefix string   `json:"pathPrefix" binding:"max=255"`
+	Events     []string `json:"events"`
+	Enabled    bool     `json:"enabled"`
+	Remark     string   `json:"remark" binding:"max=255"`
+}
+
+// Update 修改webhook
+func (api *WebhookApi) Update(c *gin.Context) {
+	var req UpdateReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if _, err := api.getHook(req.Id); err != nil {
+		c.Error(err)
+		return
+	}
+	events, err := checkHook(req.Url, req.Events)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	values := map[string]any{
+		"name":        req.Name,
+		"url":         req.Url,
+		"path_prefix": cleanPrefix(req.PathPrefix),
+		"events":      events,
+		"enabled":     req.Enabled,
+		"remark":      req.Remark,
+		"update_by":   core.GetUserId(c),
+	}
+	if req.Secret != "" {
+		values["secret"] = req.Secret
+	}
+	if err := api.webhookRepo.Updates(values, repository.WithWebhookId(req.Id)); err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	api.dispatcher.Reload()
+	core.OKRep(nil).SendGin(c)
+}
+
+type DeleteReq struct {
+	Ids []int `json:"ids" binding:"required,min=1"`
+}
+
+// Delete 删除webhook以及投递记录
+func (api *WebhookApi) Delete(c *gin.Context) {
+	var req DeleteReq
+	err := c.ShouldBind(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := api.webhookRepo.Delete(repository.WithWebhookIds(req.Ids...)); err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	if err := api.webhookRepo.DeleteDeliveries(repository.WithDeliveryWebhookIds(req.Ids...)); err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	api.dispatcher.Reload()
+	core.OKRep(nil).SendGin(c)
+}
+
+type GetDeliveriesReq struct {
+	types.Pagination
+	WebhookId int    `form:"webhookId"`
+	Event     string `form:"event"`
+	Status    string `form:"status" binding:"omitempty,oneof=pending success failed"`
+}
+
+type GetDeliveriesRep struct {
+	types.Page
+	Items []models.SysWebhookDelivery `json:"items"`
+}
+
+// GetDeliveries 分页查询投递记录，按投递时间倒序
+func (api *WebhookApi) GetDeliveries(c *gin.Context) {
+	var req GetDeliveriesReq
+	err := c.ShouldBindQuery(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	var querys []base.DbScope
+	if req.WebhookId != 0 {
+		querys = append(querys, repository.WithDeliveryWebhookIds(req.WebhookId))
+	}
+	if req.Event != "" {
+		querys = append(querys, repository.WithDeliveryEvent(req.Event))
+	}
+	if req.Status != "" {
+		querys = append(querys, repository.WithDeliveryStatus(req.Status))
+	}
+	data, count, err := api.webhookRepo.FindDeliveriesWithCount(append(querys,
+		base.WithOrderBy("id", true),
+		base.WithPaginate(req.PageIndex, req.PageSize),
+	)...)
+	if err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	core.OKRep(GetDeliveriesRep{
+		Page:  types.NewPage(count, req.PageIndex, req.PageSize),
+		Items: data,
+	}).SendGin(c)
+}
+
+type IdReq struct {
+	Id int `uri:"id" binding:"required"`
+}
+
+// Redeliver 使用原来的请求体重新投递，生成新的投递记录
+func (api *WebhookApi) Redeliver(c *gin.Context) {
+	var req IdReq
+	if err := c.ShouldBindUri(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	delivery, err := api.webhookRepo.FindDelivery(repository.WithDeliveryId(req.Id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(core.NewApiBizErr(err).
+				SetHttpCode(global.StatusNotFound).
+				SetBizCode(global.BizNotFound).
+				SetMsg("投递记录不存在"))
+			return
+		}
+		c.Error(errors.WithStack(err))
+		return
+	}
+	hook, err := api.getHook(delivery.WebhookId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	data, err := api.dispatcher.Deliver(hook, delivery.Event, delivery.Payload, delivery.Id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(data).SendGin(c)
+}
+
+func (api *WebhookApi) getHook(id int) (models.SysWebhook, error) {
+	hook, err := api.webhookRepo.FindOne(repository.WithWebhookId(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return hook, core.NewApiBizErr(err).
+				SetHttpCode(global.StatusNotFound).
+				SetBizCode(global.BizNotFound).
+				SetMsg("webhook不存在")
+		}
+		return hook, errors.WithStack(err)
+	}
+	return hook, nil
+}
+
+// checkHook 检查url和事件类型，返回保存的事件列表
+func checkHook(rawUrl string, events []string) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", core.NewApiBizErr(err).SetMsg("url 必须是 http 或 https 地址")
+	}
+	for _, e := range events {
+		if !slices.Contains(Events, e) {
+			return "", core.NewApiBizErr(nil).SetMsg("不支持的事件类型: " + e)
+		}
+	}
+	return strings.Join(events, ","), nil
+}
+
+func cleanPrefix(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+	return path.Clean("/" + prefix)
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -5,9 +5,11 @@ import (
 	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/common/repository"
 	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/apis/fs/utils"
 	"go-file-server/internal/services/admin/routers"
 	"go-file-server/pkgs/cache"
 	"go-file-server/pkgs/pathtool"
+	"go-file-server/pkgs/session"
 	"go-file-server/pkgs/zlog"
 
 	"github.com/casbin/casbin/v2"
@@ -39,7 +41,8 @@ func SetupFsSvcCtx(svcCtx *types.SvcCtx) routers.FsSvcCtx {
 	return setupSvcCtx(svcCtx)
 }
 
-func RegisterRouter(svcCtx *types.SvcCtx) {
+// RegisterRouter 返回的函数在服务退出时调用，执行组件注册的 OnStop
+func RegisterRouter(svcCtx *types.SvcCtx) func(ctx context.Context) error {
 	app := fx.New(
 		fx.Provide(
 			func() *gorm.DB { return svcCtx.Db },
@@ -53,6 +56,12 @@ func RegisterRouter(svcCtx *types.SvcCtx) {
 		fx.Provide(
 			func() cache.AdapterCache { return svcCtx.Cache },
 		),
+		fx.Provide(
+			func() *session.Manager { return svcCtx.Sessions },
+		),
+		fx.Provide(
+			func() *utils.ActorTracker { return svcCtx.Actors },
+		),
 		fx.Provide(
 			func() *types.SvcCtx { return SetupSvcCtx(svcCtx) },
 		),
@@ -68,7 +77,5 @@ func RegisterRouter(svcCtx *types.SvcCtx) {
 	if err := app.Start(context.Background()); err != nil {
 		zlog.SugLog.Fatal(err)
 	}
-	if err := app.Stop(context.Background()); err != nil {
-		zlog.SugLog.Fatal(err)
-	}
+	return app.Stop
 }
@@ -6,6 +6,14 @@ import (
 	"gorm.io/gorm"
 )
 
+// 登录日志的状态
+const (
+	LoginStatusSuccess = "1"
+	LoginStatusFailed  = "2"
+	// LoginStatusLocked 连续登录失败后锁定用户名或ip
+	LoginStatusLocked = "3"
+)
+
 type SysLoginLog struct {
 	gorm.Model
 	Username      string `json:"username" gorm:"size:128;comment:用户名"`
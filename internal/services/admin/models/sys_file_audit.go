@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// 文件审计的操作结果
+const (
+	AuditSuccess = "success"
+	AuditFailed  = "failed"
+)
+
+// SysFileAudit 文件访问审计，记录web、ftp以及下载链接对文件的读写。
+// 审计记录不允许修改和删除，只按保留天数定时清理
+type SysFileAudit struct {
+	Id     int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserId int    `json:"userId" gorm:"index;comment:用户id"`
+	Actor  string `json:"actor" gorm:"size:128;index;comment:用户名"`
+	// Protocol http、ftp、sftp、share
+	Protocol string `json:"protocol" gorm:"size:16;comment:访问方式"`
+	Action   string `json:"action" gorm:"size:32;comment:操作"`
+	Path     string `json:"path" gorm:"size:1024;index:,length:255;comment:真实路径"`
+	// Dest 移动、重命名、解压、压缩、复制的目标路径
+	Dest      string    `json:"dest" gorm:"size:1024;comment:目标路径"`
+	Bytes     int64     `json:"bytes" gorm:"comment:传输的字节数"`
+	Result    string    `json:"result" gorm:"size:16;comment:操作结果"`
+	Error     string    `json:"error" gorm:"size:512;comment:失败原因"`
+	ClientIp  string    `json:"clientIp" gorm:"size:128;comment:客户端ip"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index;comment:操作时间"`
+}
+
+func (SysFileAudit) TableName() string {
+	return "sys_file_audit"
+}
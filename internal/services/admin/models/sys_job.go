@@ -0,0 +1,53 @@
+package models
+
+import (
+	"go-file-server/internal/common/models"
+	"time"
+)
+
+// 后台任务状态
+const (
+	JobPending  = "pending"
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
+)
+
+// SysJob 持久化的后台任务，服务重启后可以恢复或重试
+type SysJob struct {
+	Id   int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type string `json:"type" gorm:"size:32;not null;comment:任务类型"`
+	// Key 同一类型相同key的任务同时只执行一个，重复提交时返回已有的任务
+	Key      string `json:"key" gorm:"size:1024;comment:去重标识"`
+	Status   string `json:"status" gorm:"size:16;index;not null;comment:任务状态"`
+	Priority int    `json:"priority" gorm:"comment:优先级"` // 数值越大越先执行
+	// Params 任务参数，json格式
+	Params  string `json:"params" gorm:"type:text"`
+	Total   int64  `json:"total" gorm:"comment:总进度"`
+	Done    int64  `json:"done" gorm:"comment:已完成进度"`
+	Message string `json:"message" gorm:"size:1024;comment:最新日志"`
+	Error   string `json:"error" gorm:"size:1024;comment:失败原因"`
+	// Attempts 已执行的次数，MaxAttempts 自动重试的最大执行次数
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"maxAttempts"`
+	UserId      int        `json:"userId" gorm:"index;comment:所属用户"`
+	Username    string     `json:"username" gorm:"size:64"`
+	RoleKey     string     `json:"roleKey" gorm:"size:128"`
+	RunAt       time.Time  `json:"runAt" gorm:"comment:最早执行时间"` // 重试时延后执行
+	StartedAt   *time.Time `json:"startedAt"`
+	FinishedAt  *time.Time `json:"finishedAt"`
+	// Retried 手动重试时会重置 Attempts，用于判断之前的执行是否可能留下了部分结果
+	Retried bool `json:"retried" gorm:"default:false;comment:是否手动重试过"`
+	models.ControlBy
+	models.ModelTime
+}
+
+func (SysJob) TableName() string {
+	return "sys_job"
+}
+
+// Finished 任务是否已经结束
+func (j SysJob) Finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed || j.Status == JobCanceled
+}
@@ -9,25 +9,37 @@ const AdminRoleKey = "admin"
 type FsPermissions struct {
 	Path        string   `json:"path" binding:"required"`
 	Permissions []string `json:"permissions" binding:"required"`
+	Alias       string   `json:"alias"`
 }
 
 type SysRole struct {
-	RoleId    int             `json:"roleId" gorm:"primaryKey;autoIncrement"`    // 角色编码
-	RoleName  string          `json:"roleName" gorm:"size:128;unique;not null;"` // 角色名称
-	Status    string          `json:"status" gorm:"size:4;"`                     //
-	RoleKey   string          `json:"roleKey" gorm:"size:128;"`                  //角色代码
-	RoleSort  int             `json:"roleSort" gorm:""`                          //角色排序
-	Flag      string          `json:"flag" gorm:"size:128;"`                     //
-	Remark    string          `json:"remark" gorm:"size:255;"`                   //备注
-	Admin     bool            `json:"admin" gorm:"size:4;"`
-	DataScope string          `json:"dataScope" gorm:"size:128;"`
-	Params    string          `json:"params" gorm:"-"`
-	RateLimit uint64          `json:"rateLimit"` // 文件传输限速
-	MenuIds   []int           `json:"menuIds" gorm:"-"`
-	DeptIds   []int           `json:"deptIds" gorm:"-"`
-	SysDept   []SysDept       `json:"sysDept" gorm:"many2many:sys_role_dept;foreignKey:RoleId;joinForeignKey:role_id;references:DeptId;joinReferences:dept_id;"`
-	SysMenu   []SysMenu       `json:"sysMenu" gorm:"many2many:sys_role_menu;foreignKey:RoleId;joinForeignKey:role_id;references:MenuId;joinReferences:menu_id;"`
-	FsRoles   []FsPermissions `json:"fsRoles" gorm:"-"`
+	RoleId    int    `json:"roleId" gorm:"primaryKey;autoIncrement"`    // 角色编码
+	RoleName  string `json:"roleName" gorm:"size:128;unique;not null;"` // 角色名称
+	Status    string `json:"status" gorm:"size:4;"`                     //
+	RoleKey   string `json:"roleKey" gorm:"size:128;"`                  //角色代码
+	RoleSort  int    `json:"roleSort" gorm:""`                          //角色排序
+	Flag      string `json:"flag" gorm:"size:128;"`                     //
+	Remark    string `json:"remark" gorm:"size:255;"`                   //备注
+	Admin     bool   `json:"admin" gorm:"size:4;"`
+	DataScope string `json:"dataScope" gorm:"size:128;"`
+	Params    string `json:"params" gorm:"-"`
+	RateLimit uint64 `json:"rateLimit"` // 文件传输限速
+	// 并发限制，0表示不限制
+	MaxSessions  int             `json:"maxSessions" gorm:"default:0"`  // 每个用户的最大会话数(ftp等)
+	MaxTransfers int             `json:"maxTransfers" gorm:"default:0"` // 每个用户的最大并发传输数
+	MaxIpConns   int             `json:"maxIpConns" gorm:"default:0"`   // 每个ip的最大连接数
+	MenuIds      []int           `json:"menuIds" gorm:"-"`
+	DeptIds      []int           `json:"deptIds" gorm:"-"`
+	SysDept      []SysDept       `json:"sysDept" gorm:"many2many:sys_role_dept;foreignKey:RoleId;joinForeignKey:role_id;references:DeptId;joinReferences:dept_id;"`
+	SysMenu      []SysMenu       `json:"sysMenu" gorm:"many2many:sys_role_menu;foreignKey:RoleId;joinForeignKey:role_id;references:MenuId;joinReferences:menu_id;"`
+	FsRoles      []FsPermissions `json:"fsRoles" gorm:"-"`
+
+	// 传输流量配额
+	TransferQuota
+	StorageQuota int64 `json:"storageQuota" gorm:"default:0;comment:存储配额"` // 角色可写目录的总大小限制
+	// Require2fa 要求角色下的本地用户启用两步验证，未启用的用户登录时需要先完成登记
+	Require2fa bool `json:"require2fa" gorm:"default:false;comment:要求两步验证"`
+
 	models.ControlBy
 	models.ModelTime
 }
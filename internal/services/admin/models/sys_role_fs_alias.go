@@ -0,0 +1,13 @@
+package models
+
+// SysRoleFsAlias 角色目录授权的虚拟目录名称，用于ftp等客户端的根目录展示
+type SysRoleFsAlias struct {
+	Id      int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	RoleKey string `json:"roleKey" gorm:"size:128;index"`
+	Path    string `json:"path" gorm:"size:255"`
+	Alias   string `json:"alias" gorm:"size:128"`
+}
+
+func (SysRoleFsAlias) TableName() string {
+	return "sys_role_fs_alias"
+}
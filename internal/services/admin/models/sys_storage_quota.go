@@ -0,0 +1,19 @@
+package models
+
+import (
+	"go-file-server/internal/common/models"
+)
+
+// SysStorageQuota 目录的存储配额，目录下全部文件的大小之和不能超过 Quota
+type SysStorageQuota struct {
+	Id     int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Path   string `json:"path" gorm:"size:255;unique;not null"` // 虚拟路径
+	Quota  int64  `json:"quota" gorm:"not null;comment:存储配额"`   // 单位byte
+	Remark string `json:"remark" gorm:"size:255"`
+	models.ControlBy
+	models.ModelTime
+}
+
+func (SysStorageQuota) TableName() string {
+	return "sys_storage_quota"
+}
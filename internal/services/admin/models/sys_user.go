@@ -7,6 +7,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// UserStatusNormal 用户状态正常，其他状态视为禁用
+const UserStatusNormal = "2"
+
 type SysUser struct {
 	UserId   int    `gorm:"primaryKey;autoIncrement;comment:编码"  json:"userId"`
 	Username string `json:"username" gorm:"size:64;unique;not null;comment:用户名"`
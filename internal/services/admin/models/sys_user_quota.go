@@ -0,0 +1,19 @@
+package models
+
+// TransferQuota 传输流量配额，单位byte，0表示不限制
+type TransferQuota struct {
+	DailyDownload   int64 `json:"dailyDownload" gorm:"default:0;comment:每日下载流量"`
+	DailyUpload     int64 `json:"dailyUpload" gorm:"default:0;comment:每日上传流量"`
+	MonthlyDownload int64 `json:"monthlyDownload" gorm:"default:0;comment:每月下载流量"`
+	MonthlyUpload   int64 `json:"monthlyUpload" gorm:"default:0;comment:每月上传流量"`
+}
+
+// SysUserQuota 用户级别的流量配额，存在时覆盖角色的配额
+type SysUserQuota struct {
+	UserId int `json:"userId" gorm:"primaryKey;autoIncrement:false"`
+	TransferQuota
+}
+
+func (SysUserQuota) TableName() string {
+	return "sys_user_quota"
+}
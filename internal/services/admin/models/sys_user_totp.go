@@ -0,0 +1,23 @@
+package models
+
+import (
+	"go-file-server/internal/common/models"
+	"time"
+)
+
+// SysUserTotp 用户的两步验证，Enabled 为false时是还未完成验证的登记
+type SysUserTotp struct {
+	UserId  int    `json:"userId" gorm:"primaryKey;autoIncrement:false"`
+	Secret  string `json:"-" gorm:"size:64;comment:totp密钥"`
+	Enabled bool   `json:"enabled" gorm:"default:false"`
+	// RecoveryCodes 恢复码的sha256，逗号分隔，使用后删除
+	RecoveryCodes string `json:"-" gorm:"size:1024;comment:恢复码"`
+	// LastStep 最后一次使用的时间步，同一个验证码不能重复使用
+	LastStep  int64      `json:"-"`
+	EnabledAt *time.Time `json:"enabledAt"`
+	models.ModelTime
+}
+
+func (SysUserTotp) TableName() string {
+	return "sys_user_totp"
+}
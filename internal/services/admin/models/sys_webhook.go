@@ -0,0 +1,57 @@
+package models
+
+import (
+	"go-file-server/internal/common/models"
+	"time"
+)
+
+// webhook 投递状态
+const (
+	DeliveryPending = "pending"
+	DeliverySuccess = "success"
+	DeliveryFailed  = "failed"
+)
+
+// SysWebhook 管理员配置的webhook，文件事件发生时向 Url 推送签名的json
+type SysWebhook struct {
+	Id   int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name string `json:"name" gorm:"size:64;not null"`
+	Url  string `json:"url" gorm:"size:1024;not null"`
+	// Secret 用于 HMAC-SHA256 签名，不会返回给前端
+	Secret string `json:"-" gorm:"size:255;not null"`
+	// PathPrefix 只推送该虚拟路径下的事件，为空时推送全部
+	PathPrefix string `json:"pathPrefix" gorm:"size:255"`
+	// Events 订阅的事件类型，逗号分隔，为空时订阅全部
+	Events  string `json:"events" gorm:"size:255"`
+	Enabled bool   `json:"enabled" gorm:"not null"`
+	Remark  string `json:"remark" gorm:"size:255"`
+	models.ControlBy
+	models.ModelTime
+}
+
+func (SysWebhook) TableName() string {
+	return "sys_webhook"
+}
+
+// SysWebhookDelivery webhook的投递记录
+type SysWebhookDelivery struct {
+	Id        int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	WebhookId int    `json:"webhookId" gorm:"index;not null"`
+	Event     string `json:"event" gorm:"size:32;not null"`
+	// Payload 请求体，重新投递时原样发送
+	Payload      string     `json:"payload" gorm:"type:text"`
+	Status       string     `json:"status" gorm:"size:16;index;not null"`
+	Attempts     int        `json:"attempts"`
+	ResponseCode int        `json:"responseCode"`
+	ResponseBody string     `json:"responseBody" gorm:"size:1024"`
+	Error        string     `json:"error" gorm:"size:1024"`
+	Duration     int64      `json:"duration" gorm:"comment:最后一次请求耗时(毫秒)"`
+	DeliveredAt  *time.Time `json:"deliveredAt"`
+	// RedeliveryOf 重新投递时为原投递记录的id
+	RedeliveryOf int `json:"redeliveryOf"`
+	models.ModelTime
+}
+
+func (SysWebhookDelivery) TableName() string {
+	return "sys_webhook_delivery"
+}
@@ -1,12 +1,13 @@
 package routers
 
 import (
+	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/common/types"
 	"go-file-server/internal/services/admin/apis/avatar"
 )
 
 func RegisterAvatarRoutes(svc *types.SvcCtx, avatarApi *avatar.AvatarAPI) {
-	api := svc.Router.Group("/avatar")
+	api := svc.Router.Group("/avatar", middlewares.OperTitle("用户头像"))
 	{
 		api.POST("", avatarApi.Create)
 		api.GET("", avatarApi.Get)
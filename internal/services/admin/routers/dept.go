@@ -8,7 +8,7 @@ import (
 )
 
 func RegisterDeptRoutes(svc *types.SvcCtx, deptApi *dept.DeptApi) {
-	api := svc.Router.Group("/dept").Use(middlewares.AuthCheckRole(svc))
+	api := svc.Router.Group("/dept", middlewares.OperTitle("部门管理")).Use(middlewares.AuthCheckRole(svc))
 	{
 		api.POST("", deptApi.Create)
 		api.DELETE("", deptApi.Delete)
@@ -0,0 +1,22 @@
+package routers
+
+import (
+	"go-file-server/internal/common/middlewares"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/apis/duplicate"
+)
+
+func RegisterDuplicateRoutes(svc *types.SvcCtx, duplicateApi *duplicate.DuplicateApi) {
+	api := svc.Router.Group("/duplicate", middlewares.OperTitle("重复文件"))
+	{
+		api.POST("scan", duplicateApi.Create)
+		api.GET("scan", duplicateApi.Get)
+		api.GET("scan/:id", duplicateApi.GetSets)
+		api.DELETE("scan/:id", duplicateApi.Delete)
+	}
+
+	authApi := svc.Router.Group("/duplicate", middlewares.OperTitle("重复文件")).Use(middlewares.AuthCheckRole(svc))
+	{
+		authApi.POST("resolve", duplicateApi.Resolve)
+	}
+}
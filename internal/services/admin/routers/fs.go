@@ -17,19 +17,28 @@ func RegisterFsRoutes(svc FsSvcCtx, fsApi *fs.FsApi) {
 	)
 	fsApi.Authenticator = authenticator
 
-	router := svc.Router.Group("/fsd")
+	router := svc.Router.Group("/fsd", middlewares.OperTitle("文件下载"))
 	{
 		router.GET("/*path", fsApi.Download)
 	}
 
-	authRouter := svc.Router.Group("")
-	authRouter.Use(middlewares.Auth(authenticator))
+	authRouter := svc.Router.Group("", middlewares.OperTitle("文件管理"))
+	authRouter.Use(middlewares.Auth(authenticator), fsApi.MarkActor())
 
 	{
 		authRouter.GET("/sse/fs/info", fsApi.GetInfo)
 		authRouter.GET("/sse/fs/unarchive/*path", fsApi.Unarchive)
+		authRouter.GET("/sse/fs/extract/*path", fsApi.Extract)
+		authRouter.GET("/sse/fs/compress", fsApi.Compress)
+		authRouter.GET("/sse/fs/events/*path", fsApi.Watch)
+		authRouter.DELETE("/fscompress/*path", fsApi.CancelCompress)
 		authRouter.PUT("/fs/*path", fsApi.Update)
 		authRouter.GET("/fsu/*path", fsApi.GetDownloadUrl)
+		authRouter.GET("/fsdu/*path", fsApi.GetDirUsage)
+		authRouter.GET("/fsthumb/*path", fsApi.GetThumbnail)
+		authRouter.GET("/fspreview/*path", fsApi.GetPreview)
+		authRouter.PUT("/fspreview/*path", fsApi.SaveText)
+		authRouter.GET("/fsarchive/*path", fsApi.ListArchive)
 		authRouter.POST("/fsindex", fsApi.Reset)
 
 	}
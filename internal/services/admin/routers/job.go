@@ -0,0 +1,23 @@
+package routers
+
+import (
+	"go-file-server/internal/common/middlewares"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/apis/fs"
+	"go-file-server/internal/services/admin/apis/job"
+)
+
+// RegisterJobRoutes 依赖 FsApi 保证文件操作的任务类型注册完成后再启动任务队列
+func RegisterJobRoutes(svc *types.SvcCtx, jobApi *job.JobApi, manager *job.Manager, _ *fs.FsApi) {
+	api := svc.Router.Group("/jobs", middlewares.OperTitle("后台任务"))
+	{
+		api.GET("", jobApi.GetPage)
+		api.GET("/:id", jobApi.Get)
+		api.POST("", jobApi.Create)
+		api.POST("/:id/retry", jobApi.Retry)
+		api.DELETE("/:id", jobApi.Delete)
+	}
+	svc.Router.GET("/sse/jobs/:id", jobApi.Stream)
+
+	manager.Start()
+}
@@ -3,18 +3,22 @@ package routers
 import (
 	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/apis/log/audit"
 	"go-file-server/internal/services/admin/apis/log/login"
 	"go-file-server/internal/services/admin/apis/log/opera"
 )
 
-func RegisterLogRoutes(svc *types.SvcCtx, loginApi *login.LoginAPI, operaApi *opera.OperaAPI) {
-	logApiGroup := svc.Router.Group("/log")
+func RegisterLogRoutes(svc *types.SvcCtx, loginApi *login.LoginAPI, operaApi *opera.OperaAPI,
+	auditApi *audit.AuditApi) {
+	logApiGroup := svc.Router.Group("/log", middlewares.OperTitle("日志管理"))
 	logApiGroup.Use(middlewares.AuthCheckRole(svc))
 
 	{
 		loginLogApiGroup := logApiGroup.Group("/login")
 		loginLogApiGroup.DELETE("", loginApi.Delete)
 		loginLogApiGroup.GET("", loginApi.GetPage)
+		loginLogApiGroup.GET("/lockout", loginApi.GetLockout)
+		loginLogApiGroup.DELETE("/lockout", loginApi.Unlock)
 	}
 
 	{
@@ -23,4 +27,10 @@ func RegisterLogRoutes(svc *types.SvcCtx, loginApi *login.LoginAPI, operaApi *op
 		operaLogApiGroup.GET("", operaApi.GetPage)
 	}
 
+	{
+		fileLogApiGroup := logApiGroup.Group("/file")
+		fileLogApiGroup.GET("", auditApi.GetPage)
+		fileLogApiGroup.GET("/export", auditApi.Export)
+	}
+
 }
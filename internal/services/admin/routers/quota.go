@@ -0,0 +1,25 @@
+package routers
+
+import (
+	"go-file-server/internal/common/middlewares"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/apis/quota"
+)
+
+func RegisterQuotaRoutes(svc *types.SvcCtx, quotaApi *quota.QuotaApi) {
+	api := svc.Router.Group("/quota", middlewares.OperTitle("存储配额"))
+	{
+		api.GET("usage", quotaApi.GetUsage)
+	}
+
+	authApi := svc.Router.Group("/quota", middlewares.OperTitle("存储配额")).Use(middlewares.AuthCheckRole(svc))
+	{
+		authApi.GET("user/:userId", quotaApi.GetUserQuota)
+		authApi.PUT("user", quotaApi.UpdateUserQuota)
+		authApi.DELETE("user", quotaApi.DeleteUserQuota)
+		authApi.GET("storage", quotaApi.GetStorageUsage)
+		authApi.POST("storage/dir", quotaApi.CreateDirQuota)
+		authApi.PUT("storage/dir", quotaApi.UpdateDirQuota)
+		authApi.DELETE("storage/dir", quotaApi.DeleteDirQuota)
+	}
+}
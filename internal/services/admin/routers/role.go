@@ -7,17 +7,18 @@ import (
 )
 
 func RegisterRoleRoutes(svc *types.SvcCtx, roleApi *role.RoleApi) {
-	roleGrop := svc.Router.Group("/role").Use(middlewares.AuthCheckRole(svc))
+	roleGrop := svc.Router.Group("/role", middlewares.OperTitle("角色管理")).Use(middlewares.AuthCheckRole(svc))
 	{
 
-		roleGrop.PUT("datascope", roleApi.UpdateDataScope)
-		roleGrop.PUT("status", roleApi.UpdateStatus)
-		roleGrop.PUT("fs", roleApi.UpdateFs)
+		roleGrop.PUT("datascope", middlewares.AuditChange("role.datascope"), roleApi.UpdateDataScope)
+		roleGrop.PUT("status", middlewares.AuditChange("role.status"), roleApi.UpdateStatus)
+		roleGrop.PUT("fs", middlewares.AuditChange("role.fs"), roleApi.UpdateFs)
+		roleGrop.PUT("2fa", middlewares.AuditChange("role.2fa"), roleApi.UpdateTwoFactor)
 		roleGrop.GET(":id", roleApi.GetInfo)
 		roleGrop.GET("", roleApi.GetPage)
-		roleGrop.POST("", roleApi.Create)
-		roleGrop.DELETE("", roleApi.Delete)
-		roleGrop.PUT("", roleApi.Update)
+		roleGrop.POST("", middlewares.AuditChange("role.create"), roleApi.Create)
+		roleGrop.DELETE("", middlewares.AuditChange("role.delete"), roleApi.Delete)
+		roleGrop.PUT("", middlewares.AuditChange("role.update"), roleApi.Update)
 	}
 
 }
@@ -1,16 +1,24 @@
 package routers
 
 import (
+	"go-file-server/internal/common/loginguard"
 	"go-file-server/internal/common/repository"
+	"go-file-server/internal/common/twofactor"
 	"go-file-server/internal/services/admin/apis/avatar"
 	"go-file-server/internal/services/admin/apis/dept"
+	"go-file-server/internal/services/admin/apis/duplicate"
 	"go-file-server/internal/services/admin/apis/fs"
+	"go-file-server/internal/services/admin/apis/job"
+	"go-file-server/internal/services/admin/apis/log/audit"
 	"go-file-server/internal/services/admin/apis/log/login"
 	"go-file-server/internal/services/admin/apis/log/opera"
 	"go-file-server/internal/services/admin/apis/menu"
+	"go-file-server/internal/services/admin/apis/quota"
 	"go-file-server/internal/services/admin/apis/role"
+	"go-file-server/internal/services/admin/apis/session"
 	"go-file-server/internal/services/admin/apis/system"
 	"go-file-server/internal/services/admin/apis/user"
+	"go-file-server/internal/services/admin/apis/webhook"
 
 	"go.uber.org/fx"
 )
@@ -26,6 +34,13 @@ var repos = fx.Options(
 		repository.NewMenuRepository,
 		repository.NewAvatarRepository,
 		repository.NewFsRepository,
+		repository.NewRoleFsAliasRepository,
+		repository.NewUserQuotaRepository,
+		repository.NewStorageQuotaRepository,
+		repository.NewJobRepository,
+		repository.NewWebhookRepository,
+		repository.NewFileAuditRepository,
+		repository.NewUserTotpRepository,
 	),
 )
 
@@ -34,12 +49,27 @@ var apis = fx.Options(
 		dept.NewDeptApi,
 		login.NewLogApi,
 		opera.NewOperaAPI,
+		audit.NewRecorder,
+		audit.NewAuditApi,
 		role.NewRoleApi,
+		twofactor.NewService,
+		loginguard.NewGuard,
 		user.NewUserAPI,
 		avatar.NewAvatarAPI,
 		menu.NewRoleApi,
+		job.NewManager,
+		webhook.NewDispatcher,
+		fs.NewThumbnailGenerator,
+		fs.NewFileEvents,
 		fs.NewFsApi,
 		system.NewSystemApi,
+		session.NewSessionApi,
+		quota.NewManager,
+		quota.NewStorageManager,
+		quota.NewQuotaApi,
+		duplicate.NewDuplicateApi,
+		job.NewJobApi,
+		webhook.NewWebhookApi,
 	),
 )
 
@@ -55,5 +85,10 @@ var Routers = fx.Options(
 		RegisterMenuRoutes,
 		RegisterFsRoutes,
 		RegisterSystemRoutes,
+		RegisterSessionRoutes,
+		RegisterQuotaRoutes,
+		RegisterDuplicateRoutes,
+		RegisterJobRoutes,
+		RegisterWebhookRoutes,
 	),
 )
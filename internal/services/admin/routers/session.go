@@ -0,0 +1,16 @@
+package routers
+
+import (
+	"go-file-server/internal/common/middlewares"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/apis/session"
+)
+
+func RegisterSessionRoutes(svc *types.SvcCtx, sessionApi *session.SessionApi) {
+	api := svc.Router.Group("/session", middlewares.OperTitle("会话管理"))
+	api.Use(middlewares.AuthCheckRole(svc))
+	{
+		api.GET("", sessionApi.Get)
+		api.DELETE("", sessionApi.Delete)
+	}
+}
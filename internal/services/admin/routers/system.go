@@ -1,12 +1,13 @@
 package routers
 
 import (
+	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/common/types"
 	"go-file-server/internal/services/admin/apis/system"
 )
 
 func RegisterSystemRoutes(svc *types.SvcCtx, systemApi *system.SystemApi) {
-	api := svc.Router.Group("/sse/")
+	api := svc.Router.Group("/sse/", middlewares.OperTitle("系统信息"))
 	{
 		api.GET("system", systemApi.GetInfo)
 	}
@@ -8,7 +8,7 @@ import (
 )
 
 func RegisterUserRoutes(svc *types.SvcCtx, userAPI *user.UserAPI) {
-	api := svc.Router.Group("/user")
+	api := svc.Router.Group("/user", middlewares.OperTitle("用户管理"))
 	{
 
 		api.PUT("pwd", userAPI.UpdatePwd)
@@ -18,14 +18,20 @@ func RegisterUserRoutes(svc *types.SvcCtx, userAPI *user.UserAPI) {
 		api.GET("access", userAPI.GetAccess)
 		api.GET("profile", userAPI.GetProfile)
 		api.GET("menu", userAPI.GetMenu)
+		api.GET("2fa", userAPI.GetTwoFactor)
+		api.POST("2fa/enroll", userAPI.EnrollTwoFactor)
+		api.POST("2fa/enable", userAPI.EnableTwoFactor)
+		api.POST("2fa/recovery-codes", userAPI.RegenerateRecoveryCodes)
+		api.DELETE("2fa", userAPI.DisableTwoFactor)
 	}
 
-	authApi := svc.Router.Group("/user").Use(middlewares.AuthCheckRole(svc))
+	authApi := svc.Router.Group("/user", middlewares.OperTitle("用户管理")).Use(middlewares.AuthCheckRole(svc))
 	{
-		authApi.POST("", userAPI.Create)
-		authApi.DELETE("", userAPI.Delete)
-		authApi.PUT("", userAPI.Update)
+		authApi.POST("", middlewares.AuditChange("user.create"), userAPI.Create)
+		authApi.DELETE("", middlewares.AuditChange("user.delete"), userAPI.Delete)
+		authApi.PUT("", middlewares.AuditChange("user.update"), userAPI.Update)
 		authApi.GET("", core.PermissionAction(svc.Db), userAPI.GetPage)
 		authApi.GET(":id", userAPI.GetInfo)
+		authApi.POST("2fa/reset", middlewares.AuditChange("user.2fa.reset"), userAPI.ResetTwoFactor)
 	}
 }
@@ -0,0 +1,21 @@
+package routers
+
+import (
+	"go-file-server/internal/common/middlewares"
+	"go-file-server/internal/common/types"
+	"go-file-server/internal/services/admin/apis/webhook"
+)
+
+func RegisterWebhookRoutes(svc *types.SvcCtx, webhookApi *webhook.WebhookApi, dispatcher *webhook.Dispatcher) {
+	authApi := svc.Router.Group("/webhooks", middlewares.OperTitle("webhook")).Use(middlewares.AuthCheckRole(svc))
+	{
+		authApi.GET("", webhookApi.GetList)
+		authApi.POST("", webhookApi.Create)
+		authApi.PUT("", webhookApi.Update)
+		authApi.DELETE("", webhookApi.Delete)
+		authApi.GET("deliveries", webhookApi.GetDeliveries)
+		authApi.POST("deliveries/:id/redeliver", webhookApi.Redeliver)
+	}
+
+	dispatcher.Start()
+}
@@ -1,7 +1,9 @@
 package auth
 
 import (
+	"go-file-server/internal/common/loginguard"
 	"go-file-server/internal/common/repository"
+	"go-file-server/internal/common/twofactor"
 	"go-file-server/pkgs/cache"
 )
 
@@ -11,6 +13,8 @@ type Authenticator struct {
 	roleRepo     *repository.RoleRepository
 	loginLogRepo *repository.LoginLogRepository
 	cache        cache.AdapterCache
+	twoFactor    *twofactor.Service
+	guard        *loginguard.Guard
 }
 
 func NewAuthenticatorApi(
@@ -19,6 +23,8 @@ func NewAuthenticatorApi(
 	loginLogRepo *repository.LoginLogRepository,
 	deptRepo *repository.DeptRepository,
 	cache cache.AdapterCache,
+	twoFactor *twofactor.Service,
+	guard *loginguard.Guard,
 ) *Authenticator {
 	return &Authenticator{
 		cache:        cache,
@@ -26,5 +32,7 @@ func NewAuthenticatorApi(
 		userRepo:     userRepo,
 		roleRepo:     roleRepo,
 		loginLogRepo: loginLogRepo,
+		twoFactor:    twoFactor,
+		guard:        guard,
 	}
 }
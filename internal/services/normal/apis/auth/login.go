@@ -7,6 +7,7 @@ import (
 	"go-file-server/internal/common/repository"
 	"go-file-server/internal/common/types"
 	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/auditlog"
 	"go-file-server/pkgs/zlog"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +16,12 @@ import (
 	"gorm.io/gorm"
 )
 
+// 登录方式
+const (
+	ProtocolWeb = "web"
+	ProtocolDex = "dex"
+)
+
 type LoginReq struct {
 	Username string `form:"UserName" json:"username" binding:"required"`
 	Password string `form:"Password" json:"password" binding:"required"`
@@ -26,6 +33,10 @@ type LoginRep struct {
 	Token        string `json:"token"`
 	RefreshToken string `json:"refresh_token"`
 	Expire       string `json:"expire"`
+	// TwoFactor 需要两步验证时不返回token，使用其中的 challenge 调用 /login/2fa
+	TwoFactor *TwoFactorRep `json:"twoFactor,omitempty"`
+	// RecoveryCodes 登录时完成两步验证登记后返回的恢复码，只返回一次
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
 }
 
 func (u *Authenticator) AuthHandler(c *gin.Context) {
@@ -38,6 +49,7 @@ func (u *Authenticator) AuthHandler(c *gin.Context) {
 		return
 	}
 
+	var user *models.SysUser
 	defer func() {
 		if err != nil {
 			status = "2"
@@ -45,11 +57,25 @@ func (u *Authenticator) AuthHandler(c *gin.Context) {
 			c.Error(err)
 		}
 		go u.RecordLogin(c, loginVals.Username, status, msg)
+		emitAuth(auditlog.ActionLogin, ProtocolWeb, loginVals.Username, user, core.GetClientIP(c), err)
 	}()
-	var user *models.SysUser
-	user, err = u.verify(loginVals)
+	user, err = u.verify(loginVals, core.GetClientIP(c))
+	if err != nil {
+		// 锁定时保留频率限制的错误码
+		var apiErr *core.ApiErr
+		if !errors.As(err, &apiErr) {
+			err = core.NewApiBizErr(err).SetMsg(err.Error()).SetBizCode(global.BizBadRequest)
+		}
+		return
+	}
+	var twoFactor *TwoFactorRep
+	twoFactor, err = u.startTwoFactor(user)
 	if err != nil {
-		err = core.NewApiBizErr(err).SetMsg(err.Error()).SetBizCode(global.BizBadRequest)
+		return
+	}
+	if twoFactor != nil {
+		msg = "web 等待两步验证"
+		core.OKRep(LoginRep{TwoFactor: twoFactor}).SendGin(c)
 		return
 	}
 	var token, expire string
@@ -65,14 +91,17 @@ func (u *Authenticator) AuthHandler(c *gin.Context) {
 	).SendGin(c)
 }
 
-func (u *Authenticator) verify(loginVals LoginReq) (*models.SysUser, error) {
+func (u *Authenticator) verify(loginVals LoginReq, ip string) (*models.SysUser, error) {
 
 	if !VerifyCaptcha(loginVals.UUID, loginVals.Code, true) {
 
 		return nil, errors.Errorf(global.ErrInvalidVerificationode)
 
 	}
-	return VerifyUser(u.userRepo, loginVals)
+	// 验证码错误时没有校验密码，不计入失败次数
+	return u.guard.Verify(ProtocolWeb, loginVals.Username, ip, func() (*models.SysUser, error) {
+		return VerifyUser(u.userRepo, loginVals)
+	})
 }
 
 func (u *Authenticator) createToken(user *models.SysUser) (string, string, error) {
@@ -125,6 +154,21 @@ func CreateToken(roleRepo *repository.RoleRepository, user *models.SysUser) (str
 	)
 }
 
+// emitAuth 发送登录事件到审计总线，登录成功时 user 不为空
+func emitAuth(action, protocol, username string, user *models.SysUser, clientIp string, err error) {
+	e := auditlog.Event{
+		Category: auditlog.CategoryAuth,
+		Action:   action,
+		Actor:    username,
+		Protocol: protocol,
+		ClientIp: clientIp,
+	}.WithErr(err)
+	if user != nil {
+		e.ActorId = user.UserId
+	}
+	auditlog.Emit(e)
+}
+
 func (u *Authenticator) RecordLogin(c *gin.Context, userName, status, msg string) {
 	ua := core.GetUserAgent(c)
 
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/global"
+	"go-file-server/internal/common/repository"
+	"go-file-server/internal/common/twofactor"
+	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/auditlog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type TwoFactorRep struct {
+	Challenge string `json:"challenge"`
+	// Enroll 角色要求两步验证但用户还未启用，先调用 /login/2fa/enroll 获取密钥
+	Enroll bool `json:"enroll"`
+}
+
+type LoginTwoFactorReq struct {
+	Challenge string `json:"challenge" binding:"required"`
+	// Code 身份验证器中的6位验证码或恢复码
+	Code string `json:"code" binding:"required"`
+}
+
+type EnrollTwoFactorReq struct {
+	Challenge string `json:"challenge" binding:"required"`
+}
+
+type EnrollTwoFactorRep struct {
+	Secret string `json:"secret"`
+	// Uri 生成二维码使用的 otpauth 地址
+	Uri string `json:"uri"`
+}
+
+// startTwoFactor 密码校验通过后，用户需要两步验证时返回挑战
+func (u *Authenticator) startTwoFactor(user *models.SysUser) (*TwoFactorRep, error) {
+	needed, enroll, err := u.twoFactor.Needed(user.UserId, user.RoleId)
+	if err != nil || !needed {
+		return nil, err
+	}
+	challenge, err := u.twoFactor.NewChallenge(user.UserId, enroll)
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorRep{Challenge: challenge, Enroll: enroll}, nil
+}
+
+// LoginTwoFactor 登录的第二步，校验通过后返回token
+func (u *Authenticator) LoginTwoFactor(c *gin.Context) {
+	var req LoginTwoFactorReq
+	if err := c.ShouldBind(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	var (
+		err      error
+		user     *models.SysUser
+		username string
+		status   = "1"
+	)
+	defer func() {
+		if err != nil {
+			status = "2"
+			c.Error(err)
+		}
+		if username != "" {
+			go u.RecordLogin(c, username, status, "web 两步验证")
+		}
+		emitAuth(auditlog.ActionLogin2fa, ProtocolWeb, username, user, core.GetClientIP(c), err)
+	}()
+
+	ch, err := u.twoFactor.GetChallenge(req.Challenge)
+	if err != nil {
+		return
+	}
+	user, err = u.userRepo.FindOne(repository.WithUserId(ch.UserId), repository.WithUserStatus("2"))
+	if err != nil {
+		user = nil
+		err = core.NewApiBizErr(errors.WithStack(err)).
+			SetBizCode(global.BizUnauthorizedErr).
+			SetMsg(global.ErrFailedAuthentication)
+		return
+	}
+	username = user.Username
+
+	// 验证码错误同样计入用户名和ip的失败次数
+	var codes []string
+	_, err = u.guard.Verify(ProtocolWeb, username, core.GetClientIP(c), func() (*models.SysUser, error) {
+		var err error
+		if ch.Enroll {
+			codes, err = u.twoFactor.Enable(ch.UserId, req.Code)
+		} else {
+			err = u.twoFactor.Verify(ch.UserId, req.Code)
+		}
+		return user, err
+	})
+	if err != nil {
+		u.twoFactor.FailChallenge(req.Challenge, ch)
+		return
+	}
+	if err = u.twoFactor.DeleteChallenge(req.Challenge); err != nil {
+		return
+	}
+	if ch.Enroll {
+		emitAuth(auditlog.ActionEnable2fa, ProtocolWeb, username, user, core.GetClientIP(c), nil)
+	}
+
+	var token, expire string
+	token, expire, err = u.createToken(user)
+	if err != nil {
+		return
+	}
+	core.OKRep(
+		LoginRep{
+			Token:         token,
+			RefreshToken:  token,
+			Expire:        expire,
+			RecoveryCodes: codes},
+	).SendGin(c)
+}
+
+// EnrollTwoFactor 角色要求两步验证的用户在登录时登记密钥
+func (u *Authenticator) EnrollTwoFactor(c *gin.Context) {
+	var req EnrollTwoFactorReq
+	if err := c.ShouldBind(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	ch, err := u.twoFactor.GetChallenge(req.Challenge)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !ch.Enroll {
+		c.Error(core.NewApiBizErr(twofactor.ErrAlreadyEnabled).
+			SetBizCode(global.BizBadRequest).
+			SetMsg(twofactor.ErrAlreadyEnabled.Error()))
+		return
+	}
+	user, err := u.userRepo.FindOne(repository.WithUserId(ch.UserId))
+	if err != nil {
+		c.Error(errors.WithStack(err))
+		return
+	}
+	secret, uri, err := u.twoFactor.Enroll(user.UserId, user.Username)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	core.OKRep(EnrollTwoFactorRep{Secret: secret, Uri: uri}).SendGin(c)
+}
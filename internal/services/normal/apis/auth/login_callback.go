@@ -5,11 +5,13 @@ import (
 	"go-file-server/internal/common/core"
 	"go-file-server/internal/common/repository"
 	"go-file-server/internal/services/admin/models"
+	"go-file-server/pkgs/auditlog"
 	"go-file-server/pkgs/config"
 	"strconv"
 
 	"github.com/coreos/go-oidc"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 	"gorm.io/gorm"
 )
 
@@ -23,19 +25,30 @@ type Claims struct {
 
 func (u *Authenticator) LoginCallback(c *gin.Context) {
 	ctx := c.Request.Context()
+	var (
+		err      error
+		username string
+		user     *models.SysUser
+	)
+	defer func() {
+		emitAuth(auditlog.ActionLogin, ProtocolDex, username, user, core.GetClientIP(c), err)
+	}()
 
 	if errMsg := c.Query("error"); errMsg != "" {
+		err = errors.New(errMsg)
 		core.ErrBizRep().SetMsg(errMsg)
 		return
 	}
 
 	if state := c.Query("state"); state != config.OAuthCfg.State {
+		err = errors.New("无效的state参数")
 		core.ErrBizRep().SetMsg("无效的state参数")
 		return
 	}
 
 	code := c.Query("code")
 	if code == "" {
+		err = errors.New("请求中没有 code")
 		core.ErrBizRep().SetMsg("请求中没有 code")
 		return
 	}
@@ -45,7 +58,8 @@ func (u *Authenticator) LoginCallback(c *gin.Context) {
 		c.Error(err)
 		return
 	}
-	user, err := u.syncUser(claims)
+	username = claims.Name
+	user, err = u.syncUser(claims)
 	if err != nil {
 		c.Error(err)
 		return
@@ -2,10 +2,25 @@ package auth
 
 import (
 	"go-file-server/internal/common/core"
+	"go-file-server/internal/common/middlewares"
+	"go-file-server/pkgs/auditlog"
 
 	"github.com/gin-gonic/gin"
 )
 
 func (u *Authenticator) Logout(c *gin.Context) {
+	// 退出接口不需要登录，只在能解析出用户时记录
+	if token, err := middlewares.GetToken(c); err == nil {
+		if claims, err := middlewares.ParseToken(token); err == nil {
+			auditlog.Emit(auditlog.Event{
+				Category: auditlog.CategoryAuth,
+				Action:   auditlog.ActionLogout,
+				Actor:    claims.Username,
+				ActorId:  claims.UserId,
+				Protocol: ProtocolWeb,
+				ClientIp: core.GetClientIP(c),
+			})
+		}
+	}
 	core.OKRep(nil).SendGin(c)
 }
@@ -1,18 +1,21 @@
 package routers
 
 import (
+	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/services/normal/apis/auth"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterAuthRoutes(r gin.IRouter, authApi *auth.Authenticator) {
+	login := r.Group("", middlewares.OperTitle("登录"))
 	{
-		r.POST("login", authApi.AuthHandler)
-		r.GET("login/dex", authApi.LoginDex)
-		r.GET("login/callback", authApi.LoginCallback)
-		r.POST("logout", authApi.Logout)
-		r.POST("oauthlogin", authApi.AuthHandler)
-
+		login.POST("login", authApi.AuthHandler)
+		login.POST("login/2fa", authApi.LoginTwoFactor)
+		login.POST("login/2fa/enroll", authApi.EnrollTwoFactor)
+		login.GET("login/dex", authApi.LoginDex)
+		login.GET("login/callback", authApi.LoginCallback)
+		login.POST("oauthlogin", authApi.AuthHandler)
 	}
+	r.POST("logout", middlewares.OperTitle("退出"), authApi.Logout)
 }
@@ -1,6 +1,7 @@
 package routers
 
 import (
+	"go-file-server/internal/common/middlewares"
 	"go-file-server/internal/services/normal/apis/captcha"
 
 	"github.com/gin-gonic/gin"
@@ -8,6 +9,6 @@ import (
 
 func RegisterCaptchaRoutes(r gin.IRouter, captchaApi *captcha.Captcha) {
 	{
-		r.GET("captcha", captchaApi.GenerateCaptchaHandler)
+		r.GET("captcha", middlewares.OperTitle("验证码"), captchaApi.GenerateCaptchaHandler)
 	}
 }
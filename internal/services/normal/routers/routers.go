@@ -1,7 +1,9 @@
 package routers
 
 import (
+	"go-file-server/internal/common/loginguard"
 	"go-file-server/internal/common/repository"
+	"go-file-server/internal/common/twofactor"
 	"go-file-server/internal/services/normal/apis/auth"
 	"go-file-server/internal/services/normal/apis/captcha"
 	"go-file-server/internal/services/normal/apis/config"
@@ -16,6 +18,7 @@ var repos = fx.Options(
 		repository.NewUserRepository,
 		repository.NewRoleRepository,
 		repository.NewDeptRepository,
+		repository.NewUserTotpRepository,
 	),
 )
 
@@ -23,6 +26,8 @@ var apis = fx.Options(
 	fx.Provide(
 		root.NewRootHandler,
 		config.NewConfigHandler,
+		twofactor.NewService,
+		loginguard.NewGuard,
 		auth.NewAuthenticatorApi,
 		captcha.NewCaptchaAPI,
 	),
@@ -0,0 +1,130 @@
+package auditlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogFormat(t *testing.T) {
+	s, err := NewSyslogSink(SyslogOptions{Addr: "127.0.0.1:514", Facility: 4, AppName: "app", Hostname: "host 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.procId = "42"
+	ts := time.Date(2024, 5, 1, 8, 30, 0, 123456789, time.UTC)
+	tests := []struct {
+		name       string
+		event      Event
+		wantHeader string
+	}{
+		{
+			name:       "success",
+			event:      Event{Time: ts, Category: CategoryAuth, Action: ActionLogin, Outcome: OutcomeSuccess, Actor: "admin", Protocol: "web"},
+			wantHeader: `<37>1 2024-05-01T08:30:00.123456Z host_1 app 42 login [audit@32473 category="auth" outcome="success" actor="admin" protocol="web"] ` + bom,
+		},
+		{
+			name:       "failure escaped",
+			event:      Event{Time: ts, Category: CategoryFile, Action: "download", Outcome: OutcomeFailure, Target: `/a"b]\c`},
+			wantHeader: `<36>1 2024-05-01T08:30:00.123456Z host_1 app 42 download [audit@32473 category="file" outcome="failure" target="/a\"b\]\\c"] ` + bom,
+		},
+		{
+			name:       "empty msgid",
+			event:      Event{Time: ts, Category: CategoryToken, Outcome: OutcomeSuccess},
+			wantHeader: `<37>1 2024-05-01T08:30:00.123456Z host_1 app 42 - [audit@32473 category="token" outcome="success"] ` + bom,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := s.format(tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header, body, ok := strings.Cut(string(msg), bom)
+			if !ok || header+bom != tt.wantHeader {
+				t.Fatalf("header = %q, want %q", header+bom, tt.wantHeader)
+			}
+			var got Event
+			if err := json.Unmarshal([]byte(body), &got); err != nil || got.Action != tt.event.Action {
+				t.Errorf("body = %s, err = %v", body, err)
+			}
+		})
+	}
+}
+
+func TestSyslogTcpFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var msgs []string
+		for len(msgs) < 2 {
+			size, err := r.ReadString(' ')
+			if err != nil {
+				break
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(size))
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				break
+			}
+			msgs = append(msgs, string(buf))
+		}
+		received <- msgs
+	}()
+
+	s, err := NewSyslogSink(SyslogOptions{Network: "tcp", Addr: ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, action := range []string{ActionLogin, ActionLogout} {
+		if err := s.Write(Event{Time: time.Now(), Category: CategoryAuth, Action: action, Outcome: OutcomeSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case msgs := <-received:
+		if len(msgs) != 2 || !strings.Contains(msgs[0], " login [") || !strings.Contains(msgs[1], " logout [") {
+			t.Errorf("msgs = %q", msgs)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestBus(t *testing.T) {
+	var buf bytes.Buffer
+	bus := NewBus(NewWriterSink("buffer", &buf))
+	bus.Emit(Event{Category: CategoryFile, Action: "upload", Target: "/a.txt"})
+	bus.Close()
+	// 关闭后的事件被丢弃
+	bus.Emit(Event{Category: CategoryFile, Action: "delete"})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("lines = %q", lines)
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(lines[0]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Outcome != OutcomeSuccess || e.Time.IsZero() || e.Target != "/a.txt" {
+		t.Errorf("event = %+v", e)
+	}
+}
@@ -0,0 +1,123 @@
+package auditlog
+
+import (
+	"go-file-server/pkgs/zlog"
+	"sync"
+	"time"
+)
+
+// queueSize 等待输出的事件数，超出后丢弃新的事件
+const queueSize = 4096
+
+// Sink 审计事件的输出，只在 Bus 的goroutine中调用
+type Sink interface {
+	Name() string
+	Write(e Event) error
+	Close() error
+}
+
+var std = NewBus()
+
+// Init 使用配置的输出替换默认的 Bus，需要在产生事件之前调用
+func Init(sinks ...Sink) {
+	std = NewBus(sinks...)
+}
+
+// Emit 发送事件到默认的 Bus
+func Emit(e Event) {
+	std.Emit(e)
+}
+
+// Close 输出剩余的事件后关闭默认的 Bus
+func Close() {
+	std.Close()
+}
+
+// Bus 审计事件总线，认证、token、权限和文件访问的事件都通过 Emit 发送，
+// 由单独的goroutine依次写入每个输出，不阻塞调用方
+type Bus struct {
+	sinks []Sink
+	queue chan Event
+	done  chan struct{}
+	// mutex 保护 closed，避免关闭后继续写入队列
+	mutex  sync.RWMutex
+	closed bool
+}
+
+// NewBus 没有输出时 Emit 直接丢弃事件
+func NewBus(sinks ...Sink) *Bus {
+	b := &Bus{
+		sinks: sinks,
+		queue: make(chan Event, queueSize),
+		done:  make(chan struct{}),
+	}
+	if len(sinks) == 0 {
+		close(b.done)
+		return b
+	}
+	go b.run()
+	return b
+}
+
+func (b *Bus) Emit(e Event) {
+	if len(b.sinks) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Outcome == "" {
+		e.Outcome = OutcomeSuccess
+	}
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if b.closed {
+		return
+	}
+	select {
+	case b.queue <- e:
+	default:
+		logf("审计事件过多，丢弃事件: %s %s %s", e.Category, e.Action, e.Actor)
+	}
+}
+
+func (b *Bus) Close() {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.queue)
+	b.mutex.Unlock()
+	<-b.done
+}
+
+func (b *Bus) run() {
+	defer close(b.done)
+	// failing 输出连续失败时只在第一次和恢复时记录日志
+	failing := make([]bool, len(b.sinks))
+	for e := range b.queue {
+		for i, s := range b.sinks {
+			err := s.Write(e)
+			if err != nil && !failing[i] {
+				logf("审计事件输出到 %s 失败: %v", s.Name(), err)
+			}
+			if err == nil && failing[i] {
+				logf("审计事件输出到 %s 已恢复", s.Name())
+			}
+			failing[i] = err != nil
+		}
+	}
+	for _, s := range b.sinks {
+		if err := s.Close(); err != nil {
+			logf("关闭审计输出 %s 失败: %v", s.Name(), err)
+		}
+	}
+}
+
+func logf(template string, args ...any) {
+	if zlog.SugLog != nil {
+		zlog.SugLog.Warnf(template, args...)
+	}
+}
@@ -0,0 +1,69 @@
+package auditlog
+
+import "time"
+
+// 事件分类
+const (
+	CategoryAuth       = "auth"
+	CategoryToken      = "token"
+	CategoryPermission = "permission"
+	CategoryFile       = "file"
+)
+
+// 事件结果
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// 认证和token相关的操作，权限和文件操作使用各自的操作名
+const (
+	ActionLogin  = "login"
+	ActionLogout = "logout"
+	// ActionLogin2fa 登录时的两步验证
+	ActionLogin2fa = "login.2fa"
+	// ActionEnable2fa、ActionDisable2fa 用户启用或关闭两步验证
+	ActionEnable2fa  = "2fa.enable"
+	ActionDisable2fa = "2fa.disable"
+	// ActionRecovery2fa 重新生成恢复码
+	ActionRecovery2fa = "2fa.recovery"
+	// ActionReset2fa 管理员重置用户的两步验证
+	ActionReset2fa = "2fa.reset"
+	// ActionLockout、ActionUnlock 连续登录失败后锁定，管理员解除锁定
+	ActionLockout = "login.lockout"
+	ActionUnlock  = "login.unlock"
+	// ActionTokenCreate 生成个人token
+	ActionTokenCreate = "token.create"
+	// ActionTokenRevoke 删除个人token
+	ActionTokenRevoke = "token.revoke"
+	// ActionTokenReset 修改密码或角色后用户之前签发的全部token失效
+	ActionTokenReset = "token.reset"
+)
+
+// Event 一条审计事件，输出到全部的 Sink
+type Event struct {
+	Time     time.Time `json:"time"`
+	Category string    `json:"category"`
+	Action   string    `json:"action"`
+	Outcome  string    `json:"outcome"`
+	Actor    string    `json:"actor,omitempty"`
+	ActorId  int       `json:"actorId,omitempty"`
+	// Protocol 登录或访问的方式，如 web、dex、ftp、http
+	Protocol string `json:"protocol,omitempty"`
+	ClientIp string `json:"clientIp,omitempty"`
+	// Target 操作的对象，如文件路径、用户名或角色
+	Target string `json:"target,omitempty"`
+	// Reason 失败的原因
+	Reason  string         `json:"reason,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// WithErr 根据 err 设置事件结果
+func (e Event) WithErr(err error) Event {
+	e.Outcome = OutcomeSuccess
+	if err != nil {
+		e.Outcome = OutcomeFailure
+		e.Reason = err.Error()
+	}
+	return e
+}
@@ -0,0 +1,212 @@
+package auditlog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// syslogTimeout 连接和每次写入的超时时间
+	syslogTimeout = 5 * time.Second
+	// sdId 结构化数据的id，32473 是 RFC 5612 中用于示例的企业编号
+	sdId = "audit@32473"
+	// timeFormat RFC 5424 的时间格式，小数秒最多6位
+	timeFormat = "2006-01-02T15:04:05.000000Z07:00"
+	// bom RFC 5424 中 utf-8 编码的 MSG 以 BOM 开头
+	bom = "\xef\xbb\xbf"
+)
+
+// 严重级别，成功的事件为 notice，失败的事件为 warning
+const (
+	severityWarning = 4
+	severityNotice  = 5
+)
+
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// ParseFacility 解析设施名称，为空时使用 local0
+func ParseFacility(name string) (int, error) {
+	if name == "" {
+		return facilities["local0"], nil
+	}
+	f, ok := facilities[strings.ToLower(name)]
+	if !ok {
+		return 0, errors.Errorf("不支持的 syslog facility: %s", name)
+	}
+	return f, nil
+}
+
+type SyslogOptions struct {
+	// Network udp、tcp 或 tls
+	Network string
+	Addr    string
+	// TLSConfig Network 为 tls 时使用
+	TLSConfig *tls.Config
+	Facility  int
+	AppName   string
+	// Hostname 为空时使用本机名称
+	Hostname string
+}
+
+// SyslogSink 按 RFC 5424 格式发送到 syslog 服务，tcp 和 tls 使用 RFC 6587 的长度前缀分帧。
+// 连接在第一次写入时建立，断开后下次写入时重连
+type SyslogSink struct {
+	opts   SyslogOptions
+	procId string
+	mutex  sync.Mutex
+	conn   net.Conn
+}
+
+func NewSyslogSink(opts SyslogOptions) (*SyslogSink, error) {
+	switch opts.Network {
+	case "":
+		opts.Network = "udp"
+	case "udp", "tcp", "tls":
+	default:
+		return nil, errors.Errorf("不支持的 syslog 协议: %s", opts.Network)
+	}
+	if _, _, err := net.SplitHostPort(opts.Addr); err != nil {
+		return nil, errors.Wrapf(err, "无效的 syslog 地址: %s", opts.Addr)
+	}
+	if opts.Network == "tls" && opts.TLSConfig == nil {
+		opts.TLSConfig = &tls.Config{}
+	}
+	if opts.AppName == "" {
+		opts.AppName = "go-file-server"
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	return &SyslogSink{opts: opts, procId: strconv.Itoa(os.Getpid())}, nil
+}
+
+func (s *SyslogSink) Name() string {
+	return "syslog:" + s.opts.Network + "://" + s.opts.Addr
+}
+
+// Write 写入失败时重连后再试一次
+func (s *SyslogSink) Write(e Event) error {
+	msg, err := s.format(e)
+	if err != nil {
+		return err
+	}
+	if s.opts.Network != "udp" {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err = s.write(msg); err != nil {
+		err = s.write(msg)
+	}
+	return err
+}
+
+func (s *SyslogSink) write(msg []byte) error {
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+	if _, err := s.conn.Write(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogTimeout}
+	if s.opts.Network == "tls" {
+		conn, err := tls.DialWithDialer(dialer, "tcp", s.opts.Addr, s.opts.TLSConfig)
+		return conn, errors.WithStack(err)
+	}
+	conn, err := dialer.Dial(s.opts.Network, s.opts.Addr)
+	return conn, errors.WithStack(err)
+}
+
+func (s *SyslogSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// format 生成 <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG，MSG 为事件的json
+func (s *SyslogSink) format(e Event) ([]byte, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	severity := severityNotice
+	if e.Outcome == OutcomeFailure {
+		severity = severityWarning
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s %s ",
+		s.opts.Facility*8+severity,
+		e.Time.Format(timeFormat),
+		headerField(s.opts.Hostname, 255),
+		headerField(s.opts.AppName, 48),
+		headerField(s.procId, 128),
+		headerField(e.Action, 32),
+	)
+	buf.WriteString("[" + sdId)
+	for _, p := range [][2]string{
+		{"category", e.Category},
+		{"outcome", e.Outcome},
+		{"actor", e.Actor},
+		{"protocol", e.Protocol},
+		{"clientIp", e.ClientIp},
+		{"target", e.Target},
+	} {
+		if p[1] != "" {
+			fmt.Fprintf(&buf, ` %s="%s"`, p[0], sdEscaper.Replace(p[1]))
+		}
+	}
+	buf.WriteString("] " + bom)
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+// sdEscaper 结构化数据的参数值中需要转义 " \ ]
+var sdEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+// headerField 头部字段只能是可打印的ascii字符，为空时使用 -
+func headerField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+package auditlog
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/natefinch/lumberjack"
+	"github.com/pkg/errors"
+)
+
+// WriterSink 每个事件输出一行json
+type WriterSink struct {
+	name string
+	w    io.Writer
+	enc  *json.Encoder
+}
+
+func NewWriterSink(name string, w io.Writer) *WriterSink {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &WriterSink{name: name, w: w, enc: enc}
+}
+
+// NewStdoutSink 输出到标准输出，适合容器中由日志采集器收集
+func NewStdoutSink() *WriterSink {
+	return NewWriterSink("stdout", os.Stdout)
+}
+
+// FileOptions 按大小轮转的json行文件，与 zlog 的日志文件相同
+type FileOptions struct {
+	Path string
+	// MaxSize 单个文件的大小，单位MB
+	MaxSize    int
+	MaxBackups int
+	// MaxAge 轮转后的文件保留天数
+	MaxAge   int
+	Compress bool
+}
+
+func NewFileSink(opts FileOptions) (*WriterSink, error) {
+	if opts.Path == "" {
+		return nil, errors.New("审计文件路径不能为空")
+	}
+	return NewWriterSink("file:"+opts.Path, &lumberjack.Logger{
+		Filename:   opts.Path,
+		MaxSize:    opts.MaxSize,
+		MaxBackups: opts.MaxBackups,
+		MaxAge:     opts.MaxAge,
+		Compress:   opts.Compress,
+		LocalTime:  true,
+	}), nil
+}
+
+func (s *WriterSink) Name() string {
+	return s.name
+}
+
+func (s *WriterSink) Write(e Event) error {
+	return errors.WithStack(s.enc.Encode(e))
+}
+
+// Close 不关闭标准输出
+func (s *WriterSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok && s.w != os.Stdout {
+		return c.Close()
+	}
+	return nil
+}
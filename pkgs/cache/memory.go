@@ -74,23 +74,32 @@ func (m *Memory) Decrease(key string) error {
 	return m.changeValue(key, -1)
 }
 
-func (m *Memory) changeValue(key string, delta int) error {
+func (m *Memory) IncreaseBy(key string, delta int64) error {
+	return m.changeValue(key, delta)
+}
+
+// changeValue 修改计数，保留key原有的过期时间
+func (m *Memory) changeValue(key string, delta int64) error {
 	m.Lock()
 	defer m.Unlock()
 
-	v, ok := m.Cache.Get(key)
+	v, expiration, ok := m.Cache.GetWithExpiration(key)
 	if !ok {
-		m.Cache.Set(key, strconv.Itoa(delta), cache.NoExpiration)
+		m.Cache.Set(key, strconv.FormatInt(delta, 10), cache.NoExpiration)
 		return nil
 	}
 
-	currentVal, err := strconv.Atoi(v.(string))
+	currentVal, err := strconv.ParseInt(v.(string), 10, 64)
 	if err != nil {
 		return err
 	}
 
 	newVal := currentVal + delta
-	m.Cache.Set(key, strconv.Itoa(newVal), cache.NoExpiration)
+	ttl := cache.NoExpiration
+	if !expiration.IsZero() {
+		ttl = time.Until(expiration)
+	}
+	m.Cache.Set(key, strconv.FormatInt(newVal, 10), ttl)
 	return nil
 }
 
@@ -70,6 +70,10 @@ func (r *Redis) Increase(key string) error {
 	return r.client.Incr(context.TODO(), key).Err()
 }
 
+func (r *Redis) IncreaseBy(key string, delta int64) error {
+	return r.client.IncrBy(context.TODO(), key, delta).Err()
+}
+
 func (r *Redis) Decrease(key string) error {
 	return r.client.Decr(context.TODO(), key).Err()
 }
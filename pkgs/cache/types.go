@@ -13,6 +13,7 @@ type AdapterCache interface {
 	HashGet(hk, key string) (string, error)
 	HashDel(hk, key string) error
 	Increase(key string) error
+	IncreaseBy(key string, delta int64) error
 	Decrease(key string) error
 	Expire(key string, dur time.Duration) error
 	GetClient() any
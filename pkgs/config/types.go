@@ -12,14 +12,110 @@ type Config struct {
 }
 
 type Application struct {
-	Host    string
-	Port    string
-	Basedir string
+	Host      string
+	Port      string
+	Basedir   string
+	Mounts    []Mount   `mapstructure:"mounts"`
+	Unarchive Unarchive `mapstructure:"unarchive"`
+	Audit     Audit     `mapstructure:"audit"`
+	Lockout   Lockout   `mapstructure:"lockout"`
+	// TrustedProxies 反向代理的ip或网段，只有来自这些地址的请求才使用 X-Forwarded-For 和 X-Real-Ip 作为客户端ip，
+	// 不配置时只信任本机
+	TrustedProxies []string `mapstructure:"trustedProxies"`
+}
+
+// Lockout 登录失败的次数限制，web 和 ftp 共用。0 或不配置时使用默认值，次数和延迟小于0时关闭对应的限制
+type Lockout struct {
+	// MaxFailures 同一用户名在 WindowMinutes 内失败的次数，达到后锁定该用户名
+	MaxFailures int `mapstructure:"maxFailures"`
+	// MaxIpFailures 同一ip在 WindowMinutes 内失败的次数，达到后锁定该ip
+	MaxIpFailures int `mapstructure:"maxIpFailures"`
+	// WindowMinutes 统计失败次数的时间范围
+	WindowMinutes int `mapstructure:"windowMinutes"`
+	// LockMinutes 锁定时长
+	LockMinutes int `mapstructure:"lockMinutes"`
+	// MaxDelaySeconds 每次失败后下一次校验的延迟翻倍，从1秒开始，不超过该值
+	MaxDelaySeconds int `mapstructure:"maxDelaySeconds"`
+}
+
+// Audit 文件访问审计
+type Audit struct {
+	// RetentionDays 审计记录保留天数，0 表示使用默认值，小于0时不清理
+	RetentionDays int `mapstructure:"retentionDays"`
+	// Sinks 登录、token、权限修改和文件访问事件的输出，为空时只在数据库中记录文件访问
+	Sinks []AuditSink `mapstructure:"sinks"`
+}
+
+// AuditSink 审计事件的输出
+type AuditSink struct {
+	// Type syslog、file 或 stdout
+	Type string `mapstructure:"type"`
+	// Network syslog 使用的协议 udp、tcp 或 tls，默认 udp
+	Network string `mapstructure:"network"`
+	// Addr syslog 服务地址 host:port
+	Addr string `mapstructure:"addr"`
+	// Facility syslog 设施，默认 local0
+	Facility string `mapstructure:"facility"`
+	// AppName syslog 中的应用名称，默认 go-file-server
+	AppName string `mapstructure:"appName"`
+	// CaFile 校验 syslog 服务证书的 ca，为空时使用系统证书
+	CaFile string `mapstructure:"caFile"`
+	// CertFile、KeyFile 客户端证书，服务端要求双向认证时配置
+	CertFile           string `mapstructure:"certFile"`
+	KeyFile            string `mapstructure:"keyFile"`
+	InsecureSkipVerify bool   `mapstructure:"insecureSkipVerify"`
+	// Path file 类型输出的文件路径
+	Path string `mapstructure:"path"`
+	// MaxSize 单个文件的大小，单位MB，默认100
+	MaxSize int `mapstructure:"maxSize"`
+	// MaxBackups 保留的轮转文件数，0 表示全部保留
+	MaxBackups int `mapstructure:"maxBackups"`
+	// MaxAge 轮转文件的保留天数，0 表示不按时间删除
+	MaxAge   int  `mapstructure:"maxAge"`
+	Compress bool `mapstructure:"compress"`
+}
+
+// Unarchive 解压限制，0 表示使用默认值
+type Unarchive struct {
+	// MaxSize 单次解压的最大字节数
+	MaxSize int64 `mapstructure:"maxSize"`
+	// MaxEntries 单次解压的最大条目数
+	MaxEntries int `mapstructure:"maxEntries"`
+}
+
+// Mount 挂载点，在根目录下以 Name 作为虚拟目录暴露 Path
+type Mount struct {
+	Name     string `mapstructure:"name"`
+	Path     string `mapstructure:"path"`
+	ReadOnly bool   `mapstructure:"readOnly"`
+	Index    bool   `mapstructure:"index"`
+	Watch    bool   `mapstructure:"watch"`
+	Trash    string `mapstructure:"trash"`
 }
 
 type Logger struct {
 	Path  string
 	Level string
+	// Retention 操作日志和登录日志的保留和归档
+	Retention LogRetention `mapstructure:"retention"`
+	// Exclude 匹配的请求不记录操作日志
+	Exclude []LogExclude `mapstructure:"exclude"`
+}
+
+// LogRetention 保留天数小于等于0或不配置时不清理
+type LogRetention struct {
+	OperaDays int `mapstructure:"operaDays"`
+	LoginDays int `mapstructure:"loginDays"`
+	// ArchiveDir 过期的日志先压缩保存到该目录再删除，为空时直接删除
+	ArchiveDir string `mapstructure:"archiveDir"`
+}
+
+// LogExclude 操作日志的排除规则
+type LogExclude struct {
+	// Path 请求路径，支持 path.Match 的通配符，以 /** 结尾时匹配该路径下的全部请求
+	Path string `mapstructure:"path"`
+	// Methods 为空时匹配全部请求方法
+	Methods []string `mapstructure:"methods"`
 }
 
 type Jwt struct {
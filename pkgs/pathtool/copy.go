@@ -0,0 +1,159 @@
+package pathtool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Move 移动文件或目录，源和目标不在同一设备时(EXDEV)退化为复制后删除
+func Move(src, des string) error {
+	err := os.Rename(src, des)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := CopyAll(src, des); err != nil {
+		os.RemoveAll(des)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// CopyAll 递归复制文件或目录，保留权限和修改时间，目标已存在时返回 os.ErrExist
+func CopyAll(src, des string) error {
+	return CopyAllWithContext(context.Background(), src, des, nil)
+}
+
+// CopyAllWithContext 同 CopyAll，每复制一个条目前检查ctx是否取消，
+// 复制完一个文件后调用 onCopied，参数为源路径和文件大小
+func CopyAllWithContext(ctx context.Context, src, des string, onCopied func(path string, size int64)) error {
+	if _, err := os.Lstat(des); err == nil {
+		return os.ErrExist
+	}
+	return copyTree(ctx, src, des, false, onCopied)
+}
+
+// ResumeCopyWithContext 继续之前中断的复制，des 可以已经存在。复制完文件后才会设置修改时间，
+// 大小和修改时间与源文件一致的文件视为已经复制完成并跳过，其他已存在的文件重新复制
+func ResumeCopyWithContext(ctx context.Context, src, des string, onCopied func(path string, size int64)) error {
+	return copyTree(ctx, src, des, true, onCopied)
+}
+
+// copyDirPerm 复制过程中目录的权限，只读的源目录复制完子条目后才设置原来的权限
+const copyDirPerm = 0700
+
+type copiedDir struct {
+	path  string
+	perm  fs.FileMode
+	mtime time.Time
+}
+
+func copyTree(ctx context.Context, src, des string, resume bool, onCopied func(path string, size int64)) error {
+	var dirs []copiedDir
+	err := filepath.Walk(src, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(des, rel)
+		switch {
+		case info.IsDir():
+			if err := os.MkdirAll(target, copyDirPerm); err != nil {
+				return err
+			}
+			// 继续复制时目录可能已经存在
+			if err := os.Chmod(target, copyDirPerm); err != nil {
+				return err
+			}
+			// 复制子条目会改变目录的修改时间，权限和修改时间在全部复制完成后设置
+			dirs = append(dirs, copiedDir{target, info.Mode().Perm(), info.ModTime()})
+			return nil
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if resume {
+				if err := removeExisting(target); err != nil {
+					return err
+				}
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			if resume {
+				if ti, err := os.Lstat(target); err == nil && ti.Mode().IsRegular() &&
+					ti.Size() == info.Size() && ti.ModTime().Equal(info.ModTime()) {
+					if onCopied != nil {
+						onCopied(path, info.Size())
+					}
+					return nil
+				}
+				if err := removeExisting(target); err != nil {
+					return err
+				}
+			}
+			if err := CopyFile(path, target, info.Mode().Perm()); err != nil {
+				return err
+			}
+			if onCopied != nil {
+				onCopied(path, info.Size())
+			}
+		default:
+			return nil
+		}
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+	if err != nil {
+		return err
+	}
+	// 先处理子目录，设置父目录时子目录已经不会再改变
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := os.Chmod(d.path, d.perm); err != nil {
+			return err
+		}
+		if err := os.Chtimes(d.path, d.mtime, d.mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeExisting(path string) error {
+	if err := os.RemoveAll(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// CopyFile 复制单个文件，目标文件存在时返回错误
+func CopyFile(src, des string, perm os.FileMode) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(des, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,110 @@
+package pathtool
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResumeCopy(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	des := filepath.Join(root, "des")
+	files := map[string]string{
+		"done.txt":    "finished",
+		"partial.txt": "complete content",
+		"new.txt":     "not copied yet",
+	}
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, dir := range []string{src, des} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		p := filepath.Join(src, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 上次复制完成的文件设置了修改时间，中断的文件内容不完整
+	done := filepath.Join(des, "done.txt")
+	if err := os.WriteFile(done, []byte("finished"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(done, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(des, "partial.txt"), []byte("compl"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var copied int64
+	err := ResumeCopyWithContext(context.Background(), src, des, func(path string, size int64) {
+		copied += size
+	})
+	if err != nil {
+		t.Fatalf("ResumeCopyWithContext() error = %v", err)
+	}
+	for name, content := range files {
+		if b, _ := os.ReadFile(filepath.Join(des, name)); string(b) != content {
+			t.Errorf("%s = %q, want %q", name, b, content)
+		}
+	}
+	if want := int64(len("finished") + len("complete content") + len("not copied yet")); copied != want {
+		t.Errorf("copied = %d, want %d", copied, want)
+	}
+}
+
+func TestCopyAllDirMode(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	des := filepath.Join(root, "des")
+	sub := filepath.Join(src, "readonly")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, dir := range []string{sub, src} {
+		if err := os.Chmod(dir, 0555); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(dir, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 只读目录下的文件需要恢复权限后 TempDir 才能删除
+	t.Cleanup(func() {
+		for _, dir := range []string{src, sub, des, filepath.Join(des, "readonly")} {
+			os.Chmod(dir, 0755)
+		}
+	})
+
+	if err := CopyAll(src, des); err != nil {
+		t.Fatalf("CopyAll() error = %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(des, "readonly", "a.txt")); string(b) != "a" {
+		t.Errorf("a.txt = %q, want %q", b, "a")
+	}
+	for _, dir := range []string{des, filepath.Join(des, "readonly")} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0555 {
+			t.Errorf("%s mode = %v, want %v", dir, info.Mode().Perm(), fs.FileMode(0555))
+		}
+		if !info.ModTime().Equal(mtime) {
+			t.Errorf("%s mtime = %v, want %v", dir, info.ModTime(), mtime)
+		}
+	}
+}
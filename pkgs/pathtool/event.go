@@ -0,0 +1,95 @@
+package pathtool
+
+import (
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// EventOp 归一化后的文件变化类型
+type EventOp string
+
+const (
+	EventCreated  EventOp = "created"
+	EventDeleted  EventOp = "deleted"
+	EventRenamed  EventOp = "renamed"
+	EventModified EventOp = "modified"
+)
+
+// renameWindow fsnotify 将重命名拆分为旧路径的 Rename 和新路径的 Create，
+// Rename 之后该时间内紧接着的 Create 视为重命名，否则视为删除(移动到了监听范围之外)
+const renameWindow = 100 * time.Millisecond
+
+// Event 文件变化事件，路径为真实路径
+type Event struct {
+	Op   EventOp
+	Path string
+	// OldPath 重命名前的路径
+	OldPath string
+}
+
+// EventListener 文件变化的监听，在索引的锁内调用，不能阻塞
+type EventListener func(Event)
+
+type pendingRename struct {
+	path  string
+	timer *time.Timer
+}
+
+// OnEvent 注册归一化后的文件变化监听，只有开启了监听的目录才会触发
+func (fi *FileIndexer) OnEvent(fn EventListener) {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.eventListeners = append(fi.eventListeners, fn)
+}
+
+// emitEvent 持有锁时调用，将 fsnotify 的事件转换为 Event
+func (fi *FileIndexer) emitEvent(event fsnotify.Event) {
+	if len(fi.eventListeners) == 0 {
+		return
+	}
+	pending := fi.pendingRename
+	fi.pendingRename = nil
+	if pending != nil {
+		// 定时器已经触发时回调会因为 pendingRename 已变化而忽略
+		pending.timer.Stop()
+	}
+	switch {
+	case event.Has(fsnotify.Create):
+		if pending != nil {
+			fi.notifyEvent(Event{Op: EventRenamed, Path: event.Name, OldPath: pending.path})
+			return
+		}
+		fi.notifyEvent(Event{Op: EventCreated, Path: event.Name})
+		return
+	}
+	if pending != nil {
+		fi.notifyEvent(Event{Op: EventDeleted, Path: pending.path})
+	}
+	switch {
+	case event.Has(fsnotify.Rename):
+		p := &pendingRename{path: event.Name}
+		p.timer = time.AfterFunc(renameWindow, func() {
+			fi.mutex.Lock()
+			defer fi.mutex.Unlock()
+			if fi.pendingRename == p {
+				fi.pendingRename = nil
+				fi.notifyEvent(Event{Op: EventDeleted, Path: p.path})
+			}
+		})
+		fi.pendingRename = p
+	case event.Has(fsnotify.Remove):
+		fi.notifyEvent(Event{Op: EventDeleted, Path: event.Name})
+	case event.Has(fsnotify.Write):
+		fi.notifyEvent(Event{Op: EventModified, Path: event.Name})
+	}
+}
+
+func (fi *FileIndexer) notifyEvent(e Event) {
+	if e.Path == "" || fi.IsSkippePath(e.Path) {
+		return
+	}
+	for _, fn := range fi.eventListeners {
+		fn(e)
+	}
+}
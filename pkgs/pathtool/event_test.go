@@ -0,0 +1,73 @@
+package pathtool
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestEmitEvent(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []fsnotify.Event
+		want   []Event
+	}{
+		{
+			name:   "创建",
+			events: []fsnotify.Event{{Name: "/data/a", Op: fsnotify.Create}},
+			want:   []Event{{Op: EventCreated, Path: "/data/a"}},
+		},
+		{
+			name: "重命名",
+			events: []fsnotify.Event{
+				{Name: "/data/a", Op: fsnotify.Rename},
+				{Name: "/data/b", Op: fsnotify.Create},
+			},
+			want: []Event{{Op: EventRenamed, Path: "/data/b", OldPath: "/data/a"}},
+		},
+		{
+			name: "移出监听目录",
+			events: []fsnotify.Event{
+				{Name: "/data/a", Op: fsnotify.Rename},
+				{Name: "/data/c", Op: fsnotify.Write},
+			},
+			want: []Event{{Op: EventDeleted, Path: "/data/a"}, {Op: EventModified, Path: "/data/c"}},
+		},
+		{
+			name:   "重命名超时",
+			events: []fsnotify.Event{{Name: "/data/a", Op: fsnotify.Rename}},
+			want:   []Event{{Op: EventDeleted, Path: "/data/a"}},
+		},
+		{
+			name:   "忽略监听范围外",
+			events: []fsnotify.Event{{Name: "/tmp/a", Op: fsnotify.Remove}},
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got []Event
+			fi := &FileIndexer{WatchedRootDir: "/data", enableWatch: true}
+			fi.OnEvent(func(e Event) {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, e)
+			})
+			for _, e := range tt.events {
+				fi.mutex.Lock()
+				fi.emitEvent(e)
+				fi.mutex.Unlock()
+			}
+			time.Sleep(2 * renameWindow)
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -40,3 +40,26 @@ func TestMvproject(t *testing.T) {
 		})
 	}
 }
+
+func TestIsSubPath(t *testing.T) {
+	tests := []struct {
+		parent string
+		path   string
+		want   bool
+	}{
+		{"/data", "/data", true},
+		{"/data", "/data/a.txt", true},
+		{"/data/", "/data/a.txt", true},
+		{"/data", "/data/a/", true},
+		{"/data", "/database", false},
+		{"/data/a", "/data", false},
+		{"/", "/data", true},
+		{"/", "/", true},
+		{"/data", "/data/../etc", false},
+	}
+	for _, tt := range tests {
+		if got := IsSubPath(tt.parent, tt.path); got != tt.want {
+			t.Errorf("IsSubPath(%q, %q) = %v, want %v", tt.parent, tt.path, got, tt.want)
+		}
+	}
+}
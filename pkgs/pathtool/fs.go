@@ -286,3 +286,17 @@ func (f *Filetool) getfiletype() string {
 	}
 	return contentType.Extension
 }
+
+// IsSubPath 判断 path 是否为 parent 或位于 parent 下，两个路径先经过 filepath.Clean，
+// 结尾的分隔符不影响结果，parent 为根目录时包含全部绝对路径
+func IsSubPath(parent, path string) bool {
+	parent = filepath.Clean(parent)
+	path = filepath.Clean(path)
+	if path == parent {
+		return true
+	}
+	if !strings.HasSuffix(parent, string(filepath.Separator)) {
+		parent += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, parent)
+}
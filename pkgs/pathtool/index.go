@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/blevesearch/bleve/v2"
@@ -24,10 +25,14 @@ var fields = []struct {
 	{Name: "ParentPath", Mapping: bleve.NewTextFieldMapping(), Analyzer: "keyword"},
 	{Name: "Name", Mapping: bleve.NewTextFieldMapping(), Analyzer: "keyword"},
 	{Name: "IsDir", Mapping: bleve.NewBooleanFieldMapping()},
+	{Name: "Size", Mapping: bleve.NewNumericFieldMapping()},
 }
 
 type UpdateCallback func(*FileIndexer)
 
+// ChangeListener 监听到文件被修改、删除或重命名时调用，参数为变化的路径
+type ChangeListener func(path string)
+
 type storageType int
 
 const (
@@ -35,22 +40,39 @@ const (
 	UseDisk
 )
 
+// IndexRoot 额外的索引根目录
+type IndexRoot struct {
+	Path  string
+	Watch bool
+}
+
 type FileIndexer struct {
 	storageType    storageType
 	IndexPath      string
 	Index          bleve.Index
 	WatchedRootDir string
+	extraRoots     []IndexRoot
 	Logger         *zap.SugaredLogger
 	mutex          sync.RWMutex
 	enableWatch    bool
 	watcher        *fsnotify.Watcher
 	updateCallback UpdateCallback
+	// version 索引每次变更时递增，用于判断基于索引的统计结果是否需要重新计算
+	version   atomic.Uint64
+	listeners []ChangeListener
+	// indexListeners 索引条目变化的监听，用于增量更新基于索引的统计
+	indexListeners []IndexListener
+	// eventListeners 归一化后的文件变化监听，见 event.go
+	eventListeners []EventListener
+	pendingRename  *pendingRename
 }
 type FileDocument struct {
 	Name       string
 	Path       string
 	ParentPath string
 	IsDir      bool
+	// Size 文件大小，文件夹为0，用于统计目录占用空间
+	Size int64
 }
 
 type Opt func(*FileIndexer)
@@ -67,6 +89,13 @@ func WithEnableWatch(b bool) Opt {
 	}
 }
 
+// WithExtraRoot 添加额外的索引根目录，watch 控制是否监听该目录的文件变化
+func WithExtraRoot(path string, watch bool) Opt {
+	return func(fi *FileIndexer) {
+		fi.extraRoots = append(fi.extraRoots, IndexRoot{Path: filepath.Clean(path), Watch: watch})
+	}
+}
+
 func WithLog(log *zap.SugaredLogger) Opt {
 	return func(fi *FileIndexer) {
 		fi.Logger = log
@@ -91,7 +120,7 @@ func NewFileIndexer(path string, opts ...Opt) (*FileIndexer, error) {
 
 	fileIndexer := &FileIndexer{
 		storageType:    UseMem,
-		WatchedRootDir: path,
+		WatchedRootDir: filepath.Clean(path),
 	}
 	for _, o := range opts {
 		o(fileIndexer)
@@ -112,15 +141,16 @@ func NewFileIndexer(path string, opts ...Opt) (*FileIndexer, error) {
 	}
 	fileIndexer.IndexPath = filepath.Join(fileIndexer.IndexPath, ".bleve.index")
 
-	//初始化索引文档
-	if err := fileIndexer.IndexInit(); err != nil {
-		return nil, errors.Wrap(err, "索引初始化失败")
-	}
-	if fileIndexer.enableWatch {
+	if fileIndexer.needWatch() {
 		if err := fileIndexer.initWatch(); err != nil {
 			return nil, err
 		}
 	}
+
+	//初始化索引文档
+	if err := fileIndexer.IndexInit(); err != nil {
+		return nil, errors.Wrap(err, "索引初始化失败")
+	}
 	return fileIndexer, nil
 }
 
@@ -141,10 +171,17 @@ func (fi *FileIndexer) IndexInit() error {
 		return err
 	}
 	fi.Index = index
+	fi.version.Add(1)
+	fi.notifyIndex(IndexChange{Reset: true})
 	//添加条目
 	if err := fi.addResource(fi.WatchedRootDir); err != nil {
 		return err
 	}
+	for _, root := range fi.extraRoots {
+		if err := fi.addResource(root.Path); err != nil {
+			fi.Logger.Errorf("索引目录 %s 失败: %v", root.Path, err)
+		}
+	}
 	go fi.printDocCount()
 	return nil
 }
@@ -197,7 +234,41 @@ func (fi *FileIndexer) Search(req *bleve.SearchRequest) (*bleve.SearchResult, er
 }
 
 func (fi *FileIndexer) IsSkippePath(path string) bool {
-	return fi.storageType == UseDisk && strings.HasPrefix(path, fi.IndexPath)
+	if fi.storageType == UseDisk && strings.HasPrefix(path, fi.IndexPath) {
+		return true
+	}
+	_, ok := fi.getRoot(path)
+	return !ok
+}
+
+// getRoot 返回路径所属的索引根目录
+func (fi *FileIndexer) getRoot(path string) (IndexRoot, bool) {
+	for _, root := range fi.extraRoots {
+		if IsSubPath(root.Path, path) {
+			return root, true
+		}
+	}
+	if IsSubPath(fi.WatchedRootDir, path) {
+		return IndexRoot{Path: fi.WatchedRootDir, Watch: fi.enableWatch}, true
+	}
+	return IndexRoot{}, false
+}
+
+func (fi *FileIndexer) isRoot(path string) bool {
+	root, ok := fi.getRoot(path)
+	return ok && root.Path == path
+}
+
+func (fi *FileIndexer) needWatch() bool {
+	if fi.enableWatch {
+		return true
+	}
+	for _, root := range fi.extraRoots {
+		if root.Watch {
+			return true
+		}
+	}
+	return false
 }
 
 func (fi *FileIndexer) DelResource(path string) error {
@@ -207,7 +278,25 @@ func (fi *FileIndexer) DelResource(path string) error {
 }
 
 func (fi *FileIndexer) delResource(path string) error {
-	return fi.Index.Delete(path)
+	old, ok := fi.lookup(path)
+	fi.version.Add(1)
+	if err := fi.Index.Delete(path); err != nil {
+		return err
+	}
+	switch {
+	case !ok:
+	case old.IsDir:
+		// 目录下的条目不一定同时删除，无法计算增量
+		fi.notifyIndex(IndexChange{Path: path, Reset: true})
+	default:
+		fi.notifyIndex(IndexChange{Path: path, Size: -old.Size, Files: -1})
+	}
+	return nil
+}
+
+// Version 返回索引的版本号，索引内容变更后版本号会变化
+func (fi *FileIndexer) Version() uint64 {
+	return fi.version.Load()
 }
 
 func (fi *FileIndexer) AddResource(path string) error {
@@ -225,15 +314,51 @@ func (fi *FileIndexer) addResource(path string) error {
 		return err
 	}
 	if !info.IsDir() {
-		return fi.Index.Index(path, buildDoc(path, info))
+		return fi.indexFile(path, info)
 	}
+	fi.version.Add(1)
 	fi.addDirResource(path)
+	fi.notifyIndex(IndexChange{Path: path, Reset: true})
 	if fi.updateCallback != nil {
 		go fi.updateCallback(fi)
 	}
 	return nil
 }
 
+// indexFile 索引一个文件，按索引中原来的大小计算增量
+func (fi *FileIndexer) indexFile(path string, info os.FileInfo) error {
+	old, ok := fi.lookup(path)
+	fi.version.Add(1)
+	doc := buildDoc(path, info)
+	if err := fi.Index.Index(path, doc); err != nil {
+		return err
+	}
+	switch {
+	case !ok:
+		fi.notifyIndex(IndexChange{Path: path, Size: doc.Size, Files: 1})
+	case old.IsDir:
+		fi.notifyIndex(IndexChange{Path: path, Reset: true})
+	default:
+		fi.notifyIndex(IndexChange{Path: path, Size: doc.Size - old.Size})
+	}
+	return nil
+}
+
+// lookup 返回索引中的条目，调用方需要持有锁
+func (fi *FileIndexer) lookup(path string) (FileDocument, bool) {
+	req := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{path}))
+	req.Fields = []string{"IsDir", "Size"}
+	results, err := fi.Index.Search(req)
+	if err != nil || len(results.Hits) == 0 {
+		return FileDocument{}, false
+	}
+	doc := FileDocument{Path: path}
+	doc.IsDir, _ = results.Hits[0].Fields["IsDir"].(bool)
+	size, _ := results.Hits[0].Fields["Size"].(float64)
+	doc.Size = int64(size)
+	return doc, true
+}
+
 func (fi *FileIndexer) addDirResource(path string) {
 
 	batch := fi.Index.NewBatch()
@@ -248,10 +373,12 @@ func (fi *FileIndexer) addDirResource(path string) {
 			return nil
 		}
 
-		if fi.enableWatch && info.IsDir() {
-			fi.watchDir(path)
+		if info.IsDir() {
+			if root, _ := fi.getRoot(path); root.Watch {
+				fi.watchDir(path)
+			}
 		}
-		if path == fi.WatchedRootDir {
+		if fi.isRoot(path) {
 			return nil
 		}
 		err = batch.Index(path, buildDoc(path, info))
@@ -270,6 +397,9 @@ func buildDoc(path string, info os.FileInfo) FileDocument {
 		ParentPath: filepath.Dir(path),
 		IsDir:      info.IsDir(),
 	}
+	if !info.IsDir() {
+		doc.Size = info.Size()
+	}
 	return doc
 }
 
@@ -290,9 +420,47 @@ func (fi *FileIndexer) watchDir(path string) {
 	}
 }
 
+// IndexChange 索引中一个条目的变化，Size 和 Files 为文件大小和文件数的增量。
+// Reset 为true时无法计算增量，Path 的上级和下级目录都需要重新统计，Path 为空时表示索引重建
+type IndexChange struct {
+	Path  string
+	Size  int64
+	Files int64
+	Reset bool
+}
+
+type IndexListener func(IndexChange)
+
+// OnIndexChange 注册索引条目变化的监听，包括接口写入和监听到的文件变化，
+// 调用时持有索引的锁，监听中不能再访问索引
+func (fi *FileIndexer) OnIndexChange(fn IndexListener) {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.indexListeners = append(fi.indexListeners, fn)
+}
+
+func (fi *FileIndexer) notifyIndex(c IndexChange) {
+	for _, fn := range fi.indexListeners {
+		fn(c)
+	}
+}
+
+// OnChange 注册文件变化的监听，只有开启了监听的目录才会触发
+func (fi *FileIndexer) OnChange(fn ChangeListener) {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.listeners = append(fi.listeners, fn)
+}
+
 func (fi *FileIndexer) processEvent(event fsnotify.Event) error {
 	fi.mutex.Lock()
 	defer fi.mutex.Unlock()
+	if event.Op&(fsnotify.Write|fsnotify.Remove|fsnotify.Rename) != 0 {
+		for _, fn := range fi.listeners {
+			fn(event.Name)
+		}
+	}
+	fi.emitEvent(event)
 	switch {
 	case event.Op&fsnotify.Create == fsnotify.Create:
 		fi.addResource(event.Name)
@@ -300,6 +468,13 @@ func (fi *FileIndexer) processEvent(event fsnotify.Event) error {
 	case event.Op&fsnotify.Remove == fsnotify.Remove || event.Op&fsnotify.Rename == fsnotify.Rename:
 		fi.delResource(event.Name)
 
+	case event.Op&fsnotify.Write == fsnotify.Write:
+		// 文件内容变化时更新索引中的文件大小
+		if info, err := os.Stat(event.Name); err == nil && !info.IsDir() && !fi.IsSkippePath(event.Name) {
+			return fi.indexFile(event.Name, info)
+		}
+		return nil
+
 	default:
 		return nil
 	}
@@ -0,0 +1,167 @@
+package session
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrTooManySessions  = errors.New("会话数超出限制")
+	ErrTooManyTransfers = errors.New("并发传输数超出限制")
+	ErrTooManyIpConns   = errors.New("当前ip的连接数超出限制")
+)
+
+// Limits 并发限制，0 表示不限制
+type Limits struct {
+	// MaxSessions 每个用户的最大会话数
+	MaxSessions int `json:"maxSessions"`
+	// MaxTransfers 每个用户的最大并发传输数，包括http上传下载和ftp传输
+	MaxTransfers int `json:"maxTransfers"`
+	// MaxIpConns 每个ip的最大连接数，包括会话和http传输
+	MaxIpConns int `json:"maxIpConns"`
+}
+
+// IsLimitErr 判断是否为超出并发限制的错误
+func IsLimitErr(err error) bool {
+	return errors.Is(err, ErrTooManySessions) ||
+		errors.Is(err, ErrTooManyTransfers) ||
+		errors.Is(err, ErrTooManyIpConns)
+}
+
+type UserStat struct {
+	UserId    int    `json:"userId"`
+	Username  string `json:"username"`
+	Sessions  int    `json:"sessions"`
+	Transfers int    `json:"transfers"`
+}
+
+type IpStat struct {
+	Ip    string `json:"ip"`
+	Conns int    `json:"conns"`
+}
+
+// Stats 当前的并发计数
+type Stats struct {
+	Users []UserStat `json:"users"`
+	Ips   []IpStat   `json:"ips"`
+}
+
+// AddWithLimits 检查会话数和ip连接数后记录会话
+func (m *Manager) AddWithLimits(s *Session, l Limits) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if l.MaxSessions > 0 && m.countUserSessions(s.UserId) >= l.MaxSessions {
+		return ErrTooManySessions
+	}
+	if l.MaxIpConns > 0 && m.countIpConns(s.Ip) >= l.MaxIpConns {
+		return ErrTooManyIpConns
+	}
+	m.sessions[s.Id] = s
+	return nil
+}
+
+// CheckIpConns 检查ip的连接数，用于认证前提前拒绝，记录会话时仍由 AddWithLimits 检查
+func (m *Manager) CheckIpConns(ip string, l Limits) error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if l.MaxIpConns > 0 && m.countIpConns(ip) >= l.MaxIpConns {
+		return ErrTooManyIpConns
+	}
+	return nil
+}
+
+// AcquireTransfer 占用一个传输名额，返回释放函数。
+// ip 不为空时同时占用ip连接数，已经记录了会话的连接(如ftp)传空即可
+func (m *Manager) AcquireTransfer(userId int, username, ip string, l Limits) (func(), error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if l.MaxTransfers > 0 && m.transfers[userId] >= l.MaxTransfers {
+		return nil, ErrTooManyTransfers
+	}
+	if ip != "" && l.MaxIpConns > 0 && m.countIpConns(ip) >= l.MaxIpConns {
+		return nil, ErrTooManyIpConns
+	}
+	m.transfers[userId]++
+	m.usernames[userId] = username
+	if ip != "" {
+		m.ipConns[ip]++
+	}
+
+	var once bool
+	return func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if once {
+			return
+		}
+		once = true
+		if m.transfers[userId]--; m.transfers[userId] <= 0 {
+			delete(m.transfers, userId)
+		}
+		if ip == "" {
+			return
+		}
+		if m.ipConns[ip]--; m.ipConns[ip] <= 0 {
+			delete(m.ipConns, ip)
+		}
+	}, nil
+}
+
+// Stats 返回每个用户和每个ip当前的并发计数
+func (m *Manager) Stats() Stats {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	users := make(map[int]*UserStat)
+	getUser := func(userId int, username string) *UserStat {
+		u, ok := users[userId]
+		if !ok {
+			u = &UserStat{UserId: userId, Username: username}
+			users[userId] = u
+		}
+		return u
+	}
+	ips := make(map[string]int, len(m.ipConns))
+	for ip, n := range m.ipConns {
+		ips[ip] = n
+	}
+	for _, s := range m.sessions {
+		getUser(s.UserId, s.Username).Sessions++
+		ips[s.Ip]++
+	}
+	for userId, n := range m.transfers {
+		getUser(userId, m.usernames[userId]).Transfers = n
+	}
+
+	stats := Stats{Users: []UserStat{}, Ips: []IpStat{}}
+	for _, u := range users {
+		stats.Users = append(stats.Users, *u)
+	}
+	for ip, n := range ips {
+		stats.Ips = append(stats.Ips, IpStat{Ip: ip, Conns: n})
+	}
+	sort.Slice(stats.Users, func(i, j int) bool { return stats.Users[i].UserId < stats.Users[j].UserId })
+	sort.Slice(stats.Ips, func(i, j int) bool { return stats.Ips[i].Ip < stats.Ips[j].Ip })
+	return stats
+}
+
+func (m *Manager) countUserSessions(userId int) int {
+	var n int
+	for _, s := range m.sessions {
+		if s.UserId == userId {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *Manager) countIpConns(ip string) int {
+	n := m.ipConns[ip]
+	for _, s := range m.sessions {
+		if s.Ip == ip {
+			n++
+		}
+	}
+	return n
+}
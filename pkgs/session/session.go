@@ -0,0 +1,219 @@
+package session
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ProtocolFtp    = "ftp"
+	ProtocolSftp   = "sftp"
+	ProtocolWebdav = "webdav"
+)
+
+var ErrSessionNotFound = errors.New("会话不存在")
+
+// Session 一个在线的文件传输连接(ftp/sftp/webdav)
+type Session struct {
+	Id        string
+	Protocol  string
+	UserId    int
+	Username  string
+	Ip        string
+	LoginTime time.Time
+
+	totalBytes atomic.Int64
+	mutex      sync.RWMutex
+	transfer   *transfer
+	closer     func() error
+}
+
+type transfer struct {
+	path      string
+	startTime time.Time
+	bytes     atomic.Int64
+}
+
+// Info 会话快照，用于接口展示
+type Info struct {
+	Id         string    `json:"id"`
+	Protocol   string    `json:"protocol"`
+	UserId     int       `json:"userId"`
+	Username   string    `json:"username"`
+	Ip         string    `json:"ip"`
+	LoginTime  time.Time `json:"loginTime"`
+	Transfer   string    `json:"transfer"`
+	TotalBytes int64     `json:"totalBytes"`
+	// Throughput 当前传输的速率，单位 byte/s，没有传输时为0
+	Throughput int64 `json:"throughput"`
+}
+
+func New(protocol, id string, closer func() error) *Session {
+	return &Session{
+		Id:        protocol + "-" + id,
+		Protocol:  protocol,
+		LoginTime: time.Now(),
+		closer:    closer,
+	}
+}
+
+// StartTransfer 标记开始传输文件
+func (s *Session) StartTransfer(path string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.transfer = &transfer{path: path, startTime: time.Now()}
+}
+
+// EndTransfer 标记当前传输结束
+func (s *Session) EndTransfer() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.transfer = nil
+}
+
+// AddBytes 累加传输的字节数
+func (s *Session) AddBytes(n int) {
+	s.totalBytes.Add(int64(n))
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.transfer != nil {
+		s.transfer.bytes.Add(int64(n))
+	}
+}
+
+func (s *Session) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer()
+}
+
+func (s *Session) Info() Info {
+	info := Info{
+		Id:         s.Id,
+		Protocol:   s.Protocol,
+		UserId:     s.UserId,
+		Username:   s.Username,
+		Ip:         s.Ip,
+		LoginTime:  s.LoginTime,
+		TotalBytes: s.totalBytes.Load(),
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.transfer != nil {
+		info.Transfer = s.transfer.path
+		if elapsed := time.Since(s.transfer.startTime).Seconds(); elapsed > 0 {
+			info.Throughput = int64(float64(s.transfer.bytes.Load()) / elapsed)
+		}
+	}
+	return info
+}
+
+// Manager 管理所有在线会话
+type Manager struct {
+	mutex        sync.RWMutex
+	sessions     map[string]*Session
+	invalidators []func(userId int)
+	// transfers 每个用户正在进行的传输数
+	transfers map[int]int
+	usernames map[int]string
+	// ipConns 每个ip不属于会话的连接数，如http传输
+	ipConns map[string]int
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		sessions:  make(map[string]*Session),
+		transfers: make(map[int]int),
+		usernames: make(map[int]string),
+		ipConns:   make(map[string]int),
+	}
+}
+
+func (m *Manager) Add(s *Session) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.sessions[s.Id] = s
+}
+
+func (m *Manager) Get(id string) (*Session, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	s, ok := m.sessions[id]
+	return s, ok
+}
+
+func (m *Manager) Remove(id string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.sessions, id)
+}
+
+// List 按登录时间返回会话快照，username 不为空时只返回该用户的会话
+func (m *Manager) List(username string) []Info {
+	m.mutex.RLock()
+	infos := make([]Info, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		if username != "" && s.Username != username {
+			continue
+		}
+		infos = append(infos, s.Info())
+	}
+	m.mutex.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].LoginTime.Before(infos[j].LoginTime)
+	})
+	return infos
+}
+
+// Kick 断开指定会话
+func (m *Manager) Kick(id string) error {
+	s, ok := m.Get(id)
+	if !ok {
+		return ErrSessionNotFound
+	}
+	m.Remove(id)
+	return s.Close()
+}
+
+// KickUser 断开用户的全部会话，返回断开的数量
+func (m *Manager) KickUser(userId int) int {
+	m.mutex.Lock()
+	var sessions []*Session
+	for id, s := range m.sessions {
+		if s.UserId == userId {
+			sessions = append(sessions, s)
+			delete(m.sessions, id)
+		}
+	}
+	m.mutex.Unlock()
+
+	for _, s := range sessions {
+		s.Close()
+	}
+	return len(sessions)
+}
+
+// OnInvalidate 注册用户失效时的回调，用于清理各协议缓存的登录状态
+func (m *Manager) OnInvalidate(fn func(userId int)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.invalidators = append(m.invalidators, fn)
+}
+
+// InvalidateUser 用户被禁用或重置token时调用，清理缓存的登录状态并断开全部会话
+func (m *Manager) InvalidateUser(userId int) int {
+	m.mutex.RLock()
+	invalidators := m.invalidators
+	m.mutex.RUnlock()
+
+	for _, fn := range invalidators {
+		fn(userId)
+	}
+	return m.KickUser(userId)
+}
@@ -0,0 +1,87 @@
+package session
+
+import (
+	"testing"
+)
+
+func TestManagerInvalidateUser(t *testing.T) {
+	m := NewManager()
+	var closed []string
+	var invalidated []int
+	m.OnInvalidate(func(userId int) { invalidated = append(invalidated, userId) })
+
+	for _, s := range []struct {
+		id     string
+		userId int
+	}{{"1", 1}, {"2", 1}, {"3", 2}} {
+		id := s.id
+		sess := New(ProtocolFtp, id, func() error {
+			closed = append(closed, id)
+			return nil
+		})
+		sess.UserId = s.userId
+		m.Add(sess)
+	}
+
+	tests := []struct {
+		name      string
+		userId    int
+		wantKick  int
+		wantAlive int
+	}{
+		{"kick user 1", 1, 2, 1},
+		{"kick again", 1, 0, 1},
+		{"kick user 2", 2, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.InvalidateUser(tt.userId); got != tt.wantKick {
+				t.Errorf("InvalidateUser() = %v, want %v", got, tt.wantKick)
+			}
+			if got := len(m.List("")); got != tt.wantAlive {
+				t.Errorf("List() = %v, want %v", got, tt.wantAlive)
+			}
+		})
+	}
+	if len(closed) != 3 || len(invalidated) != 3 {
+		t.Errorf("closed = %v, invalidated = %v", closed, invalidated)
+	}
+}
+
+func TestManagerLimits(t *testing.T) {
+	m := NewManager()
+	limits := Limits{MaxSessions: 1, MaxTransfers: 1, MaxIpConns: 2}
+
+	s1 := New(ProtocolFtp, "1", nil)
+	s1.UserId, s1.Ip = 1, "10.0.0.1"
+	if err := m.AddWithLimits(s1, limits); err != nil {
+		t.Fatal(err)
+	}
+	s2 := New(ProtocolFtp, "2", nil)
+	s2.UserId, s2.Ip = 1, "10.0.0.2"
+	if err := m.AddWithLimits(s2, limits); err != ErrTooManySessions {
+		t.Errorf("AddWithLimits() error = %v, want %v", err, ErrTooManySessions)
+	}
+
+	release, err := m.AcquireTransfer(2, "u2", "10.0.0.1", limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AcquireTransfer(3, "u3", "10.0.0.1", limits); err != ErrTooManyIpConns {
+		t.Errorf("AcquireTransfer() error = %v, want %v", err, ErrTooManyIpConns)
+	}
+	if err := m.CheckIpConns("10.0.0.1", limits); err != ErrTooManyIpConns {
+		t.Errorf("CheckIpConns() error = %v, want %v", err, ErrTooManyIpConns)
+	}
+	if err := m.CheckIpConns("10.0.0.2", limits); err != nil {
+		t.Errorf("CheckIpConns() on other ip error = %v", err)
+	}
+	if _, err := m.AcquireTransfer(2, "u2", "", limits); err != ErrTooManyTransfers {
+		t.Errorf("AcquireTransfer() error = %v, want %v", err, ErrTooManyTransfers)
+	}
+	release()
+	release()
+	if stats := m.Stats(); len(stats.Ips) != 1 || stats.Ips[0].Conns != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
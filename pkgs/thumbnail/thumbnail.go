@@ -0,0 +1,198 @@
+package thumbnail
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"go-file-server/pkgs/utils/concurrentpool"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	DefaultSize = 256
+
+	// maxPixels 原图的最大像素数，超过时不生成缩略图，避免解码占用过多内存
+	maxPixels   = 50_000_000
+	jpegQuality = 80
+)
+
+// Sizes 支持的缩略图尺寸(最长边)，限制尺寸种类避免缓存无限增长
+var Sizes = []int{64, 128, 256, 512, 1024}
+
+var (
+	ErrUnsupported = errors.New("不支持生成缩略图的文件格式")
+	ErrTooLarge    = errors.New("图片尺寸过大，无法生成缩略图")
+)
+
+var extensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
+
+// IsSupported 根据扩展名判断是否支持生成缩略图
+func IsSupported(path string) bool {
+	return slices.Contains(extensions, strings.ToLower(filepath.Ext(path)))
+}
+
+// Generator 生成并缓存缩略图，缓存文件按 路径+修改时间+尺寸 命名，
+// 同一个路径的缩略图位于同一个目录，文件变化时删除该目录即可
+type Generator struct {
+	dir   string
+	pool  *concurrentpool.AntsPool
+	group singleflight.Group
+}
+
+func NewGenerator(dir string, workers int) (*Generator, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pool, err := concurrentpool.NewAntsPool(concurrentpool.WithPoolSize(workers))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &Generator{dir: dir, pool: pool}, nil
+}
+
+func (g *Generator) pathDir(path string) string {
+	sum := sha1.Sum([]byte(path))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(g.dir, name[:2], name)
+}
+
+// Get 返回缩略图的缓存文件，不存在时在工作池中生成
+func (g *Generator) Get(ctx context.Context, path string, size int) (string, error) {
+	if !slices.Contains(Sizes, size) {
+		return "", errors.Errorf("不支持的缩略图尺寸 %d", size)
+	}
+	if !IsSupported(path) {
+		return "", ErrUnsupported
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", ErrUnsupported
+	}
+	dest := filepath.Join(g.pathDir(path), fmt.Sprintf("%d_%d", info.ModTime().UnixNano(), size))
+	if _, err := os.Stat(dest); err == nil {
+		return dest, nil
+	}
+
+	ch := g.group.DoChan(dest, func() (interface{}, error) {
+		done := make(chan error, 1)
+		err := g.pool.Submit(func() {
+			done <- generate(path, dest, size)
+		})
+		if err != nil {
+			g.pool.Done()
+			return nil, errors.WithStack(err)
+		}
+		return nil, <-done
+	})
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return dest, nil
+	}
+}
+
+// Invalidate 删除路径的全部缩略图。索引的每个变化都会调用，只处理支持的图片，
+// 每个路径最多几个缓存文件，直接在调用方删除，不占用生成缩略图的工作池
+func (g *Generator) Invalidate(path string) {
+	if !IsSupported(path) {
+		return
+	}
+	os.RemoveAll(g.pathDir(path))
+}
+
+func (g *Generator) Release() {
+	g.pool.Release()
+}
+
+func generate(path, dest string, size int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return ErrUnsupported
+	}
+	if config.Width*config.Height > maxPixels {
+		return ErrTooLarge
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return errors.WithStack(err)
+	}
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return ErrUnsupported
+	}
+
+	dst := Resize(src, size)
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return errors.WithStack(err)
+	}
+	// 先写入临时文件再重命名，避免并发读取到不完整的缩略图
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".tmp_*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+	if isOpaque(dst) {
+		err = jpeg.Encode(tmp, dst, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(tmp, dst)
+	}
+	if err != nil {
+		tmp.Close()
+		return errors.WithStack(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp.Name(), dest))
+}
+
+// Resize 按比例缩放图片，使最长边不超过 size，原图更小时不放大
+func Resize(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		size = max(w, h)
+	}
+	if w >= h {
+		h = max(h*size/max(w, 1), 1)
+		w = size
+	} else {
+		w = max(w*size/max(h, 1), 1)
+		h = size
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+	return dst
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
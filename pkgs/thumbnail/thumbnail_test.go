@@ -0,0 +1,69 @@
+package thumbnail
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name         string
+		w, h, size   int
+		wantW, wantH int
+	}{
+		{"landscape", 800, 400, 256, 256, 128},
+		{"portrait", 300, 600, 128, 64, 128},
+		{"smaller than size", 100, 50, 256, 100, 50},
+		{"thin", 1000, 1, 64, 64, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := image.NewRGBA(image.Rect(0, 0, tt.w, tt.h))
+			b := Resize(src, tt.size).Bounds()
+			if b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+				t.Errorf("Resize() = %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	dir := t.TempDir()
+	g, err := NewGenerator(filepath.Join(dir, "cache"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Release()
+
+	src := filepath.Join(dir, "a.png")
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	dest, err := g.Get(context.Background(), src, 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := g.Get(context.Background(), src, 128); again != dest {
+		t.Errorf("Get() = %s, want cached %s", again, dest)
+	}
+	g.Invalidate(src)
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("thumbnail still exists after Invalidate")
+	}
+	if _, err := g.Get(context.Background(), filepath.Join(dir, "a.txt"), 128); err != ErrUnsupported {
+		t.Errorf("Get() error = %v, want ErrUnsupported", err)
+	}
+}
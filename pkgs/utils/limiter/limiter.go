@@ -13,9 +13,14 @@ type ILimiter interface {
 	LimitReadertWriter(ctx context.Context, readWriter io.ReadWriter, opts ...opt) io.ReadWriter
 }
 
+// waiter 令牌桶，*rate.Limiter 和 redis 令牌桶都实现了该接口
+type waiter interface {
+	WaitN(ctx context.Context, n int) error
+}
+
 // Limiter 是一个带宽限制器结构体
 type Limiter struct {
-	limiter *rate.Limiter
+	limiter waiter
 }
 
 // NewLimiter 创建一个新的带宽限制器，
@@ -27,12 +32,16 @@ func NewLimiter(rateLimitBytes uint64, burstBytes uint64) *Limiter {
 			limiter: rate.NewLimiter(rate.Inf, 0),
 		}
 	}
+	return &Limiter{
+		limiter: newLocalLimiter(rateLimitBytes, burstBytes),
+	}
+}
+
+func newLocalLimiter(rateLimitBytes uint64, burstBytes uint64) *rate.Limiter {
 	// 内部转换：将字节转换为千字节(KB)来减少令牌的生成频率
 	rateLimitKB := rate.Limit(rateLimitBytes / 1024)
 	burstKB := burstBytes / 1024
-	return &Limiter{
-		limiter: rate.NewLimiter(rateLimitKB, int(burstKB)),
-	}
+	return rate.NewLimiter(rateLimitKB, int(burstKB))
 }
 
 // LimitReader 返回一个带限速功能的 io.Reader
@@ -81,14 +90,22 @@ func WithChunkSize(size int) opt {
 	}
 }
 
+// WithCounter 设置传输计数回调，每次读/写成功后以实际传输的字节数调用，可用于流量统计
+func WithCounter(counter func(n int)) opt {
+	return func(l *limitReaderWriter) {
+		l.counter = counter
+	}
+}
+
 // limitReader 是实现了 io.Reader 的结构体，用于限速读取
 type limitReaderWriter struct {
 	ctx       context.Context
-	limiter   *rate.Limiter
+	limiter   waiter
 	reader    io.Reader
 	writer    io.Writer
 	bufioSize int
 	chunkSize int
+	counter   func(n int)
 }
 
 func (r *limitReaderWriter) Read(p []byte) (int, error) {
@@ -112,8 +129,11 @@ func (lrw *limitReaderWriter) processIO(p []byte, operation func([]byte) (int, e
 		}
 
 		n, err := operation(p[totalProcessed : totalProcessed+chunkSize])
+		if lrw.counter != nil && n > 0 {
+			lrw.counter(n)
+		}
 		if err != nil {
-			return totalProcessed, err
+			return totalProcessed + n, err
 		}
 		totalProcessed += n
 	}
@@ -0,0 +1,115 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"go-file-server/pkgs/zlog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// redisFallbackDuration redis不可用时使用本地限速的时长，之后再尝试redis
+	redisFallbackDuration = 10 * time.Second
+	// reserveDuration 每次从redis预留该时长内生成的令牌，在本地消耗完后再访问redis，
+	// 避免每个数据块都执行一次脚本
+	reserveDuration = 100 * time.Millisecond
+)
+
+// tokenBucketScript 令牌桶脚本，令牌可以透支，返回需要等待的毫秒数。
+// 使用redis的时间，避免多个实例之间的时钟误差
+//
+//go:embed token_bucket.lua
+var tokenBucketScript string
+
+var tokenBucket = redis.NewScript(tokenBucketScript)
+
+// NewRedisLimiter 创建一个基于redis的分布式带宽限制器，多个实例共享同一个key的令牌桶，
+// redis不可用时退化为本地限速
+func NewRedisLimiter(client redis.Scripter, key string, rateLimitBytes uint64, burstBytes uint64) *Limiter {
+	if rateLimitBytes == 0 {
+		return NewLimiter(rateLimitBytes, burstBytes)
+	}
+	rateLimitKB := int64(rateLimitBytes / 1024)
+	burstKB := int64(burstBytes / 1024)
+	if rateLimitKB == 0 {
+		return NewLimiter(rateLimitBytes, burstBytes)
+	}
+	return &Limiter{
+		limiter: &redisLimiter{
+			client: client,
+			key:    key,
+			rate:   rateLimitKB,
+			burst:  burstKB,
+			local:  newLocalLimiter(rateLimitBytes, burstBytes),
+		},
+	}
+}
+
+type redisLimiter struct {
+	client redis.Scripter
+	key    string
+	// rate 每秒生成的令牌数，burst 桶容量，单位与本地限速一致为KB
+	rate  int64
+	burst int64
+	local *rate.Limiter
+	// fallbackUntil redis出错后在该时间之前使用本地限速
+	fallbackUntil atomic.Int64
+
+	mutex sync.Mutex
+	// reserved 已经从redis预留、还未消耗的令牌
+	reserved int64
+}
+
+// batchSize 需要 n 个令牌时从redis预留的数量，不超过桶容量
+func (l *redisLimiter) batchSize(n int64) int64 {
+	batch := l.rate * int64(reserveDuration) / int64(time.Second)
+	return min(max(batch, n), l.burst)
+}
+
+func (l *redisLimiter) WaitN(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if int64(n) > l.burst {
+		return fmt.Errorf("rate: Wait(n=%d) exceeds limiter's burst %d", n, l.burst)
+	}
+	if time.Now().UnixNano() < l.fallbackUntil.Load() {
+		return l.local.WaitN(ctx, n)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.reserved >= int64(n) {
+		l.reserved -= int64(n)
+		return nil
+	}
+	need := int64(n) - l.reserved
+	batch := l.batchSize(need)
+	waitMs, err := tokenBucket.Run(ctx, l.client, []string{l.key}, l.rate, l.burst, batch).Int64()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		zlog.SugLog.Warnf("redis限速不可用，使用本地限速: %v", err)
+		l.fallbackUntil.Store(time.Now().Add(redisFallbackDuration).UnixNano())
+		return l.local.WaitN(ctx, n)
+	}
+	// 等待期间持有锁，预留的令牌在等待结束后才能使用
+	if waitMs > 0 {
+		timer := time.NewTimer(time.Duration(waitMs) * time.Millisecond)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	l.reserved += batch - int64(n)
+	return nil
+}
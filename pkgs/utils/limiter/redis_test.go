@@ -0,0 +1,125 @@
+package limiter
+
+import (
+	"context"
+	"go-file-server/pkgs/zlog"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func TestRedisLimiterFallback(t *testing.T) {
+	zlog.SugLog = zap.NewNop().Sugar()
+	// 不可用的redis，限速退化为本地令牌桶
+	client := redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:1",
+		DialTimeout: 100 * time.Millisecond,
+		MaxRetries:  -1,
+	})
+	defer client.Close()
+
+	tests := []struct {
+		name    string
+		n       int
+		wantErr bool
+	}{
+		{"zero", 0, false},
+		{"within burst", 8, false},
+		{"exceeds burst", 128, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRedisLimiter(client, "test", 64*1024, 64*1024)
+			rl := l.limiter.(*redisLimiter)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := rl.WaitN(ctx, tt.n); (err != nil) != tt.wantErr {
+				t.Fatalf("WaitN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if fallback := rl.fallbackUntil.Load() > 0; fallback != (tt.n > 0 && !tt.wantErr) {
+				t.Errorf("fallback = %v", fallback)
+			}
+		})
+	}
+}
+
+// fakeScripter 记录每次执行脚本时预留的令牌数，返回固定的等待时间
+type fakeScripter struct {
+	redis.Scripter
+	reserved []int64
+	waitMs   int64
+}
+
+func (f *fakeScripter) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	f.reserved = append(f.reserved, args[2].(int64))
+	return redis.NewCmdResult(f.waitMs, nil)
+}
+
+func TestRedisLimiterReserve(t *testing.T) {
+	client := &fakeScripter{}
+	// 每秒1000KB，每次预留100KB
+	l := NewRedisLimiter(client, "test", 1000*1024, 1000*1024)
+	rl := l.limiter.(*redisLimiter)
+	ctx := context.Background()
+	for i := 0; i < 25; i++ {
+		if err := rl.WaitN(ctx, 8); err != nil {
+			t.Fatalf("WaitN() error = %v", err)
+		}
+	}
+	want := []int64{100, 100}
+	if len(client.reserved) != len(want) || client.reserved[0] != want[0] || client.reserved[1] != want[1] {
+		t.Fatalf("reserved = %v, want %v", client.reserved, want)
+	}
+	if rl.reserved != 200-25*8 {
+		t.Errorf("local tokens = %d, want %d", rl.reserved, 200-25*8)
+	}
+
+	// 需要的令牌多于每次预留的数量时按需要的数量预留
+	if got := rl.batchSize(1000); got != 1000 {
+		t.Errorf("batchSize(1000) = %d", got)
+	}
+}
+
+// TestTokenBucketScript 需要设置 REDIS_ADDR 指向可用的redis
+func TestTokenBucketScript(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR 未设置")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	ctx := context.Background()
+	key := "limiter_test:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	defer client.Del(ctx, key)
+
+	tests := []struct {
+		name string
+		n    int64
+		// 允许的等待时间范围，脚本执行期间令牌会少量增加
+		minWait, maxWait int64
+	}{
+		{"full bucket", 100, 0, 0},
+		{"drained", 100, 0, 0},
+		{"overdraw", 50, 400, 500},
+		{"debt accumulates", 100, 1400, 1500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 每秒100个令牌，容量200
+			waitMs, err := tokenBucket.Run(ctx, client, []string{key}, 100, 200, tt.n).Int64()
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if waitMs < tt.minWait || waitMs > tt.maxWait {
+				t.Errorf("wait = %dms, want [%d, %d]", waitMs, tt.minWait, tt.maxWait)
+			}
+		})
+	}
+	if ttl := client.PTTL(ctx, key).Val(); ttl <= 0 {
+		t.Errorf("key ttl = %v, want > 0", ttl)
+	}
+}
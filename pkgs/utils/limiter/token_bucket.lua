@@ -0,0 +1,33 @@
+-- KEYS[1] 令牌桶key
+-- ARGV[1] 每秒生成的令牌数，ARGV[2] 桶容量，ARGV[3] 本次需要的令牌数
+if redis.replicate_commands then
+    redis.replicate_commands()
+end
+
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+    tokens = burst
+    ts = now
+end
+
+local elapsed = math.max(0, now - ts)
+tokens = math.min(burst, tokens + elapsed * rate / 1000) - n
+
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', now)
+-- 令牌桶回满后key没有意义，多保留1秒
+redis.call('PEXPIRE', key, math.ceil((burst - tokens) * 1000 / rate) + 1000)
+
+if tokens >= 0 then
+    return 0
+end
+return math.ceil(-tokens * 1000 / rate)
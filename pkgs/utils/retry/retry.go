@@ -24,6 +24,7 @@ type RetryOptions struct {
 	logger         ILogger
 	attempts       int            // 最大尝试次数
 	delay          time.Duration  // 尝试之间的延迟
+	maxDelay       time.Duration  // 大于0时每次失败后延迟翻倍，最大为该值
 	retryCondition retryCondition // 检查错误是否可重试的函数
 }
 
@@ -48,6 +49,13 @@ func WithDelay(d time.Duration) func(*RetryOptions) {
 	}
 }
 
+// 设置指数退避的选项，每次失败后延迟翻倍，直到 max
+func WithBackoff(max time.Duration) func(*RetryOptions) {
+	return func(opts *RetryOptions) {
+		opts.maxDelay = max
+	}
+}
+
 // 设置错误检查函数的选项
 func WithRetryableCondition(f retryCondition) func(*RetryOptions) {
 	return func(opts *RetryOptions) {
@@ -80,6 +88,7 @@ func RetryWithCtx(ctx context.Context, fn func() error, opts ...func(*RetryOptio
 	}
 	var err error
 	attempt := 0
+	delay := options.delay
 
 	for attempt < options.attempts || options.attempts < 1 {
 		attempt++
@@ -96,7 +105,10 @@ func RetryWithCtx(ctx context.Context, fn func() error, opts ...func(*RetryOptio
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(options.delay):
+		case <-time.After(delay):
+		}
+		if options.maxDelay > 0 {
+			delay = min(delay*2, options.maxDelay)
 		}
 	}
 	return err
@@ -0,0 +1,71 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录B中 SHA1 的测试数据，密钥为 "12345678901234567890"
+func TestHotpRfc6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1111111111, want: "14050471"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 2000000000, want: "69279037"},
+		{unix: 20000000000, want: "65353130"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := hotp(key, uint64(tt.unix/Period), 8); got != tt.want {
+				t.Errorf("hotp() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	current, _ := Code(secret, Step(now))
+	previous, _ := Code(secret, Step(now)-1)
+	old, _ := Code(secret, Step(now)-3)
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOk   bool
+	}{
+		{name: "current", secret: secret, code: current, wantStep: Step(now), wantOk: true},
+		{name: "previous within skew", secret: secret, code: previous, wantStep: Step(now) - 1, wantOk: true},
+		{name: "lowercase secret with spaces", secret: strings.ToLower(secret[:4] + " " + secret[4:]), code: current, wantStep: Step(now), wantOk: true},
+		{name: "too old", secret: secret, code: old, wantOk: false},
+		{name: "wrong length", secret: secret, code: "12345", wantOk: false},
+		{name: "invalid secret", secret: "!!", code: current, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(tt.secret, tt.code, now, 1)
+			if ok != tt.wantOk || step != tt.wantStep {
+				t.Errorf("Validate() = %d %v, want %d %v", step, ok, tt.wantStep, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("go-file-server", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	want := "otpauth://totp/go-file-server:alice@example.com?algorithm=SHA1&digits=6&issuer=go-file-server&period=30&secret=JBSWY3DPEHPK3PXP"
+	if got != want {
+		t.Errorf("URI() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,373 @@
+package zip
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// WinZip AES 加密，格式说明见 https://www.winzip.com/en/support/aes-encryption/
+const (
+	// MethodAES 加密条目在文件头中记录的压缩方法，实际的压缩方法记录在扩展字段中
+	MethodAES uint16 = 99
+
+	aesExtraID       = 0x9901
+	aesVendorAE1     = 1
+	aesVendorAE2     = 2
+	aesStrength256   = 3
+	aesIterations    = 1000
+	aesVerifierLen   = 2
+	aesAuthCodeLen   = 10
+	flagEncrypted    = 0x1
+	flagDescriptor   = 0x8
+	aesReaderVersion = 51
+	uint32max        = (1 << 32) - 1
+)
+
+var (
+	ErrPasswordRequired     = errors.New("压缩包已加密，需要密码")
+	ErrPasswordIncorrect    = errors.New("密码错误")
+	ErrAuthFailed           = errors.New("数据校验失败，文件可能已损坏")
+	ErrUnsupportedEncrypted = errors.New("不支持的加密方式，只支持AES加密")
+	ErrUnsupportedMethod    = errors.New("不支持的压缩方法")
+)
+
+// IsEncrypted 判断条目是否加密
+func IsEncrypted(f *zip.File) bool {
+	return f.Flags&flagEncrypted != 0
+}
+
+type aesExtra struct {
+	// vendor AE-1 同时记录CRC，AE-2 不记录CRC
+	vendor   uint16
+	strength byte
+	method   uint16
+}
+
+func (e aesExtra) keyLen() int {
+	return 8 + 8*int(e.strength)
+}
+
+func (e aesExtra) saltLen() int {
+	return e.keyLen() / 2
+}
+
+// parseAESExtra 从扩展字段中读取加密强度和实际的压缩方法
+func parseAESExtra(extra []byte) (aesExtra, bool) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra)
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+		if id == aesExtraID && size == 7 && extra[2] == 'A' && extra[3] == 'E' {
+			e := aesExtra{
+				vendor:   binary.LittleEndian.Uint16(extra),
+				strength: extra[4],
+				method:   binary.LittleEndian.Uint16(extra[5:]),
+			}
+			return e, e.strength >= 1 && e.strength <= 3
+		}
+		extra = extra[size:]
+	}
+	return aesExtra{}, false
+}
+
+// deriveKeys 生成加密key、校验key以及密码校验值
+func deriveKeys(password string, salt []byte, keyLen int) (encKey, macKey, verifier []byte) {
+	key := pbkdf2.Key([]byte(password), salt, aesIterations, 2*keyLen+aesVerifierLen, sha1.New)
+	return key[:keyLen], key[keyLen : 2*keyLen], key[2*keyLen:]
+}
+
+// ctrStream WinZip AES 使用小端序计数器的CTR模式，计数器从1开始
+type ctrStream struct {
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	pos     int
+}
+
+func newCtrStream(key []byte) (*ctrStream, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &ctrStream{block: block, pos: aes.BlockSize}, nil
+}
+
+func (s *ctrStream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.pos == aes.BlockSize {
+			for j := range s.counter {
+				s.counter[j]++
+				if s.counter[j] != 0 {
+					break
+				}
+			}
+			s.block.Encrypt(s.stream[:], s.counter[:])
+			s.pos = 0
+		}
+		dst[i] = src[i] ^ s.stream[s.pos]
+		s.pos++
+	}
+}
+
+// decrypter 解密数据，读取结束后校验认证码
+type decrypter struct {
+	raw    io.Reader
+	data   io.Reader
+	ctr    *ctrStream
+	mac    hash.Hash
+	verify error
+	done   bool
+}
+
+func (d *decrypter) Read(p []byte) (int, error) {
+	n, err := d.data.Read(p)
+	if n > 0 {
+		d.mac.Write(p[:n])
+		d.ctr.XORKeyStream(p[:n], p[:n])
+	}
+	if err == io.EOF {
+		if verr := d.check(); verr != nil {
+			return n, verr
+		}
+	}
+	return n, err
+}
+
+func (d *decrypter) check() error {
+	if d.done {
+		return d.verify
+	}
+	d.done = true
+	code := make([]byte, aesAuthCodeLen)
+	if _, err := io.ReadFull(d.raw, code); err != nil {
+		d.verify = ErrAuthFailed
+		return d.verify
+	}
+	if !hmac.Equal(code, d.mac.Sum(nil)[:aesAuthCodeLen]) {
+		d.verify = ErrAuthFailed
+	}
+	return d.verify
+}
+
+type aesReadCloser struct {
+	r   io.Reader
+	dec *decrypter
+	c   io.Closer
+	// crc AE-1 在读取结束时还需要校验解压后内容的CRC
+	crc     hash.Hash32
+	wantCrc uint32
+}
+
+func (r *aesReadCloser) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if r.crc != nil {
+		r.crc.Write(p[:n])
+	}
+	if err != nil {
+		// 解压结束时可能还没有读到加密数据的结尾，读取剩余数据后校验认证码，
+		// 解压出错时优先返回认证失败，数据被篡改通常会先导致解压出错
+		if _, cerr := io.Copy(io.Discard, r.dec); cerr != nil {
+			return n, cerr
+		}
+		if verr := r.dec.check(); verr != nil {
+			return n, verr
+		}
+		if err == io.EOF && r.crc != nil && r.crc.Sum32() != r.wantCrc {
+			return n, ErrAuthFailed
+		}
+	}
+	return n, err
+}
+
+func (r *aesReadCloser) Close() error {
+	if r.c != nil {
+		return r.c.Close()
+	}
+	return nil
+}
+
+// OpenEncrypted 使用密码打开AES加密的条目，返回解密并解压后的内容，
+// 读取到结尾时校验认证码，数据被篡改时返回 ErrAuthFailed
+func OpenEncrypted(f *zip.File, password string) (io.ReadCloser, error) {
+	if password == "" {
+		return nil, ErrPasswordRequired
+	}
+	extra, ok := parseAESExtra(f.Extra)
+	if f.Method != MethodAES || !ok {
+		return nil, ErrUnsupportedEncrypted
+	}
+	overhead := uint64(extra.saltLen() + aesVerifierLen + aesAuthCodeLen)
+	if f.CompressedSize64 < overhead {
+		return nil, ErrAuthFailed
+	}
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, extra.saltLen()+aesVerifierLen)
+	if _, err := io.ReadFull(raw, header); err != nil {
+		return nil, err
+	}
+	salt, verifier := header[:extra.saltLen()], header[extra.saltLen():]
+	encKey, macKey, want := deriveKeys(password, salt, extra.keyLen())
+	if subtle.ConstantTimeCompare(verifier, want) != 1 {
+		return nil, ErrPasswordIncorrect
+	}
+	ctr, err := newCtrStream(encKey)
+	if err != nil {
+		return nil, err
+	}
+	dec := &decrypter{
+		raw:  raw,
+		data: io.LimitReader(raw, int64(f.CompressedSize64-overhead)),
+		ctr:  ctr,
+		mac:  hmac.New(sha1.New, macKey),
+	}
+	rc := &aesReadCloser{dec: dec}
+	if extra.vendor == aesVendorAE1 {
+		rc.crc, rc.wantCrc = crc32.NewIEEE(), f.CRC32
+	}
+	switch extra.method {
+	case zip.Store:
+		rc.r = dec
+	case zip.Deflate:
+		fr := flate.NewReader(dec)
+		rc.r, rc.c = fr, fr
+	default:
+		return nil, ErrUnsupportedMethod
+	}
+	return rc, nil
+}
+
+// encrypter 压缩后加密写入，关闭时写入认证码并更新文件头中的大小
+type encrypter struct {
+	fh           *zip.FileHeader
+	w            io.Writer
+	ctr          *ctrStream
+	mac          hash.Hash
+	comp         *flate.Writer
+	buf          []byte
+	written      uint64
+	uncompressed uint64
+	overhead     uint64
+}
+
+func (e *encrypter) Write(p []byte) (int, error) {
+	n, err := e.comp.Write(p)
+	e.uncompressed += uint64(n)
+	return n, err
+}
+
+// write flate 输出的压缩数据
+func (e *encrypter) write(p []byte) (int, error) {
+	if cap(e.buf) < len(p) {
+		e.buf = make([]byte, len(p))
+	}
+	buf := e.buf[:len(p)]
+	e.ctr.XORKeyStream(buf, p)
+	e.mac.Write(buf)
+	n, err := e.w.Write(buf)
+	e.written += uint64(n)
+	return len(p), err
+}
+
+func (e *encrypter) Close() error {
+	if err := e.comp.Close(); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(e.mac.Sum(nil)[:aesAuthCodeLen]); err != nil {
+		return err
+	}
+	// 文件头由 zip.Writer 持有，写入下一个条目或关闭时写入数据描述符和中央目录
+	e.fh.CompressedSize64 = e.written + e.overhead
+	e.fh.UncompressedSize64 = e.uncompressed
+	e.fh.CompressedSize = uint32(min(e.fh.CompressedSize64, uint32max))
+	e.fh.UncompressedSize = uint32(min(e.fh.UncompressedSize64, uint32max))
+	return nil
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// CreateEncrypted 添加一个 AES-256 加密(AE-2)、Deflate压缩的条目，写入完成后需要调用Close。
+// 目录不需要加密，应使用 zip.Writer.CreateHeader
+func CreateEncrypted(zw *zip.Writer, fh *zip.FileHeader, password string) (io.WriteCloser, error) {
+	if password == "" {
+		return nil, ErrPasswordRequired
+	}
+	extra := aesExtra{strength: aesStrength256, method: zip.Deflate}
+	salt := make([]byte, extra.saltLen())
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	encKey, macKey, verifier := deriveKeys(password, salt, extra.keyLen())
+	ctr, err := newCtrStream(encKey)
+	if err != nil {
+		return nil, err
+	}
+
+	field := make([]byte, 11)
+	binary.LittleEndian.PutUint16(field, aesExtraID)
+	binary.LittleEndian.PutUint16(field[2:], 7)
+	binary.LittleEndian.PutUint16(field[4:], aesVendorAE2)
+	copy(field[6:], "AE")
+	field[8] = extra.strength
+	binary.LittleEndian.PutUint16(field[9:], extra.method)
+	fh.Extra = append(fh.Extra, field...)
+	fh.Method = MethodAES
+	fh.Flags |= flagEncrypted | flagDescriptor
+	fh.ReaderVersion = aesReaderVersion
+	// AE-2 不记录CRC，由认证码校验数据
+	fh.CRC32 = 0
+	fh.CompressedSize64, fh.UncompressedSize64 = 0, 0
+	if !fh.Modified.IsZero() {
+		fh.ModifiedDate, fh.ModifiedTime = msDosTime(fh.Modified)
+	}
+
+	w, err := zw.CreateRaw(fh)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(salt, verifier...)); err != nil {
+		return nil, err
+	}
+	e := &encrypter{
+		fh:       fh,
+		w:        w,
+		ctr:      ctr,
+		mac:      hmac.New(sha1.New, macKey),
+		overhead: uint64(len(salt) + aesVerifierLen + aesAuthCodeLen),
+	}
+	e.comp, err = flate.NewWriter(writerFunc(e.write), flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// msDosTime CreateRaw 不会根据 Modified 设置文件头中的时间
+func msDosTime(t time.Time) (uint16, uint16) {
+	fDate := uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	fTime := uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return fDate, fTime
+}
@@ -0,0 +1,266 @@
+package zip
+
+import (
+	"archive/zip"
+	"bytes"
+	"hash/crc32"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// testdata 中的压缩包由 libarchive(bsdtar 3.7.7) 生成，密码为 secret：
+//
+//	bsdtar --format zip --options zip:encryption=aes128,zip:compression=store --passphrase secret -cf aes128-store.zip a.txt b.txt
+//	bsdtar --format zip --options zip:encryption=aes256 --passphrase secret -cf aes256-deflate.zip a.txt b.txt
+//
+// libarchive 对小于20字节的文件使用 AE-2，其它文件使用 AE-1
+var fixtureFiles = map[string][]byte{
+	"a.txt": bytes.Repeat([]byte("WinZip AES 测试内容 0123456789\n"), 200),
+	"b.txt": []byte("tiny"),
+}
+
+func TestOpenEncryptedFixtures(t *testing.T) {
+	tests := []struct {
+		file     string
+		strength byte
+		method   uint16
+	}{
+		{"aes128-store.zip", 1, zip.Store},
+		{"aes256-deflate.zip", 3, zip.Deflate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			zr, err := zip.OpenReader(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer zr.Close()
+			vendors := map[uint16]bool{}
+			for _, f := range zr.File {
+				extra, ok := parseAESExtra(f.Extra)
+				if !ok || extra.strength != tt.strength {
+					t.Fatalf("%s extra = %+v", f.Name, extra)
+				}
+				vendors[extra.vendor] = true
+				if _, err := OpenEncrypted(f, "wrong"); !errors.Is(err, ErrPasswordIncorrect) {
+					t.Errorf("%s 错误的密码 error = %v", f.Name, err)
+				}
+				rc, err := OpenEncrypted(f, "secret")
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := io.ReadAll(rc)
+				rc.Close()
+				if err != nil {
+					t.Fatalf("%s: %v", f.Name, err)
+				}
+				if !bytes.Equal(got, fixtureFiles[f.Name]) {
+					t.Errorf("%s 内容不一致", f.Name)
+				}
+				// 大文件可能是 Deflate，小文件 libarchive 总是存储
+				if f.Name == "a.txt" && extra.method != tt.method {
+					t.Errorf("%s method = %d, want %d", f.Name, extra.method, tt.method)
+				}
+			}
+			if !vendors[aesVendorAE1] || !vendors[aesVendorAE2] {
+				t.Errorf("vendors = %v, want AE-1 and AE-2", vendors)
+			}
+		})
+	}
+}
+
+// TestOpenEncryptedCrc AE-1 的数据通过了认证码校验但CRC不一致时同样返回错误
+func TestOpenEncryptedCrc(t *testing.T) {
+	zr, err := zip.OpenReader(filepath.Join("testdata", "aes256-deflate.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	f := zr.File[0]
+	if extra, _ := parseAESExtra(f.Extra); extra.vendor != aesVendorAE1 {
+		t.Fatalf("%s 不是 AE-1", f.Name)
+	}
+	f.CRC32++
+	rc, err := OpenEncrypted(f, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, err := io.ReadAll(rc); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("ReadAll() error = %v, want %v", err, ErrAuthFailed)
+	}
+}
+
+// TestCreateEncryptedExternal 使用 7z 或 bsdtar 解压 CreateEncrypted 生成的压缩包，
+// 都没有安装时跳过
+func TestCreateEncryptedExternal(t *testing.T) {
+	var args func(archive, name string) []string
+	tool, err := exec.LookPath("7z")
+	if err == nil {
+		args = func(archive, name string) []string { return []string{"x", "-psecret", "-so", archive, name} }
+	} else if tool, err = exec.LookPath("bsdtar"); err == nil {
+		args = func(archive, name string) []string { return []string{"--passphrase", "secret", "-xOf", archive, name} }
+	} else {
+		t.Skip("没有找到 7z 或 bsdtar")
+	}
+
+	archive := filepath.Join(t.TempDir(), "out.zip")
+	out, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(out)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		w, err := CreateEncrypted(zw, &zip.FileHeader{Name: name}, "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(fixtureFiles[name]); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		got, err := exec.Command(tool, args(archive, name)...).Output()
+		if err != nil {
+			t.Fatalf("%s %s: %v", tool, name, err)
+		}
+		if !bytes.Equal(got, fixtureFiles[name]) {
+			t.Errorf("%s 解压 %s 内容不一致", tool, name)
+		}
+	}
+}
+
+// zeroReader 生成大文件测试使用的内容
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// TestEncryptedZip64 超过4GB的条目需要在数据描述符和中央目录中使用zip64记录大小
+func TestEncryptedZip64(t *testing.T) {
+	if testing.Short() {
+		t.Skip("写入超过4GB的数据")
+	}
+	const size = uint32max + 1<<20
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := CreateEncrypted(zw, &zip.FileHeader{Name: "large.bin"}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.CopyN(w, zeroReader{}, size); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := zr.File[0]
+	if f.UncompressedSize64 != size || f.UncompressedSize != uint32max {
+		t.Fatalf("UncompressedSize64 = %d, UncompressedSize = %d", f.UncompressedSize64, f.UncompressedSize)
+	}
+	rc, err := OpenEncrypted(f, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	h := crc32.NewIEEE()
+	n, err := io.Copy(h, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := crc32.NewIEEE()
+	io.CopyN(want, zeroReader{}, size)
+	if n != size || h.Sum32() != want.Sum32() {
+		t.Errorf("读取 %d 字节, crc %x, want %d 字节, crc %x", n, h.Sum32(), uint64(size), want.Sum32())
+	}
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	content := bytes.Repeat([]byte("加密的文件内容 0123456789\n"), 1000)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := CreateEncrypted(zw, &zip.FileHeader{Name: "dir/a.txt"}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	tests := []struct {
+		name     string
+		password string
+		tamper   bool
+		wantErr  error
+	}{
+		{"正确的密码", "secret", false, nil},
+		{"没有密码", "", false, ErrPasswordRequired},
+		{"错误的密码", "wrong", false, ErrPasswordIncorrect},
+		{"数据被修改", "secret", true, ErrAuthFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := bytes.Clone(data)
+			zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := zr.File[0]
+			if !IsEncrypted(f) || f.UncompressedSize64 != uint64(len(content)) {
+				t.Fatalf("header = %+v", f.FileHeader)
+			}
+			if tt.tamper {
+				offset, err := f.DataOffset()
+				if err != nil {
+					t.Fatal(err)
+				}
+				// 跳过salt和密码校验值，修改加密数据
+				b[offset+16+2+5] ^= 0xff
+			}
+			rc, err := OpenEncrypted(f, tt.password)
+			if err == nil {
+				var got []byte
+				got, err = io.ReadAll(rc)
+				rc.Close()
+				if err == nil && !bytes.Equal(got, content) {
+					t.Fatal("内容不一致")
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("OpenEncrypted() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}